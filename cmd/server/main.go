@@ -12,12 +12,14 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
 	configaccess "github.com/router-for-me/CLIProxyAPI/v6/internal/access/config_access"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/kiro"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/buildinfo"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/cmd"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
@@ -85,6 +87,7 @@ func main() {
 	var githubCopilotLogin bool
 	var projectID string
 	var vertexImport string
+	var rotateEncryptionKey bool
 	var configPath string
 	var password string
 	var noIncognito bool
@@ -111,6 +114,7 @@ func main() {
 	flag.StringVar(&projectID, "project_id", "", "Project ID (Gemini only, not required)")
 	flag.StringVar(&configPath, "config", DefaultConfigPath, "Configure File Path")
 	flag.StringVar(&vertexImport, "vertex-import", "", "Import Vertex service account key JSON file")
+	flag.BoolVar(&rotateEncryptionKey, "rotate-encryption-key", false, "Re-encrypt all auth files with the current encryption key")
 	flag.StringVar(&password, "password", "", "")
 
 	flag.CommandLine.Usage = func() {
@@ -152,6 +156,8 @@ func main() {
 		pgStoreDSN           string
 		pgStoreSchema        string
 		pgStoreLocalPath     string
+		pgStoreCluster       bool
+		pgStoreInstanceID    string
 		pgStoreInst          *store.PostgresStore
 		useGitStore          bool
 		gitStoreRemoteURL    string
@@ -192,6 +198,30 @@ func main() {
 		}
 		return "", false
 	}
+	// Auth files are encrypted at rest when a key is configured; previous keys stay readable for rotation.
+	var keyConfig authcrypt.KeyConfig
+	if value, ok := lookupEnv("CLIPROXY_ENCRYPTION_KEY", "cliproxy_encryption_key"); ok {
+		keyConfig.Key = value
+	}
+	if value, ok := lookupEnv("CLIPROXY_ENCRYPTION_KEY_FILE", "cliproxy_encryption_key_file"); ok {
+		keyConfig.KeyFile = value
+	}
+	if value, ok := lookupEnv("CLIPROXY_ENCRYPTION_PREVIOUS_KEYS", "cliproxy_encryption_previous_keys"); ok {
+		keyConfig.PreviousKeys = strings.Split(value, ",")
+	}
+	if value, ok := lookupEnv("CLIPROXY_ENCRYPTION_PREVIOUS_KEY_FILES", "cliproxy_encryption_previous_key_files"); ok {
+		keyConfig.PreviousKeyFiles = strings.Split(value, ",")
+	}
+	keyring, errKeyring := authcrypt.NewKeyring(keyConfig)
+	if errKeyring != nil {
+		log.Errorf("failed to load auth encryption key: %v", errKeyring)
+		return
+	}
+	authcrypt.Configure(keyring)
+	if keyring != nil {
+		log.Infof("auth file encryption enabled (%s key %s)", keyring.PrimaryKeyType(), keyring.PrimaryKeyID())
+	}
+
 	writableBase := util.WritablePath()
 	if value, ok := lookupEnv("PGSTORE_DSN", "pgstore_dsn"); ok {
 		usePostgresStore = true
@@ -204,6 +234,16 @@ func main() {
 		if value, ok := lookupEnv("PGSTORE_LOCAL_PATH", "pgstore_local_path"); ok {
 			pgStoreLocalPath = value
 		}
+		if value, ok := lookupEnv("PGSTORE_CLUSTER", "pgstore_cluster"); ok {
+			if parsed, errParse := strconv.ParseBool(value); errParse == nil {
+				pgStoreCluster = parsed
+			} else {
+				log.Warnf("invalid PGSTORE_CLUSTER value %q, cluster mode disabled", value)
+			}
+		}
+		if value, ok := lookupEnv("PGSTORE_INSTANCE_ID", "pgstore_instance_id"); ok {
+			pgStoreInstanceID = value
+		}
 		if pgStoreLocalPath == "" {
 			if writableBase != "" {
 				pgStoreLocalPath = writableBase
@@ -260,9 +300,11 @@ func main() {
 		pgStoreLocalPath = filepath.Join(pgStoreLocalPath, "pgstore")
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		pgStoreInst, err = store.NewPostgresStore(ctx, store.PostgresStoreConfig{
-			DSN:      pgStoreDSN,
-			Schema:   pgStoreSchema,
-			SpoolDir: pgStoreLocalPath,
+			DSN:        pgStoreDSN,
+			Schema:     pgStoreSchema,
+			SpoolDir:   pgStoreLocalPath,
+			Cluster:    pgStoreCluster,
+			InstanceID: pgStoreInstanceID,
 		})
 		cancel()
 		if err != nil {
@@ -436,6 +478,7 @@ func main() {
 	}
 	usage.SetStatisticsEnabled(cfg.UsageStatisticsEnabled)
 	coreauth.SetQuotaCooldownDisabled(cfg.DisableCooling)
+	coreauth.SetMinQuotaHeadroomPercent(cfg.Routing.MinQuotaHeadroomPercent)
 
 	if err = logging.ConfigureLogOutput(cfg); err != nil {
 		log.Errorf("failed to configure log output: %v", err)
@@ -480,6 +523,9 @@ func main() {
 	if vertexImport != "" {
 		// Handle Vertex service account import
 		cmd.DoVertexImport(cfg, vertexImport)
+	} else if rotateEncryptionKey {
+		// Re-encrypt auth files with the current encryption key
+		cmd.DoRotateEncryptionKey(cfg)
 	} else if login {
 		// Handle Google/Gemini login
 		cmd.DoLogin(cfg, projectID, options)
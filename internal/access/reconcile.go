@@ -8,6 +8,7 @@ import (
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
 	sdkConfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
 	log "github.com/sirupsen/logrus"
 )
@@ -149,6 +150,7 @@ func ApplyAccessProviders(manager *sdkaccess.Manager, oldCfg, newCfg *config.Con
 	}
 
 	manager.SetProviders(providers)
+	applyAccessLimits(manager, newCfg)
 
 	if len(added)+len(updated)+len(removed) > 0 {
 		log.Debugf("auth providers reconciled (added=%d updated=%d removed=%d)", len(added), len(updated), len(removed))
@@ -160,6 +162,21 @@ func ApplyAccessProviders(manager *sdkaccess.Manager, oldCfg, newCfg *config.Con
 	return false, nil
 }
 
+// applyAccessLimits keeps the manager's limiter in sync with api-key-limits. The limiter is
+// created lazily on first use and registered as a usage plugin to receive token counts.
+func applyAccessLimits(manager *sdkaccess.Manager, cfg *config.Config) {
+	limiter := manager.Limiter()
+	if limiter == nil {
+		if len(cfg.APIKeyLimits) == 0 {
+			return
+		}
+		limiter = sdkaccess.NewLimiter()
+		coreusage.RegisterPlugin(limiter)
+		manager.SetLimiter(limiter)
+	}
+	limiter.SetLimitsFromConfig(cfg.APIKeyLimits)
+}
+
 func accessProviderMap(cfg *config.Config) map[string]*sdkConfig.AccessProvider {
 	result := make(map[string]*sdkConfig.AccessProvider)
 	if cfg == nil {
@@ -31,6 +31,7 @@ import (
 	iflowauth "github.com/router-for-me/CLIProxyAPI/v6/internal/auth/iflow"
 	kiroauth "github.com/router-for-me/CLIProxyAPI/v6/internal/auth/kiro"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/qwen"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
@@ -344,7 +345,7 @@ func (h *Handler) listAuthFilesFromDisk(c *gin.Context) {
 
 			// Read file to get type field
 			full := filepath.Join(h.cfg.AuthDir, name)
-			if data, errRead := os.ReadFile(full); errRead == nil {
+			if data, errRead := authcrypt.ReadFile(full); errRead == nil {
 				typeValue := gjson.GetBytes(data, "type").String()
 				emailValue := gjson.GetBytes(data, "email").String()
 				fileData["type"] = typeValue
@@ -429,6 +430,11 @@ func (h *Handler) buildAuthFileEntry(auth *coreauth.Auth) gin.H {
 	if claims := extractCodexIDTokenClaims(auth); claims != nil {
 		entry["id_token"] = claims
 	}
+	if h.authManager != nil {
+		if probes := h.authManager.ProbeResults(auth.ID); len(probes) > 0 {
+			entry["probe"] = probes
+		}
+	}
 	return entry
 }
 
@@ -518,7 +524,7 @@ func (h *Handler) DownloadAuthFile(c *gin.Context) {
 		return
 	}
 	full := filepath.Join(h.cfg.AuthDir, name)
-	data, err := os.ReadFile(full)
+	data, err := authcrypt.ReadFile(full)
 	if err != nil {
 		if os.IsNotExist(err) {
 			c.JSON(404, gin.H{"error": "file not found"})
@@ -550,11 +556,20 @@ func (h *Handler) UploadAuthFile(c *gin.Context) {
 				dst = abs
 			}
 		}
-		if errSave := c.SaveUploadedFile(file, dst); errSave != nil {
+		errSave := authcrypt.SealFile(dst, func(w io.Writer) error {
+			src, errOpen := file.Open()
+			if errOpen != nil {
+				return errOpen
+			}
+			defer func() { _ = src.Close() }()
+			_, errCopy := io.Copy(w, src)
+			return errCopy
+		})
+		if errSave != nil {
 			c.JSON(500, gin.H{"error": fmt.Sprintf("failed to save file: %v", errSave)})
 			return
 		}
-		data, errRead := os.ReadFile(dst)
+		data, errRead := authcrypt.ReadFile(dst)
 		if errRead != nil {
 			c.JSON(500, gin.H{"error": fmt.Sprintf("failed to read saved file: %v", errRead)})
 			return
@@ -586,7 +601,7 @@ func (h *Handler) UploadAuthFile(c *gin.Context) {
 			dst = abs
 		}
 	}
-	if errWrite := os.WriteFile(dst, data, 0o600); errWrite != nil {
+	if errWrite := authcrypt.WriteFile(dst, data, 0o600); errWrite != nil {
 		c.JSON(500, gin.H{"error": fmt.Sprintf("failed to write file: %v", errWrite)})
 		return
 	}
@@ -691,7 +706,7 @@ func (h *Handler) registerAuthFromFile(ctx context.Context, path string, data []
 	}
 	if data == nil {
 		var err error
-		data, err = os.ReadFile(path)
+		data, err = authcrypt.ReadFile(path)
 		if err != nil {
 			return fmt.Errorf("failed to read auth file: %w", err)
 		}
@@ -812,6 +827,78 @@ func (h *Handler) PatchAuthFileStatus(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"status": "ok", "disabled": *req.Disabled})
 }
 
+// ProbeAuthFiles sends a synthetic probe request with one credential and returns latency,
+// status and quota headers per probe. Without a name every enabled credential is probed in
+// the background; the request returns 202 and the round is reported by GetAuthFilesProbe.
+func (h *Handler) ProbeAuthFiles(c *gin.Context) {
+	if h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+
+	var req struct {
+		Name   string   `json:"name"`
+		Models []string `json:"models"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		round, started := h.authManager.StartProbeAll()
+		c.JSON(http.StatusAccepted, gin.H{"started": started, "round": round})
+		return
+	}
+
+	authID := ""
+	if auth, ok := h.authManager.GetByID(name); ok {
+		authID = auth.ID
+	} else {
+		for _, auth := range h.authManager.List() {
+			if auth.FileName == name {
+				authID = auth.ID
+				break
+			}
+		}
+	}
+	if authID == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "auth file not found"})
+		return
+	}
+
+	models := make([]string, 0, len(req.Models))
+	for _, model := range req.Models {
+		if trimmed := strings.TrimSpace(model); trimmed != "" {
+			models = append(models, trimmed)
+		}
+	}
+	results, err := h.authManager.ProbeAuth(ctx, authID, models...)
+	if err != nil {
+		status := http.StatusInternalServerError
+		var authErr *coreauth.Error
+		if errors.As(err, &authErr) && authErr.HTTPStatus > 0 {
+			status = authErr.HTTPStatus
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
+
+// GetAuthFilesProbe reports the probe round of every credential started by ProbeAuthFiles.
+func (h *Handler) GetAuthFilesProbe(c *gin.Context) {
+	if h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	c.JSON(http.StatusOK, h.authManager.ProbeRoundStatus())
+}
+
 func (h *Handler) disableAuth(ctx context.Context, id string) {
 	if h == nil || h.authManager == nil {
 		return
@@ -287,6 +287,8 @@ func normalizeRoutingStrategy(strategy string) (string, bool) {
 		return "round-robin", true
 	case "fill-first", "fillfirst", "ff":
 		return "fill-first", true
+	case "least-load", "leastload", "least-remaining-load", "ll":
+		return "least-load", true
 	default:
 		return "", false
 	}
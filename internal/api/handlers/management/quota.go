@@ -1,6 +1,13 @@
 package management
 
-import "github.com/gin-gonic/gin"
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
 
 // Quota exceeded toggles
 func (h *Handler) GetSwitchProject(c *gin.Context) {
@@ -16,3 +23,67 @@ func (h *Handler) GetSwitchPreviewModel(c *gin.Context) {
 func (h *Handler) PutSwitchPreviewModel(c *gin.Context) {
 	h.updateBoolField(c, func(v bool) { h.cfg.QuotaExceeded.SwitchPreviewModel = v })
 }
+
+// GetQuota lists the upstream-reported quota windows and local cooldowns of every credential.
+// The optional provider query parameter narrows the list to one provider.
+func (h *Handler) GetQuota(c *gin.Context) {
+	if h == nil || h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	provider := strings.ToLower(strings.TrimSpace(c.Query("provider")))
+	now := time.Now()
+	auths := h.authManager.List()
+	entries := make([]gin.H, 0, len(auths))
+	for _, auth := range auths {
+		if auth == nil || (provider != "" && strings.ToLower(auth.Provider) != provider) {
+			continue
+		}
+		name := strings.TrimSpace(auth.FileName)
+		if name == "" {
+			name = auth.ID
+		}
+		entry := gin.H{
+			"id":          auth.ID,
+			"name":        name,
+			"provider":    auth.Provider,
+			"label":       auth.Label,
+			"status":      auth.Status,
+			"disabled":    auth.Disabled,
+			"unavailable": auth.Unavailable,
+		}
+		if email := authEmail(auth); email != "" {
+			entry["email"] = email
+		}
+		if auth.Quota.Exceeded {
+			entry["cooldown"] = auth.Quota
+		}
+		if upstream := auth.UpstreamQuota; upstream != nil {
+			entry["upstream"] = upstream
+			if headroom, ok := upstream.Headroom("", now); ok {
+				entry["headroom_percent"] = headroom
+			}
+		}
+		models := gin.H{}
+		for model, state := range auth.ModelStates {
+			if state != nil && state.Unavailable && state.NextRetryAfter.After(now) {
+				models[model] = gin.H{"next_retry_after": state.NextRetryAfter, "quota": state.Quota}
+			}
+		}
+		if len(models) > 0 {
+			entry["model_cooldowns"] = models
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		providerI, _ := entries[i]["provider"].(string)
+		providerJ, _ := entries[j]["provider"].(string)
+		if providerI != providerJ {
+			return providerI < providerJ
+		}
+		nameI, _ := entries[i]["name"].(string)
+		nameJ, _ := entries[j]["name"].(string)
+		return strings.ToLower(nameI) < strings.ToLower(nameJ)
+	})
+	c.JSON(http.StatusOK, gin.H{"quota": entries})
+}
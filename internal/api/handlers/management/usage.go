@@ -3,6 +3,7 @@ package management
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -21,7 +22,13 @@ type usageImportPayload struct {
 }
 
 // GetUsageStatistics returns the in-memory request statistics snapshot.
+// When from, to, or group-by query parameters are present, the response is computed
+// from the persistent usage store instead.
 func (h *Handler) GetUsageStatistics(c *gin.Context) {
+	if c.Query("from") != "" || c.Query("to") != "" || c.Query("group-by") != "" {
+		h.getUsageHistory(c)
+		return
+	}
 	var snapshot usage.StatisticsSnapshot
 	if h != nil && h.usageStats != nil {
 		snapshot = h.usageStats.Snapshot()
@@ -32,6 +39,54 @@ func (h *Handler) GetUsageStatistics(c *gin.Context) {
 	})
 }
 
+func (h *Handler) getUsageHistory(c *gin.Context) {
+	store := usage.GetPersistentStore()
+	if store == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "persistent usage store not enabled"})
+		return
+	}
+	from, err := parseUsageTime(c.Query("from"), false)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from"})
+		return
+	}
+	to, err := parseUsageTime(c.Query("to"), true)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to"})
+		return
+	}
+	var groupBy []string
+	if raw := strings.TrimSpace(c.Query("group-by")); raw != "" {
+		groupBy = strings.Split(raw, ",")
+	}
+	result, err := store.Query(usage.HistoryQuery{From: from, To: to, GroupBy: groupBy})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"history": result})
+}
+
+// parseUsageTime accepts RFC3339 timestamps or YYYY-MM-DD dates (UTC). A date used as the
+// upper bound covers the whole day.
+func parseUsageTime(raw string, endOfDay bool) (time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	if ts, err := time.Parse(time.RFC3339, raw); err == nil {
+		return ts, nil
+	}
+	day, err := time.Parse("2006-01-02", raw)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		day = day.AddDate(0, 0, 1)
+	}
+	return day, nil
+}
+
 // ExportUsageStatistics returns a complete usage snapshot for backup/migration.
 func (h *Handler) ExportUsageStatistics(c *gin.Context) {
 	var snapshot usage.StatisticsSnapshot
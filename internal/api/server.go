@@ -5,10 +5,12 @@
 package api
 
 import (
+	"bytes"
 	"context"
 	"crypto/subtle"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -25,9 +27,16 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/modules"
 	ampmodule "github.com/router-for-me/CLIProxyAPI/v6/internal/api/modules/amp"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/kiro"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/batch"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cache"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/guardrails"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/managementasset"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/metrics"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/responsestore"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tracing"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
@@ -38,6 +47,7 @@ import (
 	sdkAuth "github.com/router-for-me/CLIProxyAPI/v6/sdk/auth"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
 	"gopkg.in/yaml.v3"
 )
 
@@ -161,6 +171,9 @@ type Server struct {
 	// managementRoutesEnabled controls whether management endpoints serve real handlers.
 	managementRoutesEnabled atomic.Bool
 
+	// metricsEnabled controls whether GET /metrics serves the Prometheus registry.
+	metricsEnabled atomic.Bool
+
 	// envManagementSecret indicates whether MANAGEMENT_PASSWORD is configured.
 	envManagementSecret bool
 
@@ -204,6 +217,7 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 	// Add middleware
 	engine.Use(logging.GinLogrusLogger())
 	engine.Use(logging.GinLogrusRecovery())
+	engine.Use(tracing.Middleware())
 	for _, mw := range optionState.extraMiddleware {
 		engine.Use(mw)
 	}
@@ -248,6 +262,8 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 		wsRoutes:            make(map[string]struct{}),
 	}
 	s.wsAuthEnabled.Store(cfg.WebsocketAuth)
+	s.metricsEnabled.Store(cfg.Metrics.Enabled)
+	metrics.SetCredentialSource(s.credentialMetrics)
 	// Save initial YAML snapshot
 	s.oldConfigYaml, _ = yaml.Marshal(cfg)
 	s.applyAccessConfig(nil, cfg)
@@ -256,6 +272,17 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 	}
 	managementasset.SetCurrentConfig(cfg)
 	auth.SetQuotaCooldownDisabled(cfg.DisableCooling)
+	auth.SetMinQuotaHeadroomPercent(cfg.Routing.MinQuotaHeadroomPercent)
+	s.applyUsageStoreConfig(cfg)
+	s.applyResponseCacheConfig(cfg)
+	s.applyBatchConfig(cfg)
+	s.applyResponsesStoreConfig(cfg)
+	if err := guardrails.Configure(cfg.Guardrails); err != nil {
+		log.Errorf("failed to configure guardrails: %v", err)
+	}
+	if err := tracing.Configure(cfg.Tracing); err != nil {
+		log.Errorf("failed to configure tracing: %v", err)
+	}
 	// Initialize management handler
 	s.mgmt = managementHandlers.NewHandler(cfg, configFilePath, authManager)
 	if optionState.localPassword != "" {
@@ -319,6 +346,7 @@ func (s *Server) setupRoutes() {
 	geminiCLIHandlers := gemini.NewGeminiCLIAPIHandler(s.handlers)
 	claudeCodeHandlers := claude.NewClaudeCodeAPIHandler(s.handlers)
 	openaiResponsesHandlers := openai.NewOpenAIResponsesAPIHandler(s.handlers)
+	batchHandlers := batch.NewHandler()
 
 	// OpenAI compatible API routes
 	v1 := s.engine.Group("/v1")
@@ -327,10 +355,31 @@ func (s *Server) setupRoutes() {
 		v1.GET("/models", s.unifiedModelsHandler(openaiHandlers, claudeCodeHandlers))
 		v1.POST("/chat/completions", openaiHandlers.ChatCompletions)
 		v1.POST("/completions", openaiHandlers.Completions)
+		v1.POST("/embeddings", openaiHandlers.Embeddings)
+		v1.POST("/images/generations", openaiHandlers.ImageGenerations)
+		v1.POST("/images/edits", openaiHandlers.ImageEdits)
 		v1.POST("/messages", claudeCodeHandlers.ClaudeMessages)
 		v1.POST("/messages/count_tokens", claudeCodeHandlers.ClaudeCountTokens)
 		v1.POST("/responses", openaiResponsesHandlers.Responses)
 		v1.POST("/responses/compact", openaiResponsesHandlers.Compact)
+		v1.GET("/responses/:id", openaiResponsesHandlers.GetResponse)
+		v1.GET("/responses/:id/input_items", openaiResponsesHandlers.ListInputItems)
+		v1.DELETE("/responses/:id", openaiResponsesHandlers.DeleteResponse)
+
+		v1.POST("/files", batchHandlers.UploadFile)
+		v1.GET("/files", batchHandlers.ListFiles)
+		v1.GET("/files/:id", batchHandlers.GetFile)
+		v1.GET("/files/:id/content", batchHandlers.GetFileContent)
+		v1.DELETE("/files/:id", batchHandlers.DeleteFile)
+		v1.POST("/batches", batchHandlers.CreateBatch)
+		v1.GET("/batches", batchHandlers.ListBatches)
+		v1.GET("/batches/:id", batchHandlers.GetBatch)
+		v1.POST("/batches/:id/cancel", batchHandlers.CancelBatch)
+		v1.POST("/messages/batches", batchHandlers.CreateMessageBatch)
+		v1.GET("/messages/batches", batchHandlers.ListMessageBatches)
+		v1.GET("/messages/batches/:id", batchHandlers.GetMessageBatch)
+		v1.GET("/messages/batches/:id/results", batchHandlers.MessageBatchResults)
+		v1.POST("/messages/batches/:id/cancel", batchHandlers.CancelMessageBatch)
 	}
 
 	// Gemini compatible API routes
@@ -354,6 +403,9 @@ func (s *Server) setupRoutes() {
 		})
 	})
 
+	// Prometheus scrape endpoint; answers 404 unless metrics.enabled is set.
+	s.engine.GET("/metrics", s.serveMetrics)
+
 	// Event logging endpoint - handles Claude Code telemetry requests
 	// Returns 200 OK to prevent 404 errors in logs
 	s.engine.POST("/api/event_logging/batch", func(c *gin.Context) {
@@ -536,6 +588,7 @@ func (s *Server) registerManagementRoutes() {
 
 		mgmt.POST("/api-call", s.mgmt.APICall)
 
+		mgmt.GET("/quota", s.mgmt.GetQuota)
 		mgmt.GET("/quota-exceeded/switch-project", s.mgmt.GetSwitchProject)
 		mgmt.PUT("/quota-exceeded/switch-project", s.mgmt.PutSwitchProject)
 		mgmt.PATCH("/quota-exceeded/switch-project", s.mgmt.PutSwitchProject)
@@ -642,6 +695,8 @@ func (s *Server) registerManagementRoutes() {
 		mgmt.POST("/auth-files", s.mgmt.UploadAuthFile)
 		mgmt.DELETE("/auth-files", s.mgmt.DeleteAuthFile)
 		mgmt.PATCH("/auth-files/status", s.mgmt.PatchAuthFileStatus)
+		mgmt.GET("/auth-files/probe", s.mgmt.GetAuthFilesProbe)
+		mgmt.POST("/auth-files/probe", s.mgmt.ProbeAuthFiles)
 		mgmt.POST("/vertex/import", s.mgmt.ImportVertexCredential)
 
 		mgmt.GET("/anthropic-auth-url", s.mgmt.RequestAnthropicToken)
@@ -847,6 +902,19 @@ func (s *Server) Stop(ctx context.Context) error {
 		return fmt.Errorf("failed to shutdown HTTP server: %v", err)
 	}
 
+	if err := batch.Configure(config.BatchConfig{}, "", nil); err != nil {
+		log.Warnf("failed to stop batch manager: %v", err)
+	}
+	if err := responsestore.Configure(config.ResponsesStoreConfig{}, ""); err != nil {
+		log.Warnf("failed to close responses store: %v", err)
+	}
+	if err := usage.ConfigurePersistentStore(""); err != nil {
+		log.Warnf("failed to close usage store: %v", err)
+	}
+	if err := tracing.Shutdown(ctx); err != nil {
+		log.Warnf("failed to flush traces: %v", err)
+	}
+
 	log.Debug("API server stopped")
 	return nil
 }
@@ -880,6 +948,139 @@ func (s *Server) applyAccessConfig(oldCfg, newCfg *config.Config) {
 	}
 }
 
+func (s *Server) serveMetrics(c *gin.Context) {
+	if !s.metricsEnabled.Load() {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	metrics.Handler().ServeHTTP(c.Writer, c.Request)
+}
+
+// credentialMetrics snapshots the auth manager for the credential gauges.
+func (s *Server) credentialMetrics() []metrics.CredentialState {
+	if s.handlers == nil || s.handlers.AuthManager == nil {
+		return nil
+	}
+	now := time.Now()
+	auths := s.handlers.AuthManager.List()
+	states := make([]metrics.CredentialState, 0, len(auths))
+	for _, a := range auths {
+		if a == nil {
+			continue
+		}
+		coolingDown := a.NextRetryAfter.After(now) || a.Quota.NextRecoverAt.After(now)
+		for _, state := range a.ModelStates {
+			if state != nil && (state.NextRetryAfter.After(now) || state.Quota.NextRecoverAt.After(now)) {
+				coolingDown = true
+				break
+			}
+		}
+		states = append(states, metrics.CredentialState{
+			Provider:    a.Provider,
+			Status:      string(a.Status),
+			Disabled:    a.Disabled,
+			Unavailable: a.Unavailable,
+			CoolingDown: coolingDown,
+		})
+	}
+	return states
+}
+
+// applyUsageStoreConfig opens, moves, or closes the persistent usage store to match cfg.
+func (s *Server) applyUsageStoreConfig(cfg *config.Config) {
+	path := ""
+	if cfg != nil && cfg.UsageStatisticsStore.Enabled {
+		path = strings.TrimSpace(cfg.UsageStatisticsStore.Path)
+		if path == "" {
+			if base := util.WritablePath(); base != "" {
+				path = filepath.Join(base, "usage.db")
+			} else {
+				path = filepath.Join(filepath.Dir(s.configFilePath), "usage.db")
+			}
+		}
+	}
+	if err := usage.ConfigurePersistentStore(path); err != nil {
+		log.Errorf("failed to configure usage store: %v", err)
+	}
+}
+
+// applyResponseCacheConfig installs or removes the response cache to match cfg.
+func (s *Server) applyResponseCacheConfig(cfg *config.Config) {
+	var rc config.ResponseCacheConfig
+	if cfg != nil {
+		rc = cfg.ResponseCache
+	}
+	defaultDir := filepath.Join(filepath.Dir(s.configFilePath), "response-cache")
+	if base := util.WritablePath(); base != "" {
+		defaultDir = filepath.Join(base, "response-cache")
+	}
+	if err := cache.ConfigureResponseCache(rc, defaultDir); err != nil {
+		log.Errorf("failed to configure response cache: %v", err)
+	}
+}
+
+// applyBatchConfig starts, restarts, or stops the batch manager to match cfg.
+func (s *Server) applyBatchConfig(cfg *config.Config) {
+	var bc config.BatchConfig
+	if cfg != nil {
+		bc = cfg.Batch
+	}
+	defaultDir := filepath.Join(filepath.Dir(s.configFilePath), "batches")
+	if base := util.WritablePath(); base != "" {
+		defaultDir = filepath.Join(base, "batches")
+	}
+	if err := batch.Configure(bc, defaultDir, s.executeBatchItem); err != nil {
+		log.Errorf("failed to configure batch manager: %v", err)
+	}
+}
+
+// applyResponsesStoreConfig opens, moves, or closes the Responses API store to match cfg.
+func (s *Server) applyResponsesStoreConfig(cfg *config.Config) {
+	var rc config.ResponsesStoreConfig
+	if cfg != nil {
+		rc = cfg.ResponsesStore
+	}
+	defaultPath := filepath.Join(filepath.Dir(s.configFilePath), "responses.db")
+	if base := util.WritablePath(); base != "" {
+		defaultPath = filepath.Join(base, "responses.db")
+	}
+	if err := responsestore.Configure(rc, defaultPath); err != nil {
+		log.Errorf("failed to configure responses store: %v", err)
+	}
+}
+
+// executeBatchItem runs one batch item through the regular handler pipeline. The item is
+// attributed to the client key that created the batch, as if that client had sent it, and
+// counts against that key's access limits.
+func (s *Server) executeBatchItem(ctx context.Context, handlerType, model string, body []byte, alt, apiKey string) ([]byte, *interfaces.ErrorMessage) {
+	if s.accessManager != nil {
+		if limiter := s.accessManager.Limiter(); limiter != nil {
+			release, errLimit := limiter.Acquire(apiKey, false)
+			if errLimit != nil {
+				return nil, &interfaces.ErrorMessage{StatusCode: http.StatusTooManyRequests, Error: errLimit}
+			}
+			defer release()
+		}
+	}
+	ginCtx, _ := gin.CreateTestContext(discardResponseWriter{header: make(http.Header)})
+	ginCtx.Request, _ = http.NewRequestWithContext(ctx, http.MethodPost, "/", nil)
+	if apiKey != "" {
+		ginCtx.Set("apiKey", apiKey)
+	}
+	ctx = context.WithValue(ctx, "gin", ginCtx)
+	return s.handlers.ExecuteWithAuthManager(ctx, handlerType, model, body, alt)
+}
+
+// discardResponseWriter backs the gin context of background batch items, whose response
+// headers are never sent anywhere.
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (w discardResponseWriter) Header() http.Header         { return w.header }
+func (w discardResponseWriter) Write(p []byte) (int, error) { return len(p), nil }
+func (w discardResponseWriter) WriteHeader(int)             {}
+
 // UpdateClients updates the server's client list and configuration.
 // This method is called when the configuration or authentication tokens change.
 //
@@ -916,6 +1117,34 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 		usage.SetStatisticsEnabled(cfg.UsageStatisticsEnabled)
 	}
 
+	if oldCfg == nil || oldCfg.UsageStatisticsStore != cfg.UsageStatisticsStore {
+		s.applyUsageStoreConfig(cfg)
+	}
+
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.ResponseCache, cfg.ResponseCache) {
+		s.applyResponseCacheConfig(cfg)
+	}
+
+	if oldCfg == nil || oldCfg.Batch != cfg.Batch {
+		s.applyBatchConfig(cfg)
+	}
+
+	if oldCfg == nil || oldCfg.ResponsesStore != cfg.ResponsesStore {
+		s.applyResponsesStoreConfig(cfg)
+	}
+
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.Guardrails, cfg.Guardrails) {
+		if err := guardrails.Configure(cfg.Guardrails); err != nil {
+			log.Errorf("failed to configure guardrails: %v", err)
+		}
+	}
+
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.Tracing, cfg.Tracing) {
+		if err := tracing.Configure(cfg.Tracing); err != nil {
+			log.Errorf("failed to configure tracing: %v", err)
+		}
+	}
+
 	if s.requestLogger != nil && (oldCfg == nil || oldCfg.ErrorLogsMaxFiles != cfg.ErrorLogsMaxFiles) {
 		if setter, ok := s.requestLogger.(interface{ SetErrorLogsMaxFiles(int) }); ok {
 			setter.SetErrorLogsMaxFiles(cfg.ErrorLogsMaxFiles)
@@ -925,6 +1154,7 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 	if oldCfg == nil || oldCfg.DisableCooling != cfg.DisableCooling {
 		auth.SetQuotaCooldownDisabled(cfg.DisableCooling)
 	}
+	auth.SetMinQuotaHeadroomPercent(cfg.Routing.MinQuotaHeadroomPercent)
 
 	if s.handlers != nil && s.handlers.AuthManager != nil {
 		s.handlers.AuthManager.SetRetryConfig(cfg.RequestRetry, time.Duration(cfg.MaxRetryInterval)*time.Second)
@@ -970,6 +1200,7 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 	s.applyAccessConfig(oldCfg, cfg)
 	s.cfg = cfg
 	s.wsAuthEnabled.Store(cfg.WebsocketAuth)
+	s.metricsEnabled.Store(cfg.Metrics.Enabled)
 	if oldCfg != nil && s.wsAuthChanged != nil && oldCfg.WebsocketAuth != cfg.WebsocketAuth {
 		s.wsAuthChanged(oldCfg.WebsocketAuth, cfg.WebsocketAuth)
 	}
@@ -1056,6 +1287,14 @@ func AuthMiddleware(manager *sdkaccess.Manager) gin.HandlerFunc {
 				if len(result.Metadata) > 0 {
 					c.Set("accessMetadata", result.Metadata)
 				}
+				if limiter := manager.Limiter(); limiter != nil {
+					release, errLimit := limiter.Acquire(result.Principal, isStreamingRequest(c))
+					if errLimit != nil {
+						abortWithLimitError(c, errLimit)
+						return
+					}
+					defer release()
+				}
 			}
 			c.Next()
 			return
@@ -1072,3 +1311,38 @@ func AuthMiddleware(manager *sdkaccess.Manager) gin.HandlerFunc {
 		}
 	}
 }
+
+// abortWithLimitError rejects a request that exceeded its per-key limits with a 429 response.
+func abortWithLimitError(c *gin.Context, err error) {
+	var limitErr *sdkaccess.LimitError
+	if errors.As(err, &limitErr) {
+		for key, values := range limitErr.Headers() {
+			for _, value := range values {
+				c.Header(key, value)
+			}
+		}
+	}
+	c.Data(http.StatusTooManyRequests, "application/json", handlers.BuildErrorResponseBody(http.StatusTooManyRequests, err.Error()))
+	c.Abort()
+}
+
+// isStreamingRequest reports whether the request asks for a streamed response.
+// The body is restored so downstream handlers can read it again.
+func isStreamingRequest(c *gin.Context) bool {
+	if c == nil || c.Request == nil {
+		return false
+	}
+	if strings.Contains(c.Request.URL.Path, "streamGenerateContent") {
+		return true
+	}
+	if c.Request.Body == nil || c.Request.Method != http.MethodPost {
+		return false
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	_ = c.Request.Body.Close()
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+	return gjson.GetBytes(body, "stream").Bool()
+}
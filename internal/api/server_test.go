@@ -109,3 +109,23 @@ func TestAmpProviderModelRoutes(t *testing.T) {
 		})
 	}
 }
+
+func TestMetricsEndpointToggle(t *testing.T) {
+	server := newTestServer(t)
+
+	rr := httptest.NewRecorder()
+	server.engine.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("disabled /metrics status = %d, want %d", rr.Code, http.StatusNotFound)
+	}
+
+	server.metricsEnabled.Store(true)
+	rr = httptest.NewRecorder()
+	server.engine.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("enabled /metrics status = %d, want %d", rr.Code, http.StatusOK)
+	}
+	if body := rr.Body.String(); !strings.Contains(body, "go_goroutines") {
+		t.Fatalf("metrics body missing expected series: %s", body)
+	}
+}
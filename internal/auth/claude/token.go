@@ -6,6 +6,7 @@ package claude
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 
@@ -66,7 +67,13 @@ func (ts *ClaudeTokenStorage) SaveTokenToFile(authFilePath string) error {
 	}()
 
 	// Encode and write the token data as JSON
-	if err = json.NewEncoder(f).Encode(ts); err != nil {
+	return ts.EncodeToken(f)
+}
+
+// EncodeToken writes the token record as JSON to w.
+func (ts *ClaudeTokenStorage) EncodeToken(w io.Writer) error {
+	ts.Type = "claude"
+	if err := json.NewEncoder(w).Encode(ts); err != nil {
 		return fmt.Errorf("failed to write token to file: %w", err)
 	}
 	return nil
@@ -6,6 +6,7 @@ package codex
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 
@@ -58,9 +59,14 @@ func (ts *CodexTokenStorage) SaveTokenToFile(authFilePath string) error {
 		_ = f.Close()
 	}()
 
-	if err = json.NewEncoder(f).Encode(ts); err != nil {
+	return ts.EncodeToken(f)
+}
+
+// EncodeToken writes the token record as JSON to w.
+func (ts *CodexTokenStorage) EncodeToken(w io.Writer) error {
+	ts.Type = "codex"
+	if err := json.NewEncoder(w).Encode(ts); err != nil {
 		return fmt.Errorf("failed to write token to file: %w", err)
 	}
 	return nil
-
 }
@@ -6,6 +6,7 @@ package copilot
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 
@@ -86,7 +87,13 @@ func (ts *CopilotTokenStorage) SaveTokenToFile(authFilePath string) error {
 		_ = f.Close()
 	}()
 
-	if err = json.NewEncoder(f).Encode(ts); err != nil {
+	return ts.EncodeToken(f)
+}
+
+// EncodeToken writes the token record as JSON to w.
+func (ts *CopilotTokenStorage) EncodeToken(w io.Writer) error {
+	ts.Type = "github-copilot"
+	if err := json.NewEncoder(w).Encode(ts); err != nil {
 		return fmt.Errorf("failed to write token to file: %w", err)
 	}
 	return nil
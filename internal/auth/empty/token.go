@@ -3,6 +3,8 @@
 // using API key-based authentication instead of OAuth tokens for any provider.
 package empty
 
+import "io"
+
 // EmptyStorage is a no-operation implementation of the TokenStorage interface.
 // It provides empty implementations for scenarios where token storage is not needed,
 // such as when using API keys instead of OAuth tokens for authentication.
@@ -24,3 +26,9 @@ func (ts *EmptyStorage) SaveTokenToFile(_ string) error {
 	ts.Type = "empty"
 	return nil
 }
+
+// EncodeToken writes nothing, since empty storage has no token data to persist.
+func (ts *EmptyStorage) EncodeToken(_ io.Writer) error {
+	ts.Type = "empty"
+	return nil
+}
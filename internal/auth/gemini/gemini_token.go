@@ -6,6 +6,7 @@ package gemini
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -63,7 +64,13 @@ func (ts *GeminiTokenStorage) SaveTokenToFile(authFilePath string) error {
 		}
 	}()
 
-	if err = json.NewEncoder(f).Encode(ts); err != nil {
+	return ts.EncodeToken(f)
+}
+
+// EncodeToken writes the token record as JSON to w.
+func (ts *GeminiTokenStorage) EncodeToken(w io.Writer) error {
+	ts.Type = "gemini"
+	if err := json.NewEncoder(w).Encode(ts); err != nil {
 		return fmt.Errorf("failed to write token to file: %w", err)
 	}
 	return nil
@@ -3,6 +3,7 @@ package iflow
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 
@@ -37,7 +38,13 @@ func (ts *IFlowTokenStorage) SaveTokenToFile(authFilePath string) error {
 	}
 	defer func() { _ = f.Close() }()
 
-	if err = json.NewEncoder(f).Encode(ts); err != nil {
+	return ts.EncodeToken(f)
+}
+
+// EncodeToken writes the token record as JSON to w.
+func (ts *IFlowTokenStorage) EncodeToken(w io.Writer) error {
+	ts.Type = "iflow"
+	if err := json.NewEncoder(w).Encode(ts); err != nil {
 		return fmt.Errorf("iflow token: encode token failed: %w", err)
 	}
 	return nil
@@ -16,6 +16,8 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	baseauth "github.com/router-for-me/CLIProxyAPI/v6/internal/auth"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	log "github.com/sirupsen/logrus"
@@ -460,7 +462,7 @@ func (h *OAuthWebHandler) saveTokenToFile(tokenData *KiroTokenData) {
 		Email:        tokenData.Email,
 	}
 	
-	if err := storage.SaveTokenToFile(authFilePath); err != nil {
+	if err := baseauth.SealTokenStorage(storage, authFilePath); err != nil {
 		log.Errorf("OAuth Web: failed to save token to file: %v", err)
 		return
 	}
@@ -863,7 +865,7 @@ func (h *OAuthWebHandler) handleManualRefresh(c *gin.Context) {
 		}
 
 		filePath := filepath.Join(authDir, name)
-		data, err := os.ReadFile(filePath)
+		data, err := authcrypt.ReadFile(filePath)
 		if err != nil {
 			errors = append(errors, fmt.Sprintf("%s: read error - %v", name, err))
 			continue
@@ -905,15 +907,10 @@ func (h *OAuthWebHandler) handleManualRefresh(c *gin.Context) {
 			continue
 		}
 
-		tmpFile := filePath + ".tmp"
-		if err := os.WriteFile(tmpFile, updatedData, 0600); err != nil {
+		if err := authcrypt.WriteFile(filePath, updatedData, 0600); err != nil {
 			errors = append(errors, fmt.Sprintf("%s: write error - %v", name, err))
 			continue
 		}
-		if err := os.Rename(tmpFile, filePath); err != nil {
-			errors = append(errors, fmt.Sprintf("%s: rename error - %v", name, err))
-			continue
-		}
 
 		log.Infof("OAuth Web: manually refreshed token in %s, expires at %s", name, tokenData.ExpiresAt)
 		refreshedCount++
@@ -1,10 +1,14 @@
 package kiro
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
 )
 
 // KiroTokenStorage holds the persistent token data for Kiro authentication.
@@ -44,21 +48,33 @@ func (s *KiroTokenStorage) SaveTokenToFile(authFilePath string) error {
 		return fmt.Errorf("failed to create directory: %w", err)
 	}
 
-	data, err := json.MarshalIndent(s, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal token storage: %w", err)
+	var data bytes.Buffer
+	if err := s.EncodeToken(&data); err != nil {
+		return err
 	}
 
-	if err := os.WriteFile(authFilePath, data, 0600); err != nil {
+	if err := os.WriteFile(authFilePath, data.Bytes(), 0600); err != nil {
 		return fmt.Errorf("failed to write token file: %w", err)
 	}
 
 	return nil
 }
 
+// EncodeToken writes the token record as indented JSON to w.
+func (s *KiroTokenStorage) EncodeToken(w io.Writer) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal token storage: %w", err)
+	}
+	if _, err = w.Write(data); err != nil {
+		return fmt.Errorf("failed to write token: %w", err)
+	}
+	return nil
+}
+
 // LoadFromFile loads token storage from the specified file path.
 func LoadFromFile(authFilePath string) (*KiroTokenStorage, error) {
-	data, err := os.ReadFile(authFilePath)
+	data, err := authcrypt.ReadFile(authFilePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read token file: %w", err)
 	}
@@ -5,13 +5,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/fs"
-	"os"
 	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
+
 	log "github.com/sirupsen/logrus"
 )
 
@@ -119,7 +120,7 @@ func (r *FileTokenRepository) UpdateToken(token *Token) error {
 
 	// 读取现有文件内容
 	existingData := make(map[string]any)
-	if data, err := os.ReadFile(filePath); err == nil {
+	if data, err := authcrypt.ReadFile(filePath); err == nil {
 		_ = json.Unmarshal(data, &existingData)
 	}
 
@@ -156,13 +157,8 @@ func (r *FileTokenRepository) UpdateToken(token *Token) error {
 	}
 
 	// 原子写入：先写入临时文件，再重命名
-	tmpPath := filePath + ".tmp"
-	if err := os.WriteFile(tmpPath, raw, 0o600); err != nil {
-		return fmt.Errorf("token repository: write temp file failed: %w", err)
-	}
-	if err := os.Rename(tmpPath, filePath); err != nil {
-		_ = os.Remove(tmpPath)
-		return fmt.Errorf("token repository: rename failed: %w", err)
+	if err := authcrypt.WriteFile(filePath, raw, 0o600); err != nil {
+		return fmt.Errorf("token repository: write file failed: %w", err)
 	}
 
 	log.Debugf("token repository: updated token %s", token.ID)
@@ -171,7 +167,7 @@ func (r *FileTokenRepository) UpdateToken(token *Token) error {
 
 // readTokenFile 从文件读取 token
 func (r *FileTokenRepository) readTokenFile(path string) (*Token, error) {
-	data, err := os.ReadFile(path)
+	data, err := authcrypt.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
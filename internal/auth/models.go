@@ -2,6 +2,14 @@
 // It includes interfaces and implementations for token storage and authentication methods.
 package auth
 
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
+)
+
 // TokenStorage defines the interface for storing authentication tokens.
 // Implementations of this interface should provide methods to persist
 // authentication tokens to a file system location.
@@ -15,3 +23,26 @@ type TokenStorage interface {
 	//   - error: An error if the save operation fails, nil otherwise
 	SaveTokenToFile(authFilePath string) error
 }
+
+// TokenEncoder is implemented by token storages that can serialize their record to a writer,
+// so the record can be encrypted before it is written.
+type TokenEncoder interface {
+	// EncodeToken writes the token record to w.
+	EncodeToken(w io.Writer) error
+}
+
+// SealTokenStorage persists storage at path. While auth file encryption is enabled the record
+// is serialized in memory and only its ciphertext is written; storages that do not implement
+// TokenEncoder are serialized as JSON.
+func SealTokenStorage(storage TokenStorage, path string) error {
+	if !authcrypt.Enabled() {
+		return storage.SaveTokenToFile(path)
+	}
+	misc.LogSavingCredentials(path)
+	return authcrypt.SealFile(path, func(w io.Writer) error {
+		if encoder, ok := storage.(TokenEncoder); ok {
+			return encoder.EncodeToken(w)
+		}
+		return json.NewEncoder(w).Encode(storage)
+	})
+}
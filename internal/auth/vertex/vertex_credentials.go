@@ -5,6 +5,7 @@ package vertex
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 
@@ -57,9 +58,21 @@ func (s *VertexCredentialStorage) SaveTokenToFile(authFilePath string) error {
 			log.Errorf("vertex credential: failed to close file: %v", errClose)
 		}
 	}()
-	enc := json.NewEncoder(f)
+	return s.EncodeToken(f)
+}
+
+// EncodeToken writes the credential record as indented JSON to w.
+func (s *VertexCredentialStorage) EncodeToken(w io.Writer) error {
+	if s == nil {
+		return fmt.Errorf("vertex credential: storage is nil")
+	}
+	if s.ServiceAccount == nil {
+		return fmt.Errorf("vertex credential: service account content is empty")
+	}
+	s.Type = "vertex"
+	enc := json.NewEncoder(w)
 	enc.SetIndent("", "  ")
-	if err = enc.Encode(s); err != nil {
+	if err := enc.Encode(s); err != nil {
 		return fmt.Errorf("vertex credential: encode failed: %w", err)
 	}
 	return nil
@@ -0,0 +1,508 @@
+// Package authcrypt provides envelope encryption for auth token records at rest.
+//
+// Every record is encrypted with its own random data key using AES-256-GCM. The data key is
+// wrapped with the configured key-encryption key, either a 256-bit AES-GCM key or an age X25519
+// identity. Encrypted records are JSON documents, so they still fit JSON files and JSONB columns,
+// and plaintext records written before encryption was enabled are read unchanged.
+package authcrypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+
+	"filippo.io/age"
+)
+
+const (
+	// KeyTypeAESGCM wraps data keys with a 256-bit AES-GCM key.
+	KeyTypeAESGCM = "aes-gcm"
+	// KeyTypeAge wraps data keys for an age X25519 recipient.
+	KeyTypeAge = "age"
+
+	envelopeField   = "cliproxy_encrypted"
+	envelopeVersion = 1
+)
+
+var (
+	// ErrNoKey is returned when an encrypted record is read without a configured key.
+	ErrNoKey = errors.New("authcrypt: record is encrypted but no encryption key is configured")
+	// ErrUnknownKey is returned when an encrypted record was written with a key that is not configured.
+	ErrUnknownKey = errors.New("authcrypt: record is encrypted with an unknown key")
+
+	dataAAD = []byte("cliproxy-auth-record-v1")
+	keyAAD  = []byte("cliproxy-data-key-v1")
+)
+
+// envelope is the on-disk form of an encrypted record.
+type envelope struct {
+	Version    int    `json:"version"`
+	KeyType    string `json:"key_type"`
+	KeyID      string `json:"key_id"`
+	WrappedKey string `json:"wrapped_key"`
+	Nonce      string `json:"nonce"`
+	Ciphertext string `json:"ciphertext"`
+}
+
+// wrappingKey wraps and unwraps per-record data keys.
+type wrappingKey interface {
+	id() string
+	kind() string
+	wrap(dataKey []byte) ([]byte, error)
+	unwrap(wrapped []byte) ([]byte, error)
+}
+
+// Keyring holds the key used for new records and the previous keys still accepted for reading.
+type Keyring struct {
+	primary wrappingKey
+	keys    map[string]wrappingKey
+}
+
+// KeyConfig describes where the keys come from. Each key is either an age secret key
+// ("AGE-SECRET-KEY-1...") or 32 bytes encoded as base64 or hex; key files may contain
+// comment lines, as written by age-keygen.
+type KeyConfig struct {
+	Key              string
+	KeyFile          string
+	PreviousKeys     []string
+	PreviousKeyFiles []string
+}
+
+// NewKeyring builds a keyring from cfg. It returns nil when no primary key is configured.
+func NewKeyring(cfg KeyConfig) (*Keyring, error) {
+	primary, err := loadKey(cfg.Key, cfg.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	if primary == nil {
+		return nil, nil
+	}
+	ring := &Keyring{primary: primary, keys: map[string]wrappingKey{primary.id(): primary}}
+	for _, raw := range cfg.PreviousKeys {
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+		k, errKey := parseKey(raw)
+		if errKey != nil {
+			return nil, fmt.Errorf("authcrypt: previous key: %w", errKey)
+		}
+		ring.keys[k.id()] = k
+	}
+	for _, path := range cfg.PreviousKeyFiles {
+		if strings.TrimSpace(path) == "" {
+			continue
+		}
+		k, errKey := loadKey("", path)
+		if errKey != nil {
+			return nil, errKey
+		}
+		ring.keys[k.id()] = k
+	}
+	return ring, nil
+}
+
+// PrimaryKeyID identifies the key new records are encrypted with.
+func (r *Keyring) PrimaryKeyID() string {
+	if r == nil {
+		return ""
+	}
+	return r.primary.id()
+}
+
+// PrimaryKeyType returns KeyTypeAESGCM or KeyTypeAge.
+func (r *Keyring) PrimaryKeyType() string {
+	if r == nil {
+		return ""
+	}
+	return r.primary.kind()
+}
+
+var (
+	activeMu sync.RWMutex
+	active   *Keyring
+)
+
+// Configure installs the process-wide keyring. A nil keyring disables encryption of new records.
+func Configure(ring *Keyring) {
+	activeMu.Lock()
+	active = ring
+	activeMu.Unlock()
+}
+
+// Active returns the configured keyring, or nil when encryption is disabled.
+func Active() *Keyring {
+	activeMu.RLock()
+	defer activeMu.RUnlock()
+	return active
+}
+
+// Enabled reports whether new records are encrypted.
+func Enabled() bool {
+	return Active() != nil
+}
+
+// Encrypt seals plaintext with the active keyring. Without a keyring plaintext is returned as is.
+func Encrypt(plaintext []byte) ([]byte, error) {
+	return Active().Encrypt(plaintext)
+}
+
+// Decrypt opens an encrypted record with the active keyring. Plaintext records are returned as is.
+func Decrypt(data []byte) ([]byte, error) {
+	return Active().Decrypt(data)
+}
+
+// Encrypt seals plaintext under the primary key. A nil keyring returns plaintext unchanged.
+func (r *Keyring) Encrypt(plaintext []byte) ([]byte, error) {
+	if r == nil {
+		return plaintext, nil
+	}
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, fmt.Errorf("authcrypt: generate data key: %w", err)
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("authcrypt: generate nonce: %w", err)
+	}
+	wrapped, err := r.primary.wrap(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	env := envelope{
+		Version:    envelopeVersion,
+		KeyType:    r.primary.kind(),
+		KeyID:      r.primary.id(),
+		WrappedKey: base64.StdEncoding.EncodeToString(wrapped),
+		Nonce:      base64.StdEncoding.EncodeToString(nonce),
+		Ciphertext: base64.StdEncoding.EncodeToString(aead.Seal(nil, nonce, plaintext, dataAAD)),
+	}
+	return json.Marshal(map[string]envelope{envelopeField: env})
+}
+
+// Decrypt opens an encrypted record. Records that are not encrypted are returned unchanged, so
+// plaintext files written before encryption was enabled keep working.
+func (r *Keyring) Decrypt(data []byte) ([]byte, error) {
+	env, ok := parseEnvelope(data)
+	if !ok {
+		return data, nil
+	}
+	if r == nil {
+		return nil, ErrNoKey
+	}
+	k, found := r.keys[env.KeyID]
+	if !found {
+		return nil, fmt.Errorf("%w (key id %s)", ErrUnknownKey, env.KeyID)
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(env.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("authcrypt: decode wrapped key: %w", err)
+	}
+	nonce, err := base64.StdEncoding.DecodeString(env.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("authcrypt: decode nonce: %w", err)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(env.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("authcrypt: decode ciphertext: %w", err)
+	}
+	dataKey, err := k.unwrap(wrapped)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("authcrypt: invalid nonce size")
+	}
+	plaintext, err := aead.Open(nil, nonce, ciphertext, dataAAD)
+	if err != nil {
+		return nil, fmt.Errorf("authcrypt: decrypt record: %w", err)
+	}
+	return plaintext, nil
+}
+
+// IsEncrypted reports whether data is an encrypted record.
+func IsEncrypted(data []byte) bool {
+	_, ok := parseEnvelope(data)
+	return ok
+}
+
+// IsCurrent reports whether data is stored the way new records are written: sealed with the
+// primary key when encryption is enabled, plaintext otherwise.
+func IsCurrent(data []byte) bool {
+	ring := Active()
+	if ring == nil {
+		return !IsEncrypted(data)
+	}
+	return KeyID(data) == ring.PrimaryKeyID()
+}
+
+// RecordEqual reports whether the stored record existing is current and already holds the JSON
+// document plaintext, so it need not be rewritten.
+func RecordEqual(existing, plaintext []byte) bool {
+	if !IsCurrent(existing) {
+		return false
+	}
+	stored, err := Decrypt(existing)
+	if err != nil {
+		return false
+	}
+	var a, b any
+	if json.Unmarshal(stored, &a) != nil || json.Unmarshal(plaintext, &b) != nil {
+		return false
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// KeyID returns the key id of an encrypted record, or "" for plaintext.
+func KeyID(data []byte) string {
+	env, _ := parseEnvelope(data)
+	return env.KeyID
+}
+
+func parseEnvelope(data []byte) (envelope, bool) {
+	if !bytes.Contains(data, []byte(envelopeField)) {
+		return envelope{}, false
+	}
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil || len(doc) != 1 {
+		return envelope{}, false
+	}
+	raw, ok := doc[envelopeField]
+	if !ok {
+		return envelope{}, false
+	}
+	var env envelope
+	if err := json.Unmarshal(raw, &env); err != nil || env.Version != envelopeVersion || env.KeyID == "" {
+		return envelope{}, false
+	}
+	return env, true
+}
+
+// ReadFile reads and decrypts the record stored at path.
+func ReadFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Decrypt(data)
+}
+
+// WriteFile encrypts plaintext and writes it to path atomically.
+func WriteFile(path string, plaintext []byte, perm os.FileMode) error {
+	data, err := Encrypt(plaintext)
+	if err != nil {
+		return err
+	}
+	return writeAtomic(path, data, perm)
+}
+
+// SealFile encrypts the record produced by encode and writes it to path atomically. The record
+// is only held in memory, so its plaintext never touches the disk while encryption is enabled.
+// Nothing is written when encode produces no data.
+func SealFile(path string, encode func(w io.Writer) error) error {
+	var record bytes.Buffer
+	if err := encode(&record); err != nil {
+		return err
+	}
+	if record.Len() == 0 {
+		return nil
+	}
+	return WriteFile(path, record.Bytes(), 0o600)
+}
+
+// ReencryptFile rewrites the record at path under the primary key. Plaintext records are
+// encrypted; records already sealed with the primary key are left untouched.
+func ReencryptFile(path string) (bool, error) {
+	ring := Active()
+	if ring == nil {
+		return false, ErrNoKey
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	if KeyID(data) == ring.PrimaryKeyID() {
+		return false, nil
+	}
+	plaintext, err := ring.Decrypt(data)
+	if err != nil {
+		return false, err
+	}
+	sealed, err := ring.Encrypt(plaintext)
+	if err != nil {
+		return false, err
+	}
+	if err = writeAtomic(path, sealed, 0o600); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func writeAtomic(path string, data []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("authcrypt: create directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, perm); err != nil {
+		return fmt.Errorf("authcrypt: write temp file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("authcrypt: rename file: %w", err)
+	}
+	return nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("authcrypt: init cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("authcrypt: init gcm: %w", err)
+	}
+	return aead, nil
+}
+
+// loadKey parses the inline key, or the key file when no inline key is given.
+func loadKey(inline, path string) (wrappingKey, error) {
+	if strings.TrimSpace(inline) != "" {
+		return parseKey(inline)
+	}
+	if strings.TrimSpace(path) == "" {
+		return nil, nil
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("authcrypt: read key file: %w", err)
+	}
+	var lines []string
+	for _, line := range strings.Split(string(raw), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) != 1 {
+		return nil, fmt.Errorf("authcrypt: key file %s must contain exactly one key", path)
+	}
+	k, err := parseKey(lines[0])
+	if err != nil {
+		return nil, fmt.Errorf("authcrypt: key file %s: %w", path, err)
+	}
+	return k, nil
+}
+
+func parseKey(raw string) (wrappingKey, error) {
+	raw = strings.TrimSpace(raw)
+	if strings.HasPrefix(strings.ToUpper(raw), "AGE-SECRET-KEY-") {
+		identity, err := age.ParseX25519Identity(raw)
+		if err != nil {
+			return nil, fmt.Errorf("parse age identity: %w", err)
+		}
+		return &ageKey{identity: identity}, nil
+	}
+	var key []byte
+	if decoded, err := hex.DecodeString(raw); err == nil && len(decoded) == 32 {
+		key = decoded
+	} else if decoded, err = base64.StdEncoding.DecodeString(raw); err == nil && len(decoded) == 32 {
+		key = decoded
+	} else if decoded, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(raw, "=")); err == nil && len(decoded) == 32 {
+		key = decoded
+	} else {
+		return nil, fmt.Errorf("key must be an age secret key or 32 bytes encoded as hex or base64")
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(append([]byte("cliproxy-aes-gcm:"), key...))
+	return &aesKey{aead: aead, keyID: hex.EncodeToString(sum[:8])}, nil
+}
+
+type aesKey struct {
+	aead  cipher.AEAD
+	keyID string
+}
+
+func (k *aesKey) id() string   { return k.keyID }
+func (k *aesKey) kind() string { return KeyTypeAESGCM }
+
+func (k *aesKey) wrap(dataKey []byte) ([]byte, error) {
+	nonce := make([]byte, k.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("authcrypt: generate nonce: %w", err)
+	}
+	return k.aead.Seal(nonce, nonce, dataKey, keyAAD), nil
+}
+
+func (k *aesKey) unwrap(wrapped []byte) ([]byte, error) {
+	size := k.aead.NonceSize()
+	if len(wrapped) < size {
+		return nil, fmt.Errorf("authcrypt: wrapped key too short")
+	}
+	dataKey, err := k.aead.Open(nil, wrapped[:size], wrapped[size:], keyAAD)
+	if err != nil {
+		return nil, fmt.Errorf("authcrypt: unwrap data key: %w", err)
+	}
+	return dataKey, nil
+}
+
+type ageKey struct {
+	identity *age.X25519Identity
+}
+
+func (k *ageKey) id() string {
+	sum := sha256.Sum256([]byte(k.identity.Recipient().String()))
+	return hex.EncodeToString(sum[:8])
+}
+
+func (k *ageKey) kind() string { return KeyTypeAge }
+
+func (k *ageKey) wrap(dataKey []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, k.identity.Recipient())
+	if err != nil {
+		return nil, fmt.Errorf("authcrypt: age encrypt: %w", err)
+	}
+	if _, err = w.Write(dataKey); err != nil {
+		return nil, fmt.Errorf("authcrypt: age encrypt: %w", err)
+	}
+	if err = w.Close(); err != nil {
+		return nil, fmt.Errorf("authcrypt: age encrypt: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (k *ageKey) unwrap(wrapped []byte) ([]byte, error) {
+	r, err := age.Decrypt(bytes.NewReader(wrapped), k.identity)
+	if err != nil {
+		return nil, fmt.Errorf("authcrypt: age decrypt: %w", err)
+	}
+	dataKey, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("authcrypt: age decrypt: %w", err)
+	}
+	return dataKey, nil
+}
@@ -0,0 +1,152 @@
+package authcrypt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+)
+
+func newAESKey(t *testing.T, seed byte) string {
+	t.Helper()
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{seed}, 32))
+}
+
+func newAgeKey(t *testing.T) string {
+	t.Helper()
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("GenerateX25519Identity: %v", err)
+	}
+	return identity.String()
+}
+
+func TestKeyring_RoundTrip(t *testing.T) {
+	plaintext := []byte(`{"type":"claude","refresh_token":"rt-1"}`)
+	for name, key := range map[string]string{"aes-gcm": newAESKey(t, 1), "age": newAgeKey(t)} {
+		t.Run(name, func(t *testing.T) {
+			ring, err := NewKeyring(KeyConfig{Key: key})
+			if err != nil {
+				t.Fatalf("NewKeyring: %v", err)
+			}
+			if ring.PrimaryKeyType() != name {
+				t.Fatalf("expected key type %s, got %s", name, ring.PrimaryKeyType())
+			}
+			sealed, err := ring.Encrypt(plaintext)
+			if err != nil {
+				t.Fatalf("Encrypt: %v", err)
+			}
+			if bytes.Contains(sealed, []byte("rt-1")) || !IsEncrypted(sealed) {
+				t.Fatalf("record not encrypted: %s", sealed)
+			}
+			opened, err := ring.Decrypt(sealed)
+			if err != nil {
+				t.Fatalf("Decrypt: %v", err)
+			}
+			if !bytes.Equal(opened, plaintext) {
+				t.Fatalf("round trip mismatch: %s", opened)
+			}
+		})
+	}
+}
+
+func TestKeyring_PlaintextPassesThrough(t *testing.T) {
+	ring, err := NewKeyring(KeyConfig{Key: newAESKey(t, 1)})
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	plaintext := []byte(`{"type":"gemini","email":"user@example.com"}`)
+	opened, err := ring.Decrypt(plaintext)
+	if err != nil || !bytes.Equal(opened, plaintext) {
+		t.Fatalf("expected plaintext to pass through, got %s err=%v", opened, err)
+	}
+
+	var disabled *Keyring
+	sealed, _ := ring.Encrypt(plaintext)
+	if _, err = disabled.Decrypt(sealed); !errors.Is(err, ErrNoKey) {
+		t.Fatalf("expected ErrNoKey without keyring, got %v", err)
+	}
+}
+
+func TestKeyring_RotationKeepsPreviousKeysReadable(t *testing.T) {
+	oldKey, newKey := newAESKey(t, 1), newAgeKey(t)
+	oldRing, err := NewKeyring(KeyConfig{Key: oldKey})
+	if err != nil {
+		t.Fatalf("NewKeyring old: %v", err)
+	}
+	plaintext := []byte(`{"type":"codex"}`)
+	sealed, err := oldRing.Encrypt(plaintext)
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+
+	newOnly, err := NewKeyring(KeyConfig{Key: newKey})
+	if err != nil {
+		t.Fatalf("NewKeyring new: %v", err)
+	}
+	if _, err = newOnly.Decrypt(sealed); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("expected ErrUnknownKey, got %v", err)
+	}
+
+	keyFile := filepath.Join(t.TempDir(), "old.key")
+	if err = os.WriteFile(keyFile, []byte("# previous key\n"+oldKey+"\n"), 0o600); err != nil {
+		t.Fatalf("write key file: %v", err)
+	}
+	rotating, err := NewKeyring(KeyConfig{Key: newKey, PreviousKeyFiles: []string{keyFile}})
+	if err != nil {
+		t.Fatalf("NewKeyring rotating: %v", err)
+	}
+	Configure(rotating)
+	t.Cleanup(func() { Configure(nil) })
+
+	path := filepath.Join(t.TempDir(), "codex.json")
+	if err = os.WriteFile(path, sealed, 0o600); err != nil {
+		t.Fatalf("write record: %v", err)
+	}
+	changed, err := ReencryptFile(path)
+	if err != nil || !changed {
+		t.Fatalf("ReencryptFile changed=%v err=%v", changed, err)
+	}
+	raw, _ := os.ReadFile(path)
+	if KeyID(raw) != rotating.PrimaryKeyID() {
+		t.Fatalf("record not sealed with the new key")
+	}
+	if changed, err = ReencryptFile(path); err != nil || changed {
+		t.Fatalf("expected current record to be left alone, changed=%v err=%v", changed, err)
+	}
+	opened, err := ReadFile(path)
+	if err != nil || !bytes.Equal(opened, plaintext) {
+		t.Fatalf("ReadFile got %s err=%v", opened, err)
+	}
+}
+
+func TestSealFile_EncryptsStorageOutput(t *testing.T) {
+	ring, err := NewKeyring(KeyConfig{Key: newAESKey(t, 2)})
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	Configure(ring)
+	t.Cleanup(func() { Configure(nil) })
+
+	path := filepath.Join(t.TempDir(), "claude.json")
+	err = SealFile(path, func(w io.Writer) error {
+		_, errWrite := w.Write([]byte(`{"access_token":"secret"}`))
+		return errWrite
+	})
+	if err != nil {
+		t.Fatalf("SealFile: %v", err)
+	}
+	raw, _ := os.ReadFile(path)
+	if !IsCurrent(raw) || bytes.Contains(raw, []byte("secret")) {
+		t.Fatalf("expected sealed record, got %s", raw)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Fatalf("expected only the sealed record on disk, got %d entries", len(entries))
+	}
+}
@@ -0,0 +1,213 @@
+package batch
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+// maxAnthropicCustomIDLength mirrors the Message Batches API limit on custom_id.
+const maxAnthropicCustomIDLength = 64
+
+// CreateMessageBatch handles POST /v1/messages/batches.
+func (h *Handler) CreateMessageBatch(c *gin.Context) {
+	m := h.manager(c, KindAnthropic)
+	if m == nil {
+		return
+	}
+	var req struct {
+		Requests []struct {
+			CustomID string          `json:"custom_id"`
+			Params   json.RawMessage `json:"params"`
+		} `json:"requests"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, KindAnthropic, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+		return
+	}
+	if len(req.Requests) == 0 {
+		writeError(c, KindAnthropic, http.StatusBadRequest, "requests: at least one request is required")
+		return
+	}
+	items := make([]Item, 0, len(req.Requests))
+	seen := make(map[string]struct{}, len(req.Requests))
+	for i, entry := range req.Requests {
+		switch {
+		case entry.CustomID == "" || len(entry.CustomID) > maxAnthropicCustomIDLength:
+			writeError(c, KindAnthropic, http.StatusBadRequest, fmt.Sprintf("requests.%d.custom_id: must be 1 to %d characters", i, maxAnthropicCustomIDLength))
+			return
+		case !gjson.ValidBytes(entry.Params) || !gjson.ParseBytes(entry.Params).IsObject():
+			writeError(c, KindAnthropic, http.StatusBadRequest, fmt.Sprintf("requests.%d.params: must be an object", i))
+			return
+		}
+		if _, dup := seen[entry.CustomID]; dup {
+			writeError(c, KindAnthropic, http.StatusBadRequest, fmt.Sprintf("requests.%d.custom_id: duplicate custom_id %q", i, entry.CustomID))
+			return
+		}
+		seen[entry.CustomID] = struct{}{}
+		items = append(items, Item{CustomID: entry.CustomID, Body: entry.Params})
+	}
+	b := &Batch{Kind: KindAnthropic, HandlerType: constant.Claude, APIKey: apiKey(c)}
+	accepted, err := m.Submit(b, "msgbatch_", items)
+	if err != nil {
+		log.Errorf("batch: %v", err)
+		writeError(c, KindAnthropic, http.StatusInternalServerError, "failed to store batch")
+		return
+	}
+	c.JSON(http.StatusOK, anthropicBatch(accepted))
+}
+
+// GetMessageBatch handles GET /v1/messages/batches/:id.
+func (h *Handler) GetMessageBatch(c *gin.Context) {
+	m := h.manager(c, KindAnthropic)
+	if m == nil {
+		return
+	}
+	b, err := m.Store().GetBatch(c.Param("id"), KindAnthropic, apiKey(c))
+	if err != nil {
+		writeStoreError(c, KindAnthropic, err)
+		return
+	}
+	c.JSON(http.StatusOK, anthropicBatch(b))
+}
+
+// ListMessageBatches handles GET /v1/messages/batches.
+func (h *Handler) ListMessageBatches(c *gin.Context) {
+	m := h.manager(c, KindAnthropic)
+	if m == nil {
+		return
+	}
+	batches, err := m.Store().ListBatches(KindAnthropic, apiKey(c))
+	if err != nil {
+		log.Errorf("batch: %v", err)
+	}
+	page, hasMore := paginate(batches, c.Query("after_id"), c.Query("before_id"), listLimit(c))
+	data := make([]gin.H, 0, len(page))
+	for _, b := range page {
+		data = append(data, anthropicBatch(b))
+	}
+	out := gin.H{"data": data, "has_more": hasMore, "first_id": nil, "last_id": nil}
+	if len(page) > 0 {
+		out["first_id"], out["last_id"] = page[0].ID, page[len(page)-1].ID
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// CancelMessageBatch handles POST /v1/messages/batches/:id/cancel.
+func (h *Handler) CancelMessageBatch(c *gin.Context) {
+	m := h.manager(c, KindAnthropic)
+	if m == nil {
+		return
+	}
+	b, err := m.Cancel(c.Param("id"), KindAnthropic, apiKey(c))
+	if err != nil {
+		writeStoreError(c, KindAnthropic, err)
+		return
+	}
+	c.JSON(http.StatusOK, anthropicBatch(b))
+}
+
+// MessageBatchResults handles GET /v1/messages/batches/:id/results, streaming one JSON line
+// per request once the batch has ended.
+func (h *Handler) MessageBatchResults(c *gin.Context) {
+	m := h.manager(c, KindAnthropic)
+	if m == nil {
+		return
+	}
+	b, err := m.Store().GetBatch(c.Param("id"), KindAnthropic, apiKey(c))
+	if err != nil {
+		writeStoreError(c, KindAnthropic, err)
+		return
+	}
+	if !b.Status.Ended() {
+		writeError(c, KindAnthropic, http.StatusBadRequest, fmt.Sprintf("message batch %s is still processing", b.ID))
+		return
+	}
+	results, err := m.Store().Results(b.ID)
+	if err != nil {
+		writeStoreError(c, KindAnthropic, err)
+		return
+	}
+	c.Header("Content-Type", "application/x-jsonl")
+	c.Status(http.StatusOK)
+	for _, result := range results {
+		_, _ = c.Writer.Write(append(anthropicResultLine(result), '\n'))
+	}
+}
+
+func anthropicBatch(b *Batch) gin.H {
+	status := "in_progress"
+	switch {
+	case b.Status.Ended():
+		status = "ended"
+	case b.Status == StatusCancelling:
+		status = "canceling"
+	}
+	var resultsURL any
+	if b.Status.Ended() {
+		resultsURL = "/v1/messages/batches/" + b.ID + "/results"
+	}
+	return gin.H{
+		"id":                  b.ID,
+		"type":                "message_batch",
+		"processing_status":   status,
+		"request_counts":      gin.H{"processing": b.Counts.Pending(), "succeeded": b.Counts.Succeeded, "errored": b.Counts.Failed, "canceled": b.Counts.Canceled, "expired": b.Counts.Expired},
+		"ended_at":            rfc3339OrNil(b.EndedAt()),
+		"created_at":          b.CreatedAt.Format(time.RFC3339),
+		"expires_at":          b.ExpiresAt.Format(time.RFC3339),
+		"archived_at":         nil,
+		"cancel_initiated_at": rfc3339OrNil(b.CancellingAt),
+		"results_url":         resultsURL,
+	}
+}
+
+// anthropicResultLine renders a result as a line of the Message Batches results stream.
+func anthropicResultLine(result Result) []byte {
+	outcome := gin.H{"type": result.Type}
+	switch result.Type {
+	case ResultSucceeded:
+		outcome["message"] = json.RawMessage(result.Body)
+	case ResultErrored:
+		if gjson.GetBytes(result.Body, "type").String() == "error" {
+			outcome["error"] = json.RawMessage(result.Body)
+		} else {
+			outcome["error"] = gin.H{"type": "error", "error": gin.H{"type": anthropicErrorType(result.StatusCode), "message": result.Error}}
+		}
+	}
+	data, _ := json.Marshal(gin.H{"custom_id": result.CustomID, "result": outcome})
+	return data
+}
+
+func anthropicErrorType(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "invalid_request_error"
+	case http.StatusUnauthorized:
+		return "authentication_error"
+	case http.StatusForbidden:
+		return "permission_error"
+	case http.StatusNotFound:
+		return "not_found_error"
+	case http.StatusRequestEntityTooLarge:
+		return "request_too_large"
+	case http.StatusTooManyRequests:
+		return "rate_limit_error"
+	case 529:
+		return "overloaded_error"
+	default:
+		return "api_error"
+	}
+}
+
+func rfc3339OrNil(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,229 @@
+package batch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	"github.com/tidwall/gjson"
+)
+
+func newBatchTestRouter(t *testing.T, exec Executor) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	if err := Configure(config.BatchConfig{Enabled: true, Path: t.TempDir(), Concurrency: 2, MaxAttempts: 2}, "", exec); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	t.Cleanup(func() { _ = Configure(config.BatchConfig{}, "", nil) })
+
+	h := NewHandler()
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("apiKey", c.GetHeader("X-Test-Key")) })
+	r.POST("/v1/files", h.UploadFile)
+	r.GET("/v1/files/:id/content", h.GetFileContent)
+	r.POST("/v1/batches", h.CreateBatch)
+	r.GET("/v1/batches/:id", h.GetBatch)
+	r.POST("/v1/messages/batches", h.CreateMessageBatch)
+	r.GET("/v1/messages/batches/:id", h.GetMessageBatch)
+	r.GET("/v1/messages/batches/:id/results", h.MessageBatchResults)
+	return r
+}
+
+func serveBatchTest(r *gin.Engine, req *http.Request, key string) *httptest.ResponseRecorder {
+	req.Header.Set("X-Test-Key", key)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func waitBatchEnded(t *testing.T, r *gin.Engine, path, key, field, ended string) gjson.Result {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		w := serveBatchTest(r, httptest.NewRequest(http.MethodGet, path, nil), key)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: status %d: %s", path, w.Code, w.Body.String())
+		}
+		if body := gjson.ParseBytes(w.Body.Bytes()); body.Get(field).String() == ended {
+			return body
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("batch %s did not end in time", path)
+	return gjson.Result{}
+}
+
+func TestOpenAIBatch_RunsItemsAndWritesOutputFiles(t *testing.T) {
+	var mu sync.Mutex
+	var seen []string
+	r := newBatchTestRouter(t, func(_ context.Context, handlerType, model string, body []byte, alt, apiKey string) ([]byte, *interfaces.ErrorMessage) {
+		mu.Lock()
+		seen = append(seen, handlerType+"|"+model+"|"+apiKey)
+		mu.Unlock()
+		if gjson.GetBytes(body, "stream").Exists() {
+			t.Errorf("stream flag must be stripped from batch items")
+		}
+		if model == "bad-model" {
+			return nil, &interfaces.ErrorMessage{StatusCode: http.StatusBadRequest, Error: errors.New("unknown model")}
+		}
+		return []byte(`{"object":"chat.completion","model":"` + model + `"}`), nil
+	})
+
+	input := `{"custom_id":"a","method":"POST","url":"/v1/chat/completions","body":{"model":"gpt-5","stream":true}}
+{"custom_id":"b","method":"POST","url":"/v1/chat/completions","body":{"model":"bad-model"}}
+`
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	_ = mw.WriteField("purpose", "batch")
+	part, _ := mw.CreateFormFile("file", "input.jsonl")
+	_, _ = part.Write([]byte(input))
+	_ = mw.Close()
+	upload := httptest.NewRequest(http.MethodPost, "/v1/files", &form)
+	upload.Header.Set("Content-Type", mw.FormDataContentType())
+	w := serveBatchTest(r, upload, "key-1")
+	if w.Code != http.StatusOK {
+		t.Fatalf("upload: status %d: %s", w.Code, w.Body.String())
+	}
+	fileID := gjson.GetBytes(w.Body.Bytes(), "id").String()
+
+	create := httptest.NewRequest(http.MethodPost, "/v1/batches", strings.NewReader(`{"input_file_id":"`+fileID+`","endpoint":"/v1/chat/completions","completion_window":"24h"}`))
+	create.Header.Set("Content-Type", "application/json")
+	if w = serveBatchTest(r, create, "key-2"); w.Code != http.StatusNotFound {
+		t.Fatalf("expected another key not to see the input file, got %d", w.Code)
+	}
+	create = httptest.NewRequest(http.MethodPost, "/v1/batches", strings.NewReader(`{"input_file_id":"`+fileID+`","endpoint":"/v1/chat/completions","completion_window":"24h"}`))
+	w = serveBatchTest(r, create, "key-1")
+	if w.Code != http.StatusOK {
+		t.Fatalf("create: status %d: %s", w.Code, w.Body.String())
+	}
+	batchID := gjson.GetBytes(w.Body.Bytes(), "id").String()
+
+	done := waitBatchEnded(t, r, "/v1/batches/"+batchID, "key-1", "status", "completed")
+	if done.Get("request_counts.total").Int() != 2 || done.Get("request_counts.completed").Int() != 1 || done.Get("request_counts.failed").Int() != 1 {
+		t.Fatalf("unexpected request counts %s", done.Get("request_counts").Raw)
+	}
+
+	w = serveBatchTest(r, httptest.NewRequest(http.MethodGet, "/v1/files/"+done.Get("output_file_id").String()+"/content", nil), "key-1")
+	output := gjson.Parse(strings.TrimSpace(w.Body.String()))
+	if output.Get("custom_id").String() != "a" || output.Get("response.status_code").Int() != http.StatusOK || output.Get("response.body.model").String() != "gpt-5" {
+		t.Fatalf("unexpected output file %s", w.Body.String())
+	}
+	w = serveBatchTest(r, httptest.NewRequest(http.MethodGet, "/v1/files/"+done.Get("error_file_id").String()+"/content", nil), "key-1")
+	failed := gjson.Parse(strings.TrimSpace(w.Body.String()))
+	if failed.Get("custom_id").String() != "b" || failed.Get("response.status_code").Int() != http.StatusBadRequest {
+		t.Fatalf("unexpected error file %s", w.Body.String())
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(seen) != 2 || !strings.HasSuffix(seen[0], "|key-1") || !strings.HasPrefix(seen[0], "openai|") {
+		t.Fatalf("unexpected executions %v", seen)
+	}
+}
+
+func TestAnthropicMessageBatch_RetriesAndStreamsResults(t *testing.T) {
+	var mu sync.Mutex
+	attempts := map[string]int{}
+	r := newBatchTestRouter(t, func(_ context.Context, handlerType, model string, body []byte, _, _ string) ([]byte, *interfaces.ErrorMessage) {
+		if handlerType != "claude" {
+			t.Errorf("unexpected handler type %s", handlerType)
+		}
+		prompt := gjson.GetBytes(body, "messages.0.content").String()
+		mu.Lock()
+		attempts[prompt]++
+		attempt := attempts[prompt]
+		mu.Unlock()
+		switch {
+		case prompt == "flaky" && attempt == 1:
+			return nil, &interfaces.ErrorMessage{StatusCode: http.StatusTooManyRequests, Error: errors.New("slow down")}
+		case prompt == "broken":
+			return nil, &interfaces.ErrorMessage{StatusCode: http.StatusInternalServerError, Error: errors.New("upstream exploded")}
+		}
+		return []byte(`{"type":"message","content":[{"type":"text","text":"` + prompt + `"}]}`), nil
+	})
+
+	create := httptest.NewRequest(http.MethodPost, "/v1/messages/batches", strings.NewReader(`{"requests":[
+		{"custom_id":"one","params":{"model":"claude-sonnet-4-5","max_tokens":16,"messages":[{"role":"user","content":"flaky"}]}},
+		{"custom_id":"two","params":{"model":"claude-sonnet-4-5","max_tokens":16,"messages":[{"role":"user","content":"broken"}]}}
+	]}`))
+	w := serveBatchTest(r, create, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("create: status %d: %s", w.Code, w.Body.String())
+	}
+	created := gjson.ParseBytes(w.Body.Bytes())
+	if created.Get("type").String() != "message_batch" || !strings.HasPrefix(created.Get("id").String(), "msgbatch_") {
+		t.Fatalf("unexpected batch %s", w.Body.String())
+	}
+
+	done := waitBatchEnded(t, r, "/v1/messages/batches/"+created.Get("id").String(), "", "processing_status", "ended")
+	if done.Get("request_counts.succeeded").Int() != 1 || done.Get("request_counts.errored").Int() != 1 {
+		t.Fatalf("unexpected request counts %s", done.Get("request_counts").Raw)
+	}
+
+	w = serveBatchTest(r, httptest.NewRequest(http.MethodGet, done.Get("results_url").String(), nil), "")
+	results := map[string]gjson.Result{}
+	for _, line := range strings.Split(strings.TrimSpace(w.Body.String()), "\n") {
+		result := gjson.Parse(line)
+		results[result.Get("custom_id").String()] = result.Get("result")
+	}
+	if results["one"].Get("type").String() != "succeeded" || results["one"].Get("message.content.0.text").String() != "flaky" {
+		t.Fatalf("unexpected result for retried request: %s", results["one"].Raw)
+	}
+	if results["two"].Get("type").String() != "errored" || results["two"].Get("error.error.type").String() != "api_error" {
+		t.Fatalf("unexpected result for failing request: %s", results["two"].Raw)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if attempts["flaky"] != 2 || attempts["broken"] != 2 {
+		t.Fatalf("expected each failing request to be tried twice, got %v", attempts)
+	}
+}
+
+func TestOpenAIBatch_FailsItemsOverTokenBudget(t *testing.T) {
+	var mu sync.Mutex
+	calls := 0
+	r := newBatchTestRouter(t, func(context.Context, string, string, []byte, string, string) ([]byte, *interfaces.ErrorMessage) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		return nil, &interfaces.ErrorMessage{StatusCode: http.StatusTooManyRequests, Error: &sdkaccess.LimitError{Reason: "daily_token_budget", Message: "daily token budget of 10 tokens exhausted"}}
+	})
+
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	_ = mw.WriteField("purpose", "batch")
+	part, _ := mw.CreateFormFile("file", "input.jsonl")
+	_, _ = part.Write([]byte(`{"custom_id":"a","method":"POST","url":"/v1/chat/completions","body":{"model":"gpt-5"}}` + "\n"))
+	_ = mw.Close()
+	upload := httptest.NewRequest(http.MethodPost, "/v1/files", &form)
+	upload.Header.Set("Content-Type", mw.FormDataContentType())
+	w := serveBatchTest(r, upload, "key-1")
+	create := httptest.NewRequest(http.MethodPost, "/v1/batches", strings.NewReader(`{"input_file_id":"`+gjson.GetBytes(w.Body.Bytes(), "id").String()+`","endpoint":"/v1/chat/completions","completion_window":"24h"}`))
+	w = serveBatchTest(r, create, "key-1")
+	if w.Code != http.StatusOK {
+		t.Fatalf("create: status %d: %s", w.Code, w.Body.String())
+	}
+
+	done := waitBatchEnded(t, r, "/v1/batches/"+gjson.GetBytes(w.Body.Bytes(), "id").String(), "key-1", "status", "completed")
+	w = serveBatchTest(r, httptest.NewRequest(http.MethodGet, "/v1/files/"+done.Get("error_file_id").String()+"/content", nil), "key-1")
+	failed := gjson.Parse(strings.TrimSpace(w.Body.String()))
+	if failed.Get("response.status_code").Int() != http.StatusTooManyRequests || failed.Get("response.body.error.code").String() != "rate_limited" {
+		t.Fatalf("unexpected error file %s", w.Body.String())
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if calls != 1 {
+		t.Fatalf("expected an exhausted budget not to be retried, got %d calls", calls)
+	}
+}
@@ -0,0 +1,544 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	// DefaultConcurrency bounds the items running at once when none is configured.
+	DefaultConcurrency = 4
+	// DefaultMaxAttempts is how often a retryable item is tried when none is configured.
+	DefaultMaxAttempts = 3
+
+	// completionWindow is the only window the batch APIs offer; items still pending after it expire.
+	completionWindow = 24 * time.Hour
+
+	retryBaseDelay = time.Second
+	retryMaxDelay  = 30 * time.Second
+
+	// progressFlushItems and progressFlushInterval bound how many recorded results and how much
+	// time may pass before a running batch's progress is written back to its state file.
+	progressFlushItems    = 100
+	progressFlushInterval = time.Second
+)
+
+var (
+	errBatchCanceled = errors.New("batch canceled")
+	errBatchExpired  = errors.New("batch expired")
+)
+
+// Executor runs one batch item through the proxy pipeline on behalf of apiKey and returns the
+// response body in the handler's format. Items refused by the client's access limits are reported
+// with an error wrapping *sdkaccess.LimitError.
+type Executor func(ctx context.Context, handlerType, model string, body []byte, alt, apiKey string) ([]byte, *interfaces.ErrorMessage)
+
+var (
+	activeMu sync.RWMutex
+	active   *Manager
+)
+
+// Configure installs (or removes) the process-wide batch manager. Batches interrupted by a
+// previous manager or process are resumed. defaultDir is used when cfg.Path is empty.
+func Configure(cfg config.BatchConfig, defaultDir string, exec Executor) error {
+	activeMu.Lock()
+	defer activeMu.Unlock()
+	if active != nil {
+		active.Close()
+		active = nil
+	}
+	if !cfg.Enabled {
+		return nil
+	}
+	m, err := NewManager(cfg, defaultDir, exec)
+	if err != nil {
+		return err
+	}
+	m.Start()
+	active = m
+	return nil
+}
+
+// Active returns the configured batch manager, or nil when batches are disabled.
+func Active() *Manager {
+	activeMu.RLock()
+	defer activeMu.RUnlock()
+	return active
+}
+
+// Manager accepts batches and executes their items in the background.
+type Manager struct {
+	store       *Store
+	exec        Executor
+	maxAttempts int
+	sem         chan struct{}
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	running map[string]*run
+}
+
+// run is a batch being processed. Its state is only changed under mu. Status changes are saved
+// right away; recorded results are saved in chunks, counted by unsaved.
+type run struct {
+	mu       sync.Mutex
+	batch    *Batch
+	recorded map[string]struct{}
+	unsaved  int
+	ctx      context.Context
+	cancel   context.CancelCauseFunc
+}
+
+func (r *run) isRecorded(customID string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.recorded[customID]
+	return ok
+}
+
+// NewManager opens the store for cfg. Call Start to begin processing.
+func NewManager(cfg config.BatchConfig, defaultDir string, exec Executor) (*Manager, error) {
+	if exec == nil {
+		return nil, errors.New("batch: executor is nil")
+	}
+	dir := strings.TrimSpace(cfg.Path)
+	if dir == "" {
+		dir = defaultDir
+	}
+	store, err := OpenStore(dir)
+	if err != nil {
+		return nil, err
+	}
+	concurrency := cfg.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+	maxAttempts := cfg.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{
+		store:       store,
+		exec:        exec,
+		maxAttempts: maxAttempts,
+		sem:         make(chan struct{}, concurrency),
+		ctx:         ctx,
+		cancel:      cancel,
+		running:     make(map[string]*run),
+	}, nil
+}
+
+// Store returns the underlying store.
+func (m *Manager) Store() *Store { return m.store }
+
+// Start resumes every batch that had not ended.
+func (m *Manager) Start() {
+	batches, err := m.store.allBatches()
+	if err != nil {
+		log.Warnf("batch: list batches: %v", err)
+	}
+	for _, b := range batches {
+		if !b.Status.Ended() {
+			log.Infof("batch: resuming %s (%s)", b.ID, b.Status)
+			m.start(b)
+		}
+	}
+}
+
+// Close stops processing and waits for running items to return. Unfinished batches keep their
+// state and are resumed by the next manager.
+func (m *Manager) Close() {
+	m.cancel()
+	m.wg.Wait()
+}
+
+// Submit stores a new batch with its items and starts processing it. It returns the batch as
+// accepted; b itself is owned by the runner afterwards.
+func (m *Manager) Submit(b *Batch, idPrefix string, items []Item) (*Batch, error) {
+	now := time.Now().UTC()
+	b.Status = StatusValidating
+	b.CreatedAt = now
+	b.ExpiresAt = now.Add(completionWindow)
+	if err := m.store.CreateBatch(b, idPrefix, items); err != nil {
+		return nil, err
+	}
+	accepted := *b
+	m.start(b)
+	return &accepted, nil
+}
+
+// Cancel stops a batch visible to apiKey. Items already running finish; the rest are canceled.
+func (m *Manager) Cancel(id, kind, apiKey string) (*Batch, error) {
+	b, err := m.store.GetBatch(id, kind, apiKey)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	r := m.running[id]
+	m.mu.Unlock()
+	if r == nil {
+		return b, nil
+	}
+	r.mu.Lock()
+	if !r.batch.Status.Ended() && r.batch.Status != StatusCancelling {
+		r.batch.Status = StatusCancelling
+		r.batch.CancellingAt = time.Now().UTC()
+		m.saveLocked(r)
+	}
+	snapshot := *r.batch
+	r.mu.Unlock()
+	r.cancel(errBatchCanceled)
+	return &snapshot, nil
+}
+
+func (m *Manager) start(b *Batch) {
+	ctx, cancel := context.WithCancelCause(m.ctx)
+	ctx, cancelDeadline := context.WithDeadlineCause(ctx, b.ExpiresAt, errBatchExpired)
+	r := &run{batch: b, recorded: make(map[string]struct{}), ctx: ctx, cancel: func(cause error) {
+		cancel(cause)
+		cancelDeadline()
+	}}
+	if b.Status == StatusCancelling {
+		r.cancel(errBatchCanceled)
+	}
+	m.mu.Lock()
+	m.running[b.ID] = r
+	m.mu.Unlock()
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer func() {
+			m.mu.Lock()
+			delete(m.running, b.ID)
+			m.mu.Unlock()
+			r.cancel(context.Canceled)
+		}()
+		m.process(r)
+	}()
+}
+
+func (m *Manager) process(r *run) {
+	id := r.batch.ID
+	items, err := m.store.Items(id)
+	if err != nil {
+		m.update(r, func(b *Batch) {
+			b.Status = StatusFailed
+			b.FailedAt = time.Now().UTC()
+			b.Error = err.Error()
+		})
+		return
+	}
+	results, err := m.store.Results(id)
+	if err == nil {
+		err = m.store.RewriteResults(id, results)
+	}
+	if err != nil {
+		log.Warnf("batch %s: reload results: %v", id, err)
+	}
+	m.update(r, func(b *Batch) {
+		b.Counts = Counts{Total: len(items)}
+		for _, result := range results {
+			r.recorded[result.CustomID] = struct{}{}
+			b.Counts.add(result.Type)
+		}
+		if b.Status == StatusValidating {
+			b.Status = StatusInProgress
+			b.InProgressAt = time.Now().UTC()
+		}
+	})
+
+	stopFlush := make(chan struct{})
+	go m.flushProgress(r, stopFlush)
+
+	var wg sync.WaitGroup
+	for _, item := range items {
+		if r.isRecorded(item.CustomID) {
+			continue
+		}
+		acquired := false
+		select {
+		case m.sem <- struct{}{}:
+			acquired = true
+		case <-r.ctx.Done():
+		}
+		if r.ctx.Err() != nil {
+			if acquired {
+				<-m.sem
+			}
+			break
+		}
+		wg.Add(1)
+		go func(item Item) {
+			defer wg.Done()
+			defer func() { <-m.sem }()
+			if result := m.execute(r.ctx, r.batch, item); result != nil {
+				m.record(r, result)
+			}
+		}(item)
+	}
+	wg.Wait()
+	close(stopFlush)
+	m.flush(r)
+
+	if m.ctx.Err() != nil {
+		// Shutting down; the batch is resumed from its recorded results on the next start.
+		return
+	}
+	cause := context.Cause(r.ctx)
+	for _, item := range items {
+		if r.isRecorded(item.CustomID) {
+			continue
+		}
+		if result := interruptedResult(item, cause); result != nil {
+			m.record(r, result)
+		}
+	}
+	m.finalize(r, cause)
+}
+
+// execute runs one item, retrying rate limits and transient failures. It returns nil when the
+// manager is shutting down and the item must run again after a restart.
+func (m *Manager) execute(ctx context.Context, b *Batch, item Item) *Result {
+	body, _ := sjson.DeleteBytes(item.Body, "stream")
+	model := gjson.GetBytes(body, "model").String()
+	if model == "" {
+		return erroredResult(item, http.StatusBadRequest, "model is required")
+	}
+	for attempt := 1; ; attempt++ {
+		resp, errMsg := m.exec(ctx, b.HandlerType, model, body, b.Alt, b.APIKey)
+		if errMsg == nil {
+			return &Result{ID: newID("batch_req_"), CustomID: item.CustomID, Type: ResultSucceeded, StatusCode: http.StatusOK, Body: resp}
+		}
+		if ctx.Err() != nil {
+			return m.interrupted(ctx, item)
+		}
+		message := http.StatusText(errMsg.StatusCode)
+		if errMsg.Error != nil {
+			message = errMsg.Error.Error()
+		}
+		var limitErr *sdkaccess.LimitError
+		if errors.As(errMsg.Error, &limitErr) && (attempt >= m.maxAttempts || exhaustedBudget(limitErr)) {
+			return rateLimitedResult(item, limitErr)
+		}
+		if attempt >= m.maxAttempts || !retryableStatus(errMsg.StatusCode) {
+			return erroredResult(item, errMsg.StatusCode, message)
+		}
+		log.Debugf("batch %s: item %s attempt %d failed with status %d, retrying", b.ID, item.CustomID, attempt, errMsg.StatusCode)
+		timer := time.NewTimer(retryDelay(attempt))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return m.interrupted(ctx, item)
+		}
+	}
+}
+
+func (m *Manager) interrupted(ctx context.Context, item Item) *Result {
+	if m.ctx.Err() != nil {
+		return nil
+	}
+	return interruptedResult(item, context.Cause(ctx))
+}
+
+func interruptedResult(item Item, cause error) *Result {
+	switch {
+	case errors.Is(cause, errBatchCanceled):
+		return &Result{ID: newID("batch_req_"), CustomID: item.CustomID, Type: ResultCanceled}
+	case errors.Is(cause, errBatchExpired):
+		return &Result{ID: newID("batch_req_"), CustomID: item.CustomID, Type: ResultExpired}
+	default:
+		return nil
+	}
+}
+
+func erroredResult(item Item, status int, message string) *Result {
+	if status <= 0 {
+		status = http.StatusInternalServerError
+	}
+	return &Result{
+		ID:         newID("batch_req_"),
+		CustomID:   item.CustomID,
+		Type:       ResultErrored,
+		StatusCode: status,
+		Body:       handlers.BuildErrorResponseBody(status, message),
+		Error:      message,
+	}
+}
+
+// rateLimitedResult fails an item the client's own access limits refused to run.
+func rateLimitedResult(item Item, limitErr *sdkaccess.LimitError) *Result {
+	result := erroredResult(item, http.StatusTooManyRequests, limitErr.Error())
+	result.Body, _ = sjson.SetBytes(result.Body, "error.code", "rate_limited")
+	return result
+}
+
+// exhaustedBudget reports whether limitErr is a token budget that will not refill within the
+// item's retries.
+func exhaustedBudget(limitErr *sdkaccess.LimitError) bool {
+	return limitErr.Reason == "daily_token_budget" || limitErr.Reason == "monthly_token_budget"
+}
+
+func retryableStatus(status int) bool {
+	return status <= 0 || status == http.StatusRequestTimeout || status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
+
+func retryDelay(attempt int) time.Duration {
+	delay := retryBaseDelay << (attempt - 1)
+	if delay <= 0 || delay > retryMaxDelay {
+		return retryMaxDelay
+	}
+	return delay
+}
+
+func (m *Manager) record(r *run, result *Result) {
+	if err := m.store.AppendResult(r.batch.ID, *result); err != nil {
+		log.Errorf("batch %s: record result for %s: %v", r.batch.ID, result.CustomID, err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.recorded[result.CustomID] = struct{}{}
+	r.batch.Counts.add(result.Type)
+	r.unsaved++
+	if r.unsaved >= progressFlushItems {
+		m.saveLocked(r)
+	}
+}
+
+// flushProgress saves the progress of r every progressFlushInterval until stop is closed.
+func (m *Manager) flushProgress(r *run, stop <-chan struct{}) {
+	ticker := time.NewTicker(progressFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.flush(r)
+		case <-stop:
+			return
+		}
+	}
+}
+
+// flush saves results recorded since the last save of r.
+func (m *Manager) flush(r *run) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.unsaved > 0 {
+		m.saveLocked(r)
+	}
+}
+
+func (m *Manager) finalize(r *run, cause error) {
+	m.update(r, func(b *Batch) {
+		b.Status = StatusFinalizing
+		b.FinalizingAt = time.Now().UTC()
+	})
+	var errFiles error
+	if r.batch.Kind == KindOpenAI {
+		errFiles = m.writeOutputFiles(r)
+	}
+	m.update(r, func(b *Batch) {
+		now := time.Now().UTC()
+		switch {
+		case errFiles != nil:
+			b.Status, b.FailedAt, b.Error = StatusFailed, now, errFiles.Error()
+		case errors.Is(cause, errBatchCanceled):
+			b.Status, b.CancelledAt = StatusCancelled, now
+		case errors.Is(cause, errBatchExpired) && b.Counts.Expired > 0:
+			b.Status, b.ExpiredAt = StatusExpired, now
+		default:
+			b.Status, b.CompletedAt = StatusCompleted, now
+		}
+	})
+}
+
+// writeOutputFiles renders the results of an OpenAI batch into its output and error files.
+func (m *Manager) writeOutputFiles(r *run) error {
+	id := r.batch.ID
+	results, err := m.store.Results(id)
+	if err != nil {
+		return fmt.Errorf("read results: %w", err)
+	}
+	var output, failed []byte
+	for _, result := range results {
+		line := append(openAIResultLine(result), '\n')
+		if result.Type == ResultSucceeded {
+			output = append(output, line...)
+		} else {
+			failed = append(failed, line...)
+		}
+	}
+	outputID, errOutput := m.writeResultFile(r, id+"_output.jsonl", output)
+	if errOutput != nil {
+		return errOutput
+	}
+	errorID, errError := m.writeResultFile(r, id+"_error.jsonl", failed)
+	if errError != nil {
+		return errError
+	}
+	m.update(r, func(b *Batch) {
+		b.OutputFileID, b.ErrorFileID = outputID, errorID
+	})
+	return nil
+}
+
+func (m *Manager) writeResultFile(r *run, name string, content []byte) (string, error) {
+	if len(content) == 0 {
+		return "", nil
+	}
+	f, err := m.store.CreateFile(name, "batch_output", r.batch.APIKey, strings.NewReader(string(content)))
+	if err != nil {
+		return "", err
+	}
+	return f.ID, nil
+}
+
+func (m *Manager) update(r *run, mutate func(b *Batch)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	mutate(r.batch)
+	m.saveLocked(r)
+}
+
+// saveLocked writes the state of r, including any unsaved progress. r.mu must be held.
+func (m *Manager) saveLocked(r *run) {
+	m.save(r.batch)
+	r.unsaved = 0
+}
+
+func (m *Manager) save(b *Batch) {
+	if err := m.store.SaveBatch(b); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Errorf("batch %s: %v", b.ID, err)
+	}
+}
+
+func (c *Counts) add(resultType string) {
+	switch resultType {
+	case ResultSucceeded:
+		c.Succeeded++
+	case ResultErrored:
+		c.Failed++
+	case ResultCanceled:
+		c.Canceled++
+	case ResultExpired:
+		c.Expired++
+	}
+}
@@ -0,0 +1,464 @@
+package batch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	defaultListLimit = 20
+	maxListLimit     = 100
+)
+
+// openAIEndpoint describes how items sent to a batch endpoint are executed.
+type openAIEndpoint struct {
+	handlerType string
+	alt         string
+}
+
+// openAIEndpoints lists the endpoints OpenAI batches may target.
+var openAIEndpoints = map[string]openAIEndpoint{
+	"/v1/chat/completions": {handlerType: constant.OpenAI},
+	"/v1/embeddings":       {handlerType: constant.OpenAI, alt: "embeddings"},
+	"/v1/responses":        {handlerType: constant.OpenaiResponse},
+}
+
+// Handler serves the batch and file endpoints from the active manager.
+type Handler struct{}
+
+// NewHandler returns the batch API handler.
+func NewHandler() *Handler { return &Handler{} }
+
+// manager returns the active manager or answers 404 when batches are disabled.
+func (h *Handler) manager(c *gin.Context, kind string) *Manager {
+	m := Active()
+	if m == nil {
+		writeError(c, kind, http.StatusNotFound, "batch API is not enabled on this server")
+	}
+	return m
+}
+
+// UploadFile handles POST /v1/files.
+func (h *Handler) UploadFile(c *gin.Context) {
+	m := h.manager(c, KindOpenAI)
+	if m == nil {
+		return
+	}
+	purpose := strings.TrimSpace(c.PostForm("purpose"))
+	if purpose == "" {
+		writeError(c, KindOpenAI, http.StatusBadRequest, "purpose is required")
+		return
+	}
+	header, err := c.FormFile("file")
+	if err != nil {
+		writeError(c, KindOpenAI, http.StatusBadRequest, "file is required")
+		return
+	}
+	content, err := header.Open()
+	if err != nil {
+		writeError(c, KindOpenAI, http.StatusBadRequest, fmt.Sprintf("read file: %v", err))
+		return
+	}
+	defer func() { _ = content.Close() }()
+	f, err := m.Store().CreateFile(header.Filename, purpose, apiKey(c), content)
+	if err != nil {
+		log.Errorf("batch: %v", err)
+		writeError(c, KindOpenAI, http.StatusInternalServerError, "failed to store file")
+		return
+	}
+	c.JSON(http.StatusOK, openAIFile(f))
+}
+
+// ListFiles handles GET /v1/files.
+func (h *Handler) ListFiles(c *gin.Context) {
+	m := h.manager(c, KindOpenAI)
+	if m == nil {
+		return
+	}
+	files, err := m.Store().ListFiles(apiKey(c))
+	if err != nil {
+		log.Errorf("batch: %v", err)
+	}
+	purpose := strings.TrimSpace(c.Query("purpose"))
+	data := make([]gin.H, 0, len(files))
+	for _, f := range files {
+		if purpose == "" || f.Purpose == purpose {
+			data = append(data, openAIFile(f))
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"object": "list", "data": data, "has_more": false})
+}
+
+// GetFile handles GET /v1/files/:id.
+func (h *Handler) GetFile(c *gin.Context) {
+	m := h.manager(c, KindOpenAI)
+	if m == nil {
+		return
+	}
+	f, err := m.Store().GetFile(c.Param("id"), apiKey(c))
+	if err != nil {
+		writeStoreError(c, KindOpenAI, err)
+		return
+	}
+	c.JSON(http.StatusOK, openAIFile(f))
+}
+
+// GetFileContent handles GET /v1/files/:id/content.
+func (h *Handler) GetFileContent(c *gin.Context) {
+	m := h.manager(c, KindOpenAI)
+	if m == nil {
+		return
+	}
+	f, content, err := m.Store().OpenFileContent(c.Param("id"), apiKey(c))
+	if err != nil {
+		writeStoreError(c, KindOpenAI, err)
+		return
+	}
+	defer func() { _ = content.Close() }()
+	c.DataFromReader(http.StatusOK, f.Bytes, "application/octet-stream", content, nil)
+}
+
+// DeleteFile handles DELETE /v1/files/:id.
+func (h *Handler) DeleteFile(c *gin.Context) {
+	m := h.manager(c, KindOpenAI)
+	if m == nil {
+		return
+	}
+	id := c.Param("id")
+	if err := m.Store().DeleteFile(id, apiKey(c)); err != nil {
+		writeStoreError(c, KindOpenAI, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": id, "object": "file", "deleted": true})
+}
+
+// CreateBatch handles POST /v1/batches.
+func (h *Handler) CreateBatch(c *gin.Context) {
+	m := h.manager(c, KindOpenAI)
+	if m == nil {
+		return
+	}
+	var req struct {
+		InputFileID      string            `json:"input_file_id"`
+		Endpoint         string            `json:"endpoint"`
+		CompletionWindow string            `json:"completion_window"`
+		Metadata         map[string]string `json:"metadata"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, KindOpenAI, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+		return
+	}
+	endpoint, ok := openAIEndpoints[req.Endpoint]
+	if !ok {
+		writeError(c, KindOpenAI, http.StatusBadRequest, fmt.Sprintf("unsupported endpoint %q", req.Endpoint))
+		return
+	}
+	if req.CompletionWindow != "24h" {
+		writeError(c, KindOpenAI, http.StatusBadRequest, "completion_window must be 24h")
+		return
+	}
+	key := apiKey(c)
+	_, content, err := m.Store().OpenFileContent(req.InputFileID, key)
+	if err != nil {
+		writeStoreError(c, KindOpenAI, err)
+		return
+	}
+	items, err := parseOpenAIItems(content, req.Endpoint)
+	_ = content.Close()
+	if err != nil {
+		writeError(c, KindOpenAI, http.StatusBadRequest, err.Error())
+		return
+	}
+	b := &Batch{
+		Kind:             KindOpenAI,
+		HandlerType:      endpoint.handlerType,
+		Alt:              endpoint.alt,
+		Endpoint:         req.Endpoint,
+		InputFileID:      req.InputFileID,
+		CompletionWindow: req.CompletionWindow,
+		Metadata:         req.Metadata,
+		APIKey:           key,
+	}
+	accepted, err := m.Submit(b, "batch_", items)
+	if err != nil {
+		log.Errorf("batch: %v", err)
+		writeError(c, KindOpenAI, http.StatusInternalServerError, "failed to store batch")
+		return
+	}
+	c.JSON(http.StatusOK, openAIBatch(accepted))
+}
+
+// parseOpenAIItems reads a batch input file: one {"custom_id","method","url","body"} per line.
+func parseOpenAIItems(r io.Reader, endpoint string) ([]Item, error) {
+	var items []Item
+	seen := make(map[string]struct{})
+	lineNo := 0
+	err := scanLines(r, func(line []byte) error {
+		lineNo++
+		var entry struct {
+			CustomID string          `json:"custom_id"`
+			Method   string          `json:"method"`
+			URL      string          `json:"url"`
+			Body     json.RawMessage `json:"body"`
+		}
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return fmt.Errorf("line %d: invalid JSON: %v", lineNo, err)
+		}
+		switch {
+		case entry.CustomID == "":
+			return fmt.Errorf("line %d: custom_id is required", lineNo)
+		case entry.Method != "" && !strings.EqualFold(entry.Method, http.MethodPost):
+			return fmt.Errorf("line %d: method must be POST", lineNo)
+		case entry.URL != endpoint:
+			return fmt.Errorf("line %d: url %q does not match the batch endpoint %s", lineNo, entry.URL, endpoint)
+		case !gjson.ValidBytes(entry.Body) || !gjson.ParseBytes(entry.Body).IsObject():
+			return fmt.Errorf("line %d: body must be a JSON object", lineNo)
+		}
+		if _, dup := seen[entry.CustomID]; dup {
+			return fmt.Errorf("line %d: duplicate custom_id %q", lineNo, entry.CustomID)
+		}
+		seen[entry.CustomID] = struct{}{}
+		items = append(items, Item{CustomID: entry.CustomID, Body: entry.Body})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, errors.New("input file contains no requests")
+	}
+	return items, nil
+}
+
+// GetBatch handles GET /v1/batches/:id.
+func (h *Handler) GetBatch(c *gin.Context) {
+	m := h.manager(c, KindOpenAI)
+	if m == nil {
+		return
+	}
+	b, err := m.Store().GetBatch(c.Param("id"), KindOpenAI, apiKey(c))
+	if err != nil {
+		writeStoreError(c, KindOpenAI, err)
+		return
+	}
+	c.JSON(http.StatusOK, openAIBatch(b))
+}
+
+// ListBatches handles GET /v1/batches.
+func (h *Handler) ListBatches(c *gin.Context) {
+	m := h.manager(c, KindOpenAI)
+	if m == nil {
+		return
+	}
+	batches, err := m.Store().ListBatches(KindOpenAI, apiKey(c))
+	if err != nil {
+		log.Errorf("batch: %v", err)
+	}
+	page, hasMore := paginate(batches, c.Query("after"), "", listLimit(c))
+	data := make([]gin.H, 0, len(page))
+	for _, b := range page {
+		data = append(data, openAIBatch(b))
+	}
+	out := gin.H{"object": "list", "data": data, "has_more": hasMore, "first_id": nil, "last_id": nil}
+	if len(page) > 0 {
+		out["first_id"], out["last_id"] = page[0].ID, page[len(page)-1].ID
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// CancelBatch handles POST /v1/batches/:id/cancel.
+func (h *Handler) CancelBatch(c *gin.Context) {
+	m := h.manager(c, KindOpenAI)
+	if m == nil {
+		return
+	}
+	b, err := m.Cancel(c.Param("id"), KindOpenAI, apiKey(c))
+	if err != nil {
+		writeStoreError(c, KindOpenAI, err)
+		return
+	}
+	c.JSON(http.StatusOK, openAIBatch(b))
+}
+
+func openAIFile(f *File) gin.H {
+	return gin.H{
+		"id":         f.ID,
+		"object":     "file",
+		"bytes":      f.Bytes,
+		"created_at": f.CreatedAt.Unix(),
+		"filename":   f.Filename,
+		"purpose":    f.Purpose,
+		"status":     "processed",
+	}
+}
+
+func openAIBatch(b *Batch) gin.H {
+	var batchErrors any
+	if b.Error != "" {
+		batchErrors = gin.H{"object": "list", "data": []gin.H{{"code": "batch_failed", "message": b.Error}}}
+	}
+	metadata := any(b.Metadata)
+	if b.Metadata == nil {
+		metadata = nil
+	}
+	return gin.H{
+		"id":                b.ID,
+		"object":            "batch",
+		"endpoint":          b.Endpoint,
+		"errors":            batchErrors,
+		"input_file_id":     b.InputFileID,
+		"completion_window": b.CompletionWindow,
+		"status":            string(b.Status),
+		"output_file_id":    optionalString(b.OutputFileID),
+		"error_file_id":     optionalString(b.ErrorFileID),
+		"created_at":        b.CreatedAt.Unix(),
+		"in_progress_at":    unixOrNil(b.InProgressAt),
+		"expires_at":        unixOrNil(b.ExpiresAt),
+		"finalizing_at":     unixOrNil(b.FinalizingAt),
+		"completed_at":      unixOrNil(b.CompletedAt),
+		"failed_at":         unixOrNil(b.FailedAt),
+		"expired_at":        unixOrNil(b.ExpiredAt),
+		"cancelling_at":     unixOrNil(b.CancellingAt),
+		"cancelled_at":      unixOrNil(b.CancelledAt),
+		"request_counts": gin.H{
+			"total":     b.Counts.Total,
+			"completed": b.Counts.Succeeded,
+			"failed":    b.Counts.Failed + b.Counts.Canceled + b.Counts.Expired,
+		},
+		"metadata": metadata,
+	}
+}
+
+// openAIResultLine renders a result as a line of a batch output or error file.
+func openAIResultLine(result Result) []byte {
+	line := gin.H{"id": result.ID, "custom_id": result.CustomID, "response": nil, "error": nil}
+	switch result.Type {
+	case ResultSucceeded, ResultErrored:
+		body := any(json.RawMessage(result.Body))
+		if len(result.Body) == 0 {
+			body = nil
+		}
+		line["response"] = gin.H{"status_code": result.StatusCode, "request_id": result.ID, "body": body}
+	case ResultCanceled:
+		line["error"] = gin.H{"code": "batch_cancelled", "message": "This request was not executed because the batch was cancelled."}
+	case ResultExpired:
+		line["error"] = gin.H{"code": "batch_expired", "message": "This request could not be executed before the completion window expired."}
+	}
+	data, _ := json.Marshal(line)
+	return data
+}
+
+// apiKey returns the client key of the request; files and batches are only visible to the key
+// that created them.
+func apiKey(c *gin.Context) string {
+	if v, exists := c.Get("apiKey"); exists {
+		if key, ok := v.(string); ok {
+			return key
+		}
+	}
+	return ""
+}
+
+func listLimit(c *gin.Context) int {
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit <= 0 {
+		return defaultListLimit
+	}
+	if limit > maxListLimit {
+		return maxListLimit
+	}
+	return limit
+}
+
+// paginate returns the page of batches (newest first) after the afterID or before the beforeID cursor.
+func paginate(batches []*Batch, afterID, beforeID string, limit int) ([]*Batch, bool) {
+	if beforeID != "" {
+		for i, b := range batches {
+			if b.ID == beforeID {
+				start := i - limit
+				if start < 0 {
+					start = 0
+				}
+				return batches[start:i], start > 0
+			}
+		}
+		return nil, false
+	}
+	if afterID != "" {
+		for i, b := range batches {
+			if b.ID == afterID {
+				batches = batches[i+1:]
+				break
+			}
+		}
+	}
+	if len(batches) > limit {
+		return batches[:limit], true
+	}
+	return batches, false
+}
+
+func writeStoreError(c *gin.Context, kind string, err error) {
+	if errors.Is(err, ErrNotFound) {
+		writeError(c, kind, http.StatusNotFound, "not found")
+		return
+	}
+	log.Errorf("batch: %v", err)
+	writeError(c, kind, http.StatusInternalServerError, "internal error")
+}
+
+// writeError answers in the error format of the API the batch kind belongs to.
+func writeError(c *gin.Context, kind string, status int, message string) {
+	if kind == KindAnthropic {
+		c.JSON(status, gin.H{"type": "error", "error": gin.H{"type": anthropicErrorType(status), "message": message}})
+		return
+	}
+	errType := "invalid_request_error"
+	if status >= http.StatusInternalServerError {
+		errType = "server_error"
+	}
+	c.JSON(status, gin.H{"error": gin.H{"message": message, "type": errType, "param": nil, "code": nil}})
+}
+
+func optionalString(value string) any {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+func unixOrNil(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t.Unix()
+}
+
+func scanLines(r io.Reader, visit func(line []byte) error) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		if err = visit(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,466 @@
+// Package batch emulates the OpenAI and Anthropic batch APIs on top of the proxy's own
+// request pipeline. Uploaded files, batches and their results are kept on disk and the
+// batch items are executed in the background through the normal credential pool.
+package batch
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrNotFound is returned when a file or batch does not exist or belongs to another client key.
+var ErrNotFound = errors.New("batch: not found")
+
+// Status is the lifecycle state of a batch, using the OpenAI vocabulary.
+type Status string
+
+const (
+	StatusValidating Status = "validating"
+	StatusInProgress Status = "in_progress"
+	StatusFinalizing Status = "finalizing"
+	StatusCompleted  Status = "completed"
+	StatusFailed     Status = "failed"
+	StatusCancelling Status = "cancelling"
+	StatusCancelled  Status = "cancelled"
+	StatusExpired    Status = "expired"
+)
+
+// Ended reports whether the batch will not process any more items.
+func (s Status) Ended() bool {
+	switch s {
+	case StatusCompleted, StatusFailed, StatusCancelled, StatusExpired:
+		return true
+	default:
+		return false
+	}
+}
+
+// API flavours a batch was created through; they only differ in how batches are rendered.
+const (
+	KindOpenAI    = "openai"
+	KindAnthropic = "anthropic"
+)
+
+// File is an uploaded or generated file.
+type File struct {
+	ID        string    `json:"id"`
+	Filename  string    `json:"filename"`
+	Purpose   string    `json:"purpose"`
+	Bytes     int64     `json:"bytes"`
+	CreatedAt time.Time `json:"created_at"`
+	APIKey    string    `json:"api_key,omitempty"`
+}
+
+// Counts tracks the outcome of the items of a batch.
+type Counts struct {
+	Total     int `json:"total"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+	Canceled  int `json:"canceled"`
+	Expired   int `json:"expired"`
+}
+
+// Pending returns the number of items without a result yet.
+func (c Counts) Pending() int {
+	pending := c.Total - c.Succeeded - c.Failed - c.Canceled - c.Expired
+	if pending < 0 {
+		return 0
+	}
+	return pending
+}
+
+// Batch is the persisted state of a batch job.
+type Batch struct {
+	ID   string `json:"id"`
+	Kind string `json:"kind"`
+	// HandlerType is the source format the items are executed in (see internal/constant).
+	HandlerType string `json:"handler_type"`
+	// Alt is passed to the executor alongside the handler type (e.g. "responses/compact").
+	Alt              string            `json:"alt,omitempty"`
+	Endpoint         string            `json:"endpoint,omitempty"`
+	InputFileID      string            `json:"input_file_id,omitempty"`
+	CompletionWindow string            `json:"completion_window,omitempty"`
+	Metadata         map[string]string `json:"metadata,omitempty"`
+	APIKey           string            `json:"api_key,omitempty"`
+	Status           Status            `json:"status"`
+	Counts           Counts            `json:"counts"`
+	OutputFileID     string            `json:"output_file_id,omitempty"`
+	ErrorFileID      string            `json:"error_file_id,omitempty"`
+	// Error explains why a batch failed validation.
+	Error string `json:"error,omitempty"`
+
+	CreatedAt    time.Time `json:"created_at"`
+	InProgressAt time.Time `json:"in_progress_at,omitempty"`
+	FinalizingAt time.Time `json:"finalizing_at,omitempty"`
+	CompletedAt  time.Time `json:"completed_at,omitempty"`
+	FailedAt     time.Time `json:"failed_at,omitempty"`
+	CancellingAt time.Time `json:"cancelling_at,omitempty"`
+	CancelledAt  time.Time `json:"cancelled_at,omitempty"`
+	ExpiredAt    time.Time `json:"expired_at,omitempty"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// EndedAt returns when the batch stopped processing, or the zero time.
+func (b *Batch) EndedAt() time.Time {
+	for _, at := range []time.Time{b.CompletedAt, b.CancelledAt, b.FailedAt, b.ExpiredAt} {
+		if !at.IsZero() {
+			return at
+		}
+	}
+	return time.Time{}
+}
+
+// Item is one request of a batch.
+type Item struct {
+	CustomID string          `json:"custom_id"`
+	Body     json.RawMessage `json:"body"`
+}
+
+// Result outcome types.
+const (
+	ResultSucceeded = "succeeded"
+	ResultErrored   = "errored"
+	ResultCanceled  = "canceled"
+	ResultExpired   = "expired"
+)
+
+// Result is the outcome of one item.
+type Result struct {
+	ID         string          `json:"id"`
+	CustomID   string          `json:"custom_id"`
+	Type       string          `json:"type"`
+	StatusCode int             `json:"status_code,omitempty"`
+	Body       json.RawMessage `json:"body,omitempty"`
+	Error      string          `json:"error,omitempty"`
+}
+
+// Store keeps files and batches in a directory:
+//
+//	files/<id>.json, files/<id>.jsonl
+//	batches/<id>.json, batches/<id>.input.jsonl, batches/<id>.results.jsonl
+type Store struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// OpenStore creates the store directories under dir.
+func OpenStore(dir string) (*Store, error) {
+	dir = strings.TrimSpace(dir)
+	if dir == "" {
+		return nil, errors.New("batch store: path is empty")
+	}
+	for _, sub := range []string{"files", "batches"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o700); err != nil {
+			return nil, fmt.Errorf("batch store: create directory: %w", err)
+		}
+	}
+	return &Store{dir: dir}, nil
+}
+
+func newID(prefix string) string {
+	return prefix + strings.ReplaceAll(uuid.NewString(), "-", "")
+}
+
+func (s *Store) filePath(id, ext string) string {
+	return filepath.Join(s.dir, "files", filepath.Base(id)+ext)
+}
+
+func (s *Store) batchPath(id, ext string) string {
+	return filepath.Join(s.dir, "batches", filepath.Base(id)+ext)
+}
+
+// CreateFile stores content as a new file.
+func (s *Store) CreateFile(filename, purpose, apiKey string, content io.Reader) (*File, error) {
+	f := &File{ID: newID("file-"), Filename: filename, Purpose: purpose, CreatedAt: time.Now().UTC(), APIKey: apiKey}
+	out, err := os.OpenFile(s.filePath(f.ID, ".jsonl"), os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("batch store: create file: %w", err)
+	}
+	f.Bytes, err = io.Copy(out, content)
+	if errClose := out.Close(); err == nil {
+		err = errClose
+	}
+	if err == nil {
+		err = writeJSON(s.filePath(f.ID, ".json"), f)
+	}
+	if err != nil {
+		_ = os.Remove(s.filePath(f.ID, ".jsonl"))
+		return nil, fmt.Errorf("batch store: write file: %w", err)
+	}
+	return f, nil
+}
+
+// GetFile returns the file metadata when it is visible to apiKey.
+func (s *Store) GetFile(id, apiKey string) (*File, error) {
+	var f File
+	if err := readJSON(s.filePath(id, ".json"), &f); err != nil {
+		return nil, err
+	}
+	if f.APIKey != apiKey {
+		return nil, ErrNotFound
+	}
+	return &f, nil
+}
+
+// OpenFileContent opens the content of a file visible to apiKey.
+func (s *Store) OpenFileContent(id, apiKey string) (*File, *os.File, error) {
+	f, err := s.GetFile(id, apiKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	content, err := os.Open(s.filePath(id, ".jsonl"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("batch store: open file: %w", err)
+	}
+	return f, content, nil
+}
+
+// DeleteFile removes a file visible to apiKey.
+func (s *Store) DeleteFile(id, apiKey string) error {
+	if _, err := s.GetFile(id, apiKey); err != nil {
+		return err
+	}
+	_ = os.Remove(s.filePath(id, ".jsonl"))
+	if err := os.Remove(s.filePath(id, ".json")); err != nil {
+		return fmt.Errorf("batch store: delete file: %w", err)
+	}
+	return nil
+}
+
+// ListFiles returns the files visible to apiKey, newest first.
+func (s *Store) ListFiles(apiKey string) ([]*File, error) {
+	var files []*File
+	err := s.list("files", func(path string) {
+		var f File
+		if readJSON(path, &f) == nil && f.APIKey == apiKey {
+			files = append(files, &f)
+		}
+	})
+	sort.Slice(files, func(i, j int) bool { return files[i].CreatedAt.After(files[j].CreatedAt) })
+	return files, err
+}
+
+// CreateBatch persists b together with its items. b.ID is assigned using prefix.
+func (s *Store) CreateBatch(b *Batch, prefix string, items []Item) error {
+	b.ID = newID(prefix)
+	b.Counts = Counts{Total: len(items)}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for i := range items {
+		if err := enc.Encode(&items[i]); err != nil {
+			return fmt.Errorf("batch store: encode item: %w", err)
+		}
+	}
+	if err := writeFileAtomic(s.batchPath(b.ID, ".input.jsonl"), buf.Bytes()); err != nil {
+		return fmt.Errorf("batch store: write items: %w", err)
+	}
+	return s.SaveBatch(b)
+}
+
+// SaveBatch writes the batch state.
+func (s *Store) SaveBatch(b *Batch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := writeJSON(s.batchPath(b.ID, ".json"), b); err != nil {
+		return fmt.Errorf("batch store: write batch: %w", err)
+	}
+	return nil
+}
+
+// GetBatch returns a batch of the given kind visible to apiKey.
+func (s *Store) GetBatch(id, kind, apiKey string) (*Batch, error) {
+	b, err := s.loadBatch(id)
+	if err != nil {
+		return nil, err
+	}
+	if b.Kind != kind || b.APIKey != apiKey {
+		return nil, ErrNotFound
+	}
+	return b, nil
+}
+
+func (s *Store) loadBatch(id string) (*Batch, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var b Batch
+	if err := readJSON(s.batchPath(id, ".json"), &b); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// ListBatches returns the batches of kind visible to apiKey, newest first.
+func (s *Store) ListBatches(kind, apiKey string) ([]*Batch, error) {
+	batches, err := s.allBatches()
+	out := batches[:0]
+	for _, b := range batches {
+		if b.Kind == kind && b.APIKey == apiKey {
+			out = append(out, b)
+		}
+	}
+	return out, err
+}
+
+func (s *Store) allBatches() ([]*Batch, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var batches []*Batch
+	err := s.list("batches", func(path string) {
+		var b Batch
+		if readJSON(path, &b) == nil {
+			batches = append(batches, &b)
+		}
+	})
+	sort.Slice(batches, func(i, j int) bool { return batches[i].CreatedAt.After(batches[j].CreatedAt) })
+	return batches, err
+}
+
+// Items reads the items of a batch.
+func (s *Store) Items(id string) ([]Item, error) {
+	var items []Item
+	err := scanJSONLines(s.batchPath(id, ".input.jsonl"), func(line []byte) error {
+		var item Item
+		if err := json.Unmarshal(line, &item); err != nil {
+			return fmt.Errorf("batch store: decode item: %w", err)
+		}
+		items = append(items, item)
+		return nil
+	})
+	return items, err
+}
+
+// Results reads the results recorded so far. A line truncated by a crash is dropped.
+func (s *Store) Results(id string) ([]Result, error) {
+	var results []Result
+	err := scanJSONLines(s.batchPath(id, ".results.jsonl"), func(line []byte) error {
+		var result Result
+		if json.Unmarshal(line, &result) == nil {
+			results = append(results, result)
+		}
+		return nil
+	})
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	return results, err
+}
+
+// RewriteResults replaces the results file, dropping any partial line before appending resumes.
+func (s *Store) RewriteResults(id string, results []Result) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for i := range results {
+		if err := enc.Encode(&results[i]); err != nil {
+			return err
+		}
+	}
+	return writeFileAtomic(s.batchPath(id, ".results.jsonl"), buf.Bytes())
+}
+
+// AppendResult records the result of one item.
+func (s *Store) AppendResult(id string, result Result) error {
+	line, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out, err := os.OpenFile(s.batchPath(id, ".results.jsonl"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("batch store: open results: %w", err)
+	}
+	_, err = out.Write(append(line, '\n'))
+	if errClose := out.Close(); err == nil {
+		err = errClose
+	}
+	return err
+}
+
+func (s *Store) list(sub string, visit func(path string)) error {
+	entries, err := os.ReadDir(filepath.Join(s.dir, sub))
+	if err != nil {
+		return fmt.Errorf("batch store: list %s: %w", sub, err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		visit(filepath.Join(s.dir, sub, entry.Name()))
+	}
+	return nil
+}
+
+func scanJSONLines(path string, visit func(line []byte) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ErrNotFound
+		}
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if err = visit(line); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func readJSON(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ErrNotFound
+		}
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func writeJSON(path string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,512 @@
+package cache
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	// DefaultResponseCacheTTL is used when the configured TTL is not positive.
+	DefaultResponseCacheTTL = 24 * time.Hour
+
+	// DefaultResponseCacheMaxEntries bounds the cache when no limit is configured.
+	DefaultResponseCacheMaxEntries = 1000
+
+	// diskResponseSweepInterval is how often the disk backend removes expired files.
+	diskResponseSweepInterval = 5 * time.Minute
+)
+
+// samplingParams are the request fields holding the sampling temperature. Unless
+// ResponseCacheConfig.CacheSampled is set, a request is only cached when one of them is 0:
+// a missing temperature means the upstream default, which samples.
+var samplingParams = []string{"temperature", "generationConfig.temperature", "request.generationConfig.temperature"}
+
+// transportParams are removed before hashing because they only affect how the
+// response is delivered, not its content.
+var transportParams = []string{"stream", "stream_options"}
+
+// ResponseEntry is a cached upstream response in the caller's source format.
+type ResponseEntry struct {
+	// Model is the model that served the original request.
+	Model string `json:"model,omitempty"`
+	// Body holds a non-streaming response payload.
+	Body []byte `json:"body,omitempty"`
+	// Chunks holds the stream chunks exactly as they were emitted to the handler.
+	Chunks [][]byte `json:"chunks,omitempty"`
+	// CreatedAt records when the entry was stored.
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ResponseKey describes the request a cache entry answers.
+type ResponseKey struct {
+	Format  string
+	Model   string
+	APIKey  string
+	Alt     string
+	Stream  bool
+	Payload []byte
+}
+
+type responseBackend interface {
+	get(key string) (*ResponseEntry, bool)
+	put(key string, entry *ResponseEntry)
+	close()
+}
+
+// ResponseCache stores completed responses keyed by the normalized request.
+type ResponseCache struct {
+	cfg     config.ResponseCacheConfig
+	ttl     time.Duration
+	backend responseBackend
+}
+
+var (
+	responseCacheMu     sync.RWMutex
+	activeResponseCache *ResponseCache
+)
+
+// ConfigureResponseCache installs (or removes) the process-wide response cache.
+// defaultDir is used by the disk backend when cfg.Path is empty.
+func ConfigureResponseCache(cfg config.ResponseCacheConfig, defaultDir string) error {
+	if !cfg.Enabled {
+		responseCacheMu.Lock()
+		previous := activeResponseCache
+		activeResponseCache = nil
+		responseCacheMu.Unlock()
+		previous.Close()
+		return nil
+	}
+	c, err := NewResponseCache(cfg, defaultDir)
+	if err != nil {
+		return err
+	}
+	responseCacheMu.Lock()
+	previous := activeResponseCache
+	activeResponseCache = c
+	responseCacheMu.Unlock()
+	previous.Close()
+	return nil
+}
+
+// ActiveResponseCache returns the configured response cache, or nil when disabled.
+func ActiveResponseCache() *ResponseCache {
+	responseCacheMu.RLock()
+	defer responseCacheMu.RUnlock()
+	return activeResponseCache
+}
+
+// NewResponseCache builds a response cache for cfg regardless of cfg.Enabled.
+func NewResponseCache(cfg config.ResponseCacheConfig, defaultDir string) (*ResponseCache, error) {
+	ttl := time.Duration(cfg.TTLSeconds) * time.Second
+	if ttl <= 0 {
+		ttl = DefaultResponseCacheTTL
+	}
+	maxEntries := cfg.MaxEntries
+	if maxEntries <= 0 {
+		maxEntries = DefaultResponseCacheMaxEntries
+	}
+	c := &ResponseCache{cfg: cfg, ttl: ttl}
+	switch strings.ToLower(strings.TrimSpace(cfg.Backend)) {
+	case "", "memory":
+		c.backend = newMemoryResponseBackend(maxEntries, ttl)
+	case "disk":
+		dir := strings.TrimSpace(cfg.Path)
+		if dir == "" {
+			dir = defaultDir
+		}
+		if dir == "" {
+			return nil, errors.New("response cache: disk backend requires a path")
+		}
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return nil, fmt.Errorf("response cache: create directory: %w", err)
+		}
+		c.backend = newDiskResponseBackend(dir, maxEntries, ttl)
+	default:
+		return nil, fmt.Errorf("response cache: unsupported backend %q", cfg.Backend)
+	}
+	return c, nil
+}
+
+// Key returns the cache key for k and whether the request may be cached at all.
+// Requests for models outside cfg.Models and requests flagged as non-deterministic
+// by the exclusion rules are not cacheable.
+func (c *ResponseCache) Key(k ResponseKey) (string, bool) {
+	if c == nil || len(k.Payload) == 0 || !gjson.ValidBytes(k.Payload) {
+		return "", false
+	}
+	if !c.modelAllowed(k.Model) || c.nonDeterministic(k.Payload) {
+		return "", false
+	}
+	normalized, err := normalizePayload(k.Payload)
+	if err != nil {
+		return "", false
+	}
+	h := sha256.New()
+	for _, part := range []string{k.Format, strings.ToLower(strings.TrimSpace(k.Model)), k.APIKey, k.Alt, strconv.FormatBool(k.Stream)} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	h.Write(normalized)
+	return hex.EncodeToString(h.Sum(nil)), true
+}
+
+// Get returns the live entry stored under key.
+func (c *ResponseCache) Get(key string) (*ResponseEntry, bool) {
+	if c == nil || key == "" {
+		return nil, false
+	}
+	return c.backend.get(key)
+}
+
+// Put stores entry under key.
+func (c *ResponseCache) Put(key string, entry *ResponseEntry) {
+	if c == nil || key == "" || entry == nil {
+		return
+	}
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	c.backend.put(key, entry)
+}
+
+// Close stops background maintenance of the cache. Entries already on disk are kept.
+func (c *ResponseCache) Close() {
+	if c == nil {
+		return
+	}
+	c.backend.close()
+}
+
+func (c *ResponseCache) modelAllowed(model string) bool {
+	if len(c.cfg.Models) == 0 {
+		return true
+	}
+	model = strings.ToLower(strings.TrimSpace(model))
+	for _, pattern := range c.cfg.Models {
+		if matchPattern(strings.ToLower(strings.TrimSpace(pattern)), model) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *ResponseCache) nonDeterministic(payload []byte) bool {
+	if !c.cfg.CacheSampled && !greedy(payload) {
+		return true
+	}
+	for _, path := range c.cfg.ExcludeParams {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		if isSet(gjson.GetBytes(payload, path)) {
+			return true
+		}
+	}
+	return false
+}
+
+// greedy reports whether payload explicitly requests a sampling temperature of 0.
+func greedy(payload []byte) bool {
+	for _, path := range samplingParams {
+		value := gjson.GetBytes(payload, path)
+		if value.Exists() && value.Type != gjson.Null {
+			return value.Type == gjson.Number && value.Float() == 0
+		}
+	}
+	return false
+}
+
+func isSet(value gjson.Result) bool {
+	switch value.Type {
+	case gjson.Null:
+		return false
+	case gjson.False:
+		return false
+	case gjson.Number:
+		return value.Float() != 0
+	case gjson.String:
+		return value.String() != ""
+	default:
+		return true
+	}
+}
+
+// normalizePayload re-encodes payload with sorted object keys and without transport-only fields.
+func normalizePayload(payload []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if obj, ok := v.(map[string]any); ok {
+		for _, field := range transportParams {
+			delete(obj, field)
+		}
+	}
+	return json.Marshal(v)
+}
+
+// matchPattern performs wildcard matching where '*' matches any substring.
+func matchPattern(pattern, value string) bool {
+	if pattern == "" {
+		return false
+	}
+	if !strings.Contains(pattern, "*") {
+		return pattern == value
+	}
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(value, parts[0]) {
+		return false
+	}
+	value = value[len(parts[0]):]
+	last := parts[len(parts)-1]
+	for _, segment := range parts[1 : len(parts)-1] {
+		idx := strings.Index(value, segment)
+		if idx < 0 {
+			return false
+		}
+		value = value[idx+len(segment):]
+	}
+	return strings.HasSuffix(value, last)
+}
+
+type memoryResponseItem struct {
+	key   string
+	entry *ResponseEntry
+}
+
+type memoryResponseBackend struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	order      *list.List
+	items      map[string]*list.Element
+}
+
+func newMemoryResponseBackend(maxEntries int, ttl time.Duration) *memoryResponseBackend {
+	return &memoryResponseBackend{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		order:      list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+func (b *memoryResponseBackend) get(key string) (*ResponseEntry, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	elem, ok := b.items[key]
+	if !ok {
+		return nil, false
+	}
+	item := elem.Value.(*memoryResponseItem)
+	if time.Since(item.entry.CreatedAt) > b.ttl {
+		b.order.Remove(elem)
+		delete(b.items, key)
+		return nil, false
+	}
+	b.order.MoveToFront(elem)
+	return item.entry, true
+}
+
+func (b *memoryResponseBackend) put(key string, entry *ResponseEntry) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if elem, ok := b.items[key]; ok {
+		elem.Value.(*memoryResponseItem).entry = entry
+		b.order.MoveToFront(elem)
+		return
+	}
+	b.items[key] = b.order.PushFront(&memoryResponseItem{key: key, entry: entry})
+	for b.order.Len() > b.maxEntries {
+		oldest := b.order.Back()
+		b.order.Remove(oldest)
+		delete(b.items, oldest.Value.(*memoryResponseItem).key)
+	}
+}
+
+func (b *memoryResponseBackend) close() {}
+
+type diskResponseItem struct {
+	key     string
+	created time.Time
+}
+
+// diskResponseBackend stores one JSON file per entry. An in-memory index keeps the files in
+// least recently used order so the directory stays within maxEntries, and expired files are
+// removed periodically instead of only when they are read.
+type diskResponseBackend struct {
+	dir        string
+	ttl        time.Duration
+	maxEntries int
+
+	mu    sync.Mutex
+	order list.List
+	items map[string]*list.Element
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func newDiskResponseBackend(dir string, maxEntries int, ttl time.Duration) *diskResponseBackend {
+	b := &diskResponseBackend{
+		dir:        dir,
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		items:      make(map[string]*list.Element),
+		stop:       make(chan struct{}),
+	}
+	b.load()
+	go b.sweepLoop()
+	return b
+}
+
+// load indexes the entries already on disk, oldest first, using the file modification time.
+func (b *diskResponseBackend) load() {
+	files, err := os.ReadDir(b.dir)
+	if err != nil {
+		return
+	}
+	items := make([]diskResponseItem, 0, len(files))
+	for _, file := range files {
+		key, ok := strings.CutSuffix(file.Name(), ".json")
+		if !ok || file.IsDir() {
+			continue
+		}
+		info, errInfo := file.Info()
+		if errInfo != nil {
+			continue
+		}
+		items = append(items, diskResponseItem{key: key, created: info.ModTime()})
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].created.Before(items[j].created) })
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i := range items {
+		b.items[items[i].key] = b.order.PushFront(&items[i])
+	}
+	b.evictLocked()
+}
+
+func (b *diskResponseBackend) sweepLoop() {
+	ticker := time.NewTicker(diskResponseSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+			b.sweep(time.Now())
+		}
+	}
+}
+
+// sweep removes the files of entries that expired before now.
+func (b *diskResponseBackend) sweep(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for elem := b.order.Back(); elem != nil; {
+		prev := elem.Prev()
+		if now.Sub(elem.Value.(*diskResponseItem).created) > b.ttl {
+			b.removeLocked(elem)
+		}
+		elem = prev
+	}
+}
+
+func (b *diskResponseBackend) close() {
+	b.stopOnce.Do(func() { close(b.stop) })
+}
+
+func (b *diskResponseBackend) evictLocked() {
+	for b.order.Len() > b.maxEntries {
+		b.removeLocked(b.order.Back())
+	}
+}
+
+func (b *diskResponseBackend) removeLocked(elem *list.Element) {
+	key := elem.Value.(*diskResponseItem).key
+	b.order.Remove(elem)
+	delete(b.items, key)
+	_ = os.Remove(b.path(key))
+}
+
+func (b *diskResponseBackend) forget(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if elem, ok := b.items[key]; ok {
+		b.removeLocked(elem)
+		return
+	}
+	_ = os.Remove(b.path(key))
+}
+
+func (b *diskResponseBackend) path(key string) string {
+	return filepath.Join(b.dir, key+".json")
+}
+
+func (b *diskResponseBackend) get(key string) (*ResponseEntry, bool) {
+	data, err := os.ReadFile(b.path(key))
+	if err != nil {
+		return nil, false
+	}
+	var entry ResponseEntry
+	if err = json.Unmarshal(data, &entry); err != nil || time.Since(entry.CreatedAt) > b.ttl {
+		b.forget(key)
+		return nil, false
+	}
+	b.mu.Lock()
+	if elem, ok := b.items[key]; ok {
+		b.order.MoveToFront(elem)
+	}
+	b.mu.Unlock()
+	return &entry, true
+}
+
+func (b *diskResponseBackend) put(key string, entry *ResponseEntry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(b.dir, key+".*.tmp")
+	if err != nil {
+		return
+	}
+	_, errWrite := tmp.Write(data)
+	errClose := tmp.Close()
+	if errWrite != nil || errClose != nil {
+		_ = os.Remove(tmp.Name())
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err = os.Rename(tmp.Name(), b.path(key)); err != nil {
+		_ = os.Remove(tmp.Name())
+		return
+	}
+	if elem, ok := b.items[key]; ok {
+		elem.Value.(*diskResponseItem).created = entry.CreatedAt
+		b.order.MoveToFront(elem)
+		return
+	}
+	b.items[key] = b.order.PushFront(&diskResponseItem{key: key, created: entry.CreatedAt})
+	b.evictLocked()
+}
@@ -0,0 +1,128 @@
+package cache
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestResponseCacheKey_NormalizesPayload(t *testing.T) {
+	c, err := NewResponseCache(config.ResponseCacheConfig{}, "")
+	if err != nil {
+		t.Fatalf("NewResponseCache: %v", err)
+	}
+	a, okA := c.Key(ResponseKey{Format: "openai", Model: "gpt-5", Payload: []byte(`{"model":"gpt-5","stream":true,"temperature":0,"messages":[{"role":"user","content":"hi"}]}`)})
+	b, okB := c.Key(ResponseKey{Format: "openai", Model: "gpt-5", Payload: []byte(`{"messages":[{"content":"hi","role":"user"}],"model":"gpt-5","temperature":0}`)})
+	if !okA || !okB || a != b {
+		t.Fatalf("keys differ for equivalent payloads: %q (%v) vs %q (%v)", a, okA, b, okB)
+	}
+	other, _ := c.Key(ResponseKey{Format: "claude", Model: "gpt-5", Payload: []byte(`{"model":"gpt-5","temperature":0}`)})
+	if other == a {
+		t.Fatal("expected different key for a different source format")
+	}
+}
+
+func TestResponseCacheKey_ExclusionRules(t *testing.T) {
+	c, _ := NewResponseCache(config.ResponseCacheConfig{Models: []string{"gpt-*"}, ExcludeParams: []string{"seed"}}, "")
+	cases := []struct {
+		name    string
+		model   string
+		payload string
+		want    bool
+	}{
+		{"deterministic", "gpt-5", `{"temperature":0}`, true},
+		{"sampled", "gpt-5", `{"temperature":0.7}`, false},
+		{"gemini sampled", "gpt-5", `{"generationConfig":{"temperature":1}}`, false},
+		{"gemini deterministic", "gpt-5", `{"generationConfig":{"temperature":0}}`, true},
+		{"default temperature", "gpt-5", `{"messages":[]}`, false},
+		{"custom rule", "gpt-5", `{"temperature":0,"seed":42}`, false},
+		{"model filtered", "claude-sonnet-4-5", `{"temperature":0}`, false},
+	}
+	for _, tc := range cases {
+		if _, ok := c.Key(ResponseKey{Model: tc.model, Payload: []byte(tc.payload)}); ok != tc.want {
+			t.Errorf("%s: cacheable = %v, want %v", tc.name, ok, tc.want)
+		}
+	}
+
+	sampled, _ := NewResponseCache(config.ResponseCacheConfig{CacheSampled: true}, "")
+	if _, ok := sampled.Key(ResponseKey{Model: "gpt-5", Payload: []byte(`{"temperature":0.7}`)}); !ok {
+		t.Error("cache-sampled should cache requests with a temperature")
+	}
+	if _, ok := sampled.Key(ResponseKey{Model: "gpt-5", Payload: []byte(`{"messages":[]}`)}); !ok {
+		t.Error("cache-sampled should cache requests without a temperature")
+	}
+}
+
+func TestResponseCache_MemoryEvictsLeastRecentlyUsed(t *testing.T) {
+	c, _ := NewResponseCache(config.ResponseCacheConfig{MaxEntries: 2}, "")
+	c.Put("a", &ResponseEntry{Body: []byte("a")})
+	c.Put("b", &ResponseEntry{Body: []byte("b")})
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("expected entry a")
+	}
+	c.Put("c", &ResponseEntry{Body: []byte("c")})
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("expected least recently used entry b to be evicted")
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("expected entry a to survive")
+	}
+}
+
+func TestResponseCache_DiskRoundTripAndExpiry(t *testing.T) {
+	dir := t.TempDir()
+	c, err := NewResponseCache(config.ResponseCacheConfig{Backend: "disk", TTLSeconds: 60}, dir)
+	if err != nil {
+		t.Fatalf("NewResponseCache: %v", err)
+	}
+	t.Cleanup(c.Close)
+	c.Put("live", &ResponseEntry{Model: "gpt-5", Chunks: [][]byte{[]byte("data: 1\n\n"), []byte("data: 2\n\n")}})
+	c.Put("stale", &ResponseEntry{Body: []byte("old"), CreatedAt: time.Now().Add(-time.Hour)})
+
+	reopened, _ := NewResponseCache(config.ResponseCacheConfig{Backend: "disk", TTLSeconds: 60}, dir)
+	t.Cleanup(reopened.Close)
+	entry, ok := reopened.Get("live")
+	if !ok || entry.Model != "gpt-5" || len(entry.Chunks) != 2 || string(entry.Chunks[1]) != "data: 2\n\n" {
+		t.Fatalf("unexpected disk entry: %+v (ok=%v)", entry, ok)
+	}
+	if _, ok = reopened.Get("stale"); ok {
+		t.Fatal("expected expired entry to be dropped")
+	}
+}
+
+func TestResponseCache_DiskBoundsEntriesAndSweepsExpired(t *testing.T) {
+	dir := t.TempDir()
+	c, err := NewResponseCache(config.ResponseCacheConfig{Backend: "disk", TTLSeconds: 60, MaxEntries: 2}, dir)
+	if err != nil {
+		t.Fatalf("NewResponseCache: %v", err)
+	}
+	t.Cleanup(c.Close)
+	c.Put("a", &ResponseEntry{Body: []byte("a")})
+	c.Put("b", &ResponseEntry{Body: []byte("b"), CreatedAt: time.Now().Add(-time.Hour)})
+	c.Put("c", &ResponseEntry{Body: []byte("c")})
+	if files := cacheFiles(t, dir); files != 2 {
+		t.Fatalf("files on disk = %d, want 2", files)
+	}
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("expected the oldest entry to be evicted")
+	}
+
+	c.backend.(*diskResponseBackend).sweep(time.Now())
+	if files := cacheFiles(t, dir); files != 1 {
+		t.Fatalf("files on disk after sweep = %d, want 1", files)
+	}
+	if _, ok := c.Get("c"); !ok {
+		t.Fatal("expected the live entry to survive the sweep")
+	}
+}
+
+func cacheFiles(t *testing.T, dir string) int {
+	t.Helper()
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	return len(files)
+}
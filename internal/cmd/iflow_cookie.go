@@ -9,6 +9,7 @@ import (
 	"strings"
 	"time"
 
+	baseauth "github.com/router-for-me/CLIProxyAPI/v6/internal/auth"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/iflow"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 )
@@ -66,7 +67,7 @@ func DoIFlowCookieAuth(cfg *config.Config, options *LoginOptions) {
 	authFilePath := getAuthFilePath(cfg, "iflow", tokenData.Email)
 
 	// Save token to file
-	if err := tokenStorage.SaveTokenToFile(authFilePath); err != nil {
+	if err := baseauth.SealTokenStorage(tokenStorage, authFilePath); err != nil {
 		fmt.Printf("Failed to save authentication: %v\n", err)
 		return
 	}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	sdkAuth "github.com/router-for-me/CLIProxyAPI/v6/sdk/auth"
+	log "github.com/sirupsen/logrus"
+)
+
+// DoRotateEncryptionKey re-encrypts every auth file under the configured auth directory with
+// the current encryption key. Plaintext files are encrypted and files sealed with a previous key
+// are decrypted with it and sealed again. Rewritten files are pushed to the active token store
+// when it mirrors the auth directory remotely.
+//
+// Parameters:
+//   - cfg: The application configuration
+func DoRotateEncryptionKey(cfg *config.Config) {
+	ring := authcrypt.Active()
+	if ring == nil {
+		log.Error("rotate-encryption-key: no encryption key configured; set CLIPROXY_ENCRYPTION_KEY or CLIPROXY_ENCRYPTION_KEY_FILE")
+		return
+	}
+	if cfg == nil || strings.TrimSpace(cfg.AuthDir) == "" {
+		log.Error("rotate-encryption-key: auth directory not configured")
+		return
+	}
+
+	var (
+		rewritten []string
+		skipped   int
+		failed    int
+	)
+	errWalk := filepath.WalkDir(cfg.AuthDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(strings.ToLower(d.Name()), ".json") {
+			return nil
+		}
+		changed, errRotate := authcrypt.ReencryptFile(path)
+		switch {
+		case errRotate != nil:
+			failed++
+			log.Errorf("rotate-encryption-key: %s: %v", path, errRotate)
+		case changed:
+			rewritten = append(rewritten, path)
+		default:
+			skipped++
+		}
+		return nil
+	})
+	if errWalk != nil {
+		log.Errorf("rotate-encryption-key: walk auth directory failed: %v", errWalk)
+		return
+	}
+
+	if len(rewritten) > 0 {
+		if persister, ok := sdkAuth.GetTokenStore().(interface {
+			PersistAuthFiles(ctx context.Context, message string, paths ...string) error
+		}); ok {
+			if errPersist := persister.PersistAuthFiles(context.Background(), "Rotate auth encryption key", rewritten...); errPersist != nil {
+				log.Errorf("rotate-encryption-key: persist rotated auth files failed: %v", errPersist)
+				return
+			}
+		}
+	}
+
+	fmt.Printf("Encryption key %s (%s): %d auth files re-encrypted, %d already current, %d failed\n",
+		ring.PrimaryKeyID(), ring.PrimaryKeyType(), len(rewritten), skipped, failed)
+}
@@ -10,6 +10,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 	"syscall"
 
@@ -64,6 +65,15 @@ type Config struct {
 	// UsageStatisticsEnabled toggles in-memory usage aggregation; when false, usage data is discarded.
 	UsageStatisticsEnabled bool `yaml:"usage-statistics-enabled" json:"usage-statistics-enabled"`
 
+	// UsageStatisticsStore configures durable persistence of usage records across restarts.
+	UsageStatisticsStore UsageStatisticsStoreConfig `yaml:"usage-statistics-store" json:"usage-statistics-store"`
+
+	// Metrics configures the Prometheus /metrics endpoint.
+	Metrics MetricsConfig `yaml:"metrics" json:"metrics"`
+
+	// Tracing configures OpenTelemetry trace export over OTLP/HTTP.
+	Tracing TracingConfig `yaml:"tracing" json:"tracing"`
+
 	// DisableCooling disables quota cooldown scheduling when true.
 	DisableCooling bool `yaml:"disable-cooling" json:"disable-cooling"`
 
@@ -78,6 +88,22 @@ type Config struct {
 	// Routing controls credential selection behavior.
 	Routing RoutingConfig `yaml:"routing" json:"routing"`
 
+	// AuthProbe configures scheduled health probes of credentials.
+	AuthProbe AuthProbeConfig `yaml:"auth-probe" json:"auth-probe"`
+
+	// Hedging configures duplicate attempts for slow non-streaming requests.
+	Hedging HedgingConfig `yaml:"hedging" json:"hedging"`
+
+	// LocalTokenCounting answers count-tokens requests with the local tokenizers for every
+	// provider instead of calling upstream counting endpoints.
+	LocalTokenCounting bool `yaml:"local-token-counting" json:"local-token-counting"`
+
+	// Batch configures the locally emulated OpenAI and Anthropic batch APIs.
+	Batch BatchConfig `yaml:"batch" json:"batch"`
+
+	// ResponsesStore configures local persistence of Responses API objects.
+	ResponsesStore ResponsesStoreConfig `yaml:"responses-store" json:"responses-store"`
+
 	// WebsocketAuth enables or disables authentication for the WebSocket API.
 	WebsocketAuth bool `yaml:"ws-auth" json:"ws-auth"`
 
@@ -119,6 +145,12 @@ type Config struct {
 	// gemini-api-key, codex-api-key, claude-api-key, openai-compatibility, vertex-api-key, and ampcode.
 	OAuthModelAlias map[string][]OAuthModelAlias `yaml:"oauth-model-alias,omitempty" json:"oauth-model-alias,omitempty"`
 
+	// ModelFallbacks maps a requested model to an ordered list of fallback models. When every
+	// credential serving the requested model is cooling down or fails with a retryable error
+	// (401/402/403/408/429/5xx), the request is retried against each fallback in turn, even across
+	// providers and request formats.
+	ModelFallbacks map[string][]string `yaml:"model-fallbacks,omitempty" json:"model-fallbacks,omitempty"`
+
 	// Payload defines default and override rules for provider payload parameters.
 	Payload PayloadConfig `yaml:"payload" json:"payload"`
 
@@ -171,11 +203,134 @@ type QuotaExceeded struct {
 	SwitchPreviewModel bool `yaml:"switch-preview-model" json:"switch-preview-model"`
 }
 
+// UsageStatisticsStoreConfig configures the embedded database that records every usage record.
+type UsageStatisticsStoreConfig struct {
+	// Enabled turns on durable usage persistence.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// Path is the database file location. When empty, "usage.db" under WRITABLE_PATH is used,
+	// or next to the config file when WRITABLE_PATH is not set.
+	Path string `yaml:"path,omitempty" json:"path,omitempty"`
+}
+
+// TracingConfig configures OpenTelemetry tracing.
+type TracingConfig struct {
+	// Enabled turns on span export.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// Endpoint is the OTLP/HTTP collector, either "host:port" or a full URL such as
+	// "http://localhost:4318/v1/traces". When empty the OTEL_EXPORTER_OTLP_* environment variables apply.
+	Endpoint string `yaml:"endpoint,omitempty" json:"endpoint,omitempty"`
+
+	// Insecure disables TLS when talking to the collector.
+	Insecure bool `yaml:"insecure,omitempty" json:"insecure,omitempty"`
+
+	// Headers are sent with every export request (e.g. collector API keys).
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+
+	// ServiceName overrides the reported service.name resource attribute.
+	ServiceName string `yaml:"service-name,omitempty" json:"service-name,omitempty"`
+
+	// SampleRatio samples this fraction of new traces; values outside (0,1) sample everything.
+	SampleRatio float64 `yaml:"sample-ratio,omitempty" json:"sample-ratio,omitempty"`
+}
+
+// MetricsConfig configures the Prometheus scrape endpoint.
+type MetricsConfig struct {
+	// Enabled exposes GET /metrics. The endpoint is unauthenticated, so restrict access at the network level.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+}
+
 // RoutingConfig configures how credentials are selected for requests.
 type RoutingConfig struct {
 	// Strategy selects the credential selection strategy.
-	// Supported values: "round-robin" (default), "fill-first".
+	// Supported values: "round-robin" (default), "fill-first", "least-load".
 	Strategy string `yaml:"strategy,omitempty" json:"strategy,omitempty"`
+
+	// MinQuotaHeadroomPercent skips credentials whose upstream-reported quota has this share or less
+	// remaining while other credentials still have room. Defaults to 5; a negative value disables it.
+	MinQuotaHeadroomPercent int `yaml:"min-quota-headroom-percent,omitempty" json:"min-quota-headroom-percent,omitempty"`
+
+	// SessionAffinity pins the requests of one conversation to the credential that served its
+	// first turn so upstream prompt caches keep hitting.
+	SessionAffinity SessionAffinityConfig `yaml:"session-affinity,omitempty" json:"session-affinity,omitempty"`
+}
+
+// SessionAffinityConfig configures conversation-scoped sticky routing. Conversations are
+// identified by the X-Session-Id header, Claude's metadata.user_id, the Responses API
+// previous_response_id chain, or a hash of the leading messages.
+type SessionAffinityConfig struct {
+	// Enabled turns sticky routing on.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// TTLSeconds is how long an idle conversation stays pinned. Defaults to 3600.
+	TTLSeconds int `yaml:"ttl-seconds,omitempty" json:"ttl-seconds,omitempty"`
+}
+
+// AuthProbeConfig configures synthetic probe requests that check whether credentials still work.
+type AuthProbeConfig struct {
+	// Enabled turns on the background prober. Probes requested through the management API run regardless.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// IntervalSeconds is the time between probe rounds. Defaults to 1800.
+	IntervalSeconds int `yaml:"interval-seconds,omitempty" json:"interval-seconds,omitempty"`
+
+	// TimeoutSeconds bounds a single probe request. Defaults to 30.
+	TimeoutSeconds int `yaml:"timeout-seconds,omitempty" json:"timeout-seconds,omitempty"`
+
+	// Models maps a provider (e.g. "codex", "gemini-cli") to the model its credentials are probed with.
+	// Providers without an entry are probed with the first chat model registered for the credential.
+	Models map[string]string `yaml:"models,omitempty" json:"models,omitempty"`
+
+	// DisableOnUnauthorized disables credentials whose probe is rejected with 401, e.g. revoked accounts.
+	DisableOnUnauthorized bool `yaml:"disable-on-unauthorized,omitempty" json:"disable-on-unauthorized,omitempty"`
+}
+
+// HedgingConfig configures request hedging for latency-sensitive non-streaming calls.
+// When the first attempt has not answered after the delay, the same request is issued on
+// another credential or provider; the first success wins and the other attempt is cancelled.
+type HedgingConfig struct {
+	// Enabled turns hedging on for non-streaming and count_tokens requests.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// DelayMs is how long the first attempt may run before the hedge is issued. Defaults to 2000.
+	DelayMs int `yaml:"delay-ms,omitempty" json:"delay-ms,omitempty"`
+
+	// Models lists the models to hedge; "*" matches any substring. Empty disables hedging.
+	Models []string `yaml:"models,omitempty" json:"models,omitempty"`
+}
+
+// BatchConfig configures the batch APIs (/v1/batches, /v1/files and /v1/messages/batches).
+// Batches are stored on disk and their items are executed in the background through the
+// normal credential pool.
+type BatchConfig struct {
+	// Enabled exposes the batch and file endpoints.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// Path is the directory holding uploaded files, batches and results. When empty,
+	// "batches" under WRITABLE_PATH or next to the config file is used.
+	Path string `yaml:"path,omitempty" json:"path,omitempty"`
+
+	// Concurrency bounds how many batch items run at once across all batches. Defaults to 4.
+	Concurrency int `yaml:"concurrency,omitempty" json:"concurrency,omitempty"`
+
+	// MaxAttempts is how many times an item failing with a retryable error is tried. Defaults to 3.
+	MaxAttempts int `yaml:"max-attempts,omitempty" json:"max-attempts,omitempty"`
+}
+
+// ResponsesStoreConfig configures the local store behind the stateful Responses API.
+// Stored responses back previous_response_id chaining on every backend and the
+// GET/DELETE /v1/responses/{id} endpoints.
+type ResponsesStoreConfig struct {
+	// Enabled stores responses created with store left unset or true.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// Path is the database file. When empty, "responses.db" under WRITABLE_PATH or next to
+	// the config file is used.
+	Path string `yaml:"path,omitempty" json:"path,omitempty"`
+
+	// TTLHours is how long a stored response is kept. Defaults to 720 (30 days).
+	TTLHours int `yaml:"ttl-hours,omitempty" json:"ttl-hours,omitempty"`
 }
 
 // OAuthModelAlias defines a model ID alias for a specific channel.
@@ -491,6 +646,17 @@ type OpenAICompatibility struct {
 	// Models defines the model configurations including aliases for routing.
 	Models []OpenAICompatibilityModel `yaml:"models" json:"models"`
 
+	// ExcludedModels lists model IDs (wildcards supported) that should not be registered.
+	ExcludedModels []string `yaml:"excluded-models,omitempty" json:"excluded-models,omitempty"`
+
+	// DiscoverModels periodically fetches the provider's GET /models list and registers the
+	// returned models in addition to Models. Entries in Models still provide aliases.
+	DiscoverModels bool `yaml:"discover-models,omitempty" json:"discover-models,omitempty"`
+
+	// DiscoverIntervalSeconds controls how often the model list is refreshed when DiscoverModels
+	// is enabled. <= 0 uses the default of 3600 seconds.
+	DiscoverIntervalSeconds int `yaml:"discover-interval-seconds,omitempty" json:"discover-interval-seconds,omitempty"`
+
 	// Headers optionally adds extra HTTP headers for requests sent to this provider.
 	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
 }
@@ -662,6 +828,12 @@ func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
 	// Validate raw payload rules and drop invalid entries.
 	cfg.SanitizePayloadRules()
 
+	// Normalize context-window strategies and drop unknown names.
+	cfg.SanitizeContextWindow()
+
+	// Normalize model fallback keys so lookups ignoring case are deterministic.
+	cfg.SanitizeModelFallbacks()
+
 	// NOTE: Legacy migration persistence is intentionally disabled together with
 	// startup legacy migration to keep startup read-only for config.yaml.
 	// Re-enable the block below if automatic startup migration is needed again.
@@ -736,6 +908,65 @@ func payloadRawString(value any) ([]byte, bool) {
 	}
 }
 
+// contextWindowStrategies are the strategy names understood by the context-window stage.
+var contextWindowStrategies = map[string]struct{}{
+	"truncate-tool-outputs": {},
+	"drop-oldest":           {},
+	"summarize":             {},
+}
+
+// SanitizeContextWindow normalizes context-window strategy names and drops unknown ones, so a
+// typo is reported once at load time instead of on every request.
+func (cfg *Config) SanitizeContextWindow() {
+	if cfg == nil || len(cfg.ContextWindow.Strategies) == 0 {
+		return
+	}
+	out := make([]string, 0, len(cfg.ContextWindow.Strategies))
+	for _, strategy := range cfg.ContextWindow.Strategies {
+		name := strings.ToLower(strings.TrimSpace(strategy))
+		if _, ok := contextWindowStrategies[name]; !ok {
+			log.WithField("strategy", strategy).Warn("context-window strategy dropped: unknown name")
+			continue
+		}
+		out = append(out, name)
+	}
+	cfg.ContextWindow.Strategies = out
+}
+
+// SanitizeModelFallbacks lower-cases and trims model fallback keys and drops empty entries.
+// When several keys only differ in case, the first in sorted order is kept.
+func (cfg *Config) SanitizeModelFallbacks() {
+	if cfg == nil || len(cfg.ModelFallbacks) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(cfg.ModelFallbacks))
+	for key := range cfg.ModelFallbacks {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	out := make(map[string][]string, len(keys))
+	for _, rawKey := range keys {
+		key := strings.ToLower(strings.TrimSpace(rawKey))
+		if key == "" {
+			continue
+		}
+		if _, exists := out[key]; exists {
+			log.WithField("model", rawKey).Warn("model-fallbacks entry dropped: duplicate model")
+			continue
+		}
+		chain := make([]string, 0, len(cfg.ModelFallbacks[rawKey]))
+		for _, entry := range cfg.ModelFallbacks[rawKey] {
+			if entry = strings.TrimSpace(entry); entry != "" {
+				chain = append(chain, entry)
+			}
+		}
+		if len(chain) > 0 {
+			out[key] = chain
+		}
+	}
+	cfg.ModelFallbacks = out
+}
+
 // SanitizeOAuthModelAlias normalizes and deduplicates global OAuth model name aliases.
 // It trims whitespace, normalizes channel keys to lower-case, drops empty entries,
 // allows multiple aliases per upstream name, and ensures aliases are unique within each channel.
@@ -23,12 +23,148 @@ type SDKConfig struct {
 	// Access holds request authentication provider configuration.
 	Access AccessConfig `yaml:"auth,omitempty" json:"auth,omitempty"`
 
+	// APIKeyLimits configures per-client-key request rate, stream concurrency and token budgets.
+	APIKeyLimits []APIKeyLimit `yaml:"api-key-limits,omitempty" json:"api-key-limits,omitempty"`
+
 	// Streaming configures server-side streaming behavior (keep-alives and safe bootstrap retries).
 	Streaming StreamingConfig `yaml:"streaming" json:"streaming"`
 
 	// NonStreamKeepAliveInterval controls how often blank lines are emitted for non-streaming responses.
 	// <= 0 disables keep-alives. Value is in seconds.
 	NonStreamKeepAliveInterval int `yaml:"nonstream-keepalive-interval,omitempty" json:"nonstream-keepalive-interval,omitempty"`
+
+	// ResponseCache configures the optional cache replaying identical completions without an upstream call.
+	ResponseCache ResponseCacheConfig `yaml:"response-cache,omitempty" json:"response-cache,omitempty"`
+
+	// Guardrails lists content policies applied to request payloads before they are sent
+	// upstream and to model output before it is returned to the client.
+	Guardrails []GuardrailPolicy `yaml:"guardrails,omitempty" json:"guardrails,omitempty"`
+
+	// ResponsesCompact configures /v1/responses/compact for backends without native compaction.
+	ResponsesCompact ResponsesCompactConfig `yaml:"responses-compact,omitempty" json:"responses-compact,omitempty"`
+
+	// ContextWindow configures the pre-flight check fitting oversize conversations into the
+	// context window of the requested model.
+	ContextWindow ContextWindowConfig `yaml:"context-window,omitempty" json:"context-window,omitempty"`
+
+	// StructuredOutputs configures response_format emulation for upstreams that ignore it.
+	StructuredOutputs StructuredOutputsConfig `yaml:"structured-outputs,omitempty" json:"structured-outputs,omitempty"`
+}
+
+// GuardrailPolicy describes a content policy enforced by the proxy.
+type GuardrailPolicy struct {
+	// Name identifies the policy in logs and violation errors.
+	Name string `yaml:"name" json:"name"`
+
+	// APIKeys restricts the policy to the listed client keys. Empty or "*" applies it to every key.
+	APIKeys []string `yaml:"api-keys,omitempty" json:"api-keys,omitempty"`
+
+	// Direction selects what is inspected: "request", "response" or "both" (default).
+	Direction string `yaml:"direction,omitempty" json:"direction,omitempty"`
+
+	// Action is "block" (default) to reject the request or stop the response, or "redact"
+	// to mask matches with a placeholder.
+	Action string `yaml:"action,omitempty" json:"action,omitempty"`
+
+	// Detectors enables built-in detectors such as "aws-access-key", "private-key" or "email".
+	// The groups "secrets" and "pii" enable all detectors of that kind.
+	Detectors []string `yaml:"detectors,omitempty" json:"detectors,omitempty"`
+
+	// Patterns lists additional regular expressions (RE2 syntax).
+	Patterns []string `yaml:"patterns,omitempty" json:"patterns,omitempty"`
+
+	// Keywords lists case-insensitive words or phrases that trigger the policy.
+	Keywords []string `yaml:"keywords,omitempty" json:"keywords,omitempty"`
+}
+
+// ResponsesCompactConfig holds the /v1/responses/compact emulation settings. Emulated
+// compaction summarizes the conversation through the regular pipeline, so it works with
+// every provider.
+type ResponsesCompactConfig struct {
+	// Mode is "auto" (default) to emulate compaction for models served by providers without a
+	// native compact endpoint, "emulate" to always emulate it, or "native" to always forward
+	// the request to the provider.
+	Mode string `yaml:"mode,omitempty" json:"mode,omitempty"`
+
+	// Model summarizes the conversation instead of the requested model when set.
+	Model string `yaml:"model,omitempty" json:"model,omitempty"`
+
+	// Prompt replaces the built-in summarization instructions.
+	Prompt string `yaml:"prompt,omitempty" json:"prompt,omitempty"`
+}
+
+// ContextWindowConfig holds the context-window management settings. Requests whose prompt
+// exceeds the smallest context length among the providers serving the model are shortened in
+// the client's format before they are sent upstream.
+type ContextWindowConfig struct {
+	// Enabled toggles the pre-flight token count. Default is false.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// Strategies are applied in order until the request fits: "truncate-tool-outputs",
+	// "drop-oldest" and "summarize". Empty uses truncate-tool-outputs then drop-oldest.
+	Strategies []string `yaml:"strategies,omitempty" json:"strategies,omitempty"`
+
+	// MaxToolOutputChars bounds each tool output kept by truncate-tool-outputs.
+	// <= 0 uses the default of 8000.
+	MaxToolOutputChars int `yaml:"max-tool-output-chars,omitempty" json:"max-tool-output-chars,omitempty"`
+
+	// ReserveTokens is kept free for the response when the request sets no output limit.
+	// <= 0 uses the default of 4096.
+	ReserveTokens int `yaml:"reserve-tokens,omitempty" json:"reserve-tokens,omitempty"`
+
+	// SummaryModel writes the summary for the summarize strategy, which is skipped when empty.
+	// Pick a cheap model with a large context window.
+	SummaryModel string `yaml:"summary-model,omitempty" json:"summary-model,omitempty"`
+}
+
+// StructuredOutputsConfig holds the structured outputs emulation settings. Emulation asks the
+// model for JSON matching the requested schema, validates the answer and retries with the
+// violations until it conforms, so OpenAI clients get schema-valid JSON from every provider.
+type StructuredOutputsConfig struct {
+	// Mode is "auto" (default) to emulate structured outputs for models served by providers
+	// that do not enforce response_format, "emulate" to always emulate them, or "native" to
+	// always forward response_format to the provider.
+	Mode string `yaml:"mode,omitempty" json:"mode,omitempty"`
+
+	// Strategy selects how the schema reaches the model: "instructions" (default) adds it to
+	// the system prompt, "tool" forces a call of a function taking the schema as parameters.
+	// Requests carrying their own tools always use instructions.
+	Strategy string `yaml:"strategy,omitempty" json:"strategy,omitempty"`
+
+	// MaxAttempts bounds the requests made until the answer matches the schema.
+	// <= 0 uses the default of 3.
+	MaxAttempts int `yaml:"max-attempts,omitempty" json:"max-attempts,omitempty"`
+}
+
+// ResponseCacheConfig holds the response cache settings.
+type ResponseCacheConfig struct {
+	// Enabled toggles the response cache. Default is false.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// Backend selects the storage: "memory" (default) or "disk".
+	Backend string `yaml:"backend,omitempty" json:"backend,omitempty"`
+
+	// Path is the directory used by the disk backend. When empty, "response-cache"
+	// under WRITABLE_PATH (or next to the config file) is used.
+	Path string `yaml:"path,omitempty" json:"path,omitempty"`
+
+	// TTLSeconds controls how long entries are served. <= 0 uses the default of 24 hours.
+	TTLSeconds int `yaml:"ttl-seconds,omitempty" json:"ttl-seconds,omitempty"`
+
+	// MaxEntries bounds the number of cached entries in either backend; least recently used
+	// entries are evicted first. <= 0 uses the default of 1000.
+	MaxEntries int `yaml:"max-entries,omitempty" json:"max-entries,omitempty"`
+
+	// Models restricts caching to matching model names ('*' wildcards). Empty caches every model.
+	Models []string `yaml:"models,omitempty" json:"models,omitempty"`
+
+	// CacheSampled also caches sampled requests. By default only requests sending a temperature
+	// of 0 are cached; a missing temperature means the upstream default and bypasses the cache.
+	CacheSampled bool `yaml:"cache-sampled,omitempty" json:"cache-sampled,omitempty"`
+
+	// ExcludeParams lists additional request JSON paths marking a request as non-deterministic:
+	// the request bypasses the cache when any path holds a value other than 0, false, null or "".
+	ExcludeParams []string `yaml:"exclude-params,omitempty" json:"exclude-params,omitempty"`
 }
 
 // StreamingConfig holds server streaming behavior configuration.
@@ -41,6 +177,34 @@ type StreamingConfig struct {
 	// to allow auth rotation / transient recovery.
 	// <= 0 disables bootstrap retries. Default is 0.
 	BootstrapRetries int `yaml:"bootstrap-retries,omitempty" json:"bootstrap-retries,omitempty"`
+
+	// FailoverRetries controls how many times a stream that fails after bytes were sent is continued
+	// on another credential, with the partial assistant output sent along as a prefill.
+	// Supported for OpenAI chat completions, Claude messages and Gemini clients, and only continued
+	// on Claude credentials and on Antigravity credentials serving Claude models, whose upstream
+	// resumes prefills; OpenAI Responses streams are not resumed. When enabled, error events inside
+	// an Antigravity stream fail the stream instead of being passed through to the client.
+	// <= 0 disables mid-stream failover. Default is 0.
+	FailoverRetries int `yaml:"failover-retries,omitempty" json:"failover-retries,omitempty"`
+}
+
+// APIKeyLimit describes usage limits applied to a single client API key.
+// Zero values leave the corresponding limit disabled.
+type APIKeyLimit struct {
+	// APIKey is the client key the limits apply to. "*" matches every key without its own entry.
+	APIKey string `yaml:"api-key" json:"api-key"`
+
+	// RequestsPerMinute caps requests accepted within a sliding one-minute window.
+	RequestsPerMinute int `yaml:"requests-per-minute,omitempty" json:"requests-per-minute,omitempty"`
+
+	// MaxConcurrentStreams caps simultaneously open streaming requests.
+	MaxConcurrentStreams int `yaml:"max-concurrent-streams,omitempty" json:"max-concurrent-streams,omitempty"`
+
+	// DailyTokenBudget caps total tokens consumed per UTC day.
+	DailyTokenBudget int64 `yaml:"daily-token-budget,omitempty" json:"daily-token-budget,omitempty"`
+
+	// MonthlyTokenBudget caps total tokens consumed per UTC calendar month.
+	MonthlyTokenBudget int64 `yaml:"monthly-token-budget,omitempty" json:"monthly-token-budget,omitempty"`
 }
 
 // AccessConfig groups request authentication providers.
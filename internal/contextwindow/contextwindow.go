@@ -0,0 +1,407 @@
+// Package contextwindow fits oversize conversations into a model's context window before they
+// are sent upstream. It works on the request in the client's own format (Claude messages,
+// OpenAI chat, OpenAI Responses and Gemini), so the translators downstream are unaffected,
+// and it never separates a tool call from the result answering it.
+package contextwindow
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	// StrategyTruncateToolOutputs shortens large tool outputs, keeping their start and end.
+	StrategyTruncateToolOutputs = "truncate-tool-outputs"
+
+	// StrategyDropOldest removes the oldest turns, keeping the opening user message.
+	StrategyDropOldest = "drop-oldest"
+
+	// StrategySummarize replaces the middle of the conversation with a summary.
+	StrategySummarize = "summarize"
+
+	// DefaultMaxToolOutputChars bounds each tool output when no limit is configured.
+	DefaultMaxToolOutputChars = 8000
+
+	// maxTranscriptChars bounds the transcript handed to the summarizer; the oldest entries
+	// are dropped first.
+	maxTranscriptChars = 400_000
+)
+
+// DefaultStrategies are applied when none are configured.
+var DefaultStrategies = []string{StrategyTruncateToolOutputs, StrategyDropOldest}
+
+// SummaryPrefix introduces the summary that replaces the middle of a conversation.
+const SummaryPrefix = "The earlier part of this conversation was condensed to fit the context window. Summary of it:"
+
+// Options configures Fit.
+type Options struct {
+	// Budget is the number of prompt tokens the request must fit into.
+	Budget int64
+
+	// Strategies are applied in order until the request fits. Empty uses DefaultStrategies.
+	Strategies []string
+
+	// MaxToolOutputChars bounds each tool output for StrategyTruncateToolOutputs.
+	// <= 0 uses DefaultMaxToolOutputChars.
+	MaxToolOutputChars int
+
+	// Count returns the prompt tokens of a payload in the conversation's format.
+	Count func(payload []byte) (int64, error)
+
+	// Summarize condenses a plain-text transcript. StrategySummarize is skipped when nil.
+	Summarize func(transcript string) (string, error)
+
+	// Summaries caches the summaries produced by Summarize across requests. Optional.
+	Summaries *SummaryCache
+}
+
+// Result describes the outcome of Fit.
+type Result struct {
+	// Payload is the request to send, unchanged when it already fits.
+	Payload []byte
+
+	// Before and After are the prompt tokens of the original and of the returned payload.
+	Before int64
+	After  int64
+
+	// Applied lists the strategies that changed the payload, in order.
+	Applied []string
+
+	// Fits reports whether the returned payload is within the budget.
+	Fits bool
+}
+
+// fitter carries the payload through the strategies.
+type fitter struct {
+	format string
+	opts   Options
+	result Result
+}
+
+// Fit counts the prompt tokens of payload and, when they exceed opts.Budget, applies the
+// configured strategies in order until the request fits. Formats without a message list are
+// returned unchanged. A strategy that fails is skipped and the next one is tried; the failures
+// are returned joined together with the result of the remaining strategies.
+func Fit(format string, payload []byte, opts Options) (Result, error) {
+	f := &fitter{format: format, opts: opts, result: Result{Payload: payload}}
+	tokens, err := opts.Count(payload)
+	if err != nil {
+		return f.result, err
+	}
+	f.result.Before, f.result.After = tokens, tokens
+	if f.fits() || parse(format, payload) == nil {
+		f.result.Fits = f.fits()
+		return f.result, nil
+	}
+
+	strategies := opts.Strategies
+	if len(strategies) == 0 {
+		strategies = DefaultStrategies
+	}
+	var errs []error
+	for _, strategy := range strategies {
+		switch strings.ToLower(strings.TrimSpace(strategy)) {
+		case StrategyTruncateToolOutputs:
+			err = f.truncateToolOutputs()
+		case StrategyDropOldest:
+			err = f.dropOldest()
+		case StrategySummarize:
+			err = f.summarize()
+		default:
+			err = fmt.Errorf("context window: unknown strategy %q", strategy)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if f.fits() {
+			break
+		}
+	}
+	f.result.Fits = f.fits()
+	return f.result, errors.Join(errs...)
+}
+
+func (f *fitter) fits() bool {
+	return f.result.After <= f.opts.Budget
+}
+
+// apply counts a changed payload and records it together with the strategy that produced it.
+func (f *fitter) apply(strategy string, payload []byte) error {
+	tokens, err := f.opts.Count(payload)
+	if err != nil {
+		return err
+	}
+	f.record(strategy, payload, tokens)
+	return nil
+}
+
+// record stores a changed payload of known size together with the strategy that produced it.
+func (f *fitter) record(strategy string, payload []byte, tokens int64) {
+	f.result.Payload, f.result.After = payload, tokens
+	if n := len(f.result.Applied); n == 0 || f.result.Applied[n-1] != strategy {
+		f.result.Applied = append(f.result.Applied, strategy)
+	}
+}
+
+// truncateToolOutputs shortens tool outputs above the configured size. Outputs of the latest
+// turn are only shortened when truncating the older ones is not enough.
+func (f *fitter) truncateToolOutputs() error {
+	limit := f.opts.MaxToolOutputChars
+	if limit <= 0 {
+		limit = DefaultMaxToolOutputChars
+	}
+	for _, includeLatest := range []bool{false, true} {
+		c := parse(f.format, f.result.Payload)
+		if c == nil || len(c.units) == 0 {
+			return nil
+		}
+		latest := make(map[int]bool)
+		if !includeLatest {
+			for _, i := range c.units[len(c.units)-1] {
+				latest[i] = true
+			}
+		}
+		replaced := make(map[int]string)
+		for i, item := range c.items {
+			if c.kinds[i] != kindToolResult || latest[i] {
+				continue
+			}
+			raw, changed := item.Raw, false
+			for _, path := range toolOutputPaths(f.format, item) {
+				value, truncated := truncateValue(gjson.Get(raw, path), limit)
+				if !truncated {
+					continue
+				}
+				if updated, err := sjson.SetRaw(raw, path, value); err == nil {
+					raw, changed = updated, true
+				}
+			}
+			if changed {
+				replaced[i] = raw
+			}
+		}
+		if len(replaced) == 0 {
+			continue
+		}
+		payload, err := c.rebuild(nil, "", replaced)
+		if err != nil {
+			return err
+		}
+		if err = f.apply(StrategyTruncateToolOutputs, payload); err != nil {
+			return err
+		}
+		if f.fits() {
+			return nil
+		}
+	}
+	return nil
+}
+
+// dropOldest removes whole turns from the oldest on, keeping the opening user message and the
+// latest turn, and leaves a note where turns were removed. The number of turns to drop is
+// estimated from their share of the payload size and corrected after each count.
+func (f *fitter) dropOldest() error {
+	c := parse(f.format, f.result.Payload)
+	if c == nil {
+		return nil
+	}
+	first := c.firstProtected()
+	droppable := c.units[first:max(first, len(c.units)-1)]
+	if len(droppable) == 0 {
+		return nil
+	}
+
+	dropped := 0
+	for dropped < len(droppable) && !f.fits() {
+		over := float64(f.result.After - f.opts.Budget)
+		tokensPerByte := float64(f.result.After) / float64(max(len(f.result.Payload), 1))
+		estimate := 0.0
+		for dropped < len(droppable) && (estimate < over || estimate == 0) {
+			estimate += float64(c.unitSize(droppable[dropped])) * tokensPerByte
+			dropped++
+		}
+
+		removed := make(map[int]bool)
+		for _, unit := range droppable[:dropped] {
+			for _, i := range unit {
+				removed[i] = true
+			}
+		}
+		note := fmt.Sprintf("[%d earlier messages were removed to fit the context window.]", len(removed))
+		payload, err := c.rebuild(removed, note, nil)
+		if err != nil {
+			return err
+		}
+		if err = f.apply(StrategyDropOldest, payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// summarize replaces the turns between the opening user message and the most recent turns
+// with a summary. The recent turns kept verbatim take up to half of the budget. A cached
+// summary of the oldest of these turns is reused as is when the request then fits, and
+// otherwise only extended by the turns after it.
+func (f *fitter) summarize() error {
+	if f.opts.Summarize == nil {
+		return nil
+	}
+	c := parse(f.format, f.result.Payload)
+	if c == nil {
+		return nil
+	}
+	first := c.firstProtected()
+	tokensPerByte := float64(f.result.After) / float64(max(len(f.result.Payload), 1))
+	keepFrom, recent := len(c.units)-1, 0.0
+	for keepFrom > first {
+		size := float64(c.unitSize(c.units[keepFrom-1])) * tokensPerByte
+		if recent+size > float64(f.opts.Budget)/2 {
+			break
+		}
+		recent += size
+		keepFrom--
+	}
+	middle := c.units[first:max(first, keepFrom)]
+	if len(middle) == 0 {
+		return nil
+	}
+
+	keys := c.unitKeys(middle)
+	cached, summarized := "", 0
+	for k := len(middle); k > 0; k-- {
+		if summary, ok := f.opts.Summaries.get(keys[k-1]); ok {
+			cached, summarized = summary, k
+			break
+		}
+	}
+	if summarized > 0 {
+		payload, err := c.rebuild(unitItems(middle[:summarized]), SummaryPrefix+"\n"+cached, nil)
+		if err != nil {
+			return err
+		}
+		tokens, err := f.opts.Count(payload)
+		if err != nil {
+			return err
+		}
+		if summarized == len(middle) || tokens <= f.opts.Budget {
+			f.record(StrategySummarize, payload, tokens)
+			return nil
+		}
+	}
+
+	var entries []string
+	if summarized > 0 {
+		entries = append(entries, "summary of the turns before: "+cached)
+	}
+	for _, unit := range middle[summarized:] {
+		for _, i := range unit {
+			if entry := transcriptEntry(c.kinds[i], c.items[i]); entry != "" {
+				entries = append(entries, entry)
+			}
+		}
+	}
+	summary, err := f.opts.Summarize(JoinTranscript(entries, maxTranscriptChars))
+	if err != nil {
+		return fmt.Errorf("context window: summarize: %w", err)
+	}
+	summary = strings.TrimSpace(summary)
+	if summary == "" {
+		return fmt.Errorf("context window: summarize: empty summary")
+	}
+	f.opts.Summaries.put(keys[len(keys)-1], summary)
+	payload, err := c.rebuild(unitItems(middle), SummaryPrefix+"\n"+summary, nil)
+	if err != nil {
+		return err
+	}
+	return f.apply(StrategySummarize, payload)
+}
+
+// unitItems returns the set of item indices in units.
+func unitItems(units [][]int) map[int]bool {
+	items := make(map[int]bool)
+	for _, unit := range units {
+		for _, i := range unit {
+			items[i] = true
+		}
+	}
+	return items
+}
+
+// truncateValue shortens the strings of a JSON value that exceed limit and returns the new raw
+// JSON. Images, files and other binary content are left alone.
+func truncateValue(value gjson.Result, limit int) (string, bool) {
+	switch {
+	case value.Type == gjson.String:
+		text := value.String()
+		if len(text) <= limit {
+			return value.Raw, false
+		}
+		raw, err := json.Marshal(truncateText(text, limit))
+		if err != nil {
+			return value.Raw, false
+		}
+		return string(raw), true
+	case value.IsArray() || value.IsObject():
+		if value.IsObject() {
+			contentType := value.Get("type").String()
+			if strings.Contains(contentType, "image") || strings.Contains(contentType, "file") || strings.Contains(contentType, "document") {
+				return value.Raw, false
+			}
+		}
+		raw, changed := value.Raw, false
+		index := 0
+		value.ForEach(func(key, child gjson.Result) bool {
+			path := strconv.Itoa(index)
+			if value.IsObject() {
+				if key.String() == "data" {
+					return true
+				}
+				path = escapePathKey(key.String())
+			}
+			index++
+			if updated, truncated := truncateValue(child, limit); truncated {
+				if next, err := sjson.SetRaw(raw, path, updated); err == nil {
+					raw, changed = next, true
+				}
+			}
+			return true
+		})
+		return raw, changed
+	}
+	return value.Raw, false
+}
+
+// truncateText keeps the start and the end of text within limit bytes, with a marker in between.
+func truncateText(text string, limit int) string {
+	head := limit * 2 / 3
+	tail := limit - head
+	for head > 0 && !utf8.RuneStart(text[head]) {
+		head--
+	}
+	cut := len(text) - tail
+	for cut < len(text) && !utf8.RuneStart(text[cut]) {
+		cut++
+	}
+	return fmt.Sprintf("%s\n\n[... %d characters truncated to fit the context window ...]\n\n%s", text[:head], utf8.RuneCountInString(text[head:cut]), text[cut:])
+}
+
+// escapePathKey escapes an object key for use as an sjson path component.
+func escapePathKey(key string) string {
+	var b strings.Builder
+	for _, r := range key {
+		switch r {
+		case '.', '*', '?', '|', '#', '@', '\\', ':':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
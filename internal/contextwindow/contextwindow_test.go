@@ -0,0 +1,256 @@
+package contextwindow
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+// byteCounter approximates four bytes per token.
+func byteCounter(payload []byte) (int64, error) {
+	return int64(len(payload) / 4), nil
+}
+
+func claudeAgentLoop(rounds, outputSize int) []byte {
+	messages := []string{`{"role":"user","content":"Fix the failing build."}`}
+	for i := 0; i < rounds; i++ {
+		messages = append(messages,
+			fmt.Sprintf(`{"role":"assistant","content":[{"type":"text","text":"Step %d"},{"type":"tool_use","id":"toolu_%d","name":"bash","input":{"command":"make"}}]}`, i, i),
+			fmt.Sprintf(`{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_%d","content":"%s"}]}`, i, strings.Repeat("x", outputSize)))
+	}
+	return []byte(`{"model":"qwen3-coder-plus","max_tokens":1024,"system":"You are a coding agent.","messages":[` + strings.Join(messages, ",") + `]}`)
+}
+
+// assertClaudeToolPairs fails when a tool_use is not answered by the next message or a
+// tool_result has no matching tool_use right before it.
+func assertClaudeToolPairs(t *testing.T, payload []byte) {
+	t.Helper()
+	messages := gjson.GetBytes(payload, "messages").Array()
+	for i, message := range messages {
+		for _, block := range message.Get("content").Array() {
+			switch block.Get("type").String() {
+			case "tool_use":
+				id := block.Get("id").String()
+				if i+1 >= len(messages) || !strings.Contains(messages[i+1].Raw, `"tool_use_id":"`+id+`"`) {
+					t.Fatalf("tool_use %s is not followed by its result", id)
+				}
+			case "tool_result":
+				id := block.Get("tool_use_id").String()
+				if i == 0 || !strings.Contains(messages[i-1].Raw, `"id":"`+id+`"`) {
+					t.Fatalf("tool_result %s has no preceding tool_use", id)
+				}
+			}
+		}
+	}
+}
+
+func TestFit_UnderBudgetIsUnchanged(t *testing.T) {
+	payload := claudeAgentLoop(2, 10)
+	result, err := Fit("claude", payload, Options{Budget: 100_000, Count: byteCounter})
+	if err != nil {
+		t.Fatalf("Fit: %v", err)
+	}
+	if !result.Fits || len(result.Applied) != 0 || string(result.Payload) != string(payload) {
+		t.Fatalf("unexpected result %+v", result)
+	}
+}
+
+func TestFit_ClaudeTruncatesToolOutputsFirst(t *testing.T) {
+	payload := claudeAgentLoop(4, 20_000)
+	result, err := Fit("claude", payload, Options{Budget: 7_500, MaxToolOutputChars: 2_000, Count: byteCounter})
+	if err != nil {
+		t.Fatalf("Fit: %v", err)
+	}
+	if !result.Fits || len(result.Applied) != 1 || result.Applied[0] != StrategyTruncateToolOutputs {
+		t.Fatalf("expected truncation alone to fit, got %+v (after %d)", result.Applied, result.After)
+	}
+	messages := gjson.GetBytes(result.Payload, "messages").Array()
+	if len(messages) != 9 {
+		t.Fatalf("truncation must not drop messages, got %d", len(messages))
+	}
+	first := messages[2].Get("content.0.content").String()
+	if len(first) > 2_100 || !strings.Contains(first, "characters truncated") {
+		t.Fatalf("old tool output was not truncated: %d bytes", len(first))
+	}
+	if latest := messages[8].Get("content.0.content").String(); len(latest) != 20_000 {
+		t.Fatalf("latest tool output should be kept while older ones suffice, got %d bytes", len(latest))
+	}
+	assertClaudeToolPairs(t, result.Payload)
+}
+
+func TestFit_ClaudeDropsOldestTurnsKeepingPairs(t *testing.T) {
+	payload := claudeAgentLoop(20, 2_000)
+	result, err := Fit("claude", payload, Options{Budget: 3_000, Strategies: []string{StrategyDropOldest}, Count: byteCounter})
+	if err != nil {
+		t.Fatalf("Fit: %v", err)
+	}
+	if !result.Fits || result.Before <= result.After {
+		t.Fatalf("expected the request to fit, got %+v", result)
+	}
+	messages := gjson.GetBytes(result.Payload, "messages").Array()
+	if messages[0].Get("content").String() != "Fix the failing build." {
+		t.Fatalf("opening user message must be kept, got %s", messages[0].Raw)
+	}
+	if !strings.Contains(messages[1].Get("content").String(), "earlier messages were removed") {
+		t.Fatalf("expected a note where turns were removed, got %s", messages[1].Raw)
+	}
+	if last := messages[len(messages)-1].Get("content.0.tool_use_id").String(); last != "toolu_19" {
+		t.Fatalf("latest turn must be kept, got %s", last)
+	}
+	assertClaudeToolPairs(t, result.Payload)
+}
+
+func TestFit_ResponsesKeepsPinnedItemsAndCallOutputs(t *testing.T) {
+	items := []string{
+		`{"type":"message","role":"developer","content":"Be careful."}`,
+		`{"type":"message","role":"user","content":[{"type":"input_text","text":"Refactor the parser."}]}`,
+	}
+	for i := 0; i < 10; i++ {
+		items = append(items,
+			`{"type":"reasoning","summary":[]}`,
+			fmt.Sprintf(`{"type":"function_call","call_id":"call_%d","name":"shell","arguments":"{}"}`, i),
+			fmt.Sprintf(`{"type":"function_call_output","call_id":"call_%d","output":"%s"}`, i, strings.Repeat("y", 1_000)))
+	}
+	payload := []byte(`{"model":"m","input":[` + strings.Join(items, ",") + `]}`)
+	result, err := Fit("openai-response", payload, Options{Budget: 1_000, Strategies: []string{StrategyDropOldest}, Count: byteCounter})
+	if err != nil {
+		t.Fatalf("Fit: %v", err)
+	}
+	input := gjson.GetBytes(result.Payload, "input").Array()
+	if !result.Fits || input[0].Get("role").String() != "developer" || input[1].Get("role").String() != "user" {
+		t.Fatalf("pinned and opening items must be kept: %s", gjson.GetBytes(result.Payload, "input").Raw)
+	}
+	calls := map[string]int{}
+	for _, item := range input {
+		switch item.Get("type").String() {
+		case "function_call":
+			calls[item.Get("call_id").String()]++
+		case "function_call_output":
+			calls[item.Get("call_id").String()]--
+		}
+	}
+	for id, balance := range calls {
+		if balance != 0 {
+			t.Fatalf("call %s lost its pair", id)
+		}
+	}
+	if _, ok := calls["call_9"]; !ok {
+		t.Fatalf("latest call must be kept")
+	}
+}
+
+func TestFit_GeminiSummarizesTheMiddle(t *testing.T) {
+	contents := []string{`{"role":"user","parts":[{"text":"Plan the migration."}]}`}
+	for i := 0; i < 10; i++ {
+		contents = append(contents,
+			fmt.Sprintf(`{"role":"model","parts":[{"functionCall":{"name":"read","args":{"file":"f%d.go"}}}]}`, i),
+			fmt.Sprintf(`{"role":"user","parts":[{"functionResponse":{"name":"read","response":{"content":"%s"}}}]}`, strings.Repeat("z", 800)))
+	}
+	payload := []byte(`{"request":{"contents":[` + strings.Join(contents, ",") + `]}}`)
+
+	var transcript string
+	result, err := Fit("gemini-cli", payload, Options{
+		Budget:     1_000,
+		Strategies: []string{StrategySummarize},
+		Count:      byteCounter,
+		Summarize: func(text string) (string, error) {
+			transcript = text
+			return "Read f0.go to f8.go.", nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Fit: %v", err)
+	}
+	if !result.Fits || !strings.Contains(transcript, `{"file":"f0.go"}`) || strings.Contains(transcript, "Plan the migration.") {
+		t.Fatalf("unexpected transcript %q (result %+v)", transcript, result)
+	}
+	contentsOut := gjson.GetBytes(result.Payload, "request.contents").Array()
+	if contentsOut[0].Get("parts.0.text").String() != "Plan the migration." {
+		t.Fatalf("opening user message must be kept, got %s", contentsOut[0].Raw)
+	}
+	if summary := contentsOut[1].Get("parts.0.text").String(); !strings.HasPrefix(summary, SummaryPrefix) || !strings.HasSuffix(summary, "Read f0.go to f8.go.") {
+		t.Fatalf("expected the summary after the opening message, got %s", contentsOut[1].Raw)
+	}
+	if last := contentsOut[len(contentsOut)-1]; !last.Get("parts.0.functionResponse").Exists() || contentsOut[len(contentsOut)-2].Get("role").String() != "model" {
+		t.Fatalf("latest call and response must be kept, got %s", last.Raw)
+	}
+}
+
+func TestFit_SummariesAreReusedByLaterTurns(t *testing.T) {
+	turns := func(n int) []byte {
+		contents := []string{`{"role":"user","parts":[{"text":"Plan the migration."}]}`}
+		for i := 0; i < n; i++ {
+			contents = append(contents,
+				fmt.Sprintf(`{"role":"model","parts":[{"functionCall":{"name":"read","args":{"file":"f%d.go"}}}]}`, i),
+				fmt.Sprintf(`{"role":"user","parts":[{"functionResponse":{"name":"read","response":{"content":"%s"}}}]}`, strings.Repeat("z", 800)))
+		}
+		return []byte(`{"contents":[` + strings.Join(contents, ",") + `]}`)
+	}
+	var transcripts []string
+	opts := Options{
+		Budget:     1_000,
+		Strategies: []string{StrategySummarize},
+		Count:      byteCounter,
+		Summarize: func(text string) (string, error) {
+			transcripts = append(transcripts, text)
+			return "Read some files.", nil
+		},
+		Summaries: NewSummaryCache(8),
+	}
+
+	for _, payload := range [][]byte{turns(10), turns(10)} {
+		if result, err := Fit("gemini", payload, opts); err != nil || !result.Fits {
+			t.Fatalf("Fit: %v (result %+v)", err, result)
+		}
+	}
+	if len(transcripts) != 1 {
+		t.Fatalf("expected an identical turn to reuse the summary, got %d summarize calls", len(transcripts))
+	}
+
+	result, err := Fit("gemini", turns(12), opts)
+	if err != nil || !result.Fits {
+		t.Fatalf("Fit: %v (result %+v)", err, result)
+	}
+	if len(transcripts) != 2 {
+		t.Fatalf("expected the next turn to extend the cached summary, got %d summarize calls", len(transcripts))
+	}
+	if next := transcripts[1]; !strings.HasPrefix(next, "summary of the turns before: Read some files.") || strings.Contains(next, `{"file":"f0.go"}`) {
+		t.Fatalf("expected only the newer turns after the cached summary, got %q", next)
+	}
+}
+
+func TestJoinTranscript_KeepsStartAndEndOfOversizeNewestEntry(t *testing.T) {
+	huge := "tool result: " + strings.Repeat("z", 1_000) + "THE-END"
+	transcript := JoinTranscript([]string{"user: older", huge}, 500)
+	if !strings.HasPrefix(transcript, "[earlier conversation omitted]\n\ntool result: zzz") || !strings.HasSuffix(transcript, "THE-END") || len(transcript) > 700 {
+		t.Fatalf("unexpected transcript %q", transcript)
+	}
+}
+
+func TestFit_FailedStrategyFallsThroughToTheNext(t *testing.T) {
+	contents := []string{`{"role":"user","parts":[{"text":"Plan the migration."}]}`}
+	for i := 0; i < 10; i++ {
+		contents = append(contents,
+			fmt.Sprintf(`{"role":"model","parts":[{"text":"step %d"}]}`, i),
+			fmt.Sprintf(`{"role":"user","parts":[{"text":"%s"}]}`, strings.Repeat("z", 800)))
+	}
+	payload := []byte(`{"contents":[` + strings.Join(contents, ",") + `]}`)
+
+	result, err := Fit("gemini", payload, Options{
+		Budget:     2_000,
+		Strategies: []string{StrategySummarize, StrategyDropOldest},
+		Count:      byteCounter,
+		Summarize: func(string) (string, error) {
+			return "", errors.New("summary model unavailable")
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), "summary model unavailable") {
+		t.Fatalf("expected the summarize failure to be reported, got %v", err)
+	}
+	if !result.Fits || len(result.Applied) != 1 || result.Applied[0] != StrategyDropOldest {
+		t.Fatalf("expected drop-oldest to fit the request after summarize failed, got %+v", result)
+	}
+}
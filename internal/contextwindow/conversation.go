@@ -0,0 +1,227 @@
+package contextwindow
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// kind classifies a conversation item for trimming.
+type kind int
+
+const (
+	// kindPinned items (system and developer messages) are never removed.
+	kindPinned kind = iota
+	// kindUser items are genuine user input.
+	kindUser
+	// kindModel items were produced by the model: messages, tool calls and reasoning.
+	kindModel
+	// kindToolResult items answer tool calls and stay with the call that requested them.
+	kindToolResult
+)
+
+// conversation is the message list of a request in one of the supported client formats.
+type conversation struct {
+	format  string
+	payload []byte
+	path    string
+	items   []gjson.Result
+	kinds   []kind
+	// units group item indices that are removed together: a user message, or consecutive
+	// model items followed by the tool results answering them. Pinned items belong to no unit.
+	units [][]int
+}
+
+// messagesPath returns the JSON path of the message list for a client format.
+func messagesPath(format string) string {
+	switch format {
+	case "claude", "openai":
+		return "messages"
+	case "openai-response":
+		return "input"
+	case "gemini":
+		return "contents"
+	case "gemini-cli":
+		return "request.contents"
+	}
+	return ""
+}
+
+// parse reads the message list of payload. It returns nil when the format is unsupported or
+// the request carries no message array.
+func parse(format string, payload []byte) *conversation {
+	path := messagesPath(format)
+	if path == "" {
+		return nil
+	}
+	list := gjson.GetBytes(payload, path)
+	if !list.IsArray() {
+		return nil
+	}
+	c := &conversation{format: format, payload: payload, path: path, items: list.Array()}
+	c.kinds = make([]kind, len(c.items))
+	for i, item := range c.items {
+		c.kinds[i] = classify(format, item)
+	}
+
+	previous := kindPinned
+	for i, k := range c.kinds {
+		if k == kindPinned {
+			continue
+		}
+		startsUnit := k == kindUser || (k == kindModel && previous != kindModel) || len(c.units) == 0
+		if startsUnit {
+			c.units = append(c.units, []int{i})
+		} else {
+			c.units[len(c.units)-1] = append(c.units[len(c.units)-1], i)
+		}
+		previous = k
+	}
+	return c
+}
+
+// classify returns the kind of a message list item.
+func classify(format string, item gjson.Result) kind {
+	role := item.Get("role").String()
+	switch format {
+	case "claude":
+		if role == "assistant" {
+			return kindModel
+		}
+		for _, block := range item.Get("content").Array() {
+			if block.Get("type").String() == "tool_result" {
+				return kindToolResult
+			}
+		}
+		return kindUser
+	case "openai":
+		switch role {
+		case "system", "developer":
+			return kindPinned
+		case "assistant":
+			return kindModel
+		case "tool", "function":
+			return kindToolResult
+		}
+		return kindUser
+	case "openai-response":
+		itemType := item.Get("type").String()
+		if itemType == "" || itemType == "message" {
+			switch role {
+			case "system", "developer":
+				return kindPinned
+			case "user":
+				return kindUser
+			}
+			return kindModel
+		}
+		if strings.HasSuffix(itemType, "_output") || itemType == "mcp_approval_response" {
+			return kindToolResult
+		}
+		return kindModel
+	default:
+		if role == "model" {
+			return kindModel
+		}
+		if role == "function" {
+			return kindToolResult
+		}
+		for _, part := range item.Get("parts").Array() {
+			if part.Get("functionResponse").Exists() {
+				return kindToolResult
+			}
+		}
+		return kindUser
+	}
+}
+
+// firstProtected returns the number of leading units that must be kept: the opening user
+// message usually states the task.
+func (c *conversation) firstProtected() int {
+	if len(c.units) > 0 && c.kinds[c.units[0][0]] == kindUser {
+		return 1
+	}
+	return 0
+}
+
+// unitSize returns the serialized size of a unit in bytes.
+func (c *conversation) unitSize(unit []int) int {
+	size := 0
+	for _, i := range unit {
+		size += len(c.items[i].Raw)
+	}
+	return size
+}
+
+// rebuild writes the message list back into the payload. Items listed in removed are left
+// out, and message is inserted in place of the first removed item when it is not empty.
+// replaced overrides the raw JSON of individual items.
+func (c *conversation) rebuild(removed map[int]bool, message string, replaced map[int]string) ([]byte, error) {
+	var b strings.Builder
+	b.WriteByte('[')
+	inserted := message == ""
+	write := func(raw string) {
+		if b.Len() > 1 {
+			b.WriteByte(',')
+		}
+		b.WriteString(raw)
+	}
+	for i, item := range c.items {
+		if removed[i] {
+			if !inserted {
+				write(userMessage(c.format, message))
+				inserted = true
+			}
+			continue
+		}
+		if raw, ok := replaced[i]; ok {
+			write(raw)
+			continue
+		}
+		write(item.Raw)
+	}
+	b.WriteByte(']')
+	return sjson.SetRawBytes(c.payload, c.path, []byte(b.String()))
+}
+
+// userMessage renders a plain-text user message in the conversation's format.
+func userMessage(format, text string) string {
+	var raw string
+	switch format {
+	case "openai-response":
+		raw, _ = sjson.Set(`{"type":"message","role":"user","content":[{"type":"input_text","text":""}]}`, "content.0.text", text)
+	case "gemini", "gemini-cli":
+		raw, _ = sjson.Set(`{"role":"user","parts":[{"text":""}]}`, "parts.0.text", text)
+	default:
+		raw, _ = sjson.Set(`{"role":"user","content":""}`, "content", text)
+	}
+	return raw
+}
+
+// toolOutputPaths returns the JSON paths, relative to a tool result item, holding the tool output.
+func toolOutputPaths(format string, item gjson.Result) []string {
+	switch format {
+	case "claude":
+		var paths []string
+		for i, block := range item.Get("content").Array() {
+			if block.Get("type").String() == "tool_result" && block.Get("content").Exists() {
+				paths = append(paths, "content."+strconv.Itoa(i)+".content")
+			}
+		}
+		return paths
+	case "openai":
+		return []string{"content"}
+	case "openai-response":
+		return []string{"output"}
+	default:
+		var paths []string
+		for i, part := range item.Get("parts").Array() {
+			if part.Get("functionResponse.response").Exists() {
+				paths = append(paths, "parts."+strconv.Itoa(i)+".functionResponse.response")
+			}
+		}
+		return paths
+	}
+}
@@ -0,0 +1,78 @@
+package contextwindow
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+)
+
+// SummaryCache keeps the summaries of recently condensed conversation parts, keyed by a hash of
+// the items they replace. The following turns of a conversation summarize the same items again
+// plus the newer ones, so they reuse the cached summary instead of condensing everything anew.
+// The least recently used summaries are dropped beyond the configured size.
+type SummaryCache struct {
+	mu      sync.Mutex
+	max     int
+	entries map[string]*list.Element
+	order   list.List
+}
+
+type cachedSummary struct {
+	key     string
+	summary string
+}
+
+// NewSummaryCache returns a cache holding up to max summaries.
+func NewSummaryCache(max int) *SummaryCache {
+	return &SummaryCache{max: max, entries: make(map[string]*list.Element)}
+}
+
+func (c *SummaryCache) get(key string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return "", false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*cachedSummary).summary, true
+}
+
+func (c *SummaryCache) put(key, summary string) {
+	if c == nil || c.max <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*cachedSummary).summary = summary
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&cachedSummary{key: key, summary: summary})
+	for len(c.entries) > c.max {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cachedSummary).key)
+	}
+}
+
+// unitKeys returns the cache key of every prefix of units: keys[k] identifies units[:k+1].
+func (c *conversation) unitKeys(units [][]int) []string {
+	keys := make([]string, len(units))
+	var chain [sha256.Size]byte
+	for k, unit := range units {
+		h := sha256.New()
+		h.Write(chain[:])
+		for _, i := range unit {
+			h.Write([]byte(c.items[i].Raw))
+		}
+		h.Sum(chain[:0])
+		keys[k] = hex.EncodeToString(chain[:])
+	}
+	return keys
+}
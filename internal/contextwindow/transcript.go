@@ -0,0 +1,106 @@
+package contextwindow
+
+import (
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// TranscriptEntries renders the items of a message list in a client format as the entries of
+// a plain-text transcript. Identifiers, signatures and binary data are left out, as are items
+// without any text.
+func TranscriptEntries(format string, items []gjson.Result) []string {
+	var entries []string
+	for _, item := range items {
+		if entry := transcriptEntry(classify(format, item), item); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// JoinTranscript joins transcript entries, oldest first, into at most about limit bytes. The
+// oldest entries are dropped first; when the newest entry alone exceeds limit, its start and
+// end are kept rather than nothing.
+func JoinTranscript(entries []string, limit int) string {
+	size, start := 0, len(entries)
+	for start > 0 && size+len(entries[start-1]) <= limit {
+		start--
+		size += len(entries[start]) + 2
+	}
+	kept := entries[start:]
+	if start > 0 && start == len(entries) {
+		start--
+		kept = []string{truncateText(entries[start], limit)}
+	}
+	transcript := strings.Join(kept, "\n\n")
+	if start > 0 {
+		transcript = "[earlier conversation omitted]\n\n" + transcript
+	}
+	return transcript
+}
+
+// transcriptEntry renders an item as a line of a plain-text transcript.
+func transcriptEntry(k kind, item gjson.Result) string {
+	var texts []string
+	collectText(item, "", &texts)
+	if len(texts) == 0 {
+		return ""
+	}
+	label := "user"
+	switch k {
+	case kindPinned:
+		label = "system"
+	case kindModel:
+		label = "assistant"
+	case kindToolResult:
+		label = "tool result"
+	}
+	return label + ": " + strings.Join(texts, "\n")
+}
+
+// transcriptSkipKeys hold identifiers, signatures and binary data, which do not help a summary.
+var transcriptSkipKeys = map[string]struct{}{
+	"type":              {},
+	"role":              {},
+	"id":                {},
+	"call_id":           {},
+	"tool_use_id":       {},
+	"tool_call_id":      {},
+	"signature":         {},
+	"thoughtSignature":  {},
+	"thought_signature": {},
+	"encrypted_content": {},
+	"data":              {},
+	"url":               {},
+	"mimeType":          {},
+	"media_type":        {},
+	"status":            {},
+	"cache_control":     {},
+}
+
+// collectText gathers the string values of a JSON value. Structured tool call arguments are
+// kept as JSON.
+func collectText(value gjson.Result, key string, texts *[]string) {
+	if _, skip := transcriptSkipKeys[key]; skip {
+		return
+	}
+	switch {
+	case (key == "input" || key == "args") && value.IsObject():
+		*texts = append(*texts, value.Raw)
+	case value.Type == gjson.String:
+		if text := strings.TrimSpace(value.String()); text != "" {
+			*texts = append(*texts, text)
+		}
+	case value.IsArray():
+		value.ForEach(func(_, child gjson.Result) bool {
+			collectText(child, key, texts)
+			return true
+		})
+	case value.IsObject():
+		value.ForEach(func(k, child gjson.Result) bool {
+			collectText(child, k.String(), texts)
+			return true
+		})
+	}
+}
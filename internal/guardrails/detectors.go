@@ -0,0 +1,111 @@
+package guardrails
+
+import (
+	"regexp"
+	"strings"
+)
+
+// detector finds one kind of sensitive content.
+type detector struct {
+	name  string
+	re    *regexp.Regexp
+	valid func(match string) bool
+}
+
+// builtinDetectors are selectable by name through GuardrailPolicy.Detectors.
+var builtinDetectors = map[string]detector{
+	"aws-access-key": {
+		name: "aws-access-key",
+		re:   regexp.MustCompile(`\b(?:AKIA|ASIA|ABIA|ACCA)[0-9A-Z]{16}\b`),
+	},
+	"aws-secret-key": {
+		name: "aws-secret-key",
+		re:   regexp.MustCompile(`(?i)aws_?secret_?(?:access_?)?key["']?\s*[:=]\s*["']?[A-Za-z0-9/+=]{40}`),
+	},
+	"private-key": {
+		name: "private-key",
+		re:   regexp.MustCompile(`-----BEGIN (?:[A-Z0-9]+ )*PRIVATE KEY( BLOCK)?-----(?:[\s\S]*?-----END (?:[A-Z0-9]+ )*PRIVATE KEY( BLOCK)?-----)?`),
+	},
+	"github-token": {
+		name: "github-token",
+		re:   regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,255}|github_pat_[A-Za-z0-9_]{22,255})\b`),
+	},
+	"slack-token": {
+		name: "slack-token",
+		re:   regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}\b`),
+	},
+	"email": {
+		name: "email",
+		re:   regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`),
+	},
+	"phone": {
+		name: "phone",
+		re:   regexp.MustCompile(`(?:\+\d{1,3}[ .-]?)?\(?\b\d{3}\)?[ .-]\d{3}[ .-]\d{4}\b`),
+	},
+	"credit-card": {
+		name:  "credit-card",
+		re:    regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+		valid: luhnValid,
+	},
+	"ssn": {
+		name: "ssn",
+		re:   regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`),
+	},
+}
+
+// detectorGroups expand to several built-in detectors.
+var detectorGroups = map[string][]string{
+	"secrets": {"aws-access-key", "aws-secret-key", "private-key", "github-token", "slack-token"},
+	"pii":     {"email", "phone", "credit-card", "ssn"},
+}
+
+// find returns the first valid match of d in s.
+func (d detector) find(s string) (string, bool) {
+	for _, loc := range d.re.FindAllStringIndex(s, -1) {
+		match := s[loc[0]:loc[1]]
+		if d.valid == nil || d.valid(match) {
+			return match, true
+		}
+	}
+	return "", false
+}
+
+// redact replaces every valid match of d in s with a placeholder naming the detector.
+func (d detector) redact(s string) (string, bool) {
+	changed := false
+	out := d.re.ReplaceAllStringFunc(s, func(match string) string {
+		if d.valid != nil && !d.valid(match) {
+			return match
+		}
+		changed = true
+		return "[REDACTED:" + d.name + "]"
+	})
+	return out, changed
+}
+
+// luhnValid reports whether the digits in s pass the Luhn checksum used by card numbers.
+func luhnValid(s string) bool {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,371 @@
+// Package guardrails enforces content policies on request payloads and model output.
+// Policies are configured through the "guardrails" config section and inspect the JSON
+// string values of a payload, so they work on every API format and on stream chunks.
+package guardrails
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+const (
+	// DirectionRequest marks payloads sent by the client before they go upstream.
+	DirectionRequest = "request"
+
+	// DirectionResponse marks model output before it is returned to the client.
+	DirectionResponse = "response"
+
+	// streamWindowSize bounds the recent stream text kept to detect content split across chunks.
+	streamWindowSize = 4096
+)
+
+// streamContentKeys are the JSON keys whose string values carry generated text in stream chunks.
+var streamContentKeys = map[string]struct{}{
+	"text":         {},
+	"content":      {},
+	"delta":        {},
+	"thinking":     {},
+	"partial_json": {},
+	"arguments":    {},
+	"refusal":      {},
+}
+
+// Violation describes a payload rejected by a blocking policy. It never carries the matched content.
+type Violation struct {
+	Policy    string
+	Detector  string
+	Direction string
+}
+
+// Error implements the error interface.
+func (v *Violation) Error() string {
+	subject := "request"
+	if v.Direction == DirectionResponse {
+		subject = "response"
+	}
+	return fmt.Sprintf("%s blocked by guardrail policy %q: %s detected", subject, v.Policy, v.Detector)
+}
+
+type policy struct {
+	name      string
+	apiKeys   []string
+	request   bool
+	response  bool
+	redact    bool
+	detectors []detector
+}
+
+// Engine applies a compiled set of policies. A nil Engine allows everything.
+type Engine struct {
+	policies []policy
+}
+
+var (
+	activeMu sync.RWMutex
+	active   *Engine
+)
+
+// Configure installs the process-wide engine built from policies. Invalid patterns and
+// unknown detectors are reported, while the remaining rules of the policy stay active.
+func Configure(policies []config.GuardrailPolicy) error {
+	engine, err := New(policies)
+	activeMu.Lock()
+	active = engine
+	activeMu.Unlock()
+	return err
+}
+
+// Active returns the configured engine, or nil when no policy is configured.
+func Active() *Engine {
+	activeMu.RLock()
+	defer activeMu.RUnlock()
+	return active
+}
+
+// New compiles policies into an engine. It returns nil when there is nothing to enforce.
+func New(policies []config.GuardrailPolicy) (*Engine, error) {
+	var errs []error
+	engine := &Engine{}
+	for i, cfg := range policies {
+		name := strings.TrimSpace(cfg.Name)
+		if name == "" {
+			name = fmt.Sprintf("policy-%d", i+1)
+		}
+		p := policy{name: name}
+		switch strings.ToLower(strings.TrimSpace(cfg.Direction)) {
+		case "", "both":
+			p.request, p.response = true, true
+		case DirectionRequest:
+			p.request = true
+		case DirectionResponse:
+			p.response = true
+		default:
+			errs = append(errs, fmt.Errorf("guardrails: policy %q: unsupported direction %q", name, cfg.Direction))
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(cfg.Action)) {
+		case "", "block":
+		case "redact":
+			p.redact = true
+		default:
+			errs = append(errs, fmt.Errorf("guardrails: policy %q: unsupported action %q", name, cfg.Action))
+			continue
+		}
+		for _, key := range cfg.APIKeys {
+			if key = strings.TrimSpace(key); key != "" {
+				p.apiKeys = append(p.apiKeys, key)
+			}
+		}
+		seen := make(map[string]struct{})
+		for _, raw := range cfg.Detectors {
+			id := strings.ToLower(strings.TrimSpace(raw))
+			names := detectorGroups[id]
+			if names == nil {
+				names = []string{id}
+			}
+			for _, detectorName := range names {
+				d, ok := builtinDetectors[detectorName]
+				if !ok {
+					errs = append(errs, fmt.Errorf("guardrails: policy %q: unknown detector %q", name, raw))
+					continue
+				}
+				if _, dup := seen[detectorName]; dup {
+					continue
+				}
+				seen[detectorName] = struct{}{}
+				p.detectors = append(p.detectors, d)
+			}
+		}
+		for j, pattern := range cfg.Patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("guardrails: policy %q: invalid pattern %d: %w", name, j+1, err))
+				continue
+			}
+			p.detectors = append(p.detectors, detector{name: "pattern", re: re})
+		}
+		var keywords []string
+		for _, keyword := range cfg.Keywords {
+			if keyword = strings.TrimSpace(keyword); keyword != "" {
+				keywords = append(keywords, regexp.QuoteMeta(keyword))
+			}
+		}
+		if len(keywords) > 0 {
+			re := regexp.MustCompile(`(?i)` + strings.Join(keywords, "|"))
+			p.detectors = append(p.detectors, detector{name: "keyword", re: re})
+		}
+		if len(p.detectors) > 0 {
+			engine.policies = append(engine.policies, p)
+		}
+	}
+	if len(engine.policies) == 0 {
+		engine = nil
+	}
+	return engine, errors.Join(errs...)
+}
+
+// CheckRequest applies request policies for apiKey to payload. It returns the payload with
+// redactions applied, or a violation when a blocking policy matched.
+func (e *Engine) CheckRequest(apiKey string, payload []byte) ([]byte, *Violation) {
+	return e.check(apiKey, DirectionRequest, payload)
+}
+
+// CheckResponse applies response policies for apiKey to a complete response body.
+func (e *Engine) CheckResponse(apiKey string, payload []byte) ([]byte, *Violation) {
+	return e.check(apiKey, DirectionResponse, payload)
+}
+
+func (e *Engine) check(apiKey, direction string, payload []byte) ([]byte, *Violation) {
+	policies := e.policiesFor(apiKey, direction)
+	if len(policies) == 0 || len(payload) == 0 {
+		return payload, nil
+	}
+	var violation *Violation
+	out := transformStrings(payload, func(_ string, value string) (string, bool) {
+		var v *Violation
+		value, v = applyPolicies(policies, direction, value)
+		if v != nil {
+			violation = v
+			return value, false
+		}
+		return value, true
+	})
+	if violation != nil {
+		return nil, violation
+	}
+	return out, nil
+}
+
+// StreamScanner applies response policies to consecutive chunks of one stream.
+type StreamScanner struct {
+	policies []policy
+	window   string
+}
+
+// NewStreamScanner returns a scanner for a response stream of apiKey, or nil when no
+// response policy applies.
+func (e *Engine) NewStreamScanner(apiKey string) *StreamScanner {
+	policies := e.policiesFor(apiKey, DirectionResponse)
+	if len(policies) == 0 {
+		return nil
+	}
+	return &StreamScanner{policies: policies}
+}
+
+// Chunk applies response policies to a stream chunk. Redactions are applied per chunk; the
+// generated text of recent chunks is also checked so blocked content split across chunks
+// still ends the stream, even though earlier parts of it may already have been sent.
+func (s *StreamScanner) Chunk(chunk []byte) ([]byte, *Violation) {
+	if s == nil || len(chunk) == 0 {
+		return chunk, nil
+	}
+	var violation *Violation
+	var generated strings.Builder
+	out := transformStrings(chunk, func(key, value string) (string, bool) {
+		var v *Violation
+		value, v = applyPolicies(s.policies, DirectionResponse, value)
+		if v != nil {
+			violation = v
+			return value, false
+		}
+		if _, ok := streamContentKeys[key]; ok {
+			generated.WriteString(value)
+		}
+		return value, true
+	})
+	if violation != nil {
+		return nil, violation
+	}
+	if generated.Len() == 0 {
+		return out, nil
+	}
+	s.window += generated.String()
+	if len(s.window) > streamWindowSize {
+		s.window = s.window[len(s.window)-streamWindowSize:]
+	}
+	for _, p := range s.policies {
+		if p.redact {
+			continue
+		}
+		for _, d := range p.detectors {
+			if _, found := d.find(s.window); found {
+				return nil, &Violation{Policy: p.name, Detector: d.name, Direction: DirectionResponse}
+			}
+		}
+	}
+	return out, nil
+}
+
+func (e *Engine) policiesFor(apiKey, direction string) []policy {
+	if e == nil {
+		return nil
+	}
+	var out []policy
+	for _, p := range e.policies {
+		if direction == DirectionRequest && !p.request || direction == DirectionResponse && !p.response {
+			continue
+		}
+		if !p.appliesTo(apiKey) {
+			continue
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+func (p policy) appliesTo(apiKey string) bool {
+	if len(p.apiKeys) == 0 {
+		return true
+	}
+	for _, key := range p.apiKeys {
+		if key == "*" || key == apiKey {
+			return true
+		}
+	}
+	return false
+}
+
+// applyPolicies runs policies over a single string value in order.
+func applyPolicies(policies []policy, direction, value string) (string, *Violation) {
+	for _, p := range policies {
+		for _, d := range p.detectors {
+			if p.redact {
+				value, _ = d.redact(value)
+				continue
+			}
+			if _, found := d.find(value); found {
+				return value, &Violation{Policy: p.name, Detector: d.name, Direction: direction}
+			}
+		}
+	}
+	return value, nil
+}
+
+// transformStrings calls fn for every JSON string value found in data and replaces the value
+// with the returned string. Object keys are passed along as the key of the following values.
+// data may be a JSON document or SSE framing around one; bytes outside string literals are
+// copied unchanged. Scanning stops when fn returns false.
+func transformStrings(data []byte, fn func(key, value string) (string, bool)) []byte {
+	var out []byte
+	lastKey := ""
+	copied := 0
+	for i := 0; i < len(data); i++ {
+		if data[i] != '"' {
+			continue
+		}
+		end := i + 1
+		for end < len(data) && data[end] != '"' {
+			if data[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(data) {
+			break
+		}
+		literal := data[i : end+1]
+		next := end + 1
+		for next < len(data) && (data[next] == ' ' || data[next] == '\t' || data[next] == '\r' || data[next] == '\n') {
+			next++
+		}
+		var value string
+		if err := json.Unmarshal(literal, &value); err != nil {
+			i = end
+			continue
+		}
+		if next < len(data) && data[next] == ':' {
+			lastKey = value
+			i = end
+			continue
+		}
+		replaced, keepGoing := fn(lastKey, value)
+		if replaced != value {
+			out = append(out, data[copied:i]...)
+			out = append(out, encodeString(replaced)...)
+			copied = end + 1
+		}
+		if !keepGoing {
+			break
+		}
+		i = end
+	}
+	if out == nil {
+		return data
+	}
+	return append(out, data[copied:]...)
+}
+
+func encodeString(s string) []byte {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s)
+	return bytes.TrimRight(buf.Bytes(), "\n")
+}
@@ -0,0 +1,261 @@
+// Package metrics exposes Prometheus instrumentation for the proxy runtime.
+// Request, latency and token metrics are recorded as events happen, while
+// credential and websocket relay gauges are computed from registered sources
+// each time the registry is scraped.
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+const namespace = "cliproxy"
+
+// Request kinds used for the duration histogram.
+const (
+	KindExecute = "execute"
+	KindCount   = "count_tokens"
+	KindStream  = "stream"
+)
+
+// CredentialState describes one credential as seen by the credential gauges.
+type CredentialState struct {
+	Provider    string
+	Status      string
+	Disabled    bool
+	Unavailable bool
+	CoolingDown bool
+}
+
+var (
+	registry = prometheus.NewRegistry()
+
+	upstreamRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_requests_total",
+		Help:      "Upstream requests by provider, model, outcome and HTTP status code.",
+	}, []string{"provider", "model", "outcome", "status_code"})
+
+	upstreamDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "upstream_request_duration_seconds",
+		Help:      "Upstream request latency; for streams this covers the full stream.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60, 120, 300},
+	}, []string{"provider", "model", "kind"})
+
+	upstreamTTFB = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "upstream_time_to_first_byte_seconds",
+		Help:      "Time until the first streamed chunk arrives from the upstream.",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10, 20, 30, 60},
+	}, []string{"provider", "model"})
+
+	tokens = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tokens_total",
+		Help:      "Tokens reported in usage records by provider, model and token type.",
+	}, []string{"provider", "model", "type"})
+
+	hedgedRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "hedged_requests_total",
+		Help:      "Hedged requests by model and the attempt that answered first (primary, hedge or none).",
+	}, []string{"model", "winner"})
+
+	sessionAffinity = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "session_affinity_total",
+		Help:      "Credential picks for requests carrying a session key, by result (hit, miss or repin).",
+	}, []string{"result"})
+
+	openStreams = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "open_streams",
+		Help:      "Streaming responses currently being served to clients.",
+	}, []string{"handler"})
+
+	credentialsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "credentials"),
+		"Registered credentials by provider and status.",
+		[]string{"provider", "status"}, nil,
+	)
+	credentialsUnavailableDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "credentials_unavailable"),
+		"Credentials flagged unavailable by provider.",
+		[]string{"provider"}, nil,
+	)
+	credentialsCoolingDownDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "credentials_cooling_down"),
+		"Credentials with an active cooldown on the credential or any of its models.",
+		[]string{"provider"}, nil,
+	)
+	wsrelaySessionsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "wsrelay_sessions"),
+		"Connected websocket relay sessions.",
+		nil, nil,
+	)
+
+	sourcesMu            sync.RWMutex
+	credentialSource     func() []CredentialState
+	wsrelaySessionSource func() int
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		upstreamRequests,
+		upstreamDuration,
+		upstreamTTFB,
+		tokens,
+		hedgedRequests,
+		sessionAffinity,
+		openStreams,
+		sourceCollector{},
+	)
+	coreusage.RegisterPlugin(tokenPlugin{})
+}
+
+// Handler returns the HTTP handler serving the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// Registry exposes the underlying registry so embedders can add their own collectors.
+func Registry() *prometheus.Registry {
+	return registry
+}
+
+// SetCredentialSource installs the callback used to compute credential gauges at scrape time.
+func SetCredentialSource(fn func() []CredentialState) {
+	sourcesMu.Lock()
+	credentialSource = fn
+	sourcesMu.Unlock()
+}
+
+// SetWebsocketSessionSource installs the callback reporting connected websocket relay sessions.
+func SetWebsocketSessionSource(fn func() int) {
+	sourcesMu.Lock()
+	wsrelaySessionSource = fn
+	sourcesMu.Unlock()
+}
+
+// ObserveResult counts a finished upstream attempt.
+func ObserveResult(provider, model string, success bool, statusCode int) {
+	outcome := "success"
+	if !success {
+		outcome = "failure"
+	}
+	code := ""
+	if statusCode > 0 {
+		code = strconv.Itoa(statusCode)
+	}
+	upstreamRequests.WithLabelValues(provider, model, outcome, code).Inc()
+}
+
+// ObserveDuration records how long an upstream attempt took.
+func ObserveDuration(provider, model, kind string, elapsed time.Duration) {
+	upstreamDuration.WithLabelValues(provider, model, kind).Observe(elapsed.Seconds())
+}
+
+// ObserveTimeToFirstByte records the delay before the first streamed chunk.
+func ObserveTimeToFirstByte(provider, model string, elapsed time.Duration) {
+	upstreamTTFB.WithLabelValues(provider, model).Observe(elapsed.Seconds())
+}
+
+// ObserveHedge counts a request that was hedged and which attempt answered it.
+func ObserveHedge(model, winner string) {
+	hedgedRequests.WithLabelValues(model, winner).Inc()
+}
+
+// ObserveSessionAffinity counts a credential pick for a request belonging to a session.
+func ObserveSessionAffinity(result string) {
+	sessionAffinity.WithLabelValues(result).Inc()
+}
+
+// StreamOpened tracks a client streaming response and returns the func that marks it closed.
+func StreamOpened(handler string) func() {
+	gauge := openStreams.WithLabelValues(handler)
+	gauge.Inc()
+	var once sync.Once
+	return func() { once.Do(gauge.Dec) }
+}
+
+type tokenPlugin struct{}
+
+// HandleUsage implements coreusage.Plugin by adding the record's tokens to the token counters.
+func (tokenPlugin) HandleUsage(_ context.Context, record coreusage.Record) {
+	add := func(kind string, value int64) {
+		if value > 0 {
+			tokens.WithLabelValues(record.Provider, record.Model, kind).Add(float64(value))
+		}
+	}
+	add("input", record.Detail.InputTokens)
+	add("output", record.Detail.OutputTokens)
+	add("reasoning", record.Detail.ReasoningTokens)
+	add("cached", record.Detail.CachedTokens)
+}
+
+// sourceCollector evaluates the registered sources on every scrape.
+type sourceCollector struct{}
+
+func (sourceCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- credentialsDesc
+	ch <- credentialsUnavailableDesc
+	ch <- credentialsCoolingDownDesc
+	ch <- wsrelaySessionsDesc
+}
+
+func (sourceCollector) Collect(ch chan<- prometheus.Metric) {
+	sourcesMu.RLock()
+	credentialsFn := credentialSource
+	sessionsFn := wsrelaySessionSource
+	sourcesMu.RUnlock()
+
+	if credentialsFn != nil {
+		type statusKey struct{ provider, status string }
+		byStatus := make(map[statusKey]int)
+		unavailable := make(map[string]int)
+		coolingDown := make(map[string]int)
+		for _, state := range credentialsFn() {
+			status := state.Status
+			if state.Disabled {
+				status = "disabled"
+			}
+			if status == "" {
+				status = "unknown"
+			}
+			byStatus[statusKey{state.Provider, status}]++
+			if _, ok := unavailable[state.Provider]; !ok {
+				unavailable[state.Provider] = 0
+				coolingDown[state.Provider] = 0
+			}
+			if state.Unavailable {
+				unavailable[state.Provider]++
+			}
+			if state.CoolingDown {
+				coolingDown[state.Provider]++
+			}
+		}
+		for key, count := range byStatus {
+			ch <- prometheus.MustNewConstMetric(credentialsDesc, prometheus.GaugeValue, float64(count), key.provider, key.status)
+		}
+		for provider, count := range unavailable {
+			ch <- prometheus.MustNewConstMetric(credentialsUnavailableDesc, prometheus.GaugeValue, float64(count), provider)
+		}
+		for provider, count := range coolingDown {
+			ch <- prometheus.MustNewConstMetric(credentialsCoolingDownDesc, prometheus.GaugeValue, float64(count), provider)
+		}
+	}
+	if sessionsFn != nil {
+		ch <- prometheus.MustNewConstMetric(wsrelaySessionsDesc, prometheus.GaugeValue, float64(sessionsFn()))
+	}
+}
@@ -203,6 +203,19 @@ func GetGeminiModels() []*ModelInfo {
 			SupportedGenerationMethods: []string{"generateContent", "countTokens", "createCachedContent", "batchGenerateContent"},
 			Thinking:                   &ThinkingSupport{Min: 128, Max: 32768, ZeroAllowed: false, DynamicAllowed: true, Levels: []string{"low", "high"}},
 		},
+		{
+			ID:                         "gemini-embedding-001",
+			Object:                     "model",
+			Created:                    1752537600,
+			OwnedBy:                    "google",
+			Type:                       "gemini",
+			Name:                       "models/gemini-embedding-001",
+			Version:                    "001",
+			DisplayName:                "Gemini Embedding 001",
+			Description:                "Obtain a distributed representation of a text.",
+			InputTokenLimit:            2048,
+			SupportedGenerationMethods: []string{"embedContent", "batchEmbedContents"},
+		},
 	}
 }
 
@@ -359,6 +372,19 @@ func GetGeminiVertexModels() []*ModelInfo {
 			Description:                "Imagen 4.0 fast image generation model",
 			SupportedGenerationMethods: []string{"predict"},
 		},
+		{
+			ID:                         "gemini-embedding-001",
+			Object:                     "model",
+			Created:                    1752537600,
+			OwnedBy:                    "google",
+			Type:                       "gemini",
+			Name:                       "models/gemini-embedding-001",
+			Version:                    "001",
+			DisplayName:                "Gemini Embedding 001",
+			Description:                "Gemini text embedding model served through the predict action",
+			InputTokenLimit:            2048,
+			SupportedGenerationMethods: []string{"predict"},
+		},
 	}
 }
 
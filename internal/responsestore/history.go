@@ -0,0 +1,112 @@
+package responsestore
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// maxChainDepth bounds how many stored responses are walked to rebuild a conversation.
+const maxChainDepth = 1000
+
+// InputItems returns the items of a Responses API input field; a plain string becomes a
+// single user message.
+func InputItems(input gjson.Result) []json.RawMessage {
+	switch {
+	case input.Type == gjson.String:
+		item, _ := sjson.Set(`{"type":"message","role":"user","content":[{"type":"input_text","text":""}]}`, "content.0.text", input.String())
+		return []json.RawMessage{json.RawMessage(item)}
+	case input.IsArray():
+		items := make([]json.RawMessage, 0, len(input.Array()))
+		for _, item := range input.Array() {
+			items = append(items, json.RawMessage(item.Raw))
+		}
+		return items
+	case input.IsObject():
+		return []json.RawMessage{json.RawMessage(input.Raw)}
+	}
+	return nil
+}
+
+// History returns the conversation up to and including the response id, oldest first: the
+// input items and output items of every stored response in its previous_response_id chain.
+// The chain stops early at a response that has expired or been deleted.
+func (s *Store) History(id, apiKey string) ([]json.RawMessage, error) {
+	chain, err := s.chain(id, apiKey)
+	if err != nil {
+		return nil, err
+	}
+	var items []json.RawMessage
+	for i := len(chain) - 1; i >= 0; i-- {
+		items = append(items, chain[i].Input...)
+		items = append(items, outputItems(chain[i].Response)...)
+	}
+	return items, nil
+}
+
+// InputHistory returns the items the response id was generated from: the history of the
+// response it continued followed by its own input items.
+func (s *Store) InputHistory(id, apiKey string) ([]json.RawMessage, error) {
+	rec, err := s.Get(id, apiKey)
+	if err != nil {
+		return nil, err
+	}
+	var items []json.RawMessage
+	if rec.PreviousResponseID != "" {
+		previous, errHistory := s.History(rec.PreviousResponseID, apiKey)
+		if errHistory != nil && !errors.Is(errHistory, ErrNotFound) {
+			return nil, errHistory
+		}
+		items = previous
+	}
+	return append(items, rec.Input...), nil
+}
+
+// chain returns the response id and the responses it continues, newest first.
+func (s *Store) chain(id, apiKey string) ([]*Record, error) {
+	rec, err := s.Get(id, apiKey)
+	if err != nil {
+		return nil, err
+	}
+	chain := []*Record{rec}
+	seen := map[string]struct{}{rec.ID: {}}
+	for len(chain) < maxChainDepth {
+		previousID := chain[len(chain)-1].PreviousResponseID
+		if previousID == "" {
+			break
+		}
+		if _, loop := seen[previousID]; loop {
+			break
+		}
+		previous, errGet := s.Get(previousID, apiKey)
+		if errGet != nil {
+			if errors.Is(errGet, ErrNotFound) {
+				break
+			}
+			return nil, errGet
+		}
+		seen[previousID] = struct{}{}
+		chain = append(chain, previous)
+	}
+	return chain, nil
+}
+
+// outputItems returns the output items of a stored response without their ids: the backend
+// never persisted them, and some backends reject item ids they cannot resolve.
+func outputItems(response json.RawMessage) []json.RawMessage {
+	output := gjson.GetBytes(response, "output")
+	if !output.IsArray() {
+		return nil
+	}
+	items := make([]json.RawMessage, 0, len(output.Array()))
+	for _, item := range output.Array() {
+		raw := item.Raw
+		if item.Get("id").Exists() {
+			raw, _ = sjson.Delete(raw, "id")
+		}
+		items = append(items, json.RawMessage(raw))
+	}
+	return items
+}
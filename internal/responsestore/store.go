@@ -0,0 +1,242 @@
+// Package responsestore persists Responses API objects so that previous_response_id chaining
+// and the response retrieval endpoints work regardless of the backend serving the request.
+package responsestore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// DefaultTTL is used when the configured TTL is not positive.
+	DefaultTTL = 30 * 24 * time.Hour
+
+	// sweepInterval is the minimum time between two passes dropping expired responses.
+	sweepInterval = time.Hour
+)
+
+var responsesBucket = []byte("responses")
+
+// ErrNotFound is returned when a response does not exist, has expired, or belongs to another client key.
+var ErrNotFound = errors.New("response not found")
+
+// Record is a stored response together with the input items of the request that created it.
+type Record struct {
+	ID string `json:"id"`
+	// APIKey is the client key that created the response; other keys cannot see it.
+	APIKey string `json:"api_key,omitempty"`
+	// PreviousResponseID is the response the request continued, if any.
+	PreviousResponseID string `json:"previous_response_id,omitempty"`
+	// Input holds the request's own input items, without the expanded history.
+	Input     []json.RawMessage `json:"input"`
+	Response  json.RawMessage   `json:"response"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// Store is a bbolt database of responses keyed by response id.
+type Store struct {
+	db        *bolt.DB
+	path      string
+	ttl       atomic.Int64
+	lastSweep atomic.Int64
+}
+
+var (
+	activeMu sync.RWMutex
+	active   *Store
+)
+
+// Configure opens, moves, or closes the process-wide store to match cfg. defaultPath is used
+// when cfg.Path is empty.
+func Configure(cfg config.ResponsesStoreConfig, defaultPath string) error {
+	path := ""
+	if cfg.Enabled {
+		path = strings.TrimSpace(cfg.Path)
+		if path == "" {
+			path = defaultPath
+		}
+	}
+	ttl := time.Duration(cfg.TTLHours) * time.Hour
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+
+	activeMu.Lock()
+	defer activeMu.Unlock()
+	if active != nil && active.path == path {
+		active.ttl.Store(int64(ttl))
+		return nil
+	}
+	if active != nil {
+		if err := active.Close(); err != nil {
+			log.Warnf("responses store: failed to close %s: %v", active.path, err)
+		}
+		active = nil
+	}
+	if path == "" {
+		return nil
+	}
+	s, err := Open(path, ttl)
+	if err != nil {
+		return err
+	}
+	active = s
+	return nil
+}
+
+// Active returns the configured store, or nil when the stateful Responses API is disabled.
+func Active() *Store {
+	activeMu.RLock()
+	defer activeMu.RUnlock()
+	return active
+}
+
+// Open opens (or creates) the store at path.
+func Open(path string, ttl time.Duration) (*Store, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return nil, fmt.Errorf("responses store: path is empty")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("responses store: create directory: %w", err)
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("responses store: open database: %w", err)
+	}
+	if err = db.Update(func(tx *bolt.Tx) error {
+		_, errBucket := tx.CreateBucketIfNotExists(responsesBucket)
+		return errBucket
+	}); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("responses store: create bucket: %w", err)
+	}
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	s := &Store{db: db, path: path}
+	s.ttl.Store(int64(ttl))
+	s.sweep(time.Now())
+	return s, nil
+}
+
+// Path returns the database file location.
+func (s *Store) Path() string {
+	if s == nil {
+		return ""
+	}
+	return s.path
+}
+
+// Close releases the underlying database.
+func (s *Store) Close() error {
+	if s == nil || s.db == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// Put stores rec, replacing any response with the same id.
+func (s *Store) Put(rec *Record) error {
+	if rec == nil || rec.ID == "" {
+		return fmt.Errorf("responses store: record has no id")
+	}
+	if rec.CreatedAt.IsZero() {
+		rec.CreatedAt = time.Now()
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("responses store: encode %s: %w", rec.ID, err)
+	}
+	if err = s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(responsesBucket).Put([]byte(rec.ID), data)
+	}); err != nil {
+		return fmt.Errorf("responses store: write %s: %w", rec.ID, err)
+	}
+	if now := time.Now(); now.Sub(time.Unix(0, s.lastSweep.Load())) >= sweepInterval {
+		s.sweep(now)
+	}
+	return nil
+}
+
+// Get returns the response id created with apiKey.
+func (s *Store) Get(id, apiKey string) (*Record, error) {
+	var rec *Record
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(responsesBucket).Get([]byte(id))
+		if data == nil {
+			return ErrNotFound
+		}
+		rec = &Record{}
+		return json.Unmarshal(data, rec)
+	})
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("responses store: read %s: %w", id, err)
+	}
+	if rec.APIKey != apiKey || s.expired(rec, time.Now()) {
+		return nil, ErrNotFound
+	}
+	return rec, nil
+}
+
+// Delete removes the response id created with apiKey.
+func (s *Store) Delete(id, apiKey string) error {
+	if _, err := s.Get(id, apiKey); err != nil {
+		return err
+	}
+	if err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(responsesBucket).Delete([]byte(id))
+	}); err != nil {
+		return fmt.Errorf("responses store: delete %s: %w", id, err)
+	}
+	return nil
+}
+
+func (s *Store) expired(rec *Record, now time.Time) bool {
+	return !now.Before(rec.CreatedAt.Add(time.Duration(s.ttl.Load())))
+}
+
+// sweep drops expired responses.
+func (s *Store) sweep(now time.Time) {
+	s.lastSweep.Store(now.UnixNano())
+	var stale [][]byte
+	_ = s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(responsesBucket).ForEach(func(k, v []byte) error {
+			var rec Record
+			if err := json.Unmarshal(v, &rec); err != nil || s.expired(&rec, now) {
+				stale = append(stale, append([]byte(nil), k...))
+			}
+			return nil
+		})
+	})
+	if len(stale) == 0 {
+		return
+	}
+	if err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(responsesBucket)
+		for _, k := range stale {
+			if errDelete := bucket.Delete(k); errDelete != nil {
+				return errDelete
+			}
+		}
+		return nil
+	}); err != nil {
+		log.Warnf("responses store: failed to drop expired responses: %v", err)
+		return
+	}
+	log.Debugf("responses store: dropped %d expired responses", len(stale))
+}
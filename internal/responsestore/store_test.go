@@ -0,0 +1,89 @@
+package responsestore
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestStore_HistoryFollowsChainAndScopesByKey(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "responses.db"), time.Hour)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	first := &Record{
+		ID:       "resp_1",
+		APIKey:   "key-1",
+		Input:    InputItems(gjson.Parse(`"hello"`)),
+		Response: json.RawMessage(`{"id":"resp_1","output":[{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"output_text","text":"hi"}]}]}`),
+	}
+	second := &Record{
+		ID:                 "resp_2",
+		APIKey:             "key-1",
+		PreviousResponseID: "resp_1",
+		Input:              InputItems(gjson.Parse(`[{"type":"function_call_output","call_id":"c1","output":"42"}]`)),
+		Response:           json.RawMessage(`{"id":"resp_2","output":[{"id":"msg_2","type":"message","role":"assistant","content":[{"type":"output_text","text":"done"}]}]}`),
+	}
+	for _, rec := range []*Record{first, second} {
+		if err = s.Put(rec); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+
+	history, err := s.History("resp_2", "key-1")
+	if err != nil {
+		t.Fatalf("History: %v", err)
+	}
+	if len(history) != 4 {
+		t.Fatalf("history has %d items, want 4: %s", len(history), history)
+	}
+	if gjson.GetBytes(history[0], "content.0.text").String() != "hello" || gjson.GetBytes(history[0], "role").String() != "user" {
+		t.Fatalf("unexpected first item %s", history[0])
+	}
+	if gjson.GetBytes(history[1], "id").Exists() {
+		t.Fatalf("output items must not keep their ids: %s", history[1])
+	}
+	if gjson.GetBytes(history[2], "type").String() != "function_call_output" || gjson.GetBytes(history[3], "content.0.text").String() != "done" {
+		t.Fatalf("unexpected history order %s", history)
+	}
+
+	inputs, err := s.InputHistory("resp_2", "key-1")
+	if err != nil || len(inputs) != 3 {
+		t.Fatalf("InputHistory = %d items, %v; want 3", len(inputs), err)
+	}
+
+	if _, err = s.Get("resp_1", "key-2"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected another key not to see the response, got %v", err)
+	}
+	if err = s.Delete("resp_1", "key-1"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if history, err = s.History("resp_2", "key-1"); err != nil || len(history) != 2 {
+		t.Fatalf("after deleting the first response History = %d items, %v; want 2", len(history), err)
+	}
+}
+
+func TestStore_ExpiredResponsesAreNotFound(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "responses.db"), time.Hour)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	if err = s.Put(&Record{ID: "resp_old", Response: json.RawMessage(`{"id":"resp_old"}`), CreatedAt: time.Now().Add(-2 * time.Hour)}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if _, err = s.Get("resp_old", ""); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected expired response to be gone, got %v", err)
+	}
+	s.sweep(time.Now())
+	if err = s.Delete("resp_old", ""); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected sweep to drop the expired response, got %v", err)
+	}
+}
@@ -112,6 +112,9 @@ func (e *AntigravityExecutor) Execute(ctx context.Context, auth *cliproxyauth.Au
 	if opts.Alt == "responses/compact" {
 		return resp, statusErr{code: http.StatusNotImplemented, msg: "/responses/compact not supported"}
 	}
+	if opts.Alt == embeddingsAlt {
+		return resp, statusErr{code: http.StatusNotImplemented, msg: "embeddings not supported"}
+	}
 	baseModel := thinking.ParseSuffix(req.Model).ModelName
 	isClaude := strings.Contains(strings.ToLower(baseModel), "claude")
 
@@ -138,8 +141,8 @@ func (e *AntigravityExecutor) Execute(ctx context.Context, auth *cliproxyauth.Au
 		originalPayloadSource = opts.OriginalRequest
 	}
 	originalPayload := originalPayloadSource
-	originalTranslated := sdktranslator.TranslateRequest(from, to, baseModel, originalPayload, false)
-	translated := sdktranslator.TranslateRequest(from, to, baseModel, req.Payload, false)
+	originalTranslated := translateRequestTraced(ctx, from, to, baseModel, originalPayload, false)
+	translated := translateRequestTraced(ctx, from, to, baseModel, req.Payload, false)
 
 	translated, err = thinking.ApplyThinking(translated, req.Model, from.String(), to.String(), e.Identifier())
 	if err != nil {
@@ -231,7 +234,7 @@ attemptLoop:
 
 			reporter.publish(ctx, parseAntigravityUsage(bodyBytes))
 			var param any
-			converted := sdktranslator.TranslateNonStream(ctx, to, from, req.Model, opts.OriginalRequest, translated, bodyBytes, &param)
+			converted := translateNonStreamTraced(ctx, to, from, req.Model, opts.OriginalRequest, translated, bodyBytes, &param)
 			resp = cliproxyexecutor.Response{Payload: []byte(converted)}
 			reporter.ensurePublished(ctx)
 			return resp, nil
@@ -280,8 +283,8 @@ func (e *AntigravityExecutor) executeClaudeNonStream(ctx context.Context, auth *
 		originalPayloadSource = opts.OriginalRequest
 	}
 	originalPayload := originalPayloadSource
-	originalTranslated := sdktranslator.TranslateRequest(from, to, baseModel, originalPayload, true)
-	translated := sdktranslator.TranslateRequest(from, to, baseModel, req.Payload, true)
+	originalTranslated := translateRequestTraced(ctx, from, to, baseModel, originalPayload, true)
+	translated := translateRequestTraced(ctx, from, to, baseModel, req.Payload, true)
 
 	translated, err = thinking.ApplyThinking(translated, req.Model, from.String(), to.String(), e.Identifier())
 	if err != nil {
@@ -435,7 +438,7 @@ attemptLoop:
 
 			reporter.publish(ctx, parseAntigravityUsage(resp.Payload))
 			var param any
-			converted := sdktranslator.TranslateNonStream(ctx, to, from, req.Model, opts.OriginalRequest, translated, resp.Payload, &param)
+			converted := translateNonStreamTraced(ctx, to, from, req.Model, opts.OriginalRequest, translated, resp.Payload, &param)
 			resp = cliproxyexecutor.Response{Payload: []byte(converted)}
 			reporter.ensurePublished(ctx)
 
@@ -672,8 +675,8 @@ func (e *AntigravityExecutor) ExecuteStream(ctx context.Context, auth *cliproxya
 		originalPayloadSource = opts.OriginalRequest
 	}
 	originalPayload := originalPayloadSource
-	originalTranslated := sdktranslator.TranslateRequest(from, to, baseModel, originalPayload, true)
-	translated := sdktranslator.TranslateRequest(from, to, baseModel, req.Payload, true)
+	originalTranslated := translateRequestTraced(ctx, from, to, baseModel, originalPayload, true)
+	translated := translateRequestTraced(ctx, from, to, baseModel, req.Payload, true)
 
 	translated, err = thinking.ApplyThinking(translated, req.Model, from.String(), to.String(), e.Identifier())
 	if err != nil {
@@ -774,6 +777,9 @@ attemptLoop:
 				return nil, err
 			}
 
+			// In-stream error events only fail the stream when mid-stream failover is enabled;
+			// otherwise they are translated like any other chunk and the stream is finished.
+			failover := e.cfg != nil && e.cfg.Streaming.FailoverRetries > 0
 			out := make(chan cliproxyexecutor.StreamChunk)
 			stream = out
 			go func(resp *http.Response) {
@@ -798,6 +804,12 @@ attemptLoop:
 					if payload == nil {
 						continue
 					}
+					if errEvent := antigravityStreamError(payload); errEvent != nil && failover {
+						recordAPIResponseError(ctx, e.cfg, errEvent)
+						reporter.publishFailure(ctx)
+						out <- cliproxyexecutor.StreamChunk{Err: errEvent}
+						return
+					}
 
 					if detail, ok := parseAntigravityStreamUsage(payload); ok {
 						reporter.publish(ctx, detail)
@@ -808,11 +820,16 @@ attemptLoop:
 						out <- cliproxyexecutor.StreamChunk{Payload: []byte(chunks[i])}
 					}
 				}
-				tail := sdktranslator.TranslateStream(ctx, to, from, req.Model, opts.OriginalRequest, translated, []byte("[DONE]"), &param)
-				for i := range tail {
-					out <- cliproxyexecutor.StreamChunk{Payload: []byte(tail[i])}
+				errScan := scanner.Err()
+				// With mid-stream failover enabled a failed stream must not be finished first,
+				// otherwise the client sees a complete answer before the continuation.
+				if errScan == nil || !failover {
+					tail := sdktranslator.TranslateStream(ctx, to, from, req.Model, opts.OriginalRequest, translated, []byte("[DONE]"), &param)
+					for i := range tail {
+						out <- cliproxyexecutor.StreamChunk{Payload: []byte(tail[i])}
+					}
 				}
-				if errScan := scanner.Err(); errScan != nil {
+				if errScan != nil {
 					recordAPIResponseError(ctx, e.cfg, errScan)
 					reporter.publishFailure(ctx)
 					out <- cliproxyexecutor.StreamChunk{Err: errScan}
@@ -843,6 +860,20 @@ attemptLoop:
 	return nil, err
 }
 
+// antigravityStreamError converts an error event sent inside the stream (e.g. "overloaded")
+// into a stream error so a stream that died mid-way can be continued on another credential.
+func antigravityStreamError(payload []byte) error {
+	errNode := gjson.GetBytes(payload, "error")
+	if !errNode.IsObject() {
+		return nil
+	}
+	code := int(errNode.Get("code").Int())
+	if code < http.StatusBadRequest {
+		code = http.StatusServiceUnavailable
+	}
+	return statusErr{code: code, msg: string(payload)}
+}
+
 // Refresh refreshes the authentication credentials using the refresh token.
 func (e *AntigravityExecutor) Refresh(ctx context.Context, auth *cliproxyauth.Auth) (*cliproxyauth.Auth, error) {
 	if auth == nil {
@@ -857,6 +888,9 @@ func (e *AntigravityExecutor) Refresh(ctx context.Context, auth *cliproxyauth.Au
 
 // CountTokens counts tokens for the given request using the Antigravity API.
 func (e *AntigravityExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	if localTokenCounting(e.cfg) {
+		return countTokensLocally(ctx, req, opts)
+	}
 	baseModel := thinking.ParseSuffix(req.Model).ModelName
 
 	token, updatedAuth, errToken := e.ensureAccessToken(ctx, auth)
@@ -875,7 +909,7 @@ func (e *AntigravityExecutor) CountTokens(ctx context.Context, auth *cliproxyaut
 	respCtx := context.WithValue(ctx, "alt", opts.Alt)
 
 	// Prepare payload once (doesn't depend on baseURL)
-	payload := sdktranslator.TranslateRequest(from, to, baseModel, req.Payload, false)
+	payload := translateRequestTraced(ctx, from, to, baseModel, req.Payload, false)
 
 	payload, err := thinking.ApplyThinking(payload, req.Model, from.String(), to.String(), e.Identifier())
 	if err != nil {
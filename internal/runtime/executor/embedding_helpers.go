@@ -0,0 +1,197 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	geminiembeddings "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/openai/embeddings"
+	openaiembeddings "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/openai/gemini/embeddings"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// embeddingsAlt marks an embeddings execution. Handlers pass it through opts.Alt for
+// OpenAI /v1/embeddings and Gemini :embedContent/:batchEmbedContents requests; Gemini
+// sources are always normalized to the batchEmbedContents shape.
+const embeddingsAlt = "embeddings"
+
+// geminiEmbeddingsRequest builds a Gemini batchEmbedContents body from the source payload.
+func geminiEmbeddingsRequest(from sdktranslator.Format, model string, payload []byte) ([]byte, error) {
+	switch from.String() {
+	case "openai":
+		body, err := geminiembeddings.ConvertOpenAIRequestToGemini(model, payload)
+		if err != nil {
+			return nil, statusErr{code: http.StatusBadRequest, msg: err.Error()}
+		}
+		return body, nil
+	case "gemini":
+		body := payload
+		for i := range gjson.GetBytes(payload, "requests").Array() {
+			body, _ = sjson.SetBytes(body, fmt.Sprintf("requests.%d.model", i), "models/"+model)
+		}
+		return body, nil
+	default:
+		return nil, statusErr{code: http.StatusBadRequest, msg: fmt.Sprintf("embeddings are not supported for %s requests", from)}
+	}
+}
+
+// geminiEmbeddingsResponse converts a Gemini batchEmbedContents response into the source format.
+func geminiEmbeddingsResponse(from sdktranslator.Format, model string, originalRequest, data []byte, promptTokens int64) []byte {
+	if from.String() == "openai" {
+		return geminiembeddings.ConvertGeminiResponseToOpenAI(model, originalRequest, data, promptTokens)
+	}
+	return data
+}
+
+// geminiEmbeddingsPromptTokens estimates the input tokens of a batchEmbedContents body locally,
+// because Gemini does not report usage for embeddings.
+func geminiEmbeddingsPromptTokens(model string, body []byte) int64 {
+	enc, err := getTokenizer(model)
+	if err != nil {
+		return 0
+	}
+	var segments []string
+	for _, request := range gjson.GetBytes(body, "requests").Array() {
+		collectGeminiParts(request.Get("content.parts"), &segments)
+	}
+	count, err := enc.Count(strings.Join(segments, "\n"))
+	if err != nil {
+		return 0
+	}
+	return int64(count)
+}
+
+// openAIEmbeddingsRequest builds an OpenAI /embeddings body from the source payload.
+func openAIEmbeddingsRequest(from sdktranslator.Format, model string, payload []byte) ([]byte, error) {
+	switch from.String() {
+	case "openai":
+		body, _ := sjson.SetBytes(payload, "model", model)
+		return body, nil
+	case "gemini":
+		body, err := openaiembeddings.ConvertGeminiRequestToOpenAI(model, payload)
+		if err != nil {
+			return nil, statusErr{code: http.StatusBadRequest, msg: err.Error()}
+		}
+		return body, nil
+	default:
+		return nil, statusErr{code: http.StatusBadRequest, msg: fmt.Sprintf("embeddings are not supported for %s requests", from)}
+	}
+}
+
+// openAIEmbeddingsResponse converts an OpenAI embeddings response into the source format.
+func openAIEmbeddingsResponse(from sdktranslator.Format, data []byte) []byte {
+	if from.String() == "gemini" {
+		return openaiembeddings.ConvertOpenAIResponseToGemini(data)
+	}
+	return data
+}
+
+// vertexPredictEmbeddingsRequest converts a Gemini batchEmbedContents body into the
+// Vertex AI :predict body used by its embedding models.
+func vertexPredictEmbeddingsRequest(body []byte) []byte {
+	out := []byte(`{"instances":[]}`)
+	requests := gjson.GetBytes(body, "requests").Array()
+	for _, request := range requests {
+		var parts []string
+		for _, part := range request.Get("content.parts").Array() {
+			if text := part.Get("text"); text.Exists() {
+				parts = append(parts, text.String())
+			}
+		}
+		instance, _ := sjson.SetBytes([]byte(`{}`), "content", strings.Join(parts, "\n"))
+		if taskType := request.Get("taskType").String(); taskType != "" {
+			instance, _ = sjson.SetBytes(instance, "task_type", taskType)
+		}
+		if title := request.Get("title").String(); title != "" {
+			instance, _ = sjson.SetBytes(instance, "title", title)
+		}
+		out, _ = sjson.SetRawBytes(out, "instances.-1", instance)
+	}
+	if len(requests) > 0 {
+		if dimensions := requests[0].Get("outputDimensionality").Int(); dimensions > 0 {
+			out, _ = sjson.SetBytes(out, "parameters.outputDimensionality", dimensions)
+		}
+	}
+	return out
+}
+
+// vertexPredictEmbeddingsResponse converts a Vertex AI :predict embeddings response into the
+// Gemini batchEmbedContents shape and returns the total token count reported by Vertex.
+func vertexPredictEmbeddingsResponse(data []byte) ([]byte, int64) {
+	out := []byte(`{"embeddings":[]}`)
+	var tokens int64
+	for _, prediction := range gjson.GetBytes(data, "predictions").Array() {
+		raw := prediction.Get("embeddings.values").Raw
+		if raw == "" {
+			raw = "[]"
+		}
+		embedding, _ := sjson.SetRawBytes([]byte(`{}`), "values", []byte(raw))
+		out, _ = sjson.SetRawBytes(out, "embeddings.-1", embedding)
+		tokens += prediction.Get("embeddings.statistics.token_count").Int()
+	}
+	return out, tokens
+}
+
+// postEmbeddings sends an embeddings request upstream with request logging and returns the
+// successful response body. prepare attaches provider credentials and headers.
+func postEmbeddings(ctx context.Context, cfg *config.Config, auth *cliproxyauth.Auth, provider, url string, body []byte, prepare func(*http.Request)) ([]byte, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if prepare != nil {
+		prepare(httpReq)
+	}
+	var authID, authLabel, authType, authValue string
+	if auth != nil {
+		authID = auth.ID
+		authLabel = auth.Label
+		authType, authValue = auth.AccountInfo()
+	}
+	recordAPIRequest(ctx, cfg, upstreamRequestLog{
+		URL:       url,
+		Method:    http.MethodPost,
+		Headers:   httpReq.Header.Clone(),
+		Body:      body,
+		Provider:  provider,
+		AuthID:    authID,
+		AuthLabel: authLabel,
+		AuthType:  authType,
+		AuthValue: authValue,
+	})
+
+	httpClient := newProxyAwareHTTPClient(ctx, cfg, auth, 0)
+	httpResp, err := httpClient.Do(httpReq)
+	if err != nil {
+		recordAPIResponseError(ctx, cfg, err)
+		return nil, err
+	}
+	defer func() {
+		if errClose := httpResp.Body.Close(); errClose != nil {
+			log.Errorf("%s executor: close embeddings response body error: %v", provider, errClose)
+		}
+	}()
+	recordAPIResponseMetadata(ctx, cfg, httpResp.StatusCode, httpResp.Header.Clone())
+	if httpResp.StatusCode < 200 || httpResp.StatusCode >= 300 {
+		b, _ := io.ReadAll(httpResp.Body)
+		appendAPIResponseChunk(ctx, cfg, b)
+		logWithRequestID(ctx).Debugf("request error, error status: %d, error message: %s", httpResp.StatusCode, summarizeErrorBody(httpResp.Header.Get("Content-Type"), b))
+		return nil, statusErr{code: httpResp.StatusCode, msg: string(b)}
+	}
+	data, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		recordAPIResponseError(ctx, cfg, err)
+		return nil, err
+	}
+	appendAPIResponseChunk(ctx, cfg, data)
+	return data, nil
+}
@@ -0,0 +1,118 @@
+package executor
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+)
+
+func TestGeminiExecutorEmbeddingsFromOpenAI(t *testing.T) {
+	var gotPath string
+	var gotBody []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotBody, _ = io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"embeddings":[{"values":[0.5,-1]},{"values":[0.25,2]}]}`))
+	}))
+	defer server.Close()
+
+	executor := NewGeminiExecutor(&config.Config{})
+	auth := &cliproxyauth.Auth{Attributes: map[string]string{"base_url": server.URL, "api_key": "test"}}
+	payload := []byte(`{"model":"gemini-embedding-001","input":["a","b"],"dimensions":2}`)
+	resp, err := executor.Execute(context.Background(), auth, cliproxyexecutor.Request{
+		Model:   "gemini-embedding-001",
+		Payload: payload,
+	}, cliproxyexecutor.Options{
+		SourceFormat:    sdktranslator.FromString("openai"),
+		OriginalRequest: payload,
+		Alt:             "embeddings",
+	})
+	if err != nil {
+		t.Fatalf("Execute error: %v", err)
+	}
+	if gotPath != "/v1beta/models/gemini-embedding-001:batchEmbedContents" {
+		t.Fatalf("path = %q", gotPath)
+	}
+	if got := gjson.GetBytes(gotBody, "requests.1.content.parts.0.text").String(); got != "b" {
+		t.Fatalf("second request text = %q, body = %s", got, gotBody)
+	}
+	if got := gjson.GetBytes(gotBody, "requests.0.outputDimensionality").Int(); got != 2 {
+		t.Fatalf("outputDimensionality = %d", got)
+	}
+	if gjson.GetBytes(resp.Payload, "object").String() != "list" || gjson.GetBytes(resp.Payload, "data.1.index").Int() != 1 {
+		t.Fatalf("unexpected payload: %s", resp.Payload)
+	}
+	if got := gjson.GetBytes(resp.Payload, "data.1.embedding").Raw; got != "[0.25,2]" {
+		t.Fatalf("embedding = %s", got)
+	}
+	if got := gjson.GetBytes(resp.Payload, "usage.prompt_tokens").Int(); got <= 0 {
+		t.Fatalf("prompt_tokens = %d, want an estimate", got)
+	}
+}
+
+func TestClaudeExecutorRejectsEmbeddings(t *testing.T) {
+	executor := NewClaudeExecutor(&config.Config{})
+	payload := []byte(`{"model":"claude-sonnet-4-5","input":"hello"}`)
+	_, err := executor.Execute(context.Background(), &cliproxyauth.Auth{}, cliproxyexecutor.Request{
+		Model:   "claude-sonnet-4-5",
+		Payload: payload,
+	}, cliproxyexecutor.Options{SourceFormat: sdktranslator.FromString("openai"), Alt: "embeddings"})
+	se, ok := err.(statusErr)
+	if !ok || se.StatusCode() != http.StatusNotImplemented {
+		t.Fatalf("err = %v, want 501 statusErr", err)
+	}
+}
+
+func TestGeminiExecutorEmbeddingsRejectsTokenInput(t *testing.T) {
+	executor := NewGeminiExecutor(&config.Config{})
+	payload := []byte(`{"model":"gemini-embedding-001","input":[1,2,3]}`)
+	_, err := executor.Execute(context.Background(), &cliproxyauth.Auth{}, cliproxyexecutor.Request{
+		Model:   "gemini-embedding-001",
+		Payload: payload,
+	}, cliproxyexecutor.Options{SourceFormat: sdktranslator.FromString("openai"), Alt: "embeddings"})
+	se, ok := err.(statusErr)
+	if !ok || se.StatusCode() != http.StatusBadRequest {
+		t.Fatalf("err = %v, want 400 statusErr", err)
+	}
+}
+
+func TestOpenAICompatExecutorEmbeddingsFromGemini(t *testing.T) {
+	var gotPath string
+	var gotBody []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotBody, _ = io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"object":"list","data":[{"object":"embedding","index":1,"embedding":[3]},{"object":"embedding","index":0,"embedding":[1,2]}],"usage":{"prompt_tokens":4,"total_tokens":4}}`))
+	}))
+	defer server.Close()
+
+	executor := NewOpenAICompatExecutor("openai-compatibility", &config.Config{})
+	auth := &cliproxyauth.Auth{Attributes: map[string]string{"base_url": server.URL + "/v1", "api_key": "test"}}
+	payload := []byte(`{"requests":[{"content":{"parts":[{"text":"x"}]}},{"content":{"parts":[{"text":"y"},{"text":"z"}]}}]}`)
+	resp, err := executor.Execute(context.Background(), auth, cliproxyexecutor.Request{
+		Model:   "text-embedding-3-small",
+		Payload: payload,
+	}, cliproxyexecutor.Options{SourceFormat: sdktranslator.FromString("gemini"), Alt: "embeddings"})
+	if err != nil {
+		t.Fatalf("Execute error: %v", err)
+	}
+	if gotPath != "/v1/embeddings" {
+		t.Fatalf("path = %q", gotPath)
+	}
+	if got := gjson.GetBytes(gotBody, "input").Raw; got != `["x","y\nz"]` {
+		t.Fatalf("input = %s", got)
+	}
+	if got := string(resp.Payload); got != `{"embeddings":[{"values":[1,2]},{"values":[3]}]}` {
+		t.Fatalf("payload = %s", got)
+	}
+}
@@ -12,7 +12,6 @@ import (
 	"io"
 	"net"
 	"net/http"
-	"os"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -21,10 +20,12 @@ import (
 
 	"github.com/google/uuid"
 	kiroauth "github.com/router-for-me/CLIProxyAPI/v6/internal/auth/kiro"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	kiroclaude "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/kiro/claude"
 	kirocommon "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/kiro/common"
 	kiroopenai "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/kiro/openai"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tracing"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
@@ -276,7 +277,7 @@ func getKiroPooledHTTPClient() *http.Client {
 		}
 
 		kiroHTTPClientPool = &http.Client{
-			Transport: transport,
+			Transport: tracing.Transport(transport),
 			// No global timeout - let individual requests set their own timeouts via context
 		}
 
@@ -553,6 +554,9 @@ func getTokenKey(auth *cliproxyauth.Auth) string {
 // Execute sends the request to Kiro API and returns the response.
 // Supports automatic token refresh on 401/403 errors.
 func (e *KiroExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (resp cliproxyexecutor.Response, err error) {
+	if opts.Alt == embeddingsAlt {
+		return resp, statusErr{code: http.StatusNotImplemented, msg: "embeddings not supported"}
+	}
 	accessToken, profileArn := kiroCredentials(auth)
 	if accessToken == "" {
 		return resp, fmt.Errorf("kiro: access token not found in auth")
@@ -610,7 +614,7 @@ func (e *KiroExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, req
 
 	from := opts.SourceFormat
 	to := sdktranslator.FromString("kiro")
-	body := sdktranslator.TranslateRequest(from, to, req.Model, bytes.Clone(req.Payload), true)
+	body := translateRequestTraced(ctx, from, to, req.Model, bytes.Clone(req.Payload), true)
 
 	kiroModelID := e.mapModelToKiro(req.Model)
 
@@ -944,7 +948,7 @@ func (e *KiroExecutor) executeWithRetry(ctx context.Context, auth *cliproxyauth.
 			// Build response in Claude format for Kiro translator
 			// stopReason is extracted from upstream response by parseEventStream
 			kiroResponse := kiroclaude.BuildClaudeResponse(content, toolUses, req.Model, usageInfo, stopReason)
-			out := sdktranslator.TranslateNonStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), body, kiroResponse, nil)
+			out := translateNonStreamTraced(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), body, kiroResponse, nil)
 			resp = cliproxyexecutor.Response{Payload: []byte(out)}
 			return resp, nil
 		}
@@ -1020,7 +1024,7 @@ func (e *KiroExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.Aut
 
 	from := opts.SourceFormat
 	to := sdktranslator.FromString("kiro")
-	body := sdktranslator.TranslateRequest(from, to, req.Model, bytes.Clone(req.Payload), true)
+	body := translateRequestTraced(ctx, from, to, req.Model, bytes.Clone(req.Payload), true)
 
 	kiroModelID := e.mapModelToKiro(req.Model)
 
@@ -3454,48 +3458,10 @@ func (e *KiroExecutor) streamToChannel(ctx context.Context, body io.Reader, out
 // NOTE: Claude SSE event builders moved to internal/translator/kiro/claude/kiro_claude_stream.go
 // The executor now uses kiroclaude.BuildClaude*Event() functions instead
 
-// CountTokens counts tokens locally using tiktoken since Kiro API doesn't expose a token counting endpoint.
-// This provides approximate token counts for client requests.
-func (e *KiroExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
-	// Use tiktoken for local token counting
-	enc, err := getTokenizer(req.Model)
-	if err != nil {
-		log.Warnf("kiro: CountTokens failed to get tokenizer: %v, falling back to estimate", err)
-		// Fallback: estimate from payload size (roughly 4 chars per token)
-		estimatedTokens := len(req.Payload) / 4
-		if estimatedTokens == 0 && len(req.Payload) > 0 {
-			estimatedTokens = 1
-		}
-		return cliproxyexecutor.Response{
-			Payload: []byte(fmt.Sprintf(`{"count":%d}`, estimatedTokens)),
-		}, nil
-	}
-
-	// Try to count tokens from the request payload
-	var totalTokens int64
-
-	// Try OpenAI chat format first
-	if tokens, countErr := countOpenAIChatTokens(enc, req.Payload); countErr == nil && tokens > 0 {
-		totalTokens = tokens
-		log.Debugf("kiro: CountTokens counted %d tokens using OpenAI chat format", totalTokens)
-	} else {
-		// Fallback: count raw payload tokens
-		if tokenCount, countErr := enc.Count(string(req.Payload)); countErr == nil {
-			totalTokens = int64(tokenCount)
-			log.Debugf("kiro: CountTokens counted %d tokens from raw payload", totalTokens)
-		} else {
-			// Final fallback: estimate from payload size
-			totalTokens = int64(len(req.Payload) / 4)
-			if totalTokens == 0 && len(req.Payload) > 0 {
-				totalTokens = 1
-			}
-			log.Debugf("kiro: CountTokens estimated %d tokens from payload size", totalTokens)
-		}
-	}
-
-	return cliproxyexecutor.Response{
-		Payload: []byte(fmt.Sprintf(`{"count":%d}`, totalTokens)),
-	}, nil
+// CountTokens counts tokens locally since Kiro API doesn't expose a token counting endpoint.
+// This provides approximate token counts in the client's format.
+func (e *KiroExecutor) CountTokens(ctx context.Context, _ *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return countTokensLocally(ctx, req, opts)
 }
 
 // Refresh refreshes the Kiro OAuth token.
@@ -3701,12 +3667,8 @@ func (e *KiroExecutor) persistRefreshedAuth(auth *cliproxyauth.Auth) error {
 	}
 
 	// Write to temp file first, then rename (atomic write)
-	tmp := authPath + ".tmp"
-	if err := os.WriteFile(tmp, raw, 0o600); err != nil {
-		return fmt.Errorf("kiro executor: write temp auth file failed: %w", err)
-	}
-	if err := os.Rename(tmp, authPath); err != nil {
-		return fmt.Errorf("kiro executor: rename auth file failed: %w", err)
+	if err := authcrypt.WriteFile(authPath, raw, 0o600); err != nil {
+		return fmt.Errorf("kiro executor: write auth file failed: %w", err)
 	}
 
 	log.Debugf("kiro executor: persisted refreshed auth to %s", authPath)
@@ -3743,7 +3705,7 @@ func (e *KiroExecutor) reloadAuthFromFile(auth *cliproxyauth.Auth) (*cliproxyaut
 	}
 
 	// 读取文件
-	raw, err := os.ReadFile(authPath)
+	raw, err := authcrypt.ReadFile(authPath)
 	if err != nil {
 		return nil, fmt.Errorf("kiro executor: failed to read auth file %s: %w", authPath, err)
 	}
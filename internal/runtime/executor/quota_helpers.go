@@ -0,0 +1,220 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"golang.org/x/oauth2"
+)
+
+const (
+	anthropicUnifiedPrefix = "anthropic-ratelimit-unified-"
+	anthropicLimitPrefix   = "anthropic-ratelimit-"
+
+	// geminiCLIQuotaInterval throttles retrieveUserQuota lookups per credential.
+	geminiCLIQuotaInterval = 5 * time.Minute
+	geminiCLIQuotaTimeout  = 15 * time.Second
+)
+
+var anthropicLimitKinds = []string{"requests", "tokens", "input-tokens", "output-tokens"}
+
+// reportClaudeQuota forwards Anthropic rate-limit headers to the auth manager.
+func reportClaudeQuota(ctx context.Context, headers http.Header) {
+	cliproxyauth.ReportUpstreamQuota(ctx, parseClaudeQuotaHeaders(headers, time.Now()))
+}
+
+// parseClaudeQuotaHeaders reads both the unified subscription windows sent to OAuth
+// accounts (5h, 7d, ...) and the per-minute request/token limits sent to API keys.
+func parseClaudeQuotaHeaders(headers http.Header, now time.Time) *cliproxyauth.UpstreamQuota {
+	if len(headers) == 0 {
+		return nil
+	}
+	quota := &cliproxyauth.UpstreamQuota{Source: "anthropic", UpdatedAt: now}
+	for key, values := range headers {
+		name := strings.ToLower(key)
+		if !strings.HasPrefix(name, anthropicUnifiedPrefix) || !strings.HasSuffix(name, "-utilization") || len(values) == 0 {
+			continue
+		}
+		window := strings.TrimSuffix(strings.TrimPrefix(name, anthropicUnifiedPrefix), "-utilization")
+		utilization, err := strconv.ParseFloat(strings.TrimSpace(values[0]), 64)
+		if err != nil || window == "" {
+			continue
+		}
+		quota.Windows = append(quota.Windows, cliproxyauth.QuotaWindow{
+			Name:        window,
+			UsedPercent: utilization * 100,
+			ResetAt:     parseUnixHeader(headers.Get(anthropicUnifiedPrefix + window + "-reset")),
+		})
+	}
+	quota.Status = strings.TrimSpace(headers.Get(anthropicUnifiedPrefix + "status"))
+
+	for _, kind := range anthropicLimitKinds {
+		limit, errLimit := strconv.ParseInt(strings.TrimSpace(headers.Get(anthropicLimitPrefix+kind+"-limit")), 10, 64)
+		remaining, errRemaining := strconv.ParseInt(strings.TrimSpace(headers.Get(anthropicLimitPrefix+kind+"-remaining")), 10, 64)
+		if errLimit != nil || errRemaining != nil || limit <= 0 {
+			continue
+		}
+		window := cliproxyauth.QuotaWindow{Name: kind, Limit: limit, Remaining: remaining}
+		window.UsedPercent = float64(limit-remaining) * 100 / float64(limit)
+		if reset, err := time.Parse(time.RFC3339, strings.TrimSpace(headers.Get(anthropicLimitPrefix+kind+"-reset"))); err == nil {
+			window.ResetAt = reset
+		}
+		quota.Windows = append(quota.Windows, window)
+	}
+	if len(quota.Windows) == 0 {
+		return nil
+	}
+	return quota
+}
+
+// reportCodexQuota forwards ChatGPT subscription usage headers to the auth manager.
+func reportCodexQuota(ctx context.Context, headers http.Header) {
+	cliproxyauth.ReportUpstreamQuota(ctx, parseCodexQuotaHeaders(headers, time.Now()))
+}
+
+// parseCodexQuotaHeaders reads the x-codex-primary-* and x-codex-secondary-* usage windows.
+func parseCodexQuotaHeaders(headers http.Header, now time.Time) *cliproxyauth.UpstreamQuota {
+	if len(headers) == 0 {
+		return nil
+	}
+	quota := &cliproxyauth.UpstreamQuota{Source: "codex", UpdatedAt: now}
+	for _, slot := range []string{"primary", "secondary"} {
+		prefix := "x-codex-" + slot + "-"
+		used, err := strconv.ParseFloat(strings.TrimSpace(headers.Get(prefix+"used-percent")), 64)
+		if err != nil {
+			continue
+		}
+		window := cliproxyauth.QuotaWindow{Name: slot, UsedPercent: used}
+		if minutes, errMinutes := strconv.Atoi(strings.TrimSpace(headers.Get(prefix + "window-minutes"))); errMinutes == nil && minutes > 0 {
+			window.Name = quotaWindowName(minutes)
+		}
+		if seconds, errReset := strconv.ParseInt(strings.TrimSpace(headers.Get(prefix+"reset-after-seconds")), 10, 64); errReset == nil && seconds >= 0 {
+			window.ResetAt = now.Add(time.Duration(seconds) * time.Second)
+		} else {
+			window.ResetAt = parseUnixHeader(headers.Get(prefix + "reset-at"))
+		}
+		quota.Windows = append(quota.Windows, window)
+	}
+	if len(quota.Windows) == 0 {
+		return nil
+	}
+	return quota
+}
+
+// parseGeminiCLIQuota converts a retrieveUserQuota response into per-model windows.
+func parseGeminiCLIQuota(data []byte, now time.Time) *cliproxyauth.UpstreamQuota {
+	quota := &cliproxyauth.UpstreamQuota{Source: "gemini-cli", UpdatedAt: now}
+	for _, bucket := range gjson.GetBytes(data, "buckets").Array() {
+		fraction := bucket.Get("remainingFraction")
+		if !fraction.Exists() {
+			continue
+		}
+		window := cliproxyauth.QuotaWindow{
+			Name:        strings.ToLower(bucket.Get("tokenType").String()),
+			Model:       bucket.Get("modelId").String(),
+			UsedPercent: (1 - fraction.Float()) * 100,
+		}
+		if window.Name == "" {
+			window.Name = "requests"
+		}
+		if reset, err := time.Parse(time.RFC3339, bucket.Get("resetTime").String()); err == nil {
+			window.ResetAt = reset
+		}
+		quota.Windows = append(quota.Windows, window)
+	}
+	if len(quota.Windows) == 0 {
+		return nil
+	}
+	return quota
+}
+
+var geminiCLIQuotaChecks sync.Map // auth ID -> time.Time of the last lookup
+
+// refreshGeminiCLIQuota looks up the remaining Code Assist quota for the credential in the
+// background, at most once per geminiCLIQuotaInterval.
+func (e *GeminiCLIExecutor) refreshGeminiCLIQuota(ctx context.Context, auth *cliproxyauth.Auth, tokenSource oauth2.TokenSource) {
+	projectID := resolveGeminiProjectID(auth)
+	if auth == nil || auth.ID == "" || projectID == "" || tokenSource == nil {
+		return
+	}
+	now := time.Now()
+	if last, ok := geminiCLIQuotaChecks.Load(auth.ID); ok && now.Sub(last.(time.Time)) < geminiCLIQuotaInterval {
+		return
+	}
+	geminiCLIQuotaChecks.Store(auth.ID, now)
+
+	quotaCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), geminiCLIQuotaTimeout)
+	go func() {
+		defer cancel()
+		data, err := e.retrieveUserQuota(quotaCtx, auth, tokenSource, projectID)
+		if err != nil {
+			log.Debugf("gemini cli executor: retrieve user quota for %s: %v", auth.ID, err)
+			return
+		}
+		cliproxyauth.ReportUpstreamQuota(quotaCtx, parseGeminiCLIQuota(data, time.Now()))
+	}()
+}
+
+func (e *GeminiCLIExecutor) retrieveUserQuota(ctx context.Context, auth *cliproxyauth.Auth, tokenSource oauth2.TokenSource, projectID string) ([]byte, error) {
+	tok, err := tokenSource.Token()
+	if err != nil {
+		return nil, err
+	}
+	body := []byte(fmt.Sprintf(`{"project":%q}`, projectID))
+	url := fmt.Sprintf("%s/%s:%s", codeAssistEndpoint, codeAssistVersion, "retrieveUserQuota")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+tok.AccessToken)
+	applyGeminiCLIHeaders(req)
+
+	resp, err := newHTTPClient(ctx, e.cfg, auth, 0).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if errClose := resp.Body.Close(); errClose != nil {
+			log.Errorf("gemini cli executor: close quota response body error: %v", errClose)
+		}
+	}()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, statusErr{code: resp.StatusCode, msg: string(data)}
+	}
+	return data, nil
+}
+
+func parseUnixHeader(value string) time.Time {
+	seconds, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil || seconds <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0)
+}
+
+// quotaWindowName renders a window length in minutes the way providers label them ("5h", "7d").
+func quotaWindowName(minutes int) string {
+	switch {
+	case minutes%1440 == 0:
+		return fmt.Sprintf("%dd", minutes/1440)
+	case minutes%60 == 0:
+		return fmt.Sprintf("%dh", minutes/60)
+	default:
+		return fmt.Sprintf("%dm", minutes)
+	}
+}
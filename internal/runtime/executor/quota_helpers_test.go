@@ -0,0 +1,84 @@
+package executor
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseClaudeQuotaHeaders(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	headers := http.Header{}
+	headers.Set("anthropic-ratelimit-unified-status", "allowed_warning")
+	headers.Set("anthropic-ratelimit-unified-5h-utilization", "0.92")
+	headers.Set("anthropic-ratelimit-unified-5h-reset", "1700003600")
+	headers.Set("anthropic-ratelimit-unified-7d-utilization", "0.4")
+	headers.Set("anthropic-ratelimit-tokens-limit", "1000")
+	headers.Set("anthropic-ratelimit-tokens-remaining", "250")
+
+	quota := parseClaudeQuotaHeaders(headers, now)
+	if quota == nil || quota.Status != "allowed_warning" || len(quota.Windows) != 3 {
+		t.Fatalf("unexpected quota %+v", quota)
+	}
+	headroom, ok := quota.Headroom("claude-sonnet-4-5", now)
+	if !ok || headroom < 7.99 || headroom > 8.01 {
+		t.Fatalf("expected 8%% headroom from the 5h window, got %v (ok=%v)", headroom, ok)
+	}
+	for _, window := range quota.Windows {
+		if window.Name == "5h" && !window.ResetAt.Equal(time.Unix(1700003600, 0)) {
+			t.Fatalf("unexpected 5h reset %v", window.ResetAt)
+		}
+		if window.Name == "tokens" && window.RemainingPercent() != 25 {
+			t.Fatalf("unexpected tokens remaining %v", window.RemainingPercent())
+		}
+	}
+
+	if parseClaudeQuotaHeaders(http.Header{"Content-Type": []string{"application/json"}}, now) != nil {
+		t.Fatal("expected no quota without rate-limit headers")
+	}
+}
+
+func TestParseCodexQuotaHeaders(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	headers := http.Header{}
+	headers.Set("x-codex-primary-used-percent", "35.5")
+	headers.Set("x-codex-primary-window-minutes", "300")
+	headers.Set("x-codex-primary-reset-after-seconds", "600")
+	headers.Set("x-codex-secondary-used-percent", "80")
+	headers.Set("x-codex-secondary-window-minutes", "10080")
+
+	quota := parseCodexQuotaHeaders(headers, now)
+	if quota == nil || len(quota.Windows) != 2 {
+		t.Fatalf("unexpected quota %+v", quota)
+	}
+	if quota.Windows[0].Name != "5h" || !quota.Windows[0].ResetAt.Equal(now.Add(10*time.Minute)) {
+		t.Fatalf("unexpected primary window %+v", quota.Windows[0])
+	}
+	if quota.Windows[1].Name != "7d" {
+		t.Fatalf("unexpected secondary window %+v", quota.Windows[1])
+	}
+	if headroom, _ := quota.Headroom("gpt-5-codex", now); headroom != 20 {
+		t.Fatalf("expected 20%% headroom, got %v", headroom)
+	}
+}
+
+func TestParseGeminiCLIQuota(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	data := []byte(`{"buckets":[
+		{"remainingFraction":0.02,"resetTime":"2030-01-01T00:00:00Z","tokenType":"REQUESTS","modelId":"gemini-2.5-pro"},
+		{"remainingFraction":0.9,"resetTime":"2030-01-01T00:00:00Z","tokenType":"REQUESTS","modelId":"gemini-2.5-flash"}
+	]}`)
+	quota := parseGeminiCLIQuota(data, now)
+	if quota == nil || len(quota.Windows) != 2 {
+		t.Fatalf("unexpected quota %+v", quota)
+	}
+	if headroom, ok := quota.Headroom("gemini-2.5-pro", now); !ok || headroom > 2.01 {
+		t.Fatalf("expected pro to be nearly exhausted, got %v", headroom)
+	}
+	if headroom, ok := quota.Headroom("gemini-2.5-flash", now); !ok || headroom < 89.99 {
+		t.Fatalf("expected flash to have headroom, got %v", headroom)
+	}
+	if _, ok := quota.Headroom("gemini-3-pro", now); ok {
+		t.Fatal("expected no quota for an unreported model")
+	}
+}
@@ -0,0 +1,275 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+)
+
+// maxImageHeaderBase64 bounds how much of a base64 image is decoded to read its dimensions.
+const maxImageHeaderBase64 = 64 << 10
+
+// localTokenCounting reports whether count-tokens requests are answered locally for every
+// provider instead of calling the upstream counting endpoint.
+func localTokenCounting(cfg *config.Config) bool {
+	return cfg != nil && cfg.LocalTokenCounting
+}
+
+// countTokensLocally answers a count-tokens request without any network call. The prompt is
+// counted by CountPromptTokens and the result is rendered in the client's format.
+func countTokensLocally(ctx context.Context, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	count, err := CountPromptTokens(ctx, opts.SourceFormat, req.Model, req.Payload)
+	if err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
+	usageJSON := buildOpenAIUsageJSON(count)
+	translated := sdktranslator.TranslateTokenCount(ctx, sdktranslator.FromString("openai"), opts.SourceFormat, count, usageJSON)
+	return cliproxyexecutor.Response{Payload: []byte(translated)}, nil
+}
+
+// CountPromptTokens counts the prompt tokens of a payload in the client's format without any
+// network call. Claude and Gemini payloads are read directly, other formats after translation
+// to OpenAI chat, and the tokenizer matching the model is used.
+func CountPromptTokens(ctx context.Context, format sdktranslator.Format, model string, payload []byte) (int64, error) {
+	baseModel := thinking.ParseSuffix(model).ModelName
+	enc, err := getTokenizer(baseModel)
+	if err != nil {
+		return 0, fmt.Errorf("token counting: tokenizer init failed: %w", err)
+	}
+
+	var count int64
+	switch format.String() {
+	case "claude":
+		count, err = countClaudeChatTokens(enc, payload)
+	case "gemini":
+		count, err = countGeminiTokens(enc, payload)
+	case "gemini-cli":
+		count, err = countGeminiTokens(enc, []byte(gjson.GetBytes(payload, "request").Raw))
+	case "openai":
+		count, err = countOpenAIChatTokens(enc, payload)
+	default:
+		body := translateRequestTraced(ctx, format, sdktranslator.FromString("openai"), baseModel, payload, false)
+		count, err = countOpenAIChatTokens(enc, body)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("token counting: %w", err)
+	}
+	return count, nil
+}
+
+// countGeminiTokens approximates prompt tokens for Gemini generateContent payloads, including
+// the system instruction, every content part and the tool declarations.
+func countGeminiTokens(enc *TokenizerWrapper, payload []byte) (int64, error) {
+	if enc == nil {
+		return 0, fmt.Errorf("encoder is nil")
+	}
+	if len(payload) == 0 {
+		return 0, nil
+	}
+
+	root := gjson.ParseBytes(payload)
+	segments := make([]string, 0, 32)
+
+	system := root.Get("systemInstruction")
+	if !system.Exists() {
+		system = root.Get("system_instruction")
+	}
+	collectGeminiParts(system.Get("parts"), &segments)
+
+	root.Get("contents").ForEach(func(_, content gjson.Result) bool {
+		addIfNotEmpty(&segments, content.Get("role").String())
+		collectGeminiParts(content.Get("parts"), &segments)
+		return true
+	})
+
+	collectGeminiTools(root.Get("tools"), &segments)
+	if toolConfig := root.Get("toolConfig"); toolConfig.Exists() {
+		addIfNotEmpty(&segments, toolConfig.Raw)
+	}
+
+	joined := strings.TrimSpace(strings.Join(segments, "\n"))
+	if joined == "" {
+		return 0, nil
+	}
+
+	count, err := enc.Count(joined)
+	if err != nil {
+		return 0, err
+	}
+
+	// Extract and add image tokens from placeholders
+	imageTokens := extractImageTokens(joined)
+
+	return int64(count) + int64(imageTokens), nil
+}
+
+// collectGeminiParts extracts text from Gemini content parts. Inline images are replaced by a
+// token estimate based on their dimensions.
+func collectGeminiParts(parts gjson.Result, segments *[]string) {
+	if !parts.IsArray() {
+		return
+	}
+	parts.ForEach(func(_, part gjson.Result) bool {
+		switch {
+		case part.Get("text").Exists():
+			addIfNotEmpty(segments, part.Get("text").String())
+		case part.Get("inlineData").Exists() || part.Get("inline_data").Exists():
+			inline := part.Get("inlineData")
+			if !inline.Exists() {
+				inline = part.Get("inline_data")
+			}
+			width, height := base64ImageDimensions(inline.Get("data").String())
+			addIfNotEmpty(segments, fmt.Sprintf("[IMAGE:%d tokens]", estimateImageTokens(width, height)))
+		case part.Get("fileData").Exists() || part.Get("file_data").Exists():
+			addIfNotEmpty(segments, fmt.Sprintf("[IMAGE:%d tokens]", estimateImageTokens(0, 0)))
+		case part.Get("functionCall").Exists():
+			addIfNotEmpty(segments, part.Get("functionCall.name").String())
+			addIfNotEmpty(segments, part.Get("functionCall.args").Raw)
+		case part.Get("functionResponse").Exists():
+			addIfNotEmpty(segments, part.Get("functionResponse.name").String())
+			addIfNotEmpty(segments, part.Get("functionResponse.response").Raw)
+		case part.Get("executableCode").Exists():
+			addIfNotEmpty(segments, part.Get("executableCode.code").String())
+		case part.Get("codeExecutionResult").Exists():
+			addIfNotEmpty(segments, part.Get("codeExecutionResult.output").String())
+		}
+		return true
+	})
+}
+
+// collectGeminiTools extracts function declarations and built-in tool settings.
+func collectGeminiTools(tools gjson.Result, segments *[]string) {
+	if !tools.IsArray() {
+		return
+	}
+	tools.ForEach(func(_, tool gjson.Result) bool {
+		declarations := tool.Get("functionDeclarations")
+		if !declarations.Exists() {
+			declarations = tool.Get("function_declarations")
+		}
+		if !declarations.Exists() {
+			addIfNotEmpty(segments, tool.Raw)
+			return true
+		}
+		declarations.ForEach(func(_, declaration gjson.Result) bool {
+			addIfNotEmpty(segments, declaration.Get("name").String())
+			addIfNotEmpty(segments, declaration.Get("description").String())
+			for _, field := range []string{"parameters", "parametersJsonSchema"} {
+				if params := declaration.Get(field); params.Exists() {
+					addIfNotEmpty(segments, params.Raw)
+				}
+			}
+			return true
+		})
+		return true
+	})
+}
+
+// estimateSentencePieceTokens approximates the token count of a SentencePiece vocabulary such
+// as Gemini's. Whitespace before a word is folded into the word, as SentencePiece does with
+// its "▁" marker; ASCII words split into pieces of about six letters and other scripts into
+// pieces of about three; digits, punctuation, symbols and CJK characters are one token each;
+// line breaks and runs of indentation are one token each.
+func estimateSentencePieceTokens(text string) int {
+	tokens := 0
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		switch {
+		case r == '\n':
+			tokens++
+			i += size
+		case unicode.IsSpace(r):
+			start := i
+			for i < len(text) {
+				next, nextSize := utf8.DecodeRuneInString(text[i:])
+				if next == '\n' || !unicode.IsSpace(next) {
+					break
+				}
+				i += nextSize
+			}
+			// A single space joins the following word; longer runs are indentation tokens.
+			if i-start > 1 {
+				tokens++
+			}
+		case isCJK(r) || unicode.IsDigit(r) || unicode.IsPunct(r) || unicode.IsSymbol(r):
+			tokens++
+			i += size
+		case unicode.IsLetter(r) || unicode.IsMark(r):
+			letters, ascii := 0, true
+			for i < len(text) {
+				next, nextSize := utf8.DecodeRuneInString(text[i:])
+				if isCJK(next) || !(unicode.IsLetter(next) || unicode.IsMark(next)) {
+					break
+				}
+				if next >= utf8.RuneSelf {
+					ascii = false
+				}
+				letters++
+				i += nextSize
+			}
+			pieceLen := 6
+			if !ascii {
+				pieceLen = 3
+			}
+			tokens += (letters + pieceLen - 1) / pieceLen
+		default:
+			tokens++
+			i += size
+		}
+	}
+	return tokens
+}
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// imageURLPlaceholder returns the image token placeholder for an OpenAI image URL. Data URLs
+// are estimated from the image dimensions instead of being counted as text.
+func imageURLPlaceholder(url string) string {
+	if url == "" {
+		return ""
+	}
+	var width, height float64
+	if strings.HasPrefix(url, "data:") {
+		if comma := strings.IndexByte(url, ','); comma >= 0 {
+			width, height = base64ImageDimensions(url[comma+1:])
+		}
+	}
+	return fmt.Sprintf("[IMAGE:%d tokens]", estimateImageTokens(width, height))
+}
+
+// base64ImageDimensions reads the width and height of a base64-encoded PNG, JPEG or GIF image
+// from its header. It returns zeros when the format is unknown or the data is malformed.
+func base64ImageDimensions(data string) (float64, float64) {
+	if data == "" {
+		return 0, 0
+	}
+	if len(data) > maxImageHeaderBase64 {
+		data = data[:maxImageHeaderBase64]
+	}
+	data = data[:len(data)/4*4]
+	decoded, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return 0, 0
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(decoded))
+	if err != nil {
+		return 0, 0
+	}
+	return float64(cfg.Width), float64(cfg.Height)
+}
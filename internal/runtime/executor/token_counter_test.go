@@ -0,0 +1,94 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+
+	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+)
+
+func testPNGBase64(t *testing.T, width, height int) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func TestEstimateSentencePieceTokens(t *testing.T) {
+	cases := map[string]int{
+		"":                     0,
+		"Hello world":          2,
+		"internationalization": 4,
+		"2025":                 4,
+		"你好世界":                 4,
+		"a, b.":                4,
+		"line\n    indented":   5,
+	}
+	for text, want := range cases {
+		if got := estimateSentencePieceTokens(text); got != want {
+			t.Errorf("estimateSentencePieceTokens(%q) = %d, want %d", text, got, want)
+		}
+	}
+}
+
+func TestCountTokensLocally_ClientFormats(t *testing.T) {
+	picture := testPNGBase64(t, 1000, 750)
+	imageTokens := int64(estimateImageTokens(1000, 750))
+
+	claude := `{"model":"claude-sonnet-4-5","system":"You are terse.","messages":[{"role":"user","content":[
+		{"type":"text","text":"Describe this picture."},
+		{"type":"image","source":{"type":"base64","media_type":"image/png","data":"` + picture + `"}}]}],
+		"tools":[{"name":"lookup","description":"Looks things up","input_schema":{"type":"object"}}]}`
+	resp, err := countTokensLocally(context.Background(), cliproxyexecutor.Request{Model: "claude-sonnet-4-5", Payload: []byte(claude)},
+		cliproxyexecutor.Options{SourceFormat: sdktranslator.FromString("claude")})
+	if err != nil {
+		t.Fatalf("claude: %v", err)
+	}
+	claudeTokens := gjson.GetBytes(resp.Payload, "input_tokens").Int()
+	if claudeTokens <= imageTokens || claudeTokens > imageTokens+200 {
+		t.Fatalf("claude: input_tokens = %d, want image estimate %d plus a few text tokens (%s)", claudeTokens, imageTokens, resp.Payload)
+	}
+
+	gemini := `{"systemInstruction":{"parts":[{"text":"You are terse."}]},"contents":[{"role":"user","parts":[
+		{"text":"Describe this picture."},{"inlineData":{"mimeType":"image/png","data":"` + picture + `"}}]}],
+		"tools":[{"functionDeclarations":[{"name":"lookup","description":"Looks things up","parameters":{"type":"object"}}]}]}`
+	resp, err = countTokensLocally(context.Background(), cliproxyexecutor.Request{Model: "gemini-2.5-pro", Payload: []byte(gemini)},
+		cliproxyexecutor.Options{SourceFormat: sdktranslator.FromString("gemini")})
+	if err != nil {
+		t.Fatalf("gemini: %v", err)
+	}
+	geminiTokens := gjson.GetBytes(resp.Payload, "totalTokens").Int()
+	if geminiTokens <= imageTokens || geminiTokens > imageTokens+200 {
+		t.Fatalf("gemini: totalTokens = %d, want image estimate %d plus a few text tokens (%s)", geminiTokens, imageTokens, resp.Payload)
+	}
+
+	openai := `{"model":"gpt-4o","messages":[{"role":"user","content":[{"type":"text","text":"Describe this picture."},
+		{"type":"image_url","image_url":{"url":"data:image/png;base64,` + picture + `"}}]}]}`
+	resp, err = countTokensLocally(context.Background(), cliproxyexecutor.Request{Model: "gpt-4o", Payload: []byte(openai)},
+		cliproxyexecutor.Options{SourceFormat: sdktranslator.FromString("openai")})
+	if err != nil {
+		t.Fatalf("openai: %v", err)
+	}
+	if got := gjson.GetBytes(resp.Payload, "usage.prompt_tokens").Int(); got <= imageTokens || got > imageTokens+200 {
+		t.Fatalf("openai: prompt_tokens = %d, the data URL must be estimated as an image (%s)", got, resp.Payload)
+	}
+}
+
+func TestBase64ImageDimensions(t *testing.T) {
+	if w, h := base64ImageDimensions(testPNGBase64(t, 64, 32)); w != 64 || h != 32 {
+		t.Fatalf("dimensions = %vx%v, want 64x32", w, h)
+	}
+	if w, h := base64ImageDimensions(strings.Repeat("A", 100)); w != 0 || h != 0 {
+		t.Fatalf("garbage data should have no dimensions, got %vx%v", w, h)
+	}
+}
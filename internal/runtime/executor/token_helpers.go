@@ -19,13 +19,20 @@ var tokenizerCache sync.Map
 type TokenizerWrapper struct {
 	Codec            tokenizer.Codec
 	AdjustmentFactor float64 // 1.0 means no adjustment, >1.0 means tiktoken underestimates
+	// SentencePiece counts with estimateSentencePieceTokens instead of Codec (Gemini models).
+	SentencePiece bool
 }
 
 // Count returns the token count with adjustment factor applied
 func (tw *TokenizerWrapper) Count(text string) (int, error) {
-	count, err := tw.Codec.Count(text)
-	if err != nil {
-		return 0, err
+	var count int
+	if tw.SentencePiece {
+		count = estimateSentencePieceTokens(text)
+	} else {
+		var err error
+		if count, err = tw.Codec.Count(text); err != nil {
+			return 0, err
+		}
 	}
 	if tw.AdjustmentFactor != 1.0 && tw.AdjustmentFactor > 0 {
 		return int(float64(count) * tw.AdjustmentFactor), nil
@@ -67,6 +74,11 @@ func tokenizerForModel(model string) (*TokenizerWrapper, error) {
 		return &TokenizerWrapper{Codec: enc, AdjustmentFactor: 1.1}, nil
 	}
 
+	// Gemini models use a SentencePiece vocabulary that tiktoken does not model.
+	if strings.Contains(sanitized, "gemini") || strings.Contains(sanitized, "gemma") {
+		return &TokenizerWrapper{SentencePiece: true, AdjustmentFactor: 1.0}, nil
+	}
+
 	var enc tokenizer.Codec
 	var err error
 
@@ -280,6 +292,9 @@ func collectClaudeContent(content gjson.Result, segments *[]string) {
 				if source.Exists() {
 					width := source.Get("width").Float()
 					height := source.Get("height").Float()
+					if width <= 0 || height <= 0 {
+						width, height = base64ImageDimensions(source.Get("data").String())
+					}
 					if width > 0 && height > 0 {
 						tokens := estimateImageTokens(width, height)
 						addIfNotEmpty(segments, fmt.Sprintf("[IMAGE:%d tokens]", tokens))
@@ -363,8 +378,12 @@ func collectOpenAIContent(content gjson.Result, segments *[]string) {
 			switch partType {
 			case "text", "input_text", "output_text":
 				addIfNotEmpty(segments, part.Get("text").String())
-			case "image_url":
-				addIfNotEmpty(segments, part.Get("image_url.url").String())
+			case "image_url", "input_image":
+				url := part.Get("image_url.url").String()
+				if url == "" {
+					url = part.Get("image_url").String()
+				}
+				addIfNotEmpty(segments, imageURLPlaceholder(url))
 			case "input_audio", "output_audio", "audio":
 				addIfNotEmpty(segments, part.Get("id").String())
 			case "tool_result":
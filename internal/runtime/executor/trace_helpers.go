@@ -0,0 +1,32 @@
+package executor
+
+import (
+	"context"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tracing"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+)
+
+// translateRequestTraced wraps sdktranslator.TranslateRequest in a request translation span.
+func translateRequestTraced(ctx context.Context, from, to sdktranslator.Format, model string, rawJSON []byte, stream bool) []byte {
+	_, span := tracing.Start(ctx, "cliproxy.translate.request",
+		tracing.AttrFromFormat.String(from.String()),
+		tracing.AttrToFormat.String(to.String()),
+		tracing.AttrModel.String(model),
+		tracing.AttrStream.Bool(stream),
+	)
+	defer span.End()
+	return sdktranslator.TranslateRequest(from, to, model, rawJSON, stream)
+}
+
+// translateNonStreamTraced wraps sdktranslator.TranslateNonStream in a response translation span.
+// Streaming chunks are translated without spans to keep traces readable.
+func translateNonStreamTraced(ctx context.Context, from, to sdktranslator.Format, model string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) string {
+	ctx, span := tracing.Start(ctx, "cliproxy.translate.response",
+		tracing.AttrFromFormat.String(from.String()),
+		tracing.AttrToFormat.String(to.String()),
+		tracing.AttrModel.String(model),
+	)
+	defer span.End()
+	return sdktranslator.TranslateNonStream(ctx, from, to, model, originalRequestRawJSON, requestRawJSON, rawJSON, param)
+}
@@ -3,6 +3,7 @@ package executor
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
@@ -10,6 +11,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
@@ -23,6 +25,7 @@ type usageReporter struct {
 	apiKey      string
 	source      string
 	requestedAt time.Time
+	hedged      bool
 	once        sync.Once
 }
 
@@ -34,6 +37,7 @@ func newUsageReporter(ctx context.Context, provider, model string, auth *cliprox
 		requestedAt: time.Now(),
 		apiKey:      apiKey,
 		source:      resolveUsageSource(auth, apiKey),
+		hedged:      cliproxyexecutor.IsHedge(ctx),
 	}
 	if auth != nil {
 		reporter.authID = auth.ID
@@ -55,6 +59,10 @@ func (r *usageReporter) trackFailure(ctx context.Context, errPtr *error) {
 		return
 	}
 	if *errPtr != nil {
+		// An attempt cancelled because its hedge answered first did not fail.
+		if errors.Is(context.Cause(ctx), cliproxyexecutor.ErrHedgeLost) {
+			return
+		}
 		r.publishFailure(ctx)
 	}
 }
@@ -82,6 +90,7 @@ func (r *usageReporter) publishWithOutcome(ctx context.Context, detail usage.Det
 			AuthIndex:   r.authIndex,
 			RequestedAt: r.requestedAt,
 			Failed:      failed,
+			Hedged:      r.hedged,
 			Detail:      detail,
 		})
 	})
@@ -105,6 +114,7 @@ func (r *usageReporter) ensurePublished(ctx context.Context) {
 			AuthIndex:   r.authIndex,
 			RequestedAt: r.requestedAt,
 			Failed:      false,
+			Hedged:      r.hedged,
 			Detail:      usage.Detail{},
 		})
 	})
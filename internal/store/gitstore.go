@@ -18,6 +18,8 @@ import (
 	"github.com/go-git/go-git/v6/plumbing/object"
 	"github.com/go-git/go-git/v6/plumbing/transport"
 	"github.com/go-git/go-git/v6/plumbing/transport/http"
+	baseauth "github.com/router-for-me/CLIProxyAPI/v6/internal/auth"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 )
 
@@ -241,7 +243,7 @@ func (s *GitTokenStore) Save(_ context.Context, auth *cliproxyauth.Auth) (string
 
 	switch {
 	case auth.Storage != nil:
-		if err = auth.Storage.SaveTokenToFile(path); err != nil {
+		if err = baseauth.SealTokenStorage(auth.Storage, path); err != nil {
 			return "", err
 		}
 	case auth.Metadata != nil:
@@ -250,12 +252,15 @@ func (s *GitTokenStore) Save(_ context.Context, auth *cliproxyauth.Auth) (string
 			return "", fmt.Errorf("auth filestore: marshal metadata failed: %w", errMarshal)
 		}
 		if existing, errRead := os.ReadFile(path); errRead == nil {
-			if jsonEqual(existing, raw) {
+			if authcrypt.RecordEqual(existing, raw) {
 				return path, nil
 			}
 		} else if !os.IsNotExist(errRead) {
 			return "", fmt.Errorf("auth filestore: read existing failed: %w", errRead)
 		}
+		if raw, errMarshal = authcrypt.Encrypt(raw); errMarshal != nil {
+			return "", fmt.Errorf("auth filestore: encrypt metadata failed: %w", errMarshal)
+		}
 		tmp := path + ".tmp"
 		if errWrite := os.WriteFile(tmp, raw, 0o600); errWrite != nil {
 			return "", fmt.Errorf("auth filestore: write temp failed: %w", errWrite)
@@ -356,9 +361,78 @@ func (s *GitTokenStore) Delete(_ context.Context, id string) error {
 			return errCommit
 		}
 	}
+	return s.deleteRuntimeStateLocked(id)
+}
+
+// ListRuntimeStates reads the runtime state side records kept in the local repository.
+func (s *GitTokenStore) ListRuntimeStates(_ context.Context) ([]*cliproxyauth.RuntimeState, error) {
+	if err := s.EnsureRepository(); err != nil {
+		return nil, err
+	}
+	dir := s.runtimeStateDir()
+	if dir == "" {
+		return nil, fmt.Errorf("git token store: repository path not configured")
+	}
+	return cliproxyauth.ReadRuntimeStateDir(dir)
+}
+
+// SaveRuntimeState writes the runtime state side record of an auth. The record stays local and
+// is never committed: cooldowns and quota changes are too frequent to push to the remote.
+func (s *GitTokenStore) SaveRuntimeState(_ context.Context, state *cliproxyauth.RuntimeState) error {
+	if state == nil || state.ID == "" {
+		return fmt.Errorf("git token store: runtime state id is empty")
+	}
+	if err := s.EnsureRepository(); err != nil {
+		return err
+	}
+	dir := s.runtimeStateDir()
+	if dir == "" {
+		return fmt.Errorf("git token store: repository path not configured")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := cliproxyauth.WriteRuntimeStateFile(dir, state)
+	return err
+}
+
+// DeleteRuntimeState removes the runtime state side record of an auth.
+func (s *GitTokenStore) DeleteRuntimeState(_ context.Context, id string) error {
+	if strings.TrimSpace(id) == "" {
+		return nil
+	}
+	if err := s.EnsureRepository(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.deleteRuntimeStateLocked(id)
+}
+
+func (s *GitTokenStore) deleteRuntimeStateLocked(id string) error {
+	dir := s.runtimeStateDir()
+	if dir == "" {
+		return nil
+	}
+	if err := os.Remove(filepath.Join(dir, cliproxyauth.RuntimeStateKey(id))); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("git token store: delete runtime state: %w", err)
+	}
 	return nil
 }
 
+// runtimeStateDir returns the directory holding runtime state side records. It lives inside the
+// .git directory so the records survive restarts but are never part of a commit.
+func (s *GitTokenStore) runtimeStateDir() string {
+	repoDir := s.repoDirSnapshot()
+	if repoDir == "" {
+		return ""
+	}
+	return filepath.Join(repoDir, ".git", "runtime-state")
+}
+
 // PersistAuthFiles commits and pushes the provided paths to the remote repository.
 // It no-ops when the store is not fully configured or when there are no paths.
 func (s *GitTokenStore) PersistAuthFiles(_ context.Context, message string, paths ...string) error {
@@ -406,7 +480,7 @@ func (s *GitTokenStore) resolveDeletePath(id string) (string, error) {
 }
 
 func (s *GitTokenStore) readAuthFile(path, baseDir string) (*cliproxyauth.Auth, error) {
-	data, err := os.ReadFile(path)
+	data, err := authcrypt.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("read file: %w", err)
 	}
@@ -17,6 +17,8 @@ import (
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
+	baseauth "github.com/router-for-me/CLIProxyAPI/v6/internal/auth"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	log "github.com/sirupsen/logrus"
@@ -25,6 +27,8 @@ import (
 const (
 	objectStoreConfigKey  = "config/config.yaml"
 	objectStoreAuthPrefix = "auths"
+
+	objectStoreRuntimeStatePrefix = "runtime-state"
 )
 
 // ObjectStoreConfig captures configuration for the object storage-backed token store.
@@ -184,7 +188,7 @@ func (s *ObjectTokenStore) Save(ctx context.Context, auth *cliproxyauth.Auth) (s
 
 	switch {
 	case auth.Storage != nil:
-		if err = auth.Storage.SaveTokenToFile(path); err != nil {
+		if err = baseauth.SealTokenStorage(auth.Storage, path); err != nil {
 			return "", err
 		}
 	case auth.Metadata != nil:
@@ -193,12 +197,15 @@ func (s *ObjectTokenStore) Save(ctx context.Context, auth *cliproxyauth.Auth) (s
 			return "", fmt.Errorf("object store: marshal metadata: %w", errMarshal)
 		}
 		if existing, errRead := os.ReadFile(path); errRead == nil {
-			if jsonEqual(existing, raw) {
+			if authcrypt.RecordEqual(existing, raw) {
 				return path, nil
 			}
 		} else if errRead != nil && !errors.Is(errRead, fs.ErrNotExist) {
 			return "", fmt.Errorf("object store: read existing metadata: %w", errRead)
 		}
+		if raw, errMarshal = authcrypt.Encrypt(raw); errMarshal != nil {
+			return "", fmt.Errorf("object store: encrypt metadata: %w", errMarshal)
+		}
 		tmp := path + ".tmp"
 		if errWrite := os.WriteFile(tmp, raw, 0o600); errWrite != nil {
 			return "", fmt.Errorf("object store: write temp auth file: %w", errWrite)
@@ -278,7 +285,62 @@ func (s *ObjectTokenStore) Delete(ctx context.Context, id string) error {
 	if err = s.deleteAuthObject(ctx, path); err != nil {
 		return err
 	}
-	return nil
+	return s.deleteObject(ctx, objectStoreRuntimeStatePrefix+"/"+cliproxyauth.RuntimeStateKey(id))
+}
+
+// ListRuntimeStates downloads the runtime state side records from the object storage backend.
+func (s *ObjectTokenStore) ListRuntimeStates(ctx context.Context) ([]*cliproxyauth.RuntimeState, error) {
+	prefix := s.prefixedKey(objectStoreRuntimeStatePrefix + "/")
+	objectCh := s.client.ListObjects(ctx, s.cfg.Bucket, minio.ListObjectsOptions{
+		Prefix:    prefix,
+		Recursive: true,
+	})
+	states := make([]*cliproxyauth.RuntimeState, 0, 16)
+	for object := range objectCh {
+		if object.Err != nil {
+			return nil, fmt.Errorf("object store: list runtime state objects: %w", object.Err)
+		}
+		if !strings.HasSuffix(object.Key, ".state") {
+			continue
+		}
+		reader, errGet := s.client.GetObject(ctx, s.cfg.Bucket, object.Key, minio.GetObjectOptions{})
+		if errGet != nil {
+			return nil, fmt.Errorf("object store: download runtime state %s: %w", object.Key, errGet)
+		}
+		data, errRead := io.ReadAll(reader)
+		_ = reader.Close()
+		if errRead != nil {
+			return nil, fmt.Errorf("object store: read runtime state %s: %w", object.Key, errRead)
+		}
+		state, errDecode := cliproxyauth.DecodeRuntimeState(data)
+		if errDecode != nil {
+			log.WithError(errDecode).WithField("key", object.Key).Warn("object store: skip runtime state")
+			continue
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+// SaveRuntimeState uploads the runtime state side record of an auth.
+func (s *ObjectTokenStore) SaveRuntimeState(ctx context.Context, state *cliproxyauth.RuntimeState) error {
+	if state == nil || state.ID == "" {
+		return fmt.Errorf("object store: runtime state id is empty")
+	}
+	raw, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("object store: marshal runtime state: %w", err)
+	}
+	key := objectStoreRuntimeStatePrefix + "/" + cliproxyauth.RuntimeStateKey(state.ID)
+	return s.putObject(ctx, key, raw, "application/json")
+}
+
+// DeleteRuntimeState removes the runtime state side record of an auth.
+func (s *ObjectTokenStore) DeleteRuntimeState(ctx context.Context, id string) error {
+	if strings.TrimSpace(id) == "" {
+		return nil
+	}
+	return s.deleteObject(ctx, objectStoreRuntimeStatePrefix+"/"+cliproxyauth.RuntimeStateKey(id))
 }
 
 // PersistAuthFiles uploads the provided auth files to the object storage backend.
@@ -554,7 +616,7 @@ func (s *ObjectTokenStore) resolveDeletePath(id string) (string, error) {
 }
 
 func (s *ObjectTokenStore) readAuthFile(path, baseDir string) (*cliproxyauth.Auth, error) {
-	data, err := os.ReadFile(path)
+	data, err := authcrypt.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("read file: %w", err)
 	}
@@ -0,0 +1,412 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	changeKindAuth         = "auth"
+	changeKindConfig       = "config"
+	changeKindRuntimeState = "runtime-state"
+
+	// listenRetryMax caps the delay between attempts to re-establish the notification listener.
+	listenRetryMax = 30 * time.Second
+)
+
+// storeChange is the payload of a change notification sent to the other replicas.
+type storeChange struct {
+	Origin string `json:"origin"`
+	Kind   string `json:"kind"`
+	ID     string `json:"id"`
+}
+
+// SubscribeRuntimeState registers fn to receive runtime states written by other replicas.
+func (s *PostgresStore) SubscribeRuntimeState(fn func(state *cliproxyauth.RuntimeState)) {
+	s.runtimeStateMu.Lock()
+	s.runtimeStateHandler = fn
+	s.runtimeStateMu.Unlock()
+}
+
+// AcquireRefresh elects this replica to refresh auth by taking a session-level advisory lock
+// keyed by the auth record. The lock acts as a lease: it is held until release is called or the
+// holding connection drops. When the database holds a different copy of the credential, another
+// replica refreshed it first; the local copy is replaced and the refresh is skipped.
+// Outside cluster mode every refresh is allowed.
+func (s *PostgresStore) AcquireRefresh(ctx context.Context, auth *cliproxyauth.Auth) (func(), bool, error) {
+	noop := func() {}
+	if !s.cfg.Cluster || auth == nil {
+		return noop, true, nil
+	}
+	path, err := s.resolveAuthPath(auth)
+	if err != nil || path == "" {
+		return noop, true, nil
+	}
+	relID, err := s.relativeAuthID(path)
+	if err != nil {
+		return noop, true, nil
+	}
+
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("postgres store: acquire lease connection: %w", err)
+	}
+	key := refreshLockKey(relID)
+	var locked bool
+	if err = conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&locked); err != nil {
+		_ = conn.Close()
+		return nil, false, fmt.Errorf("postgres store: try refresh lease: %w", err)
+	}
+	if !locked {
+		_ = conn.Close()
+		return nil, false, nil
+	}
+	release := func() {
+		unlockCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if _, errUnlock := conn.ExecContext(unlockCtx, "SELECT pg_advisory_unlock($1)", key); errUnlock != nil {
+			log.WithError(errUnlock).Warnf("postgres store: release refresh lease for %s", relID)
+		}
+		_ = conn.Close()
+	}
+
+	adopted, err := s.adoptNewerAuth(ctx, relID, path, auth.Metadata)
+	if err != nil {
+		release()
+		return nil, false, err
+	}
+	if adopted {
+		release()
+		return nil, false, nil
+	}
+	return release, true, nil
+}
+
+// adoptNewerAuth reports whether the database copy of the credential differs from the metadata
+// the caller is about to refresh with. In that case the database copy is mirrored to path so the
+// watcher loads it, and the caller must not refresh with its stale refresh token.
+func (s *PostgresStore) adoptNewerAuth(ctx context.Context, relID, path string, metadata map[string]any) (bool, error) {
+	query := fmt.Sprintf("SELECT content FROM %s WHERE id = $1", s.fullTableName(s.cfg.AuthTable))
+	var payload string
+	err := s.db.QueryRowContext(ctx, query, relID).Scan(&payload)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("postgres store: load auth %s: %w", relID, err)
+	}
+	if metadata != nil {
+		current, errMarshal := json.Marshal(metadata)
+		stored, errDecrypt := authcrypt.Decrypt([]byte(payload))
+		if errMarshal == nil && errDecrypt == nil && jsonEqual(current, stored) {
+			return false, nil
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err = writeAuthIfChanged(path, []byte(payload)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// refreshLockKey maps an auth record to the advisory lock guarding its refresh.
+func refreshLockKey(relID string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte("cliproxy:refresh:" + relID))
+	return int64(h.Sum64())
+}
+
+// notifyChannel returns the LISTEN/NOTIFY channel shared by the replicas of this schema.
+func (s *PostgresStore) notifyChannel() string {
+	channel := "cliproxy_store"
+	if schema := strings.TrimSpace(s.cfg.Schema); schema != "" {
+		channel += "_" + strings.ToLower(schema)
+	}
+	if len(channel) > 63 {
+		channel = channel[:63]
+	}
+	return channel
+}
+
+// notify tells the other replicas that a record changed.
+func (s *PostgresStore) notify(ctx context.Context, kind, id string) error {
+	if !s.cfg.Cluster {
+		return nil
+	}
+	payload, err := json.Marshal(storeChange{Origin: s.cfg.InstanceID, Kind: kind, ID: id})
+	if err != nil {
+		return fmt.Errorf("postgres store: marshal change notification: %w", err)
+	}
+	if _, err = s.db.ExecContext(ctx, "SELECT pg_notify($1, $2)", s.notifyChannel(), string(payload)); err != nil {
+		return fmt.Errorf("postgres store: notify %s change: %w", kind, err)
+	}
+	return nil
+}
+
+// notifyIfChanged notifies the other replicas when result affected a row.
+func (s *PostgresStore) notifyIfChanged(ctx context.Context, result sql.Result, kind, id string) error {
+	if !s.cfg.Cluster {
+		return nil
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return nil
+	}
+	return s.notify(ctx, kind, id)
+}
+
+func (s *PostgresStore) startListener() {
+	if s.listenCancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.listenCancel = cancel
+	go s.listen(ctx)
+}
+
+// listen receives change notifications until ctx is cancelled, reconnecting with backoff.
+// After a reconnect the workspace is reconciled with the database because notifications sent
+// while disconnected are lost.
+func (s *PostgresStore) listen(ctx context.Context) {
+	delay := time.Second
+	reconcile := false
+	for ctx.Err() == nil {
+		started := time.Now()
+		err := s.listenOnce(ctx, reconcile)
+		if ctx.Err() != nil {
+			return
+		}
+		log.WithError(err).Warn("postgres store: change listener disconnected")
+		if time.Since(started) > listenRetryMax {
+			delay = time.Second
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay *= 2
+		if delay > listenRetryMax {
+			delay = listenRetryMax
+		}
+		reconcile = true
+	}
+}
+
+func (s *PostgresStore) listenOnce(ctx context.Context, reconcile bool) error {
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+	return conn.Raw(func(driverConn any) error {
+		stdConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return fmt.Errorf("postgres store: unexpected driver connection %T", driverConn)
+		}
+		pgConn := stdConn.Conn()
+		channel := quoteIdentifier(s.notifyChannel())
+		if _, errListen := pgConn.Exec(ctx, "LISTEN "+channel); errListen != nil {
+			return errListen
+		}
+		defer func() {
+			unlistenCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_, _ = pgConn.Exec(unlistenCtx, "UNLISTEN "+channel)
+		}()
+		if reconcile {
+			s.reconcile(ctx)
+		}
+		for {
+			notification, errWait := pgConn.WaitForNotification(ctx)
+			if errWait != nil {
+				return errWait
+			}
+			s.handleChange(ctx, notification.Payload)
+		}
+	})
+}
+
+func (s *PostgresStore) handleChange(ctx context.Context, payload string) {
+	var change storeChange
+	if err := json.Unmarshal([]byte(payload), &change); err != nil {
+		log.WithError(err).Warn("postgres store: ignoring malformed change notification")
+		return
+	}
+	if change.Origin == s.cfg.InstanceID {
+		return
+	}
+	var err error
+	switch change.Kind {
+	case changeKindAuth:
+		err = s.syncAuthRecord(ctx, change.ID)
+	case changeKindConfig:
+		err = s.syncConfigRecord(ctx)
+	case changeKindRuntimeState:
+		err = s.deliverRuntimeState(ctx, change.ID)
+	}
+	if err != nil {
+		log.WithError(err).Warnf("postgres store: apply %s change %s", change.Kind, change.ID)
+	}
+}
+
+// syncAuthRecord mirrors one auth record into the workspace; the file watcher picks it up.
+func (s *PostgresStore) syncAuthRecord(ctx context.Context, relID string) error {
+	path, err := s.absoluteAuthPath(relID)
+	if err != nil {
+		return err
+	}
+	query := fmt.Sprintf("SELECT content FROM %s WHERE id = $1", s.fullTableName(s.cfg.AuthTable))
+	var payload string
+	err = s.db.QueryRowContext(ctx, query, relID).Scan(&payload)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		if errRemove := os.Remove(path); errRemove != nil && !errors.Is(errRemove, fs.ErrNotExist) {
+			return fmt.Errorf("postgres store: remove auth file: %w", errRemove)
+		}
+		return nil
+	case err != nil:
+		return fmt.Errorf("postgres store: load auth %s: %w", relID, err)
+	}
+	_, err = writeAuthIfChanged(path, []byte(payload))
+	return err
+}
+
+// syncConfigRecord mirrors the shared config into the workspace; the config watcher reloads it.
+func (s *PostgresStore) syncConfigRecord(ctx context.Context) error {
+	query := fmt.Sprintf("SELECT content FROM %s WHERE id = $1", s.fullTableName(s.cfg.ConfigTable))
+	var content string
+	err := s.db.QueryRowContext(ctx, query, defaultConfigKey).Scan(&content)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("postgres store: load config: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	normalized := []byte(normalizeLineEndings(content))
+	if existing, errRead := os.ReadFile(s.configPath); errRead == nil && string(existing) == string(normalized) {
+		return nil
+	}
+	if err = os.WriteFile(s.configPath, normalized, 0o600); err != nil {
+		return fmt.Errorf("postgres store: write config to spool: %w", err)
+	}
+	return nil
+}
+
+// deliverRuntimeState passes the runtime state of id written by another replica to the subscriber.
+func (s *PostgresStore) deliverRuntimeState(ctx context.Context, id string) error {
+	s.runtimeStateMu.RLock()
+	handler := s.runtimeStateHandler
+	s.runtimeStateMu.RUnlock()
+	if handler == nil {
+		return nil
+	}
+	query := fmt.Sprintf("SELECT content FROM %s WHERE id = $1", s.fullTableName(s.cfg.RuntimeStateTable))
+	var payload string
+	err := s.db.QueryRowContext(ctx, query, id).Scan(&payload)
+	if errors.Is(err, sql.ErrNoRows) {
+		handler(&cliproxyauth.RuntimeState{ID: id})
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("postgres store: load runtime state %s: %w", id, err)
+	}
+	state, err := cliproxyauth.DecodeRuntimeState([]byte(payload))
+	if err != nil {
+		return err
+	}
+	handler(state)
+	return nil
+}
+
+// reconcile brings the workspace and runtime states up to date after missed notifications.
+func (s *PostgresStore) reconcile(ctx context.Context) {
+	if err := s.syncConfigRecord(ctx); err != nil {
+		log.WithError(err).Warn("postgres store: reconcile config")
+	}
+	query := fmt.Sprintf("SELECT id FROM %s", s.fullTableName(s.cfg.AuthTable))
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		log.WithError(err).Warn("postgres store: reconcile auth")
+		return
+	}
+	known := make(map[string]struct{})
+	for rows.Next() {
+		var id string
+		if errScan := rows.Scan(&id); errScan == nil {
+			known[id] = struct{}{}
+		}
+	}
+	_ = rows.Close()
+	for id := range known {
+		if errSync := s.syncAuthRecord(ctx, id); errSync != nil {
+			log.WithError(errSync).Warnf("postgres store: reconcile auth %s", id)
+		}
+	}
+	_ = filepath.WalkDir(s.authDir, func(path string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil || d.IsDir() || !strings.HasSuffix(strings.ToLower(d.Name()), ".json") {
+			return nil
+		}
+		relID, errRel := s.relativeAuthID(path)
+		if errRel != nil {
+			return nil
+		}
+		if _, ok := known[relID]; !ok {
+			s.mu.Lock()
+			_ = os.Remove(path)
+			s.mu.Unlock()
+		}
+		return nil
+	})
+
+	s.runtimeStateMu.RLock()
+	handler := s.runtimeStateHandler
+	s.runtimeStateMu.RUnlock()
+	if handler == nil {
+		return
+	}
+	states, err := s.ListRuntimeStates(ctx)
+	if err != nil {
+		log.WithError(err).Warn("postgres store: reconcile runtime state")
+		return
+	}
+	for _, state := range states {
+		handler(state)
+	}
+}
+
+// writeAuthIfChanged atomically writes data to path unless the file already holds the same JSON.
+func writeAuthIfChanged(path string, data []byte) (bool, error) {
+	if existing, err := os.ReadFile(path); err == nil && jsonEqual(existing, data) {
+		return false, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return false, fmt.Errorf("postgres store: create auth subdir: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return false, fmt.Errorf("postgres store: write temp auth file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return false, fmt.Errorf("postgres store: rename auth file: %w", err)
+	}
+	return true, nil
+}
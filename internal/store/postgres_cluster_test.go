@@ -0,0 +1,123 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// newClusterTestStores connects two cluster-mode replicas to the local Postgres named by
+// PGSTORE_TEST_DSN, isolated in a fresh schema. The test is skipped when it is not set.
+func newClusterTestStores(t *testing.T) (*PostgresStore, *PostgresStore) {
+	t.Helper()
+	dsn := os.Getenv("PGSTORE_TEST_DSN")
+	if dsn == "" {
+		t.Skip("PGSTORE_TEST_DSN not set; skipping Postgres cluster test")
+	}
+	schema := fmt.Sprintf("cliproxy_test_%d", time.Now().UnixNano())
+	ctx := context.Background()
+	stores := make([]*PostgresStore, 0, 2)
+	for i := 0; i < 2; i++ {
+		s, err := NewPostgresStore(ctx, PostgresStoreConfig{
+			DSN:        dsn,
+			Schema:     schema,
+			SpoolDir:   t.TempDir(),
+			Cluster:    true,
+			InstanceID: fmt.Sprintf("replica-%d", i),
+		})
+		if err != nil {
+			t.Fatalf("NewPostgresStore: %v", err)
+		}
+		if err = s.Bootstrap(ctx, ""); err != nil {
+			t.Fatalf("Bootstrap: %v", err)
+		}
+		stores = append(stores, s)
+	}
+	t.Cleanup(func() {
+		_, _ = stores[0].db.ExecContext(context.Background(), "DROP SCHEMA "+quoteIdentifier(schema)+" CASCADE")
+		for _, s := range stores {
+			_ = s.Close()
+		}
+	})
+	// Give the listeners time to subscribe.
+	time.Sleep(200 * time.Millisecond)
+	return stores[0], stores[1]
+}
+
+func TestPostgresCluster_RefreshLeaseIsExclusive(t *testing.T) {
+	a, b := newClusterTestStores(t)
+	ctx := context.Background()
+	auth := &cliproxyauth.Auth{ID: "claude-user.json", Provider: "claude", Metadata: map[string]any{"type": "claude", "refresh_token": "rt-1"}}
+	if _, err := a.Save(ctx, auth.Clone()); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	waitForFile(t, filepath.Join(b.AuthDir(), "claude-user.json"))
+
+	release, ok, err := a.AcquireRefresh(ctx, auth.Clone())
+	if err != nil || !ok {
+		t.Fatalf("expected replica a to acquire the lease, ok=%v err=%v", ok, err)
+	}
+	if _, ok, err = b.AcquireRefresh(ctx, auth.Clone()); err != nil || ok {
+		t.Fatalf("expected replica b to be denied while a holds the lease, ok=%v err=%v", ok, err)
+	}
+	refreshed := auth.Clone()
+	refreshed.Metadata["refresh_token"] = "rt-2"
+	if _, err = a.Save(ctx, refreshed); err != nil {
+		t.Fatalf("Save refreshed: %v", err)
+	}
+	release()
+
+	// b still holds rt-1 unless the notification already arrived; either way it must not refresh.
+	if _, ok, err = b.AcquireRefresh(ctx, auth.Clone()); err != nil || ok {
+		t.Fatalf("expected replica b to adopt the refreshed credential, ok=%v err=%v", ok, err)
+	}
+}
+
+func TestPostgresCluster_RuntimeStateIsShared(t *testing.T) {
+	a, b := newClusterTestStores(t)
+	received := make(chan *cliproxyauth.RuntimeState, 4)
+	b.SubscribeRuntimeState(func(state *cliproxyauth.RuntimeState) { received <- state })
+
+	ctx := context.Background()
+	state := &cliproxyauth.RuntimeState{ID: "gemini:apikey:1", Status: cliproxyauth.StatusError, Unavailable: true}
+	if err := a.SaveRuntimeState(ctx, state); err != nil {
+		t.Fatalf("SaveRuntimeState: %v", err)
+	}
+	select {
+	case got := <-received:
+		if got.ID != state.ID || !got.Unavailable {
+			t.Fatalf("unexpected runtime state %+v", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("runtime state notification not received")
+	}
+
+	if err := a.DeleteRuntimeState(ctx, state.ID); err != nil {
+		t.Fatalf("DeleteRuntimeState: %v", err)
+	}
+	select {
+	case got := <-received:
+		if got.ID != state.ID || got.Unavailable {
+			t.Fatalf("expected deletion notice, got %+v", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("runtime state deletion not received")
+	}
+}
+
+func waitForFile(t *testing.T, path string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := os.Stat(path); err == nil {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("%s was not mirrored", path)
+}
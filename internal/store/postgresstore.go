@@ -13,7 +13,10 @@ import (
 	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	_ "github.com/jackc/pgx/v5/stdlib"
+	baseauth "github.com/router-for-me/CLIProxyAPI/v6/internal/auth"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	log "github.com/sirupsen/logrus"
@@ -23,6 +26,8 @@ const (
 	defaultConfigTable = "config_store"
 	defaultAuthTable   = "auth_store"
 	defaultConfigKey   = "config"
+
+	defaultRuntimeStateTable = "auth_runtime_state"
 )
 
 // PostgresStoreConfig captures configuration required to initialize a Postgres-backed store.
@@ -32,6 +37,14 @@ type PostgresStoreConfig struct {
 	ConfigTable string
 	AuthTable   string
 	SpoolDir    string
+
+	RuntimeStateTable string
+
+	// Cluster enables coordination between replicas sharing the database: change notifications,
+	// shared runtime state and refresh leases.
+	Cluster bool
+	// InstanceID identifies this replica in change notifications. Generated when empty.
+	InstanceID string
 }
 
 // PostgresStore persists configuration and authentication metadata using PostgreSQL as backend
@@ -43,6 +56,10 @@ type PostgresStore struct {
 	configPath string
 	authDir    string
 	mu         sync.Mutex
+
+	runtimeStateMu      sync.RWMutex
+	runtimeStateHandler func(*cliproxyauth.RuntimeState)
+	listenCancel        context.CancelFunc
 }
 
 // NewPostgresStore establishes a connection to PostgreSQL and prepares the local workspace.
@@ -58,6 +75,12 @@ func NewPostgresStore(ctx context.Context, cfg PostgresStoreConfig) (*PostgresSt
 	if cfg.AuthTable == "" {
 		cfg.AuthTable = defaultAuthTable
 	}
+	if cfg.RuntimeStateTable == "" {
+		cfg.RuntimeStateTable = defaultRuntimeStateTable
+	}
+	if strings.TrimSpace(cfg.InstanceID) == "" {
+		cfg.InstanceID = uuid.NewString()
+	}
 
 	spoolRoot := strings.TrimSpace(cfg.SpoolDir)
 	if spoolRoot == "" {
@@ -104,6 +127,9 @@ func (s *PostgresStore) Close() error {
 	if s == nil || s.db == nil {
 		return nil
 	}
+	if s.listenCancel != nil {
+		s.listenCancel()
+	}
 	return s.db.Close()
 }
 
@@ -140,6 +166,16 @@ func (s *PostgresStore) EnsureSchema(ctx context.Context) error {
 	`, authTable)); err != nil {
 		return fmt.Errorf("postgres store: create auth table: %w", err)
 	}
+	runtimeStateTable := s.fullTableName(s.cfg.RuntimeStateTable)
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			id TEXT PRIMARY KEY,
+			content JSONB NOT NULL,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)
+	`, runtimeStateTable)); err != nil {
+		return fmt.Errorf("postgres store: create runtime state table: %w", err)
+	}
 	return nil
 }
 
@@ -154,6 +190,9 @@ func (s *PostgresStore) Bootstrap(ctx context.Context, exampleConfigPath string)
 	if err := s.syncAuthFromDatabase(ctx); err != nil {
 		return err
 	}
+	if s.cfg.Cluster {
+		s.startListener()
+	}
 	return nil
 }
 
@@ -214,7 +253,7 @@ func (s *PostgresStore) Save(ctx context.Context, auth *cliproxyauth.Auth) (stri
 
 	switch {
 	case auth.Storage != nil:
-		if err = auth.Storage.SaveTokenToFile(path); err != nil {
+		if err = baseauth.SealTokenStorage(auth.Storage, path); err != nil {
 			return "", err
 		}
 	case auth.Metadata != nil:
@@ -223,12 +262,15 @@ func (s *PostgresStore) Save(ctx context.Context, auth *cliproxyauth.Auth) (stri
 			return "", fmt.Errorf("postgres store: marshal metadata: %w", errMarshal)
 		}
 		if existing, errRead := os.ReadFile(path); errRead == nil {
-			if jsonEqual(existing, raw) {
+			if authcrypt.RecordEqual(existing, raw) {
 				return path, nil
 			}
 		} else if errRead != nil && !errors.Is(errRead, fs.ErrNotExist) {
 			return "", fmt.Errorf("postgres store: read existing metadata: %w", errRead)
 		}
+		if raw, errMarshal = authcrypt.Encrypt(raw); errMarshal != nil {
+			return "", fmt.Errorf("postgres store: encrypt metadata: %w", errMarshal)
+		}
 		tmp := path + ".tmp"
 		if errWrite := os.WriteFile(tmp, raw, 0o600); errWrite != nil {
 			return "", fmt.Errorf("postgres store: write temp auth file: %w", errWrite)
@@ -284,8 +326,13 @@ func (s *PostgresStore) List(ctx context.Context) ([]*cliproxyauth.Auth, error)
 			log.WithError(errPath).Warnf("postgres store: skipping auth %s outside spool", id)
 			continue
 		}
+		plain, errDecrypt := authcrypt.Decrypt([]byte(payload))
+		if errDecrypt != nil {
+			log.WithError(errDecrypt).Warnf("postgres store: skipping auth %s that cannot be decrypted", id)
+			continue
+		}
 		metadata := make(map[string]any)
-		if err = json.Unmarshal([]byte(payload), &metadata); err != nil {
+		if err = json.Unmarshal(plain, &metadata); err != nil {
 			log.WithError(err).Warnf("postgres store: skipping auth %s with invalid json", id)
 			continue
 		}
@@ -339,7 +386,72 @@ func (s *PostgresStore) Delete(ctx context.Context, id string) error {
 	if err != nil {
 		return err
 	}
-	return s.deleteAuthRecord(ctx, relID)
+	if err = s.deleteAuthRecord(ctx, relID); err != nil {
+		return err
+	}
+	return s.DeleteRuntimeState(ctx, id)
+}
+
+// ListRuntimeStates returns the runtime state side records stored in PostgreSQL.
+func (s *PostgresStore) ListRuntimeStates(ctx context.Context) ([]*cliproxyauth.RuntimeState, error) {
+	query := fmt.Sprintf("SELECT id, content FROM %s", s.fullTableName(s.cfg.RuntimeStateTable))
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("postgres store: list runtime state: %w", err)
+	}
+	defer rows.Close()
+
+	states := make([]*cliproxyauth.RuntimeState, 0, 16)
+	for rows.Next() {
+		var (
+			id      string
+			payload string
+		)
+		if err = rows.Scan(&id, &payload); err != nil {
+			return nil, fmt.Errorf("postgres store: scan runtime state row: %w", err)
+		}
+		state, errDecode := cliproxyauth.DecodeRuntimeState([]byte(payload))
+		if errDecode != nil {
+			log.WithError(errDecode).Warnf("postgres store: skipping runtime state %s", id)
+			continue
+		}
+		states = append(states, state)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres store: iterate runtime state rows: %w", err)
+	}
+	return states, nil
+}
+
+// SaveRuntimeState upserts the runtime state side record of an auth.
+func (s *PostgresStore) SaveRuntimeState(ctx context.Context, state *cliproxyauth.RuntimeState) error {
+	if state == nil || state.ID == "" {
+		return fmt.Errorf("postgres store: runtime state id is empty")
+	}
+	raw, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("postgres store: marshal runtime state: %w", err)
+	}
+	query := fmt.Sprintf(`
+		INSERT INTO %s (id, content, updated_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (id)
+		DO UPDATE SET content = EXCLUDED.content, updated_at = NOW()
+	`, s.fullTableName(s.cfg.RuntimeStateTable))
+	if _, err = s.db.ExecContext(ctx, query, state.ID, json.RawMessage(raw)); err != nil {
+		return fmt.Errorf("postgres store: upsert runtime state: %w", err)
+	}
+	return s.notify(ctx, changeKindRuntimeState, state.ID)
+}
+
+// DeleteRuntimeState removes the runtime state side record of an auth.
+func (s *PostgresStore) DeleteRuntimeState(ctx context.Context, id string) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE id = $1", s.fullTableName(s.cfg.RuntimeStateTable))
+	result, err := s.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("postgres store: delete runtime state: %w", err)
+	}
+	return s.notifyIfChanged(ctx, result, changeKindRuntimeState, id)
 }
 
 // PersistAuthFiles stores the provided auth file changes in PostgreSQL.
@@ -502,46 +614,53 @@ func (s *PostgresStore) upsertAuthRecord(ctx context.Context, relID, path string
 
 func (s *PostgresStore) persistAuth(ctx context.Context, relID string, data []byte) error {
 	jsonPayload := json.RawMessage(data)
+	// Unchanged content is not rewritten so replicas are only notified about real changes.
 	query := fmt.Sprintf(`
-		INSERT INTO %s (id, content, created_at, updated_at)
+		INSERT INTO %s AS t (id, content, created_at, updated_at)
 		VALUES ($1, $2, NOW(), NOW())
 		ON CONFLICT (id)
 		DO UPDATE SET content = EXCLUDED.content, updated_at = NOW()
+		WHERE t.content IS DISTINCT FROM EXCLUDED.content
 	`, s.fullTableName(s.cfg.AuthTable))
-	if _, err := s.db.ExecContext(ctx, query, relID, jsonPayload); err != nil {
+	result, err := s.db.ExecContext(ctx, query, relID, jsonPayload)
+	if err != nil {
 		return fmt.Errorf("postgres store: upsert auth record: %w", err)
 	}
-	return nil
+	return s.notifyIfChanged(ctx, result, changeKindAuth, relID)
 }
 
 func (s *PostgresStore) deleteAuthRecord(ctx context.Context, relID string) error {
 	query := fmt.Sprintf("DELETE FROM %s WHERE id = $1", s.fullTableName(s.cfg.AuthTable))
-	if _, err := s.db.ExecContext(ctx, query, relID); err != nil {
+	result, err := s.db.ExecContext(ctx, query, relID)
+	if err != nil {
 		return fmt.Errorf("postgres store: delete auth record: %w", err)
 	}
-	return nil
+	return s.notifyIfChanged(ctx, result, changeKindAuth, relID)
 }
 
 func (s *PostgresStore) persistConfig(ctx context.Context, data []byte) error {
 	query := fmt.Sprintf(`
-		INSERT INTO %s (id, content, created_at, updated_at)
+		INSERT INTO %s AS t (id, content, created_at, updated_at)
 		VALUES ($1, $2, NOW(), NOW())
 		ON CONFLICT (id)
 		DO UPDATE SET content = EXCLUDED.content, updated_at = NOW()
+		WHERE t.content IS DISTINCT FROM EXCLUDED.content
 	`, s.fullTableName(s.cfg.ConfigTable))
 	normalized := normalizeLineEndings(string(data))
-	if _, err := s.db.ExecContext(ctx, query, defaultConfigKey, normalized); err != nil {
+	result, err := s.db.ExecContext(ctx, query, defaultConfigKey, normalized)
+	if err != nil {
 		return fmt.Errorf("postgres store: upsert config: %w", err)
 	}
-	return nil
+	return s.notifyIfChanged(ctx, result, changeKindConfig, defaultConfigKey)
 }
 
 func (s *PostgresStore) deleteConfigRecord(ctx context.Context) error {
 	query := fmt.Sprintf("DELETE FROM %s WHERE id = $1", s.fullTableName(s.cfg.ConfigTable))
-	if _, err := s.db.ExecContext(ctx, query, defaultConfigKey); err != nil {
+	result, err := s.db.ExecContext(ctx, query, defaultConfigKey)
+	if err != nil {
 		return fmt.Errorf("postgres store: delete config: %w", err)
 	}
-	return nil
+	return s.notifyIfChanged(ctx, result, changeKindConfig, defaultConfigKey)
 }
 
 func (s *PostgresStore) resolveAuthPath(auth *cliproxyauth.Auth) (string, error) {
@@ -0,0 +1,394 @@
+// Package structuredoutput supports emulating OpenAI structured outputs for upstreams that
+// ignore response_format. It renders a JSON Schema as model instructions, extracts the JSON
+// document from a model answer and validates it against the schema.
+package structuredoutput
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tidwall/gjson"
+)
+
+const (
+	// maxDepth bounds schema recursion, which $ref cycles could otherwise make infinite.
+	maxDepth = 64
+	// maxViolations bounds the violations reported for one document.
+	maxViolations = 20
+)
+
+// Instructions asks the model for a single JSON value conforming to schema. A nil schema asks
+// for any JSON object, as response_format json_object does.
+func Instructions(name string, schema []byte) string {
+	if len(schema) == 0 {
+		return "Respond with a single valid JSON object and nothing else: no explanations and no Markdown code fences."
+	}
+	var b strings.Builder
+	b.WriteString("Respond with a single JSON value and nothing else: no explanations and no Markdown code fences. ")
+	b.WriteString("The JSON must conform to the following JSON Schema")
+	if name != "" {
+		b.WriteString(" (")
+		b.WriteString(name)
+		b.WriteString(")")
+	}
+	b.WriteString(". Include every required property and no properties the schema does not allow.\n")
+	b.Write(schema)
+	return b.String()
+}
+
+// Extract returns the JSON document in a model answer. Answers wrapped in Markdown code fences
+// or surrounded by prose are repaired by taking the fenced block or the outermost object or
+// array. It reports false when no valid JSON is found.
+func Extract(answer string) (string, bool) {
+	text := strings.TrimSpace(answer)
+	if text != "" && gjson.Valid(text) {
+		return text, true
+	}
+	if start := strings.Index(text, "```"); start >= 0 {
+		block := text[start+3:]
+		if newline := strings.IndexByte(block, '\n'); newline >= 0 {
+			block = block[newline+1:]
+		}
+		if end := strings.Index(block, "```"); end >= 0 {
+			if candidate := strings.TrimSpace(block[:end]); candidate != "" && gjson.Valid(candidate) {
+				return candidate, true
+			}
+		}
+	}
+	for _, delims := range [][2]byte{{'{', '}'}, {'[', ']'}} {
+		start := strings.IndexByte(text, delims[0])
+		end := strings.LastIndexByte(text, delims[1])
+		if start >= 0 && end > start {
+			if candidate := text[start : end+1]; gjson.Valid(candidate) {
+				return candidate, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Validate checks the JSON document doc against schema and returns the violations, each
+// prefixed with the path of the offending value. It supports the JSON Schema subset accepted
+// by OpenAI structured outputs: types, enum and const, object properties, required and
+// additionalProperties, array items, string, number and size bounds, patterns, the anyOf,
+// oneOf, allOf and not combinators, and local $ref pointers. Formats are not checked.
+func Validate(schema, doc []byte) []string {
+	v := &validator{root: gjson.ParseBytes(schema)}
+	v.validate(v.root, gjson.ParseBytes(doc), "$", 0)
+	return v.violations
+}
+
+type validator struct {
+	root       gjson.Result
+	violations []string
+}
+
+func (v *validator) fail(path, format string, args ...any) {
+	if len(v.violations) < maxViolations {
+		v.violations = append(v.violations, path+": "+fmt.Sprintf(format, args...))
+	}
+}
+
+// matches reports whether value conforms to schema without recording violations.
+func (v *validator) matches(schema, value gjson.Result, depth int) bool {
+	sub := &validator{root: v.root}
+	sub.validate(schema, value, "$", depth)
+	return len(sub.violations) == 0
+}
+
+func (v *validator) validate(schema, value gjson.Result, path string, depth int) {
+	if depth > maxDepth {
+		v.fail(path, "schema nesting is too deep")
+		return
+	}
+	switch schema.Type {
+	case gjson.True:
+		return
+	case gjson.False:
+		v.fail(path, "no value is allowed here")
+		return
+	}
+	if !schema.IsObject() {
+		return
+	}
+	keywords := fields(schema)
+
+	if ref, ok := keywords["$ref"]; ok {
+		target, found := v.resolve(ref.String())
+		if !found {
+			v.fail(path, "unresolvable schema reference %s", ref.String())
+			return
+		}
+		v.validate(target, value, path, depth+1)
+	}
+
+	if types, ok := keywords["type"]; ok {
+		nullable := keywords["nullable"].Bool() && value.Type == gjson.Null
+		if !nullable && !matchesType(types, value) {
+			v.fail(path, "expected %s, got %s", typeList(types), typeName(value))
+			return
+		}
+	}
+	if enum, ok := keywords["enum"]; ok {
+		found := false
+		for _, option := range enum.Array() {
+			if equalJSON(option, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.fail(path, "must be one of %s", enum.Raw)
+		}
+	}
+	if constant, ok := keywords["const"]; ok && !equalJSON(constant, value) {
+		v.fail(path, "must be %s", constant.Raw)
+	}
+
+	switch {
+	case value.IsObject():
+		v.validateObject(keywords, value, path, depth)
+	case value.IsArray():
+		v.validateArray(keywords, value, path, depth)
+	case value.Type == gjson.String:
+		v.validateString(keywords, value.String(), path)
+	case value.Type == gjson.Number:
+		v.validateNumber(keywords, value.Float(), path)
+	}
+
+	if all, ok := keywords["allOf"]; ok {
+		for _, sub := range all.Array() {
+			v.validate(sub, value, path, depth+1)
+		}
+	}
+	if anyOf, ok := keywords["anyOf"]; ok {
+		matched := false
+		for _, sub := range anyOf.Array() {
+			if v.matches(sub, value, depth+1) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			v.fail(path, "does not match any of the allowed schemas")
+		}
+	}
+	if oneOf, ok := keywords["oneOf"]; ok {
+		matched := 0
+		for _, sub := range oneOf.Array() {
+			if v.matches(sub, value, depth+1) {
+				matched++
+			}
+		}
+		if matched != 1 {
+			v.fail(path, "must match exactly one of the allowed schemas, matches %d", matched)
+		}
+	}
+	if not, ok := keywords["not"]; ok && v.matches(not, value, depth+1) {
+		v.fail(path, "matches a schema it must not match")
+	}
+}
+
+func (v *validator) validateObject(keywords map[string]gjson.Result, value gjson.Result, path string, depth int) {
+	members := fields(value)
+	properties := fields(keywords["properties"])
+	for _, name := range keywords["required"].Array() {
+		if _, ok := members[name.String()]; !ok {
+			v.fail(path, "missing required property %q", name.String())
+		}
+	}
+	additional, hasAdditional := keywords["additionalProperties"]
+	for name, member := range members {
+		memberPath := path + "." + name
+		if sub, ok := properties[name]; ok {
+			v.validate(sub, member, memberPath, depth+1)
+			continue
+		}
+		if !hasAdditional {
+			continue
+		}
+		if additional.Type == gjson.False {
+			v.fail(path, "property %q is not allowed", name)
+			continue
+		}
+		v.validate(additional, member, memberPath, depth+1)
+	}
+	if limit, ok := keywords["minProperties"]; ok && int64(len(members)) < limit.Int() {
+		v.fail(path, "must have at least %d properties", limit.Int())
+	}
+	if limit, ok := keywords["maxProperties"]; ok && int64(len(members)) > limit.Int() {
+		v.fail(path, "must have at most %d properties", limit.Int())
+	}
+}
+
+func (v *validator) validateArray(keywords map[string]gjson.Result, value gjson.Result, path string, depth int) {
+	elements := value.Array()
+	prefix := keywords["prefixItems"].Array()
+	for i, element := range elements {
+		elementPath := path + "[" + strconv.Itoa(i) + "]"
+		if i < len(prefix) {
+			v.validate(prefix[i], element, elementPath, depth+1)
+		} else if items, ok := keywords["items"]; ok {
+			v.validate(items, element, elementPath, depth+1)
+		}
+	}
+	if limit, ok := keywords["minItems"]; ok && int64(len(elements)) < limit.Int() {
+		v.fail(path, "must have at least %d items", limit.Int())
+	}
+	if limit, ok := keywords["maxItems"]; ok && int64(len(elements)) > limit.Int() {
+		v.fail(path, "must have at most %d items", limit.Int())
+	}
+}
+
+func (v *validator) validateString(keywords map[string]gjson.Result, value, path string) {
+	length := int64(utf8.RuneCountInString(value))
+	if limit, ok := keywords["minLength"]; ok && length < limit.Int() {
+		v.fail(path, "must be at least %d characters long", limit.Int())
+	}
+	if limit, ok := keywords["maxLength"]; ok && length > limit.Int() {
+		v.fail(path, "must be at most %d characters long", limit.Int())
+	}
+	if pattern, ok := keywords["pattern"]; ok {
+		re, err := regexp.Compile(pattern.String())
+		if err == nil && !re.MatchString(value) {
+			v.fail(path, "must match the pattern %s", pattern.String())
+		}
+	}
+}
+
+func (v *validator) validateNumber(keywords map[string]gjson.Result, value float64, path string) {
+	if limit, ok := keywords["minimum"]; ok && value < limit.Float() {
+		v.fail(path, "must be >= %s", limit.Raw)
+	}
+	if limit, ok := keywords["maximum"]; ok && value > limit.Float() {
+		v.fail(path, "must be <= %s", limit.Raw)
+	}
+	if limit, ok := keywords["exclusiveMinimum"]; ok && limit.Type == gjson.Number && value <= limit.Float() {
+		v.fail(path, "must be > %s", limit.Raw)
+	}
+	if limit, ok := keywords["exclusiveMaximum"]; ok && limit.Type == gjson.Number && value >= limit.Float() {
+		v.fail(path, "must be < %s", limit.Raw)
+	}
+	if divisor, ok := keywords["multipleOf"]; ok && divisor.Float() > 0 {
+		quotient := value / divisor.Float()
+		if math.Abs(quotient-math.Round(quotient)) > 1e-9 {
+			v.fail(path, "must be a multiple of %s", divisor.Raw)
+		}
+	}
+}
+
+// resolve follows a local JSON pointer such as "#/$defs/item".
+func (v *validator) resolve(ref string) (gjson.Result, bool) {
+	if ref == "#" {
+		return v.root, true
+	}
+	if !strings.HasPrefix(ref, "#/") {
+		return gjson.Result{}, false
+	}
+	current := v.root
+	for _, token := range strings.Split(ref[2:], "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		if current.IsArray() {
+			index, err := strconv.Atoi(token)
+			elements := current.Array()
+			if err != nil || index < 0 || index >= len(elements) {
+				return gjson.Result{}, false
+			}
+			current = elements[index]
+			continue
+		}
+		next, ok := fields(current)[token]
+		if !ok {
+			return gjson.Result{}, false
+		}
+		current = next
+	}
+	return current, true
+}
+
+// fields returns the members of a JSON object by name, without interpreting the names as paths.
+func fields(object gjson.Result) map[string]gjson.Result {
+	out := make(map[string]gjson.Result)
+	if !object.IsObject() {
+		return out
+	}
+	object.ForEach(func(key, value gjson.Result) bool {
+		out[key.String()] = value
+		return true
+	})
+	return out
+}
+
+func matchesType(types, value gjson.Result) bool {
+	if types.IsArray() {
+		for _, t := range types.Array() {
+			if matchesType(t, value) {
+				return true
+			}
+		}
+		return false
+	}
+	switch types.String() {
+	case "object":
+		return value.IsObject()
+	case "array":
+		return value.IsArray()
+	case "string":
+		return value.Type == gjson.String
+	case "number":
+		return value.Type == gjson.Number
+	case "integer":
+		return value.Type == gjson.Number && value.Float() == math.Trunc(value.Float())
+	case "boolean":
+		return value.Type == gjson.True || value.Type == gjson.False
+	case "null":
+		return value.Type == gjson.Null
+	}
+	return true
+}
+
+func typeList(types gjson.Result) string {
+	if !types.IsArray() {
+		return types.String()
+	}
+	var names []string
+	for _, t := range types.Array() {
+		names = append(names, t.String())
+	}
+	return strings.Join(names, " or ")
+}
+
+func typeName(value gjson.Result) string {
+	switch {
+	case value.IsObject():
+		return "object"
+	case value.IsArray():
+		return "array"
+	}
+	switch value.Type {
+	case gjson.String:
+		return "string"
+	case gjson.Number:
+		return "number"
+	case gjson.True, gjson.False:
+		return "boolean"
+	case gjson.Null:
+		return "null"
+	}
+	return "nothing"
+}
+
+// equalJSON compares two JSON values structurally.
+func equalJSON(a, b gjson.Result) bool {
+	var left, right any
+	if json.Unmarshal([]byte(a.Raw), &left) != nil || json.Unmarshal([]byte(b.Raw), &right) != nil {
+		return false
+	}
+	return reflect.DeepEqual(left, right)
+}
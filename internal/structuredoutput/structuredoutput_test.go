@@ -0,0 +1,71 @@
+package structuredoutput
+
+import (
+	"strings"
+	"testing"
+)
+
+const invoiceSchema = `{
+	"type":"object",
+	"properties":{
+		"number":{"type":"string","pattern":"^INV-[0-9]+$"},
+		"total":{"type":"number","minimum":0},
+		"currency":{"type":"string","enum":["EUR","USD"]},
+		"lines":{"type":"array","minItems":1,"items":{"$ref":"#/$defs/line"}},
+		"note":{"type":["string","null"]}
+	},
+	"required":["number","total","currency","lines","note"],
+	"additionalProperties":false,
+	"$defs":{"line":{"type":"object","properties":{"sku":{"type":"string"},"qty":{"type":"integer"}},"required":["sku","qty"],"additionalProperties":false}}
+}`
+
+func TestValidate(t *testing.T) {
+	valid := `{"number":"INV-12","total":9.5,"currency":"EUR","lines":[{"sku":"a","qty":2}],"note":null}`
+	if violations := Validate([]byte(invoiceSchema), []byte(valid)); len(violations) != 0 {
+		t.Fatalf("valid document rejected: %v", violations)
+	}
+
+	cases := map[string]string{
+		`{"number":"12","total":9.5,"currency":"EUR","lines":[{"sku":"a","qty":2}],"note":null}`:               "$.number: must match the pattern",
+		`{"number":"INV-1","total":-1,"currency":"EUR","lines":[{"sku":"a","qty":2}],"note":null}`:             "$.total: must be >= 0",
+		`{"number":"INV-1","total":1,"currency":"GBP","lines":[{"sku":"a","qty":2}],"note":null}`:              "$.currency: must be one of",
+		`{"number":"INV-1","total":1,"currency":"EUR","lines":[],"note":null}`:                                 "$.lines: must have at least 1 items",
+		`{"number":"INV-1","total":1,"currency":"EUR","lines":[{"sku":"a","qty":1.5}],"note":null}`:            "$.lines[0].qty: expected integer, got number",
+		`{"number":"INV-1","total":1,"currency":"EUR","lines":[{"sku":"a","qty":1}]}`:                          `$: missing required property "note"`,
+		`{"number":"INV-1","total":1,"currency":"EUR","lines":[{"sku":"a","qty":1}],"note":null,"extra":true}`: `$: property "extra" is not allowed`,
+		`{"number":"INV-1","total":1,"currency":"EUR","lines":[{"sku":"a","qty":1,"price":3}],"note":null}`:    `$.lines[0]: property "price" is not allowed`,
+		`{"number":"INV-1","total":1,"currency":"EUR","lines":[{"sku":"a","qty":1}],"note":4}`:                 "$.note: expected string or null, got number",
+		`["not","an","object"]`: "$: expected object, got array",
+	}
+	for doc, want := range cases {
+		violations := Validate([]byte(invoiceSchema), []byte(doc))
+		if len(violations) == 0 || !strings.Contains(strings.Join(violations, "\n"), want) {
+			t.Errorf("Validate(%s) = %v, want a violation containing %q", doc, violations, want)
+		}
+	}
+
+	anyOf := `{"anyOf":[{"type":"string"},{"type":"object","properties":{"id":{"const":1}},"required":["id"]}]}`
+	if violations := Validate([]byte(anyOf), []byte(`{"id":1}`)); len(violations) != 0 {
+		t.Fatalf("anyOf match rejected: %v", violations)
+	}
+	if violations := Validate([]byte(anyOf), []byte(`{"id":2}`)); len(violations) != 1 {
+		t.Fatalf("anyOf mismatch = %v, want one violation", violations)
+	}
+}
+
+func TestExtract(t *testing.T) {
+	cases := map[string]string{
+		`{"a":1}`:                 `{"a":1}`,
+		"```json\n{\"a\":1}\n```": `{"a":1}`,
+		"Here is the result:\n{\"a\":{\"b\":[1,2]}}\nDone.": `{"a":{"b":[1,2]}}`,
+		"Items: [1, 2, 3]": `[1, 2, 3]`,
+	}
+	for answer, want := range cases {
+		if got, ok := Extract(answer); !ok || got != want {
+			t.Errorf("Extract(%q) = %q, %v; want %q", answer, got, ok, want)
+		}
+	}
+	if _, ok := Extract("I cannot answer that."); ok {
+		t.Fatal("prose without JSON must not be extracted")
+	}
+}
@@ -0,0 +1,284 @@
+// Package tracing wires OpenTelemetry tracing through the request pipeline.
+// Spans are created through the global tracer provider, so they are no-ops until
+// Configure installs an exporter, and tests can install an in-memory exporter with
+// otel.SetTracerProvider.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/buildinfo"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+const instrumentationName = "github.com/router-for-me/CLIProxyAPI/v6"
+
+const defaultServiceName = "cli-proxy-api"
+
+// Span attribute keys shared by the instrumented stages.
+const (
+	AttrRequestID  = attribute.Key("cliproxy.request_id")
+	AttrHandler    = attribute.Key("cliproxy.handler")
+	AttrModel      = attribute.Key("cliproxy.model")
+	AttrProvider   = attribute.Key("cliproxy.provider")
+	AttrAuthIndex  = attribute.Key("cliproxy.auth.index")
+	AttrAttempt    = attribute.Key("cliproxy.attempt")
+	AttrStream     = attribute.Key("cliproxy.stream")
+	AttrFromFormat = attribute.Key("cliproxy.translator.from")
+	AttrToFormat   = attribute.Key("cliproxy.translator.to")
+	AttrCandidates = attribute.Key("cliproxy.auth.candidates")
+	AttrCache      = attribute.Key("cliproxy.cache")
+	AttrStatusCode = semconv.HTTPResponseStatusCodeKey
+)
+
+var (
+	providerMu sync.Mutex
+	provider   *sdktrace.TracerProvider
+	active     config.TracingConfig
+)
+
+func init() {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+}
+
+// Tracer returns the tracer used for proxy spans.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Start begins a span named name as a child of the span carried by ctx.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records err (if any) on span and ends it.
+func End(span trace.Span, err error) {
+	if span == nil {
+		return
+	}
+	RecordError(span, err)
+	span.End()
+}
+
+// RecordError marks span as failed with err; nil errors are ignored.
+func RecordError(span trace.Span, err error) {
+	if span == nil || err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// SetStatusCode attaches an HTTP status code to span; non-positive codes are ignored.
+func SetStatusCode(span trace.Span, code int) {
+	if span == nil || code <= 0 {
+		return
+	}
+	span.SetAttributes(AttrStatusCode.Int(code))
+}
+
+// WithSpanFrom returns dst carrying the span of src when dst has none.
+// Handlers detach their execution context from the gin request context, so the
+// server span has to be copied across explicitly.
+func WithSpanFrom(dst, src context.Context) context.Context {
+	if dst == nil || src == nil {
+		return dst
+	}
+	if trace.SpanContextFromContext(dst).IsValid() {
+		return dst
+	}
+	span := trace.SpanFromContext(src)
+	if !span.SpanContext().IsValid() {
+		return dst
+	}
+	return trace.ContextWithSpan(dst, span)
+}
+
+// Configure installs (or removes) the OTLP exporter described by cfg.
+// Reconfiguring with an identical config is a no-op.
+func Configure(cfg config.TracingConfig) error {
+	providerMu.Lock()
+	defer providerMu.Unlock()
+	if provider != nil && tracingConfigEqual(active, cfg) {
+		return nil
+	}
+	if provider != nil {
+		shutdownLocked()
+	}
+	active = cfg
+	if !cfg.Enabled {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	exporter, err := otlptracehttp.New(ctx, exporterOptions(cfg)...)
+	if err != nil {
+		return fmt.Errorf("tracing: create OTLP exporter: %w", err)
+	}
+	serviceName := strings.TrimSpace(cfg.ServiceName)
+	if serviceName == "" {
+		serviceName = defaultServiceName
+	}
+	res := resource.NewSchemaless(
+		semconv.ServiceName(serviceName),
+		semconv.ServiceVersion(buildinfo.Version),
+	)
+	sampler := sdktrace.ParentBased(sdktrace.AlwaysSample())
+	if cfg.SampleRatio > 0 && cfg.SampleRatio < 1 {
+		sampler = sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))
+	}
+	provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sampler),
+	)
+	otel.SetTracerProvider(provider)
+	return nil
+}
+
+// Shutdown flushes pending spans and uninstalls the exporter.
+func Shutdown(ctx context.Context) error {
+	providerMu.Lock()
+	defer providerMu.Unlock()
+	if provider == nil {
+		return nil
+	}
+	err := provider.Shutdown(ctx)
+	provider = nil
+	active = config.TracingConfig{}
+	otel.SetTracerProvider(noop.NewTracerProvider())
+	return err
+}
+
+func shutdownLocked() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_ = provider.Shutdown(ctx)
+	provider = nil
+	otel.SetTracerProvider(noop.NewTracerProvider())
+}
+
+func exporterOptions(cfg config.TracingConfig) []otlptracehttp.Option {
+	var opts []otlptracehttp.Option
+	if endpoint := strings.TrimSpace(cfg.Endpoint); endpoint != "" {
+		if strings.Contains(endpoint, "://") {
+			opts = append(opts, otlptracehttp.WithEndpointURL(endpoint))
+		} else {
+			opts = append(opts, otlptracehttp.WithEndpoint(endpoint))
+		}
+	}
+	if cfg.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	if len(cfg.Headers) > 0 {
+		opts = append(opts, otlptracehttp.WithHeaders(cfg.Headers))
+	}
+	return opts
+}
+
+func tracingConfigEqual(a, b config.TracingConfig) bool {
+	if a.Enabled != b.Enabled || a.Endpoint != b.Endpoint || a.Insecure != b.Insecure ||
+		a.ServiceName != b.ServiceName || a.SampleRatio != b.SampleRatio || len(a.Headers) != len(b.Headers) {
+		return false
+	}
+	for k, v := range a.Headers {
+		if b.Headers[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Middleware starts a server span per request, continuing any W3C trace context sent by the client.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+		route := c.FullPath()
+		if route == "" {
+			route = c.Request.URL.Path
+		}
+		ctx, span := Tracer().Start(ctx, c.Request.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(c.Request.Method),
+				semconv.HTTPRoute(route),
+				semconv.URLPath(c.Request.URL.Path),
+			),
+		)
+		defer span.End()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		SetStatusCode(span, status)
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
+
+// Transport wraps base so that every upstream round trip is recorded as a client span.
+// Trace headers are not injected: upstreams are third-party APIs that should not
+// receive the proxy's internal trace identifiers.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if _, ok := base.(*tracingTransport); ok {
+		return base
+	}
+	return &tracingTransport{base: base}
+}
+
+type tracingTransport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := Tracer().Start(req.Context(), "HTTP "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(req.Method),
+			semconv.ServerAddress(req.URL.Hostname()),
+			semconv.URLPath(req.URL.Path),
+		),
+	)
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		End(span, err)
+		return resp, err
+	}
+	SetStatusCode(span, resp.StatusCode)
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, resp.Status)
+	}
+	span.End()
+	return resp, nil
+}
+
+// Unwrap exposes the underlying transport.
+func (t *tracingTransport) Unwrap() http.RoundTripper {
+	return t.base
+}
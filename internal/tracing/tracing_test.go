@@ -0,0 +1,79 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func installTestProvider(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return exporter
+}
+
+func TestTransport_RecordsUpstreamRoundTrip(t *testing.T) {
+	exporter := installTestProvider(t)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("traceparent") != "" {
+			t.Errorf("traceparent leaked to upstream")
+		}
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer upstream.Close()
+
+	ctx, parent := Start(context.Background(), "parent")
+	client := &http.Client{Transport: Transport(nil)}
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, upstream.URL+"/v1/messages", nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	_ = resp.Body.Close()
+	parent.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("spans = %d, want 2", len(spans))
+	}
+	roundTrip := spans[0]
+	if roundTrip.Name != "HTTP POST" {
+		t.Fatalf("span name = %q, want %q", roundTrip.Name, "HTTP POST")
+	}
+	if roundTrip.Parent.SpanID() != spans[1].SpanContext.SpanID() {
+		t.Fatalf("round trip span is not a child of the caller span")
+	}
+	var status int64
+	for _, attr := range roundTrip.Attributes {
+		if attr.Key == AttrStatusCode {
+			status = attr.Value.AsInt64()
+		}
+	}
+	if status != http.StatusTooManyRequests {
+		t.Fatalf("status code attribute = %d, want %d", status, http.StatusTooManyRequests)
+	}
+	if Transport(client.Transport) != client.Transport {
+		t.Fatalf("Transport() wrapped an already traced transport")
+	}
+}
+
+func TestWithSpanFrom(t *testing.T) {
+	installTestProvider(t)
+	src, span := Start(context.Background(), "server")
+	defer span.End()
+
+	dst := WithSpanFrom(context.Background(), src)
+	_, child := Start(dst, "child")
+	defer child.End()
+	if child.SpanContext().TraceID() != span.SpanContext().TraceID() {
+		t.Fatalf("child trace id = %s, want %s", child.SpanContext().TraceID(), span.SpanContext().TraceID())
+	}
+}
@@ -0,0 +1,92 @@
+// Package embeddings translates OpenAI embeddings requests into Gemini batchEmbedContents
+// requests and converts the Gemini embeddings back into the OpenAI response shape.
+package embeddings
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"math"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// ErrTokenInput reports an OpenAI request whose input is pre-tokenized; Gemini only embeds text.
+var ErrTokenInput = errors.New("token array input is not supported for Gemini embeddings")
+
+// ErrEmptyInput reports an OpenAI request without any text to embed.
+var ErrEmptyInput = errors.New("input must be a non-empty string or array of strings")
+
+// ConvertOpenAIRequestToGemini converts an OpenAI /v1/embeddings request into a Gemini
+// batchEmbedContents request for modelName. "dimensions" maps to outputDimensionality.
+func ConvertOpenAIRequestToGemini(modelName string, rawJSON []byte) ([]byte, error) {
+	input := gjson.GetBytes(rawJSON, "input")
+	var texts []string
+	switch {
+	case input.Type == gjson.String:
+		texts = append(texts, input.String())
+	case input.IsArray():
+		for _, item := range input.Array() {
+			if item.Type != gjson.String {
+				return nil, ErrTokenInput
+			}
+			texts = append(texts, item.String())
+		}
+	}
+	if len(texts) == 0 {
+		return nil, ErrEmptyInput
+	}
+
+	modelPath := "models/" + strings.TrimPrefix(modelName, "models/")
+	dimensions := gjson.GetBytes(rawJSON, "dimensions").Int()
+	out := []byte(`{"requests":[]}`)
+	for _, text := range texts {
+		item := []byte(`{}`)
+		item, _ = sjson.SetBytes(item, "model", modelPath)
+		item, _ = sjson.SetBytes(item, "content.parts.0.text", text)
+		if dimensions > 0 {
+			item, _ = sjson.SetBytes(item, "outputDimensionality", dimensions)
+		}
+		out, _ = sjson.SetRawBytes(out, "requests.-1", item)
+	}
+	return out, nil
+}
+
+// ConvertGeminiResponseToOpenAI converts a Gemini batchEmbedContents response into an
+// OpenAI embeddings list. originalRequest is the client request; when it asks for
+// encoding_format "base64" the vectors are encoded as little-endian float32 bytes.
+// promptTokens fills the usage block because Gemini does not report it for embeddings.
+func ConvertGeminiResponseToOpenAI(modelName string, originalRequest, rawJSON []byte, promptTokens int64) []byte {
+	useBase64 := gjson.GetBytes(originalRequest, "encoding_format").String() == "base64"
+	out := []byte(`{"object":"list","data":[],"model":"","usage":{"prompt_tokens":0,"total_tokens":0}}`)
+	out, _ = sjson.SetBytes(out, "model", modelName)
+	for i, embedding := range gjson.GetBytes(rawJSON, "embeddings").Array() {
+		item := []byte(`{"object":"embedding","index":0}`)
+		item, _ = sjson.SetBytes(item, "index", i)
+		values := embedding.Get("values")
+		if useBase64 {
+			item, _ = sjson.SetBytes(item, "embedding", encodeFloat32Base64(values))
+		} else {
+			raw := values.Raw
+			if raw == "" {
+				raw = "[]"
+			}
+			item, _ = sjson.SetRawBytes(item, "embedding", []byte(raw))
+		}
+		out, _ = sjson.SetRawBytes(out, "data.-1", item)
+	}
+	out, _ = sjson.SetBytes(out, "usage.prompt_tokens", promptTokens)
+	out, _ = sjson.SetBytes(out, "usage.total_tokens", promptTokens)
+	return out
+}
+
+func encodeFloat32Base64(values gjson.Result) string {
+	items := values.Array()
+	buf := make([]byte, 4*len(items))
+	for i, v := range items {
+		binary.LittleEndian.PutUint32(buf[i*4:], math.Float32bits(float32(v.Float())))
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
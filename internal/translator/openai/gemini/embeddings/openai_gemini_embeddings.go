@@ -0,0 +1,57 @@
+// Package embeddings translates Gemini batchEmbedContents requests into OpenAI embeddings
+// requests and converts the OpenAI embeddings back into the Gemini response shape.
+package embeddings
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// ErrEmptyInput reports a Gemini request without any text parts to embed.
+var ErrEmptyInput = errors.New("requests must contain at least one text part")
+
+// ConvertGeminiRequestToOpenAI converts a Gemini batchEmbedContents request into an OpenAI
+// /v1/embeddings request for modelName. Text parts of one content are joined with newlines
+// and outputDimensionality maps to "dimensions".
+func ConvertGeminiRequestToOpenAI(modelName string, rawJSON []byte) ([]byte, error) {
+	out := []byte(`{"model":"","input":[]}`)
+	out, _ = sjson.SetBytes(out, "model", modelName)
+	requests := gjson.GetBytes(rawJSON, "requests").Array()
+	if len(requests) == 0 {
+		return nil, ErrEmptyInput
+	}
+	for _, request := range requests {
+		var parts []string
+		for _, part := range request.Get("content.parts").Array() {
+			if text := part.Get("text"); text.Exists() {
+				parts = append(parts, text.String())
+			}
+		}
+		out, _ = sjson.SetBytes(out, "input.-1", strings.Join(parts, "\n"))
+	}
+	if dimensions := requests[0].Get("outputDimensionality").Int(); dimensions > 0 {
+		out, _ = sjson.SetBytes(out, "dimensions", dimensions)
+	}
+	return out, nil
+}
+
+// ConvertOpenAIResponseToGemini converts an OpenAI embeddings list into a Gemini
+// batchEmbedContents response, ordering the vectors by their index.
+func ConvertOpenAIResponseToGemini(rawJSON []byte) []byte {
+	data := gjson.GetBytes(rawJSON, "data").Array()
+	sort.SliceStable(data, func(i, j int) bool { return data[i].Get("index").Int() < data[j].Get("index").Int() })
+	out := []byte(`{"embeddings":[]}`)
+	for _, item := range data {
+		raw := item.Get("embedding").Raw
+		if raw == "" || !strings.HasPrefix(raw, "[") {
+			raw = "[]"
+		}
+		embedding, _ := sjson.SetRawBytes([]byte(`{}`), "values", []byte(raw))
+		out, _ = sjson.SetRawBytes(out, "embeddings.-1", embedding)
+	}
+	return out
+}
@@ -20,6 +20,7 @@ var statisticsEnabled atomic.Bool
 func init() {
 	statisticsEnabled.Store(true)
 	coreusage.RegisterPlugin(NewLoggerPlugin())
+	coreusage.RegisterPlugin(persistentStorePlugin{})
 }
 
 // LoggerPlugin collects in-memory request statistics for usage analysis.
@@ -94,6 +95,7 @@ type RequestDetail struct {
 	AuthIndex string     `json:"auth_index"`
 	Tokens    TokenStats `json:"tokens"`
 	Failed    bool       `json:"failed"`
+	Hedged    bool       `json:"hedged,omitempty"`
 }
 
 // TokenStats captures the token usage breakdown for a request.
@@ -202,6 +204,7 @@ func (s *RequestStatistics) Record(ctx context.Context, record coreusage.Record)
 		AuthIndex: record.AuthIndex,
 		Tokens:    detail,
 		Failed:    failed,
+		Hedged:    record.Hedged,
 	})
 
 	s.requestsByDay[dayKey]++
@@ -0,0 +1,343 @@
+package usage
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	log "github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+var usageRecordsBucket = []byte("records")
+
+// Supported group-by dimensions for history queries.
+const (
+	GroupByAPIKey    = "api_key"
+	GroupByAuthIndex = "auth_index"
+	GroupByModel     = "model"
+	GroupByProvider  = "provider"
+	GroupByDay       = "day"
+)
+
+// StoredRecord is the durable representation of a single usage record.
+type StoredRecord struct {
+	Timestamp time.Time  `json:"timestamp"`
+	APIKey    string     `json:"api_key,omitempty"`
+	AuthID    string     `json:"auth_id,omitempty"`
+	AuthIndex string     `json:"auth_index,omitempty"`
+	Provider  string     `json:"provider,omitempty"`
+	Model     string     `json:"model,omitempty"`
+	Source    string     `json:"source,omitempty"`
+	Failed    bool       `json:"failed"`
+	Hedged    bool       `json:"hedged,omitempty"`
+	Tokens    TokenStats `json:"tokens"`
+}
+
+// HistoryQuery selects stored records by time range and aggregates them by the requested dimensions.
+type HistoryQuery struct {
+	// From is the inclusive lower bound; zero means the beginning of history.
+	From time.Time
+	// To is the exclusive upper bound; zero means now.
+	To time.Time
+	// GroupBy lists the dimensions used to aggregate records (see the GroupBy constants).
+	GroupBy []string
+}
+
+// HistoryGroup aggregates stored records sharing the same group-by values.
+type HistoryGroup struct {
+	APIKey    string     `json:"api_key,omitempty"`
+	AuthIndex string     `json:"auth_index,omitempty"`
+	Model     string     `json:"model,omitempty"`
+	Provider  string     `json:"provider,omitempty"`
+	Day       string     `json:"day,omitempty"`
+	Requests  int64      `json:"requests"`
+	Failures  int64      `json:"failures"`
+	Tokens    TokenStats `json:"tokens"`
+}
+
+// HistoryResult is the response produced by PersistentStore.Query.
+type HistoryResult struct {
+	From    time.Time      `json:"from"`
+	To      time.Time      `json:"to"`
+	GroupBy []string       `json:"group_by"`
+	Groups  []HistoryGroup `json:"groups"`
+}
+
+// PersistentStore appends usage records to an embedded bbolt database so that
+// accounting history survives restarts and can be queried by time range.
+type PersistentStore struct {
+	db   *bolt.DB
+	path string
+}
+
+// OpenPersistentStore opens (or creates) the usage database at path.
+func OpenPersistentStore(path string) (*PersistentStore, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return nil, fmt.Errorf("usage store: path is empty")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("usage store: create directory: %w", err)
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("usage store: open database: %w", err)
+	}
+	if err = db.Update(func(tx *bolt.Tx) error {
+		_, errBucket := tx.CreateBucketIfNotExists(usageRecordsBucket)
+		return errBucket
+	}); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("usage store: create bucket: %w", err)
+	}
+	return &PersistentStore{db: db, path: path}, nil
+}
+
+// Path returns the database file location.
+func (s *PersistentStore) Path() string {
+	if s == nil {
+		return ""
+	}
+	return s.path
+}
+
+// Close releases the underlying database.
+func (s *PersistentStore) Close() error {
+	if s == nil || s.db == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// HandleUsage implements coreusage.Plugin by appending the record to the database.
+func (s *PersistentStore) HandleUsage(ctx context.Context, record coreusage.Record) {
+	if s == nil {
+		return
+	}
+	timestamp := record.RequestedAt
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	apiKey := record.APIKey
+	if apiKey == "" {
+		apiKey = resolveAPIIdentifier(ctx, record)
+	}
+	failed := record.Failed
+	if !failed {
+		failed = !resolveSuccess(ctx)
+	}
+	stored := StoredRecord{
+		Timestamp: timestamp.UTC(),
+		APIKey:    apiKey,
+		AuthID:    record.AuthID,
+		AuthIndex: record.AuthIndex,
+		Provider:  record.Provider,
+		Model:     record.Model,
+		Source:    record.Source,
+		Failed:    failed,
+		Hedged:    record.Hedged,
+		Tokens:    normaliseDetail(record.Detail),
+	}
+	if err := s.Append(stored); err != nil {
+		log.Errorf("usage store: failed to persist record: %v", err)
+	}
+}
+
+// Append writes a stored record to the database.
+func (s *PersistentStore) Append(record StoredRecord) error {
+	if s == nil || s.db == nil {
+		return fmt.Errorf("usage store: not open")
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(usageRecordsBucket)
+		seq, errSeq := bucket.NextSequence()
+		if errSeq != nil {
+			return errSeq
+		}
+		return bucket.Put(recordKey(record.Timestamp, seq), data)
+	})
+}
+
+// Query aggregates stored records within the requested time range.
+func (s *PersistentStore) Query(query HistoryQuery) (HistoryResult, error) {
+	result := HistoryResult{From: query.From, To: query.To, Groups: []HistoryGroup{}}
+	if s == nil || s.db == nil {
+		return result, fmt.Errorf("usage store: not open")
+	}
+	groupBy, err := normaliseGroupBy(query.GroupBy)
+	if err != nil {
+		return result, err
+	}
+	result.GroupBy = groupBy
+	if result.To.IsZero() {
+		result.To = time.Now().UTC()
+	}
+
+	groups := make(map[string]*HistoryGroup)
+	err = s.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(usageRecordsBucket).Cursor()
+		upper := recordKey(result.To, 0)
+		for key, value := cursor.Seek(recordKey(result.From, 0)); key != nil; key, value = cursor.Next() {
+			if string(key) >= string(upper) {
+				break
+			}
+			var record StoredRecord
+			if errUnmarshal := json.Unmarshal(value, &record); errUnmarshal != nil {
+				continue
+			}
+			group := groupForRecord(groups, groupBy, record)
+			group.Requests++
+			if record.Failed {
+				group.Failures++
+			}
+			group.Tokens.InputTokens += record.Tokens.InputTokens
+			group.Tokens.OutputTokens += record.Tokens.OutputTokens
+			group.Tokens.ReasoningTokens += record.Tokens.ReasoningTokens
+			group.Tokens.CachedTokens += record.Tokens.CachedTokens
+			group.Tokens.TotalTokens += record.Tokens.TotalTokens
+		}
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		result.Groups = append(result.Groups, *groups[key])
+	}
+	return result, nil
+}
+
+func groupForRecord(groups map[string]*HistoryGroup, groupBy []string, record StoredRecord) *HistoryGroup {
+	var group HistoryGroup
+	parts := make([]string, 0, len(groupBy))
+	for _, dimension := range groupBy {
+		switch dimension {
+		case GroupByAPIKey:
+			group.APIKey = record.APIKey
+			parts = append(parts, record.APIKey)
+		case GroupByAuthIndex:
+			group.AuthIndex = record.AuthIndex
+			parts = append(parts, record.AuthIndex)
+		case GroupByModel:
+			group.Model = record.Model
+			parts = append(parts, record.Model)
+		case GroupByProvider:
+			group.Provider = record.Provider
+			parts = append(parts, record.Provider)
+		case GroupByDay:
+			group.Day = record.Timestamp.UTC().Format("2006-01-02")
+			parts = append(parts, group.Day)
+		}
+	}
+	key := strings.Join(parts, "\x00")
+	if existing, ok := groups[key]; ok {
+		return existing
+	}
+	groups[key] = &group
+	return &group
+}
+
+func normaliseGroupBy(dimensions []string) ([]string, error) {
+	out := make([]string, 0, len(dimensions))
+	seen := make(map[string]struct{}, len(dimensions))
+	for _, raw := range dimensions {
+		dimension := strings.ToLower(strings.TrimSpace(raw))
+		dimension = strings.ReplaceAll(dimension, "-", "_")
+		if dimension == "" {
+			continue
+		}
+		switch dimension {
+		case GroupByAPIKey, GroupByAuthIndex, GroupByModel, GroupByProvider, GroupByDay:
+		default:
+			return nil, fmt.Errorf("usage store: unsupported group-by dimension %q", raw)
+		}
+		if _, ok := seen[dimension]; ok {
+			continue
+		}
+		seen[dimension] = struct{}{}
+		out = append(out, dimension)
+	}
+	return out, nil
+}
+
+// recordKey orders records by timestamp; the sequence keeps keys unique within the same instant.
+func recordKey(ts time.Time, seq uint64) []byte {
+	key := make([]byte, 16)
+	nanos := ts.UnixNano()
+	if ts.IsZero() || nanos < 0 {
+		nanos = 0
+	}
+	binary.BigEndian.PutUint64(key[:8], uint64(nanos))
+	binary.BigEndian.PutUint64(key[8:], seq)
+	return key
+}
+
+var (
+	persistentStoreMu sync.RWMutex
+	persistentStore   *PersistentStore
+)
+
+// persistentStorePlugin forwards usage records to the currently configured persistent store.
+type persistentStorePlugin struct{}
+
+// HandleUsage implements coreusage.Plugin. The read lock is held for the whole write so a
+// reload cannot close the store while a record is being appended.
+func (persistentStorePlugin) HandleUsage(ctx context.Context, record coreusage.Record) {
+	persistentStoreMu.RLock()
+	defer persistentStoreMu.RUnlock()
+	if persistentStore != nil {
+		persistentStore.HandleUsage(ctx, record)
+	}
+}
+
+// GetPersistentStore returns the active persistent store, or nil when disabled.
+func GetPersistentStore() *PersistentStore {
+	persistentStoreMu.RLock()
+	defer persistentStoreMu.RUnlock()
+	return persistentStore
+}
+
+// ConfigurePersistentStore opens the persistent store at path, replacing any store that
+// uses a different path. An empty path closes the active store and disables persistence.
+func ConfigurePersistentStore(path string) error {
+	path = strings.TrimSpace(path)
+	persistentStoreMu.Lock()
+	defer persistentStoreMu.Unlock()
+	if persistentStore != nil && persistentStore.Path() == path {
+		return nil
+	}
+	if persistentStore != nil {
+		if err := persistentStore.Close(); err != nil {
+			log.Warnf("usage store: failed to close %s: %v", persistentStore.Path(), err)
+		}
+		persistentStore = nil
+	}
+	if path == "" {
+		return nil
+	}
+	store, err := OpenPersistentStore(path)
+	if err != nil {
+		return err
+	}
+	persistentStore = store
+	return nil
+}
@@ -0,0 +1,116 @@
+package usage
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestPersistentStoreQuery_GroupsByKeyAndDay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.db")
+	store, err := OpenPersistentStore(path)
+	if err != nil {
+		t.Fatalf("OpenPersistentStore() error = %v", err)
+	}
+
+	day1 := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	day2 := day1.Add(24 * time.Hour)
+	records := []coreusage.Record{
+		{APIKey: "a", Model: "m1", RequestedAt: day1, Detail: coreusage.Detail{InputTokens: 10, OutputTokens: 5}},
+		{APIKey: "a", Model: "m2", RequestedAt: day1.Add(time.Minute), Detail: coreusage.Detail{TotalTokens: 7}},
+		{APIKey: "b", Model: "m1", RequestedAt: day1, Failed: true},
+		{APIKey: "a", Model: "m1", RequestedAt: day2, Detail: coreusage.Detail{TotalTokens: 100}},
+	}
+	for _, record := range records {
+		store.HandleUsage(context.Background(), record)
+	}
+	if err = store.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// Reopen to verify records survive restarts.
+	store, err = OpenPersistentStore(path)
+	if err != nil {
+		t.Fatalf("OpenPersistentStore() reopen error = %v", err)
+	}
+	defer func() { _ = store.Close() }()
+
+	result, err := store.Query(HistoryQuery{From: day1.Truncate(24 * time.Hour), To: day2.Truncate(24 * time.Hour), GroupBy: []string{"api-key", "day"}})
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if len(result.Groups) != 2 {
+		t.Fatalf("Query() groups = %+v, want 2 groups", result.Groups)
+	}
+	first := result.Groups[0]
+	if first.APIKey != "a" || first.Day != "2026-03-01" || first.Requests != 2 || first.Tokens.TotalTokens != 22 {
+		t.Fatalf("unexpected first group: %+v", first)
+	}
+	second := result.Groups[1]
+	if second.APIKey != "b" || second.Requests != 1 || second.Failures != 1 {
+		t.Fatalf("unexpected second group: %+v", second)
+	}
+
+	all, err := store.Query(HistoryQuery{GroupBy: []string{"model"}})
+	if err != nil {
+		t.Fatalf("Query() all error = %v", err)
+	}
+	if len(all.Groups) != 2 || all.Groups[0].Model != "m1" || all.Groups[0].Requests != 3 {
+		t.Fatalf("unexpected model groups: %+v", all.Groups)
+	}
+
+	if _, err = store.Query(HistoryQuery{GroupBy: []string{"unknown"}}); err == nil {
+		t.Fatalf("Query() with unsupported group-by error = nil")
+	}
+}
+
+func TestPersistentStorePlugin_KeepsRecordsAcrossReload(t *testing.T) {
+	dir := t.TempDir()
+	first, second := filepath.Join(dir, "first.db"), filepath.Join(dir, "second.db")
+	if err := ConfigurePersistentStore(first); err != nil {
+		t.Fatalf("ConfigurePersistentStore() error = %v", err)
+	}
+	t.Cleanup(func() { _ = ConfigurePersistentStore("") })
+
+	const writers, perWriter = 4, 50
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				persistentStorePlugin{}.HandleUsage(context.Background(), coreusage.Record{APIKey: "k", Model: "m"})
+			}
+		}()
+	}
+	if err := ConfigurePersistentStore(second); err != nil {
+		t.Fatalf("ConfigurePersistentStore() reload error = %v", err)
+	}
+	wg.Wait()
+	if err := ConfigurePersistentStore(""); err != nil {
+		t.Fatalf("ConfigurePersistentStore() close error = %v", err)
+	}
+
+	var total int64
+	for _, path := range []string{first, second} {
+		store, err := OpenPersistentStore(path)
+		if err != nil {
+			t.Fatalf("OpenPersistentStore(%s) error = %v", path, err)
+		}
+		result, err := store.Query(HistoryQuery{})
+		_ = store.Close()
+		if err != nil {
+			t.Fatalf("Query() error = %v", err)
+		}
+		for _, group := range result.Groups {
+			total += group.Requests
+		}
+	}
+	if total != writers*perWriter {
+		t.Fatalf("persisted %d records across the reload, want %d", total, writers*perWriter)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	kiroauth "github.com/router-for-me/CLIProxyAPI/v6/internal/auth/kiro"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/watcher/diff"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	log "github.com/sirupsen/logrus"
@@ -225,6 +226,7 @@ func (s *ConfigSynthesizer) synthesizeOpenAICompat(ctx *SynthesisContext) []*cor
 			if hash := diff.ComputeOpenAICompatModelsHash(compat.Models); hash != "" {
 				attrs["models_hash"] = hash
 			}
+			addOpenAICompatDiscoveryAttrs(compat, attrs)
 			addConfigHeadersToAttrs(compat.Headers, attrs)
 			a := &coreauth.Auth{
 				ID:         id,
@@ -256,6 +258,7 @@ func (s *ConfigSynthesizer) synthesizeOpenAICompat(ctx *SynthesisContext) []*cor
 			if hash := diff.ComputeOpenAICompatModelsHash(compat.Models); hash != "" {
 				attrs["models_hash"] = hash
 			}
+			addOpenAICompatDiscoveryAttrs(compat, attrs)
 			addConfigHeadersToAttrs(compat.Headers, attrs)
 			a := &coreauth.Auth{
 				ID:         id,
@@ -273,6 +276,20 @@ func (s *ConfigSynthesizer) synthesizeOpenAICompat(ctx *SynthesisContext) []*cor
 	return out
 }
 
+// addOpenAICompatDiscoveryAttrs records model discovery and exclusion settings so that
+// changing them re-registers the provider models.
+func addOpenAICompatDiscoveryAttrs(compat *config.OpenAICompatibility, attrs map[string]string) {
+	if hash := diff.ComputeExcludedModelsHash(compat.ExcludedModels); hash != "" {
+		attrs["excluded_models_hash"] = hash
+	}
+	if compat.DiscoverModels {
+		attrs["discover_models"] = "true"
+		if compat.DiscoverIntervalSeconds > 0 {
+			attrs["discover_interval_seconds"] = strconv.Itoa(compat.DiscoverIntervalSeconds)
+		}
+	}
+}
+
 // synthesizeVertexCompat creates Auth entries for Vertex-compatible providers.
 func (s *ConfigSynthesizer) synthesizeVertexCompat(ctx *SynthesisContext) []*coreauth.Auth {
 	cfg := ctx.Config
@@ -92,6 +92,16 @@ func (m *Manager) Handler() http.Handler {
 	return http.HandlerFunc(m.handleWebsocket)
 }
 
+// SessionCount reports how many websocket sessions are currently connected.
+func (m *Manager) SessionCount() int {
+	if m == nil {
+		return 0
+	}
+	m.sessMutex.RLock()
+	defer m.sessMutex.RUnlock()
+	return len(m.sessions)
+}
+
 // Stop gracefully closes all active websocket sessions.
 func (m *Manager) Stop(_ context.Context) error {
 	m.sessMutex.Lock()
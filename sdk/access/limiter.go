@@ -0,0 +1,272 @@
+package access
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+// WildcardPrincipal selects the limits applied to principals without a dedicated entry.
+const WildcardPrincipal = "*"
+
+const rateWindow = time.Minute
+
+// Limits describes the usage limits enforced for a single principal.
+// Zero values disable the corresponding limit.
+type Limits struct {
+	RequestsPerMinute    int
+	MaxConcurrentStreams int
+	DailyTokenBudget     int64
+	MonthlyTokenBudget   int64
+}
+
+func (l Limits) enabled() bool {
+	return l.RequestsPerMinute > 0 || l.MaxConcurrentStreams > 0 || l.DailyTokenBudget > 0 || l.MonthlyTokenBudget > 0
+}
+
+// LimitError reports that a principal exceeded one of its configured limits.
+type LimitError struct {
+	// Reason is a short machine readable identifier of the exceeded limit.
+	Reason string
+	// Message is a human readable description of the failure.
+	Message string
+	// RetryAfter is how long the caller should wait before retrying.
+	RetryAfter time.Duration
+}
+
+// Error implements the error interface.
+func (e *LimitError) Error() string {
+	if e == nil {
+		return ""
+	}
+	return e.Message
+}
+
+// StatusCode reports the HTTP status associated with limit failures.
+func (e *LimitError) StatusCode() int { return http.StatusTooManyRequests }
+
+// Headers returns the Retry-After header matching the limit reset time.
+func (e *LimitError) Headers() http.Header {
+	headers := make(http.Header)
+	if e == nil {
+		return headers
+	}
+	seconds := int(math.Ceil(e.RetryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	headers.Set("Retry-After", strconv.Itoa(seconds))
+	return headers
+}
+
+type principalState struct {
+	requests    []time.Time
+	streams     int
+	day         string
+	dayTokens   int64
+	month       string
+	monthTokens int64
+}
+
+// Limiter enforces per-principal request rates, stream concurrency and token budgets.
+// It implements usage.Plugin so token consumption can be fed from usage records.
+type Limiter struct {
+	mu     sync.Mutex
+	limits map[string]Limits
+	states map[string]*principalState
+	now    func() time.Time
+}
+
+// NewLimiter constructs a limiter without any configured limits.
+func NewLimiter() *Limiter {
+	return &Limiter{
+		limits: make(map[string]Limits),
+		states: make(map[string]*principalState),
+		now:    time.Now,
+	}
+}
+
+// SetLimits replaces the configured limits keyed by principal.
+// Accumulated counters are kept so budgets survive configuration reloads.
+func (l *Limiter) SetLimits(limits map[string]Limits) {
+	if l == nil {
+		return
+	}
+	cloned := make(map[string]Limits, len(limits))
+	for principal, limit := range limits {
+		principal = strings.TrimSpace(principal)
+		if principal == "" || !limit.enabled() {
+			continue
+		}
+		cloned[principal] = limit
+	}
+	l.mu.Lock()
+	l.limits = cloned
+	l.mu.Unlock()
+}
+
+// SetLimitsFromConfig replaces the configured limits using api-key-limits entries.
+func (l *Limiter) SetLimitsFromConfig(entries []config.APIKeyLimit) {
+	limits := make(map[string]Limits, len(entries))
+	for i := range entries {
+		entry := entries[i]
+		key := strings.TrimSpace(entry.APIKey)
+		if key == "" {
+			continue
+		}
+		limits[key] = Limits{
+			RequestsPerMinute:    entry.RequestsPerMinute,
+			MaxConcurrentStreams: entry.MaxConcurrentStreams,
+			DailyTokenBudget:     entry.DailyTokenBudget,
+			MonthlyTokenBudget:   entry.MonthlyTokenBudget,
+		}
+	}
+	l.SetLimits(limits)
+}
+
+// Acquire admits a request for the principal or returns a *LimitError.
+// On success the returned release func must be called once the request completes.
+func (l *Limiter) Acquire(principal string, stream bool) (func(), error) {
+	noop := func() {}
+	if l == nil || principal == "" {
+		return noop, nil
+	}
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	limit, ok := l.limitsForLocked(principal)
+	if !ok {
+		return noop, nil
+	}
+	state := l.stateLocked(principal, now)
+
+	if limit.MonthlyTokenBudget > 0 && state.monthTokens >= limit.MonthlyTokenBudget {
+		return nil, &LimitError{
+			Reason:     "monthly_token_budget",
+			Message:    fmt.Sprintf("monthly token budget of %d tokens exhausted", limit.MonthlyTokenBudget),
+			RetryAfter: nextMonthUTC(now).Sub(now),
+		}
+	}
+	if limit.DailyTokenBudget > 0 && state.dayTokens >= limit.DailyTokenBudget {
+		return nil, &LimitError{
+			Reason:     "daily_token_budget",
+			Message:    fmt.Sprintf("daily token budget of %d tokens exhausted", limit.DailyTokenBudget),
+			RetryAfter: nextDayUTC(now).Sub(now),
+		}
+	}
+	if limit.RequestsPerMinute > 0 {
+		cutoff := now.Add(-rateWindow)
+		kept := state.requests[:0]
+		for _, ts := range state.requests {
+			if ts.After(cutoff) {
+				kept = append(kept, ts)
+			}
+		}
+		state.requests = kept
+		if len(state.requests) >= limit.RequestsPerMinute {
+			return nil, &LimitError{
+				Reason:     "requests_per_minute",
+				Message:    fmt.Sprintf("rate limit of %d requests per minute exceeded", limit.RequestsPerMinute),
+				RetryAfter: state.requests[0].Add(rateWindow).Sub(now),
+			}
+		}
+	}
+	if stream && limit.MaxConcurrentStreams > 0 && state.streams >= limit.MaxConcurrentStreams {
+		return nil, &LimitError{
+			Reason:     "concurrent_streams",
+			Message:    fmt.Sprintf("concurrent stream limit of %d reached", limit.MaxConcurrentStreams),
+			RetryAfter: time.Second,
+		}
+	}
+
+	if limit.RequestsPerMinute > 0 {
+		state.requests = append(state.requests, now)
+	}
+	if !stream {
+		return noop, nil
+	}
+	state.streams++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			if state.streams > 0 {
+				state.streams--
+			}
+			l.mu.Unlock()
+		})
+	}, nil
+}
+
+// HandleUsage implements usage.Plugin by charging token usage against the record's API key.
+func (l *Limiter) HandleUsage(ctx context.Context, record usage.Record) {
+	_ = ctx
+	if l == nil || record.APIKey == "" {
+		return
+	}
+	tokens := record.Detail.TotalTokens
+	if tokens == 0 {
+		tokens = record.Detail.InputTokens + record.Detail.OutputTokens + record.Detail.ReasoningTokens
+	}
+	if tokens <= 0 {
+		return
+	}
+	at := record.RequestedAt
+	if at.IsZero() {
+		at = l.now()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.limitsForLocked(record.APIKey); !ok {
+		return
+	}
+	state := l.stateLocked(record.APIKey, at)
+	state.dayTokens += tokens
+	state.monthTokens += tokens
+}
+
+func (l *Limiter) limitsForLocked(principal string) (Limits, bool) {
+	if limit, ok := l.limits[principal]; ok {
+		return limit, true
+	}
+	limit, ok := l.limits[WildcardPrincipal]
+	return limit, ok
+}
+
+func (l *Limiter) stateLocked(principal string, now time.Time) *principalState {
+	state, ok := l.states[principal]
+	if !ok {
+		state = &principalState{}
+		l.states[principal] = state
+	}
+	utc := now.UTC()
+	if day := utc.Format("2006-01-02"); state.day != day {
+		state.day = day
+		state.dayTokens = 0
+	}
+	if month := utc.Format("2006-01"); state.month != month {
+		state.month = month
+		state.monthTokens = 0
+	}
+	return state
+}
+
+func nextDayUTC(now time.Time) time.Time {
+	utc := now.UTC()
+	return time.Date(utc.Year(), utc.Month(), utc.Day()+1, 0, 0, 0, 0, time.UTC)
+}
+
+func nextMonthUTC(now time.Time) time.Time {
+	utc := now.UTC()
+	return time.Date(utc.Year(), utc.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+}
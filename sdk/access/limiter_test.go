@@ -0,0 +1,90 @@
+package access
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func newTestLimiter(now *time.Time, limits map[string]Limits) *Limiter {
+	limiter := NewLimiter()
+	limiter.now = func() time.Time { return *now }
+	limiter.SetLimits(limits)
+	return limiter
+}
+
+func TestLimiterAcquire_RequestsPerMinute(t *testing.T) {
+	now := time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC)
+	limiter := newTestLimiter(&now, map[string]Limits{"key": {RequestsPerMinute: 2}})
+
+	for i := 0; i < 2; i++ {
+		if _, err := limiter.Acquire("key", false); err != nil {
+			t.Fatalf("Acquire() #%d error = %v", i, err)
+		}
+	}
+	_, err := limiter.Acquire("key", false)
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) {
+		t.Fatalf("Acquire() error = %v, want *LimitError", err)
+	}
+	if limitErr.Reason != "requests_per_minute" {
+		t.Fatalf("Reason = %q, want %q", limitErr.Reason, "requests_per_minute")
+	}
+	if got := limitErr.Headers().Get("Retry-After"); got != "60" {
+		t.Fatalf("Retry-After = %q, want %q", got, "60")
+	}
+
+	now = now.Add(61 * time.Second)
+	if _, err = limiter.Acquire("key", false); err != nil {
+		t.Fatalf("Acquire() after window error = %v", err)
+	}
+}
+
+func TestLimiterAcquire_ConcurrentStreams(t *testing.T) {
+	now := time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC)
+	limiter := newTestLimiter(&now, map[string]Limits{WildcardPrincipal: {MaxConcurrentStreams: 1}})
+
+	release, err := limiter.Acquire("any", true)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	if _, err = limiter.Acquire("any", false); err != nil {
+		t.Fatalf("Acquire() non-stream error = %v", err)
+	}
+	if _, err = limiter.Acquire("any", true); err == nil {
+		t.Fatalf("Acquire() second stream error = nil, want limit error")
+	}
+	release()
+	release()
+	if _, err = limiter.Acquire("any", true); err != nil {
+		t.Fatalf("Acquire() after release error = %v", err)
+	}
+}
+
+func TestLimiterHandleUsage_DailyBudget(t *testing.T) {
+	now := time.Date(2026, 1, 10, 23, 0, 0, 0, time.UTC)
+	limiter := newTestLimiter(&now, map[string]Limits{"key": {DailyTokenBudget: 100}})
+
+	limiter.HandleUsage(context.Background(), usage.Record{APIKey: "key", RequestedAt: now, Detail: usage.Detail{InputTokens: 60, OutputTokens: 40}})
+	limiter.HandleUsage(context.Background(), usage.Record{APIKey: "other", RequestedAt: now, Detail: usage.Detail{TotalTokens: 1000}})
+
+	_, err := limiter.Acquire("key", false)
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || limitErr.Reason != "daily_token_budget" {
+		t.Fatalf("Acquire() error = %v, want daily_token_budget", err)
+	}
+	if limitErr.RetryAfter != time.Hour {
+		t.Fatalf("RetryAfter = %v, want %v", limitErr.RetryAfter, time.Hour)
+	}
+	if _, err = limiter.Acquire("other", false); err != nil {
+		t.Fatalf("Acquire() unlimited principal error = %v", err)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if _, err = limiter.Acquire("key", false); err != nil {
+		t.Fatalf("Acquire() next day error = %v", err)
+	}
+}
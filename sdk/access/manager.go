@@ -11,6 +11,7 @@ import (
 type Manager struct {
 	mu        sync.RWMutex
 	providers []Provider
+	limiter   *Limiter
 }
 
 // NewManager constructs an empty manager.
@@ -42,6 +43,26 @@ func (m *Manager) Providers() []Provider {
 	return snapshot
 }
 
+// SetLimiter attaches the per-principal limiter consulted after authentication.
+func (m *Manager) SetLimiter(limiter *Limiter) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.limiter = limiter
+	m.mu.Unlock()
+}
+
+// Limiter returns the attached per-principal limiter, if any.
+func (m *Manager) Limiter() *Limiter {
+	if m == nil {
+		return nil
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.limiter
+}
+
 // Authenticate evaluates providers until one succeeds.
 func (m *Manager) Authenticate(ctx context.Context, r *http.Request) (*Result, error) {
 	if m == nil {
@@ -313,6 +313,11 @@ type claudeErrorResponse struct {
 }
 
 func (h *ClaudeCodeAPIHandler) toClaudeError(msg *interfaces.ErrorMessage) claudeErrorResponse {
+	// Errors already shaped as Claude errors (e.g. guardrail violations) are passed through.
+	var passthrough claudeErrorResponse
+	if err := json.Unmarshal([]byte(msg.Error.Error()), &passthrough); err == nil && passthrough.Type == "error" && passthrough.Error.Message != "" {
+		return passthrough
+	}
 	return claudeErrorResponse{
 		Type: "error",
 		Error: claudeErrorDetail{
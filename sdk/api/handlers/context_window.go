@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/contextwindow"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// defaultContextReserveTokens is kept free for the response when the request sets no output limit.
+const defaultContextReserveTokens = 4096
+
+// contextSummaryPrompt instructs the model condensing the middle of an oversize conversation.
+const contextSummaryPrompt = `Summarize the following part of a conversation between a user and an AI assistant so the assistant can continue without it.
+Keep the decisions made, facts and results learned from tool calls, file paths, identifiers and open questions. Leave out pleasantries and repeated content.
+Answer with the summary only.`
+
+// contextSummaryCacheSize bounds the summaries kept for the following turns of conversations.
+const contextSummaryCacheSize = 1024
+
+// contextSummaries lets consecutive turns of a conversation reuse the summary of its middle.
+var contextSummaries = contextwindow.NewSummaryCache(contextSummaryCacheSize)
+
+// outputLimitFields are the request fields holding the requested output tokens in each client format.
+var outputLimitFields = []string{
+	"max_tokens",
+	"max_completion_tokens",
+	"max_output_tokens",
+	"generationConfig.maxOutputTokens",
+	"request.generationConfig.maxOutputTokens",
+}
+
+// contextWindowBypassKey marks requests issued by the context-window stage itself, which must
+// not be fitted again.
+type contextWindowBypassKey struct{}
+
+// fitContextWindow shortens rawJSON when its prompt exceeds the context window of the model,
+// using the configured strategies. The request is returned unchanged when the stage is
+// disabled, the context length is unknown or the prompt already fits.
+func (h *BaseAPIHandler) fitContextWindow(ctx context.Context, handlerType, modelName string, providers []string, rawJSON []byte) []byte {
+	if h.Cfg == nil || !h.Cfg.ContextWindow.Enabled || len(rawJSON) == 0 || ctx.Value(contextWindowBypassKey{}) != nil {
+		return rawJSON
+	}
+	cfg := h.Cfg.ContextWindow
+	budget := contextBudget(modelName, providers, rawJSON, cfg.ReserveTokens)
+	if budget <= 0 {
+		return rawJSON
+	}
+
+	format := sdktranslator.FromString(handlerType)
+	opts := contextwindow.Options{
+		Budget:             budget,
+		Strategies:         cfg.Strategies,
+		MaxToolOutputChars: cfg.MaxToolOutputChars,
+		Count: func(payload []byte) (int64, error) {
+			return executor.CountPromptTokens(ctx, format, modelName, payload)
+		},
+	}
+	if summaryModel := strings.TrimSpace(cfg.SummaryModel); summaryModel != "" {
+		opts.Summarize = func(transcript string) (string, error) {
+			return h.summarizeContext(ctx, summaryModel, transcript)
+		}
+		opts.Summaries = contextSummaries
+	}
+
+	result, err := contextwindow.Fit(handlerType, rawJSON, opts)
+	if err != nil {
+		log.Warnf("context window: %s: %v", modelName, err)
+	}
+	if len(result.Applied) > 0 {
+		log.Infof("context window: %s prompt shortened from %d to %d tokens (budget %d) using %s",
+			modelName, result.Before, result.After, budget, strings.Join(result.Applied, ", "))
+	}
+	if !result.Fits {
+		log.Warnf("context window: %s prompt has %d tokens, above the budget of %d", modelName, result.After, budget)
+	}
+	return result.Payload
+}
+
+// contextBudget returns the prompt tokens a request may use: the smallest context length among
+// the providers serving the model, minus the tokens reserved for the response. It returns 0 when
+// no provider declares a context length.
+func contextBudget(modelName string, providers []string, rawJSON []byte, reserveTokens int) int64 {
+	baseModel := thinking.ParseSuffix(modelName).ModelName
+	reserve := int64(reserveTokens)
+	if reserve <= 0 {
+		reserve = defaultContextReserveTokens
+	}
+	for _, field := range outputLimitFields {
+		if limit := gjson.GetBytes(rawJSON, field).Int(); limit > 0 {
+			reserve = limit
+			break
+		}
+	}
+
+	var budget int64
+	for _, provider := range providers {
+		info := registry.LookupModelInfo(baseModel, provider)
+		if info == nil {
+			continue
+		}
+		var limit int64
+		switch {
+		case info.ContextLength > 0:
+			// The context length covers the prompt and the response.
+			output := reserve
+			if info.MaxCompletionTokens > 0 {
+				output = min(output, int64(info.MaxCompletionTokens))
+			}
+			limit = int64(info.ContextLength) - min(output, int64(info.ContextLength)/2)
+		case info.InputTokenLimit > 0:
+			limit = int64(info.InputTokenLimit)
+		default:
+			continue
+		}
+		if budget == 0 || limit < budget {
+			budget = limit
+		}
+	}
+	return budget
+}
+
+// summarizeContext asks model for a summary of a conversation transcript.
+func (h *BaseAPIHandler) summarizeContext(ctx context.Context, model, transcript string) (string, error) {
+	request := []byte(`{"model":"","messages":[{"role":"system","content":""},{"role":"user","content":""}],"stream":false}`)
+	request, _ = sjson.SetBytes(request, "model", model)
+	request, _ = sjson.SetBytes(request, "messages.0.content", contextSummaryPrompt)
+	request, _ = sjson.SetBytes(request, "messages.1.content", transcript)
+
+	resp, errMsg := h.ExecuteWithAuthManager(context.WithValue(ctx, contextWindowBypassKey{}, true), constant.OpenAI, model, request, "")
+	if errMsg != nil {
+		return "", errMsg.Error
+	}
+	return gjson.GetBytes(resp, "choices.0.message.content").String(), nil
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+func TestContextBudget_UsesSmallestProviderWindow(t *testing.T) {
+	reg := registry.GetGlobalRegistry()
+	reg.RegisterClient("ctx-small", "ctx-small-provider", []*registry.ModelInfo{{ID: "ctx-model", ContextLength: 8192, MaxCompletionTokens: 2048}})
+	reg.RegisterClient("ctx-large", "ctx-large-provider", []*registry.ModelInfo{{ID: "ctx-model", ContextLength: 128000}})
+	t.Cleanup(func() {
+		reg.UnregisterClient("ctx-small")
+		reg.UnregisterClient("ctx-large")
+	})
+	providers := []string{"ctx-small-provider", "ctx-large-provider"}
+
+	if got := contextBudget("ctx-model", providers, []byte(`{"max_tokens":1000}`), 0); got != 8192-1000 {
+		t.Fatalf("budget = %d, want the small window minus max_tokens", got)
+	}
+	if got := contextBudget("ctx-model", providers, []byte(`{}`), 0); got != 8192-2048 {
+		t.Fatalf("budget = %d, want the default reserve capped at the model's completion limit", got)
+	}
+	if got := contextBudget("ctx-model(high)", providers, []byte(`{"max_tokens":64000}`), 0); got != 8192-2048 {
+		t.Fatalf("budget = %d, want the requested output capped at the model's completion limit", got)
+	}
+	if got := contextBudget("ctx-unknown-model", providers, []byte(`{}`), 0); got != 0 {
+		t.Fatalf("budget = %d, want 0 for a model without a declared context length", got)
+	}
+}
+
+func TestFitContextWindow_ShortensOversizeRequests(t *testing.T) {
+	reg := registry.GetGlobalRegistry()
+	reg.RegisterClient("ctx-fit", "ctx-fit-provider", []*registry.ModelInfo{{ID: "ctx-fit-model", ContextLength: 4096, MaxCompletionTokens: 1024}})
+	t.Cleanup(func() { reg.UnregisterClient("ctx-fit") })
+
+	messages := []string{`{"role":"system","content":"You are a coding agent."}`, `{"role":"user","content":"Fix the build."}`}
+	for i := 0; i < 12; i++ {
+		messages = append(messages,
+			fmt.Sprintf(`{"role":"assistant","tool_calls":[{"id":"call_%d","type":"function","function":{"name":"bash","arguments":"{}"}}]}`, i),
+			fmt.Sprintf(`{"role":"tool","tool_call_id":"call_%d","content":"%s"}`, i, strings.Repeat("compile error in module ", 100)))
+	}
+	rawJSON := []byte(`{"model":"ctx-fit-model","messages":[` + strings.Join(messages, ",") + `]}`)
+
+	disabled := NewBaseAPIHandlers(&sdkconfig.SDKConfig{}, nil)
+	if out := disabled.fitContextWindow(context.Background(), constant.OpenAI, "ctx-fit-model", []string{"ctx-fit-provider"}, rawJSON); string(out) != string(rawJSON) {
+		t.Fatal("requests must pass unchanged while the stage is disabled")
+	}
+
+	h := NewBaseAPIHandlers(&sdkconfig.SDKConfig{ContextWindow: sdkconfig.ContextWindowConfig{
+		Enabled:    true,
+		Strategies: []string{"drop-oldest"},
+	}}, nil)
+	out := h.fitContextWindow(context.Background(), constant.OpenAI, "ctx-fit-model", []string{"ctx-fit-provider"}, rawJSON)
+	sent := gjson.GetBytes(out, "messages").Array()
+	if len(sent) >= len(messages) {
+		t.Fatalf("expected turns to be dropped, got %d of %d messages", len(sent), len(messages))
+	}
+	if sent[0].Get("role").String() != "system" || sent[1].Get("content").String() != "Fix the build." {
+		t.Fatalf("system prompt and task must be kept: %s", gjson.GetBytes(out, "messages").Raw)
+	}
+	if last := sent[len(sent)-1]; last.Get("tool_call_id").String() != "call_11" || sent[len(sent)-2].Get("tool_calls.0.id").String() != "call_11" {
+		t.Fatalf("latest tool call and result must be kept: %s", gjson.GetBytes(out, "messages").Raw)
+	}
+}
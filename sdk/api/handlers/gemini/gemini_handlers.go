@@ -17,6 +17,8 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
 )
 
 // GeminiAPIHandler contains the handlers for Gemini API endpoints.
@@ -160,6 +162,10 @@ func (h *GeminiAPIHandler) GeminiHandler(c *gin.Context) {
 		h.handleStreamGenerateContent(c, action[0], rawJSON)
 	case "countTokens":
 		h.handleCountTokens(c, action[0], rawJSON)
+	case "embedContent":
+		h.handleEmbedContent(c, action[0], rawJSON, false)
+	case "batchEmbedContents":
+		h.handleEmbedContent(c, action[0], rawJSON, true)
 	}
 }
 
@@ -272,6 +278,38 @@ func (h *GeminiAPIHandler) handleCountTokens(c *gin.Context, modelName string, r
 	cliCancel()
 }
 
+// handleEmbedContent handles embedContent and batchEmbedContents requests.
+// Single requests are wrapped into the batch shape before execution and unwrapped
+// afterwards, so providers only deal with batchEmbedContents payloads.
+//
+// Parameters:
+//   - c: The Gin context for the request
+//   - modelName: The name of the embedding model
+//   - rawJSON: The raw JSON request body
+//   - batch: Whether the request uses the batchEmbedContents shape
+func (h *GeminiAPIHandler) handleEmbedContent(c *gin.Context, modelName string, rawJSON []byte, batch bool) {
+	c.Header("Content-Type", "application/json")
+	if !batch {
+		rawJSON, _ = sjson.SetRawBytes([]byte(`{"requests":[]}`), "requests.0", rawJSON)
+	}
+	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+	resp, errMsg := h.ExecuteWithAuthManager(cliCtx, h.HandlerType(), modelName, rawJSON, "embeddings")
+	if errMsg != nil {
+		h.WriteErrorResponse(c, errMsg)
+		cliCancel(errMsg.Error)
+		return
+	}
+	if !batch {
+		single := []byte(`{"embedding":{"values":[]}}`)
+		if embedding := gjson.GetBytes(resp, "embeddings.0"); embedding.Exists() {
+			single, _ = sjson.SetRawBytes([]byte(`{}`), "embedding", []byte(embedding.Raw))
+		}
+		resp = single
+	}
+	_, _ = c.Writer.Write(resp)
+	cliCancel()
+}
+
 // handleGenerateContent handles non-streaming content generation requests for Gemini models.
 // This function processes the request synchronously and returns the complete generated
 // response in a single API call. It supports various generation parameters and
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/guardrails"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/sjson"
+)
+
+// guardrailRequest applies the configured request policies to rawJSON before it is translated
+// and sent upstream. It returns the (possibly redacted) payload or a violation error.
+func guardrailRequest(ctx context.Context, handlerType string, rawJSON []byte) ([]byte, *interfaces.ErrorMessage) {
+	engine := guardrails.Active()
+	if engine == nil {
+		return rawJSON, nil
+	}
+	apiKey := clientAPIKey(ctx)
+	out, violation := engine.CheckRequest(apiKey, rawJSON)
+	if violation != nil {
+		return nil, guardrailViolation(handlerType, apiKey, violation)
+	}
+	return out, nil
+}
+
+// guardrailResponse applies the configured response policies to a translated response body.
+func guardrailResponse(ctx context.Context, handlerType string, body []byte) ([]byte, *interfaces.ErrorMessage) {
+	engine := guardrails.Active()
+	if engine == nil {
+		return body, nil
+	}
+	apiKey := clientAPIKey(ctx)
+	out, violation := engine.CheckResponse(apiKey, body)
+	if violation != nil {
+		return nil, guardrailViolation(handlerType, apiKey, violation)
+	}
+	return out, nil
+}
+
+// guardrailStreamScanner returns the response policy scanner for a stream, or nil when none applies.
+func guardrailStreamScanner(ctx context.Context) *guardrails.StreamScanner {
+	return guardrails.Active().NewStreamScanner(clientAPIKey(ctx))
+}
+
+// guardrailViolation logs a policy violation and converts it into an error whose body is
+// shaped like an error of the caller's API format.
+func guardrailViolation(handlerType, apiKey string, violation *guardrails.Violation) *interfaces.ErrorMessage {
+	log.Warnf("guardrails: %s (client key %s)", violation.Error(), util.HideAPIKey(apiKey))
+	message := violation.Error()
+	var body []byte
+	switch handlerType {
+	case constant.Claude:
+		body = []byte(`{"type":"error","error":{"type":"invalid_request_error","message":""}}`)
+		body, _ = sjson.SetBytes(body, "error.message", message)
+	case constant.Gemini, constant.GeminiCLI:
+		body = []byte(`{"error":{"code":400,"message":"","status":"INVALID_ARGUMENT"}}`)
+		body, _ = sjson.SetBytes(body, "error.message", message)
+	default:
+		body = []byte(`{"error":{"message":"","type":"invalid_request_error","code":"guardrail_violation"}}`)
+		body, _ = sjson.SetBytes(body, "error.message", message)
+	}
+	return &interfaces.ErrorMessage{StatusCode: http.StatusBadRequest, Error: errors.New(string(body))}
+}
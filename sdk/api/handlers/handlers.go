@@ -14,14 +14,19 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cache"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/metrics"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tracing"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
 	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/net/context"
 )
 
@@ -242,6 +247,7 @@ func (h *BaseAPIHandler) GetContextWithCancel(handler interfaces.APIHandler, c *
 			parentCtx = logging.WithRequestID(parentCtx, requestID)
 		}
 	}
+	parentCtx = tracing.WithSpanFrom(parentCtx, requestCtx)
 	newCtx, cancel := context.WithCancel(parentCtx)
 	if requestCtx != nil && requestCtx != parentCtx {
 		go func() {
@@ -372,12 +378,26 @@ func appendAPIResponse(c *gin.Context, data []byte) {
 // ExecuteWithAuthManager executes a non-streaming request via the core auth manager.
 // This path is the only supported execution route.
 func (h *BaseAPIHandler) ExecuteWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) ([]byte, *interfaces.ErrorMessage) {
+	ctx, span := startHandlerSpan(ctx, "cliproxy.handler.execute", handlerType, modelName, false)
+	defer span.End()
 	providers, normalizedModel, errMsg := h.getRequestDetails(modelName)
 	if errMsg != nil {
+		tracing.RecordError(span, errMsg.Error)
 		return nil, errMsg
 	}
+	if rawJSON, errMsg = guardrailRequest(ctx, handlerType, rawJSON); errMsg != nil {
+		tracing.RecordError(span, errMsg.Error)
+		return nil, errMsg
+	}
+	responseCache, cacheKey, cached := responseCacheLookup(ctx, handlerType, normalizedModel, rawJSON, alt, false)
+	if cached != nil {
+		serveCacheHit(ctx, span, normalizedModel, providers, cached)
+		return guardrailResponse(ctx, handlerType, cloneBytes(cached.Body))
+	}
+	rawJSON = h.fitContextWindow(ctx, handlerType, normalizedModel, providers, rawJSON)
 	reqMeta := requestExecutionMetadata(ctx)
 	reqMeta[coreexecutor.RequestedModelMetadataKey] = normalizedModel
+	session := withSessionKey(ctx, reqMeta, rawJSON)
 	payload := rawJSON
 	if len(payload) == 0 {
 		payload = nil
@@ -394,6 +414,7 @@ func (h *BaseAPIHandler) ExecuteWithAuthManager(ctx context.Context, handlerType
 	}
 	opts.Metadata = reqMeta
 	resp, err := h.AuthManager.Execute(ctx, providers, req, opts)
+	setServedModelHeader(ctx, reqMeta)
 	if err != nil {
 		status := http.StatusInternalServerError
 		if se, ok := err.(interface{ StatusCode() int }); ok && se != nil {
@@ -407,16 +428,36 @@ func (h *BaseAPIHandler) ExecuteWithAuthManager(ctx context.Context, handlerType
 				addon = hdr.Clone()
 			}
 		}
+		tracing.SetStatusCode(span, status)
+		tracing.RecordError(span, err)
 		return nil, &interfaces.ErrorMessage{StatusCode: status, Error: err, Addon: addon}
 	}
-	return resp.Payload, nil
+	body, errMsg := guardrailResponse(ctx, handlerType, resp.Payload)
+	if errMsg != nil {
+		tracing.RecordError(span, errMsg.Error)
+		return nil, errMsg
+	}
+	rememberResponseSession(handlerType, session, body)
+	if cacheKey != "" {
+		markCacheMiss(ctx, span)
+		served, _ := reqMeta[coreexecutor.ServedModelMetadataKey].(string)
+		responseCache.Put(cacheKey, &cache.ResponseEntry{Model: served, Body: cloneBytes(body)})
+	}
+	return body, nil
 }
 
 // ExecuteCountWithAuthManager executes a non-streaming request via the core auth manager.
 // This path is the only supported execution route.
 func (h *BaseAPIHandler) ExecuteCountWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) ([]byte, *interfaces.ErrorMessage) {
+	ctx, span := startHandlerSpan(ctx, "cliproxy.handler.count_tokens", handlerType, modelName, false)
+	defer span.End()
 	providers, normalizedModel, errMsg := h.getRequestDetails(modelName)
 	if errMsg != nil {
+		tracing.RecordError(span, errMsg.Error)
+		return nil, errMsg
+	}
+	if rawJSON, errMsg = guardrailRequest(ctx, handlerType, rawJSON); errMsg != nil {
+		tracing.RecordError(span, errMsg.Error)
 		return nil, errMsg
 	}
 	reqMeta := requestExecutionMetadata(ctx)
@@ -437,6 +478,7 @@ func (h *BaseAPIHandler) ExecuteCountWithAuthManager(ctx context.Context, handle
 	}
 	opts.Metadata = reqMeta
 	resp, err := h.AuthManager.ExecuteCount(ctx, providers, req, opts)
+	setServedModelHeader(ctx, reqMeta)
 	if err != nil {
 		status := http.StatusInternalServerError
 		if se, ok := err.(interface{ StatusCode() int }); ok && se != nil {
@@ -450,6 +492,8 @@ func (h *BaseAPIHandler) ExecuteCountWithAuthManager(ctx context.Context, handle
 				addon = hdr.Clone()
 			}
 		}
+		tracing.SetStatusCode(span, status)
+		tracing.RecordError(span, err)
 		return nil, &interfaces.ErrorMessage{StatusCode: status, Error: err, Addon: addon}
 	}
 	return resp.Payload, nil
@@ -458,15 +502,32 @@ func (h *BaseAPIHandler) ExecuteCountWithAuthManager(ctx context.Context, handle
 // ExecuteStreamWithAuthManager executes a streaming request via the core auth manager.
 // This path is the only supported execution route.
 func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) (<-chan []byte, <-chan *interfaces.ErrorMessage) {
+	ctx, span := startHandlerSpan(ctx, "cliproxy.handler.stream", handlerType, modelName, true)
 	providers, normalizedModel, errMsg := h.getRequestDetails(modelName)
 	if errMsg != nil {
+		tracing.End(span, errMsg.Error)
 		errChan := make(chan *interfaces.ErrorMessage, 1)
 		errChan <- errMsg
 		close(errChan)
 		return nil, errChan
 	}
+	if rawJSON, errMsg = guardrailRequest(ctx, handlerType, rawJSON); errMsg != nil {
+		tracing.End(span, errMsg.Error)
+		errChan := make(chan *interfaces.ErrorMessage, 1)
+		errChan <- errMsg
+		close(errChan)
+		return nil, errChan
+	}
+	guard := guardrailStreamScanner(ctx)
+	responseCache, cacheKey, cached := responseCacheLookup(ctx, handlerType, normalizedModel, rawJSON, alt, true)
+	if cached != nil {
+		serveCacheHit(ctx, span, normalizedModel, providers, cached)
+		return replayCachedStream(ctx, span, cached, handlerType, guard)
+	}
+	rawJSON = h.fitContextWindow(ctx, handlerType, normalizedModel, providers, rawJSON)
 	reqMeta := requestExecutionMetadata(ctx)
 	reqMeta[coreexecutor.RequestedModelMetadataKey] = normalizedModel
+	session := withSessionKey(ctx, reqMeta, rawJSON)
 	payload := rawJSON
 	if len(payload) == 0 {
 		payload = nil
@@ -482,7 +543,21 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 		SourceFormat:    sdktranslator.FromString(handlerType),
 	}
 	opts.Metadata = reqMeta
-	chunks, err := h.AuthManager.ExecuteStream(ctx, providers, req, opts)
+	var (
+		transcript  *streamTranscript
+		servedAuth  string
+		failedAuths []string
+	)
+	maxFailoverRetries := StreamingFailoverRetries(h.Cfg)
+	resumeProviders := prefillCapableProviders(providers, normalizedModel)
+	execCtx := ctx
+	if maxFailoverRetries > 0 && len(resumeProviders) > 0 {
+		if transcript = newStreamTranscript(handlerType); transcript != nil {
+			execCtx = coreexecutor.WithSelectionObserver(ctx, func(authID, _ string) { servedAuth = authID })
+		}
+	}
+	chunks, err := h.AuthManager.ExecuteStream(execCtx, providers, req, opts)
+	setServedModelHeader(ctx, reqMeta)
 	if err != nil {
 		errChan := make(chan *interfaces.ErrorMessage, 1)
 		status := http.StatusInternalServerError
@@ -497,18 +572,28 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 				addon = hdr.Clone()
 			}
 		}
+		tracing.SetStatusCode(span, status)
+		tracing.End(span, err)
 		errChan <- &interfaces.ErrorMessage{StatusCode: status, Error: err, Addon: addon}
 		close(errChan)
 		return nil, errChan
 	}
+	if cacheKey != "" {
+		markCacheMiss(ctx, span)
+	}
 	dataChan := make(chan []byte)
 	errChan := make(chan *interfaces.ErrorMessage, 1)
+	streamClosed := metrics.StreamOpened(handlerType)
 	go func() {
+		defer streamClosed()
+		defer span.End()
 		defer close(dataChan)
 		defer close(errChan)
 		sentPayload := false
+		var cachedChunks [][]byte
 		bootstrapRetries := 0
 		maxBootstrapRetries := StreamingBootstrapRetries(h.Cfg)
+		failoverRetries := 0
 
 		sendErr := func(msg *interfaces.ErrorMessage) bool {
 			if ctx == nil {
@@ -565,6 +650,10 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 					chunk, ok = <-chunks
 				}
 				if !ok {
+					if cacheKey != "" {
+						served, _ := reqMeta[coreexecutor.ServedModelMetadataKey].(string)
+						responseCache.Put(cacheKey, &cache.ResponseEntry{Model: served, Chunks: cachedChunks})
+					}
 					return
 				}
 				if chunk.Err != nil {
@@ -574,13 +663,36 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 					if !sentPayload {
 						if bootstrapRetries < maxBootstrapRetries && bootstrapEligible(streamErr) {
 							bootstrapRetries++
-							retryChunks, retryErr := h.AuthManager.ExecuteStream(ctx, providers, req, opts)
+							retryChunks, retryErr := h.AuthManager.ExecuteStream(execCtx, providers, req, opts)
 							if retryErr == nil {
 								chunks = retryChunks
 								continue outer
 							}
 							streamErr = retryErr
 						}
+					} else if failoverRetries < maxFailoverRetries && bootstrapEligible(streamErr) {
+						// Mid-stream failover: continue the answer on another credential of a provider
+						// that honours prefills, prefilled with the text the client already received,
+						// and splice it into the same stream.
+						if continuation, ok := transcript.continuationRequest(rawJSON); ok {
+							failoverRetries++
+							if servedAuth != "" {
+								failedAuths = append(failedAuths, servedAuth)
+							}
+							retryReq := req
+							retryReq.Payload = continuation
+							retryOpts := opts
+							retryOpts.OriginalRequest = continuation
+							retryOpts.Metadata = withExcludedAuths(opts.Metadata, failedAuths)
+							retryChunks, retryErr := h.AuthManager.ExecuteStream(execCtx, resumeProviders, retryReq, retryOpts)
+							if retryErr == nil {
+								log.Warnf("stream for model %s failed mid-way (%v); continuing on another credential", normalizedModel, streamErr)
+								transcript.beginContinuation()
+								chunks = retryChunks
+								continue outer
+							}
+							log.Warnf("mid-stream failover for model %s failed: %v", normalizedModel, retryErr)
+						}
 					}
 
 					status := http.StatusInternalServerError
@@ -595,12 +707,30 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 							addon = hdr.Clone()
 						}
 					}
+					tracing.SetStatusCode(span, status)
+					tracing.RecordError(span, streamErr)
 					_ = sendErr(&interfaces.ErrorMessage{StatusCode: status, Error: streamErr, Addon: addon})
 					return
 				}
 				if len(chunk.Payload) > 0 {
+					spliced := transcript.splice(cloneBytes(chunk.Payload))
+					if len(spliced) == 0 {
+						continue
+					}
+					payload, violation := guard.Chunk(spliced)
+					if violation != nil {
+						errMsg := guardrailViolation(handlerType, clientAPIKey(ctx), violation)
+						tracing.RecordError(span, errMsg.Error)
+						_ = sendErr(errMsg)
+						return
+					}
 					sentPayload = true
-					if okSendData := sendData(cloneBytes(chunk.Payload)); !okSendData {
+					transcript.observe(payload)
+					rememberResponseSession(handlerType, session, payload)
+					if cacheKey != "" {
+						cachedChunks = append(cachedChunks, cloneBytes(payload))
+					}
+					if okSendData := sendData(payload); !okSendData {
 						return
 					}
 				}
@@ -610,6 +740,31 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 	return dataChan, errChan
 }
 
+// ServedModelHeader names the response header reporting the model that served the request.
+// It differs from the requested model when a configured model fallback was used.
+const ServedModelHeader = "X-CPA-Served-Model"
+
+// setServedModelHeader copies the served model recorded by the auth manager onto the response.
+func setServedModelHeader(ctx context.Context, meta map[string]any) {
+	model, _ := meta[coreexecutor.ServedModelMetadataKey].(string)
+	if model == "" || ctx == nil {
+		return
+	}
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		ginCtx.Header(ServedModelHeader, model)
+	}
+}
+
+// startHandlerSpan opens the span covering a handler-level execution.
+func startHandlerSpan(ctx context.Context, name, handlerType, modelName string, stream bool) (context.Context, trace.Span) {
+	return tracing.Start(ctx, name,
+		tracing.AttrHandler.String(handlerType),
+		tracing.AttrModel.String(modelName),
+		tracing.AttrStream.Bool(stream),
+		tracing.AttrRequestID.String(logging.GetRequestID(ctx)),
+	)
+}
+
 func statusFromError(err error) int {
 	if err == nil {
 		return 0
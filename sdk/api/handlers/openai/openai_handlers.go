@@ -138,6 +138,11 @@ func (h *OpenAIAPIHandler) ChatCompletions(c *gin.Context) {
 		stream = gjson.GetBytes(rawJSON, "stream").Bool()
 	}
 
+	if format := requestedStructuredFormat(rawJSON); format != nil && shouldEmulateStructuredOutputs(h.BaseAPIHandler, modelName) {
+		h.handleStructuredOutput(c, rawJSON, format, stream)
+		return
+	}
+
 	if stream {
 		h.handleStreamingResponse(c, rawJSON)
 	} else {
@@ -191,6 +196,47 @@ func (h *OpenAIAPIHandler) Completions(c *gin.Context) {
 
 }
 
+// Embeddings handles the /v1/embeddings endpoint.
+// The request is routed through the auth manager like chat requests; providers that
+// serve embedding models (Gemini, Vertex, OpenAI-compatible) translate it as needed, and
+// every other executor rejects it with 501 instead of running a chat completion.
+//
+// Parameters:
+//   - c: The Gin context containing the HTTP request and response
+func (h *OpenAIAPIHandler) Embeddings(c *gin.Context) {
+	rawJSON, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: fmt.Sprintf("Invalid request: %v", err),
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+	modelName := gjson.GetBytes(rawJSON, "model").String()
+	if modelName == "" || !gjson.GetBytes(rawJSON, "input").Exists() {
+		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: "Invalid request: model and input are required",
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+
+	c.Header("Content-Type", "application/json")
+	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+	resp, errMsg := h.ExecuteWithAuthManager(cliCtx, h.HandlerType(), modelName, rawJSON, "embeddings")
+	if errMsg != nil {
+		h.WriteErrorResponse(c, errMsg)
+		cliCancel(errMsg.Error)
+		return
+	}
+	_, _ = c.Writer.Write(resp)
+	cliCancel()
+}
+
 // convertCompletionsRequestToChatCompletions converts OpenAI completions API request to chat completions format.
 // This allows the completions endpoint to use the existing chat completions infrastructure.
 //
@@ -0,0 +1,355 @@
+package openai
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	// defaultImageModel is used when an images request does not name a model.
+	defaultImageModel = "gemini-3-pro-image-preview"
+
+	// maxImagesPerRequest caps the "n" parameter; every image is a separate upstream call.
+	maxImagesPerRequest = 10
+)
+
+// imageGenerationRequest is the provider-neutral form of an OpenAI images request.
+type imageGenerationRequest struct {
+	Model          string
+	Prompt         string
+	N              int
+	Size           string
+	ResponseFormat string
+	Images         []imageInput
+	Mask           *imageInput
+}
+
+// imageInput is an input image for edits, already base64 encoded.
+type imageInput struct {
+	MimeType string
+	Data     string
+}
+
+// ImageGenerations handles the /v1/images/generations endpoint.
+// The request is translated to a Gemini generateContent call with image response
+// modalities and executed through the auth manager, so any provider serving the
+// requested Gemini image model participates in credential rotation.
+//
+// Parameters:
+//   - c: The Gin context containing the HTTP request and response
+func (h *OpenAIAPIHandler) ImageGenerations(c *gin.Context) {
+	rawJSON, err := c.GetRawData()
+	if err != nil {
+		writeImageRequestError(c, fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+	req := imageGenerationRequest{
+		Model:          gjson.GetBytes(rawJSON, "model").String(),
+		Prompt:         gjson.GetBytes(rawJSON, "prompt").String(),
+		N:              int(gjson.GetBytes(rawJSON, "n").Int()),
+		Size:           gjson.GetBytes(rawJSON, "size").String(),
+		ResponseFormat: gjson.GetBytes(rawJSON, "response_format").String(),
+	}
+	h.generateImages(c, req)
+}
+
+// ImageEdits handles the /v1/images/edits endpoint.
+// It accepts the multipart form used by the OpenAI API ("image" or "image[]" files and an
+// optional "mask") as well as a JSON body whose "images" entries carry data URLs.
+//
+// Parameters:
+//   - c: The Gin context containing the HTTP request and response
+func (h *OpenAIAPIHandler) ImageEdits(c *gin.Context) {
+	var req imageGenerationRequest
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		form, err := c.MultipartForm()
+		if err != nil {
+			writeImageRequestError(c, fmt.Sprintf("Invalid request: %v", err))
+			return
+		}
+		req.Model = formValue(form, "model")
+		req.Prompt = formValue(form, "prompt")
+		req.N, _ = strconv.Atoi(formValue(form, "n"))
+		req.Size = formValue(form, "size")
+		req.ResponseFormat = formValue(form, "response_format")
+		for _, field := range []string{"image", "image[]"} {
+			for _, file := range form.File[field] {
+				img, errRead := readImageFile(file)
+				if errRead != nil {
+					writeImageRequestError(c, fmt.Sprintf("Invalid image: %v", errRead))
+					return
+				}
+				req.Images = append(req.Images, img)
+			}
+		}
+		if masks := form.File["mask"]; len(masks) > 0 {
+			mask, errRead := readImageFile(masks[0])
+			if errRead != nil {
+				writeImageRequestError(c, fmt.Sprintf("Invalid mask: %v", errRead))
+				return
+			}
+			req.Mask = &mask
+		}
+	} else {
+		rawJSON, err := c.GetRawData()
+		if err != nil {
+			writeImageRequestError(c, fmt.Sprintf("Invalid request: %v", err))
+			return
+		}
+		req.Model = gjson.GetBytes(rawJSON, "model").String()
+		req.Prompt = gjson.GetBytes(rawJSON, "prompt").String()
+		req.N = int(gjson.GetBytes(rawJSON, "n").Int())
+		req.Size = gjson.GetBytes(rawJSON, "size").String()
+		req.ResponseFormat = gjson.GetBytes(rawJSON, "response_format").String()
+		for _, item := range gjson.GetBytes(rawJSON, "images").Array() {
+			img, ok := parseImageDataURL(item.Get("image_url").String())
+			if !ok {
+				writeImageRequestError(c, "Invalid image: only data URLs are supported")
+				return
+			}
+			req.Images = append(req.Images, img)
+		}
+		if maskURL := gjson.GetBytes(rawJSON, "mask.image_url").String(); maskURL != "" {
+			mask, ok := parseImageDataURL(maskURL)
+			if !ok {
+				writeImageRequestError(c, "Invalid mask: only data URLs are supported")
+				return
+			}
+			req.Mask = &mask
+		}
+	}
+	if len(req.Images) == 0 {
+		writeImageRequestError(c, "Invalid request: at least one image is required")
+		return
+	}
+	h.generateImages(c, req)
+}
+
+// generateImages executes req once per requested image and writes the OpenAI images response.
+// Generated images are returned as b64_json, or as data URLs when response_format is "url".
+func (h *OpenAIAPIHandler) generateImages(c *gin.Context, req imageGenerationRequest) {
+	if strings.TrimSpace(req.Prompt) == "" {
+		writeImageRequestError(c, "Invalid request: prompt is required")
+		return
+	}
+	if req.Model == "" {
+		req.Model = defaultImageModel
+	}
+	if req.N <= 0 {
+		req.N = 1
+	}
+	if req.N > maxImagesPerRequest {
+		writeImageRequestError(c, fmt.Sprintf("Invalid request: n must be at most %d", maxImagesPerRequest))
+		return
+	}
+	responseFormat := strings.ToLower(strings.TrimSpace(req.ResponseFormat))
+	if responseFormat == "" {
+		responseFormat = "b64_json"
+	}
+	if responseFormat != "b64_json" && responseFormat != "url" {
+		writeImageRequestError(c, fmt.Sprintf("Invalid request: unsupported response_format %q", req.ResponseFormat))
+		return
+	}
+	geminiRequest := buildGeminiImageRequest(req)
+
+	c.Header("Content-Type", "application/json")
+	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+	// Every call is expected to produce a new image, so identical requests must not be cached.
+	cliCtx = handlers.WithoutResponseCache(cliCtx)
+	stopKeepAlive := h.StartNonStreamingKeepAlive(c, cliCtx)
+
+	out := []byte(`{"created":0,"data":[]}`)
+	out, _ = sjson.SetBytes(out, "created", time.Now().Unix())
+	for i := 0; i < req.N; i++ {
+		resp, errMsg := h.ExecuteWithAuthManager(cliCtx, constant.Gemini, req.Model, geminiRequest, "")
+		if errMsg != nil {
+			stopKeepAlive()
+			h.WriteErrorResponse(c, errMsg)
+			cliCancel(errMsg.Error)
+			return
+		}
+		images, text := extractGeminiImages(resp)
+		for _, img := range images {
+			item := []byte(`{}`)
+			if responseFormat == "url" {
+				item, _ = sjson.SetBytes(item, "url", "data:"+img.MimeType+";base64,"+img.Data)
+			} else {
+				item, _ = sjson.SetBytes(item, "b64_json", img.Data)
+			}
+			if text != "" {
+				item, _ = sjson.SetBytes(item, "revised_prompt", text)
+			}
+			out, _ = sjson.SetRawBytes(out, "data.-1", item)
+		}
+	}
+	stopKeepAlive()
+	if len(gjson.GetBytes(out, "data").Array()) == 0 {
+		errNoImage := errors.New("the model returned no image")
+		h.WriteErrorResponse(c, &interfaces.ErrorMessage{StatusCode: http.StatusBadGateway, Error: errNoImage})
+		cliCancel(errNoImage)
+		return
+	}
+	_, _ = c.Writer.Write(out)
+	cliCancel()
+}
+
+// buildGeminiImageRequest builds a Gemini generateContent request asking for image output.
+// The OpenAI size is mapped to the closest Gemini aspect ratio; sizes above 1024 pixels
+// also request a larger Gemini image size.
+func buildGeminiImageRequest(req imageGenerationRequest) []byte {
+	out := []byte(`{"contents":[{"role":"user","parts":[]}],"generationConfig":{"responseModalities":["TEXT","IMAGE"]}}`)
+	out, _ = sjson.SetBytes(out, "contents.0.parts.-1.text", req.Prompt)
+	for _, img := range req.Images {
+		out = appendInlineImage(out, img)
+	}
+	if req.Mask != nil {
+		out, _ = sjson.SetBytes(out, "contents.0.parts.-1.text", "The next image is a mask: only edit the areas where the mask is transparent.")
+		out = appendInlineImage(out, *req.Mask)
+	}
+	if width, height, ok := parseImageSize(req.Size); ok {
+		out, _ = sjson.SetBytes(out, "generationConfig.imageConfig.aspectRatio", closestAspectRatio(width, height))
+		longest := max(width, height)
+		switch {
+		case longest > 2048:
+			out, _ = sjson.SetBytes(out, "generationConfig.imageConfig.imageSize", "4K")
+		case longest > 1024:
+			out, _ = sjson.SetBytes(out, "generationConfig.imageConfig.imageSize", "2K")
+		}
+	}
+	return out
+}
+
+func appendInlineImage(out []byte, img imageInput) []byte {
+	part := []byte(`{"inlineData":{"mimeType":"","data":""}}`)
+	part, _ = sjson.SetBytes(part, "inlineData.mimeType", img.MimeType)
+	part, _ = sjson.SetBytes(part, "inlineData.data", img.Data)
+	out, _ = sjson.SetRawBytes(out, "contents.0.parts.-1", part)
+	return out
+}
+
+// extractGeminiImages returns the inline images and the concatenated non-thought text of a
+// Gemini generateContent response.
+func extractGeminiImages(resp []byte) ([]imageInput, string) {
+	var images []imageInput
+	var text strings.Builder
+	for _, candidate := range gjson.GetBytes(resp, "candidates").Array() {
+		for _, part := range candidate.Get("content.parts").Array() {
+			if part.Get("thought").Bool() {
+				continue
+			}
+			inline := part.Get("inlineData")
+			if !inline.Exists() {
+				inline = part.Get("inline_data")
+			}
+			if data := inline.Get("data").String(); data != "" {
+				mimeType := inline.Get("mimeType").String()
+				if mimeType == "" {
+					mimeType = inline.Get("mime_type").String()
+				}
+				if mimeType == "" {
+					mimeType = "image/png"
+				}
+				images = append(images, imageInput{MimeType: mimeType, Data: data})
+				continue
+			}
+			text.WriteString(part.Get("text").String())
+		}
+	}
+	return images, strings.TrimSpace(text.String())
+}
+
+// geminiAspectRatios lists the aspect ratios accepted by Gemini image models.
+var geminiAspectRatios = []string{"1:1", "2:3", "3:2", "3:4", "4:3", "4:5", "5:4", "9:16", "16:9", "21:9"}
+
+func closestAspectRatio(width, height int) string {
+	target := float64(width) / float64(height)
+	best := "1:1"
+	bestDiff := -1.0
+	for _, ratio := range geminiAspectRatios {
+		w, h, _ := strings.Cut(ratio, ":")
+		rw, _ := strconv.Atoi(w)
+		rh, _ := strconv.Atoi(h)
+		diff := target - float64(rw)/float64(rh)
+		if diff < 0 {
+			diff = -diff
+		}
+		if bestDiff < 0 || diff < bestDiff {
+			best, bestDiff = ratio, diff
+		}
+	}
+	return best
+}
+
+// parseImageSize parses an OpenAI "WIDTHxHEIGHT" size; "auto" and empty sizes are ignored.
+func parseImageSize(size string) (int, int, bool) {
+	w, h, ok := strings.Cut(strings.ToLower(strings.TrimSpace(size)), "x")
+	if !ok {
+		return 0, 0, false
+	}
+	width, errW := strconv.Atoi(w)
+	height, errH := strconv.Atoi(h)
+	if errW != nil || errH != nil || width <= 0 || height <= 0 {
+		return 0, 0, false
+	}
+	return width, height, true
+}
+
+func parseImageDataURL(value string) (imageInput, bool) {
+	rest, ok := strings.CutPrefix(value, "data:")
+	if !ok {
+		return imageInput{}, false
+	}
+	meta, data, ok := strings.Cut(rest, ",")
+	if !ok || !strings.HasSuffix(meta, ";base64") {
+		return imageInput{}, false
+	}
+	return imageInput{MimeType: strings.TrimSuffix(meta, ";base64"), Data: data}, true
+}
+
+func readImageFile(file *multipart.FileHeader) (imageInput, error) {
+	f, err := file.Open()
+	if err != nil {
+		return imageInput{}, err
+	}
+	defer func() { _ = f.Close() }()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return imageInput{}, err
+	}
+	mimeType := file.Header.Get("Content-Type")
+	if mimeType == "" || mimeType == "application/octet-stream" {
+		mimeType = http.DetectContentType(data)
+	}
+	return imageInput{MimeType: mimeType, Data: base64.StdEncoding.EncodeToString(data)}, nil
+}
+
+func formValue(form *multipart.Form, key string) string {
+	if values := form.Value[key]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func writeImageRequestError(c *gin.Context, message string) {
+	c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+		Error: handlers.ErrorDetail{
+			Message: message,
+			Type:    "invalid_request_error",
+		},
+	})
+}
@@ -0,0 +1,148 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+type imageCaptureExecutor struct {
+	mu       sync.Mutex
+	payloads [][]byte
+}
+
+func (e *imageCaptureExecutor) Identifier() string { return "gemini" }
+
+func (e *imageCaptureExecutor) Execute(ctx context.Context, auth *coreauth.Auth, req coreexecutor.Request, opts coreexecutor.Options) (coreexecutor.Response, error) {
+	e.mu.Lock()
+	e.payloads = append(e.payloads, bytes.Clone(req.Payload))
+	e.mu.Unlock()
+	return coreexecutor.Response{Payload: []byte(`{"candidates":[{"content":{"parts":[{"text":"done"},{"inlineData":{"mimeType":"image/png","data":"aGVsbG8="}}]}}]}`)}, nil
+}
+
+func (e *imageCaptureExecutor) ExecuteStream(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (e *imageCaptureExecutor) Refresh(ctx context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (e *imageCaptureExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, errors.New("not implemented")
+}
+
+func (e *imageCaptureExecutor) HttpRequest(context.Context, *coreauth.Auth, *http.Request) (*http.Response, error) {
+	return nil, errors.New("not implemented")
+}
+
+func newImageTestRouter(t *testing.T, authID string) (*gin.Engine, *imageCaptureExecutor) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	executor := &imageCaptureExecutor{}
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+
+	auth := &coreauth.Auth{ID: authID, Provider: executor.Identifier(), Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("Register auth: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{{ID: "test-image-model"}})
+	t.Cleanup(func() {
+		registry.GetGlobalRegistry().UnregisterClient(auth.ID)
+	})
+
+	h := NewOpenAIAPIHandler(handlers.NewBaseAPIHandlers(&sdkconfig.SDKConfig{}, manager))
+	router := gin.New()
+	router.POST("/v1/images/generations", h.ImageGenerations)
+	router.POST("/v1/images/edits", h.ImageEdits)
+	return router, executor
+}
+
+func TestOpenAIImageGenerations(t *testing.T) {
+	router, executor := newImageTestRouter(t, "image-auth-1")
+
+	body := `{"model":"test-image-model","prompt":"a red fox","n":2,"size":"1792x1024"}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/images/generations", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", resp.Code, http.StatusOK, resp.Body.String())
+	}
+	data := gjson.Get(resp.Body.String(), "data").Array()
+	if len(data) != 2 {
+		t.Fatalf("data items = %d, want 2: %s", len(data), resp.Body.String())
+	}
+	if got := data[0].Get("b64_json").String(); got != "aGVsbG8=" {
+		t.Fatalf("b64_json = %q, want %q", got, "aGVsbG8=")
+	}
+	if got := data[0].Get("revised_prompt").String(); got != "done" {
+		t.Fatalf("revised_prompt = %q, want %q", got, "done")
+	}
+	if len(executor.payloads) != 2 {
+		t.Fatalf("executor calls = %d, want 2", len(executor.payloads))
+	}
+	payload := executor.payloads[0]
+	if got := gjson.GetBytes(payload, "generationConfig.responseModalities.1").String(); got != "IMAGE" {
+		t.Fatalf("responseModalities = %s", gjson.GetBytes(payload, "generationConfig.responseModalities").Raw)
+	}
+	if got := gjson.GetBytes(payload, "generationConfig.imageConfig.aspectRatio").String(); got != "16:9" {
+		t.Fatalf("aspectRatio = %q, want %q", got, "16:9")
+	}
+	if got := gjson.GetBytes(payload, "contents.0.parts.0.text").String(); got != "a red fox" {
+		t.Fatalf("prompt = %q, want %q", got, "a red fox")
+	}
+}
+
+func TestOpenAIImageEditsMultipart(t *testing.T) {
+	router, executor := newImageTestRouter(t, "image-auth-2")
+
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	_ = writer.WriteField("model", "test-image-model")
+	_ = writer.WriteField("prompt", "add a hat")
+	_ = writer.WriteField("response_format", "url")
+	part, err := writer.CreateFormFile("image", "input.png")
+	if err != nil {
+		t.Fatalf("CreateFormFile: %v", err)
+	}
+	_, _ = part.Write([]byte("\x89PNG\r\n\x1a\nimage"))
+	if err = writer.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/images/edits", &buf)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", resp.Code, http.StatusOK, resp.Body.String())
+	}
+	if got := gjson.Get(resp.Body.String(), "data.0.url").String(); got != "data:image/png;base64,aGVsbG8=" {
+		t.Fatalf("url = %q", got)
+	}
+	if len(executor.payloads) != 1 {
+		t.Fatalf("executor calls = %d, want 1", len(executor.payloads))
+	}
+	inline := gjson.GetBytes(executor.payloads[0], "contents.0.parts.1.inlineData")
+	if inline.Get("mimeType").String() != "image/png" || inline.Get("data").String() == "" {
+		t.Fatalf("inline image = %s", inline.Raw)
+	}
+}
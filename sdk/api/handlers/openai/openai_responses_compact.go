@@ -0,0 +1,201 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	. "github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/contextwindow"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	compactModeEmulate = "emulate"
+	compactModeNative  = "native"
+
+	// maxCompactTranscriptChars bounds the transcript sent to the summarizer; older turns are
+	// dropped first so the request fits the context of the model that has run out of it.
+	maxCompactTranscriptChars = 400_000
+	// maxKeptUserMessageChars bounds the recent user messages carried over verbatim.
+	maxKeptUserMessageChars = 80_000
+)
+
+// defaultCompactPrompt asks the summarizer for a handoff note another model can resume from.
+const defaultCompactPrompt = `You are performing a context checkpoint compaction. Write a handoff summary for another model that will resume this task.
+
+Include:
+- Progress so far and the key decisions made
+- Important context, constraints and user preferences
+- What remains to be done, as clear next steps
+- Any data, file paths, commands or references needed to continue
+
+Be concise and structured, and focus on what the next model needs to continue seamlessly.`
+
+// compactSummaryPrefix introduces the summary in the compacted history.
+const compactSummaryPrefix = "Another language model started to solve this problem and produced a summary of its work so far. Build on that work and avoid repeating it. Here is the summary:"
+
+// nonCompactingProviders have no native compact endpoint; their executors reject the request.
+var nonCompactingProviders = map[string]struct{}{
+	"aistudio":       {},
+	"antigravity":    {},
+	"claude":         {},
+	"gemini":         {},
+	"gemini-cli":     {},
+	"github-copilot": {},
+	"iflow":          {},
+	"kiro":           {},
+	"qwen":           {},
+	"vertex":         {},
+}
+
+// shouldEmulateCompact reports whether compaction for modelName is emulated by the proxy
+// instead of being forwarded to the provider.
+func (h *OpenAIResponsesAPIHandler) shouldEmulateCompact(modelName string) bool {
+	mode := ""
+	if h.Cfg != nil {
+		mode = strings.ToLower(strings.TrimSpace(h.Cfg.ResponsesCompact.Mode))
+	}
+	switch mode {
+	case compactModeEmulate:
+		return true
+	case compactModeNative:
+		return false
+	}
+	// Auto: emulate as soon as one provider serving the model cannot compact natively.
+	for _, provider := range util.GetProviderName(modelName) {
+		if _, ok := nonCompactingProviders[strings.ToLower(provider)]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// emulateCompact summarizes the conversation with the configured (or requested) model and
+// answers in the compact output schema: the recent user messages followed by the summary.
+func (h *OpenAIResponsesAPIHandler) emulateCompact(ctx context.Context, rawJSON []byte) ([]byte, *interfaces.ErrorMessage) {
+	input := gjson.GetBytes(rawJSON, "input")
+	modelName := gjson.GetBytes(rawJSON, "model").String()
+	prompt := defaultCompactPrompt
+	if h.Cfg != nil {
+		if model := strings.TrimSpace(h.Cfg.ResponsesCompact.Model); model != "" {
+			modelName = model
+		}
+		if custom := strings.TrimSpace(h.Cfg.ResponsesCompact.Prompt); custom != "" {
+			prompt = custom
+		}
+	}
+
+	request := []byte(`{"model":"","instructions":"","input":[{"type":"message","role":"user","content":[{"type":"input_text","text":""}]}],"store":false}`)
+	request, _ = sjson.SetBytes(request, "model", modelName)
+	request, _ = sjson.SetBytes(request, "instructions", prompt)
+	request, _ = sjson.SetBytes(request, "input.0.content.0.text", compactTranscript(input)+"\n\nWrite the handoff summary of the conversation above.")
+
+	resp, errMsg := h.ExecuteWithAuthManager(ctx, OpenaiResponse, modelName, request, "")
+	if errMsg != nil {
+		return nil, errMsg
+	}
+	summary := strings.TrimSpace(responseOutputText(resp))
+	if summary == "" {
+		return nil, &interfaces.ErrorMessage{
+			StatusCode: http.StatusBadGateway,
+			Error:      fmt.Errorf("compaction summary from %s is empty", modelName),
+		}
+	}
+
+	output := []byte(`[]`)
+	for _, message := range keptUserMessages(input) {
+		output, _ = sjson.SetRawBytes(output, "-1", []byte(message))
+	}
+	summaryItem, _ := sjson.Set(`{"type":"message","role":"user","content":[{"type":"input_text","text":""}]}`, "content.0.text", compactSummaryPrefix+"\n"+summary)
+	output, _ = sjson.SetRawBytes(output, "-1", []byte(summaryItem))
+
+	out := []byte(`{"id":"","object":"response.compaction","created_at":0,"output":[]}`)
+	out, _ = sjson.SetBytes(out, "id", "resp_"+strings.ReplaceAll(uuid.NewString(), "-", ""))
+	out, _ = sjson.SetBytes(out, "created_at", time.Now().Unix())
+	out, _ = sjson.SetRawBytes(out, "output", output)
+	if usage := gjson.GetBytes(resp, "usage"); usage.IsObject() {
+		out, _ = sjson.SetRawBytes(out, "usage", []byte(usage.Raw))
+	}
+	return out, nil
+}
+
+// compactTranscript renders the Responses input items as plain text, so the summarizer needs
+// neither tool definitions nor provider-specific item types. The oldest turns are dropped
+// when the transcript exceeds maxCompactTranscriptChars.
+func compactTranscript(input gjson.Result) string {
+	if input.Type == gjson.String {
+		return "user: " + input.String()
+	}
+	return contextwindow.JoinTranscript(contextwindow.TranscriptEntries(OpenaiResponse, input.Array()), maxCompactTranscriptChars)
+}
+
+// contentText joins the text parts of a message content field.
+func contentText(content gjson.Result) string {
+	if content.Type == gjson.String {
+		return content.String()
+	}
+	var parts []string
+	for _, part := range content.Array() {
+		switch {
+		case part.Get("text").Exists():
+			parts = append(parts, part.Get("text").String())
+		case strings.Contains(part.Get("type").String(), "image"):
+			parts = append(parts, "[image]")
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// keptUserMessages returns the most recent user messages that fit maxKeptUserMessageChars,
+// oldest first, leaving out summaries of earlier compactions.
+func keptUserMessages(input gjson.Result) []string {
+	if input.Type == gjson.String {
+		item, _ := sjson.Set(`{"type":"message","role":"user","content":[{"type":"input_text","text":""}]}`, "content.0.text", input.String())
+		return []string{item}
+	}
+	items := input.Array()
+	var kept []string
+	size := 0
+	for i := len(items) - 1; i >= 0; i-- {
+		item := items[i]
+		if item.Get("role").String() != "user" || (item.Get("type").Exists() && item.Get("type").String() != "message") {
+			continue
+		}
+		text := contentText(item.Get("content"))
+		if strings.HasPrefix(text, compactSummaryPrefix) {
+			continue
+		}
+		if size+len(text) > maxKeptUserMessageChars {
+			break
+		}
+		size += len(text)
+		kept = append(kept, item.Raw)
+	}
+	for i, j := 0, len(kept)-1; i < j; i, j = i+1, j-1 {
+		kept[i], kept[j] = kept[j], kept[i]
+	}
+	return kept
+}
+
+// responseOutputText returns the assistant text of a Responses API response.
+func responseOutputText(resp []byte) string {
+	var parts []string
+	for _, item := range gjson.GetBytes(resp, "output").Array() {
+		if item.Get("type").String() != "message" {
+			continue
+		}
+		for _, part := range item.Get("content").Array() {
+			if part.Get("type").String() == "output_text" {
+				parts = append(parts, part.Get("text").String())
+			}
+		}
+	}
+	return strings.Join(parts, "")
+}
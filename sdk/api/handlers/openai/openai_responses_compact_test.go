@@ -14,6 +14,7 @@ import (
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
 	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
 )
 
 type compactCaptureExecutor struct {
@@ -118,3 +119,86 @@ func TestOpenAIResponsesCompactExecute(t *testing.T) {
 		t.Fatalf("body = %s", resp.Body.String())
 	}
 }
+
+func TestOpenAIResponsesCompactEmulated(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	executor := &statelessResponsesExecutor{}
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+
+	auth := &coreauth.Auth{ID: "auth-compact-emulated", Provider: executor.Identifier(), Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("Register auth: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{{ID: "summary-model"}})
+	t.Cleanup(func() {
+		registry.GetGlobalRegistry().UnregisterClient(auth.ID)
+	})
+
+	cfg := &sdkconfig.SDKConfig{ResponsesCompact: sdkconfig.ResponsesCompactConfig{Mode: "emulate", Model: "summary-model", Prompt: "Summarize tersely."}}
+	h := NewOpenAIResponsesAPIHandler(handlers.NewBaseAPIHandlers(cfg, manager))
+	router := gin.New()
+	router.POST("/v1/responses/compact", h.Compact)
+
+	body := `{"model":"claude-sonnet-4-5","input":[
+		{"type":"message","role":"user","content":[{"type":"input_text","text":"fix the bug"}]},
+		{"type":"function_call","name":"shell","arguments":"{\"cmd\":\"ls\"}","call_id":"c1"},
+		{"type":"function_call_output","call_id":"c1","output":"main.go"},
+		{"type":"message","role":"assistant","content":[{"type":"output_text","text":"looking at main.go"}]}
+	]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/responses/compact", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", resp.Code, resp.Body.String())
+	}
+	if len(executor.payloads) != 1 {
+		t.Fatalf("executor calls = %d, want 1", len(executor.payloads))
+	}
+	sent := gjson.ParseBytes(executor.payloads[0])
+	transcript := sent.Get("input.0.content.0.text").String()
+	if sent.Get("model").String() != "summary-model" || sent.Get("instructions").String() != "Summarize tersely." ||
+		!strings.Contains(transcript, "user: fix the bug") || !strings.Contains(transcript, "tool result: main.go") {
+		t.Fatalf("unexpected summarization request %s", sent.Raw)
+	}
+
+	out := gjson.ParseBytes(resp.Body.Bytes())
+	output := out.Get("output").Array()
+	if out.Get("object").String() != "response.compaction" || len(output) != 2 {
+		t.Fatalf("unexpected compact response %s", resp.Body.String())
+	}
+	if output[0].Get("content.0.text").String() != "fix the bug" ||
+		!strings.HasSuffix(output[1].Get("content.0.text").String(), "answer 1") || output[1].Get("role").String() != "user" {
+		t.Fatalf("unexpected compacted history %s", out.Get("output").Raw)
+	}
+}
+
+func TestShouldEmulateCompact_OnlyKnownNonCompactingProviders(t *testing.T) {
+	registry.GetGlobalRegistry().RegisterClient("compact-auto-codex", "codex", []*registry.ModelInfo{{ID: "compact-auto-native-model"}, {ID: "compact-auto-shared-model"}})
+	registry.GetGlobalRegistry().RegisterClient("compact-auto-compat", "openrouter", []*registry.ModelInfo{{ID: "compact-auto-native-model"}})
+	registry.GetGlobalRegistry().RegisterClient("compact-auto-claude", "claude", []*registry.ModelInfo{{ID: "compact-auto-shared-model"}})
+	t.Cleanup(func() {
+		registry.GetGlobalRegistry().UnregisterClient("compact-auto-codex")
+		registry.GetGlobalRegistry().UnregisterClient("compact-auto-compat")
+		registry.GetGlobalRegistry().UnregisterClient("compact-auto-claude")
+	})
+	h := NewOpenAIResponsesAPIHandler(handlers.NewBaseAPIHandlers(&sdkconfig.SDKConfig{}, nil))
+
+	if h.shouldEmulateCompact("compact-auto-native-model") {
+		t.Fatal("expected Codex and OpenAI-compatible providers to compact natively")
+	}
+	if !h.shouldEmulateCompact("compact-auto-shared-model") {
+		t.Fatal("expected a model also served by Claude to be emulated")
+	}
+}
+
+func TestCompactTranscript_TruncatesOversizeNewestEntry(t *testing.T) {
+	huge := strings.Repeat("a", maxCompactTranscriptChars) + "THE-END"
+	input := gjson.Parse(`[{"type":"message","role":"user","content":"older"},{"type":"function_call_output","call_id":"c1","output":"` + huge + `"}]`)
+	transcript := compactTranscript(input)
+	if len(transcript) > maxCompactTranscriptChars+200 || !strings.Contains(transcript, "tool result: aaa") || !strings.HasSuffix(transcript, "THE-END") {
+		t.Fatalf("expected the oversize entry to keep its start and end, got %d chars", len(transcript))
+	}
+}
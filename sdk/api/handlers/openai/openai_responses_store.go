@@ -0,0 +1,261 @@
+package openai
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/responsestore"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	defaultInputItemsLimit = 20
+	maxInputItemsLimit     = 100
+)
+
+// storedResponse tracks the Responses API state of one request: whether its
+// previous_response_id was expanded from the local store, and whether its response is saved.
+type storedResponse struct {
+	store    *responsestore.Store
+	apiKey   string
+	previous string
+	input    []json.RawMessage
+	expanded bool
+	save     bool
+	saved    bool
+}
+
+// prepareStoredResponse expands previous_response_id into the full input history when the
+// response is in the local store, so backends without server-side state see the whole
+// conversation. Unknown ids are left for the upstream to resolve. It returns the request to
+// execute and the state used to save its response; both are unchanged when the store is off.
+func prepareStoredResponse(c *gin.Context, rawJSON []byte) ([]byte, *storedResponse) {
+	store := responsestore.Active()
+	if store == nil {
+		return rawJSON, nil
+	}
+	state := &storedResponse{
+		store:    store,
+		apiKey:   c.GetString("apiKey"),
+		previous: gjson.GetBytes(rawJSON, "previous_response_id").String(),
+		input:    responsestore.InputItems(gjson.GetBytes(rawJSON, "input")),
+		save:     gjson.GetBytes(rawJSON, "store").Type != gjson.False,
+	}
+	if state.previous == "" {
+		return rawJSON, state
+	}
+	history, err := store.History(state.previous, state.apiKey)
+	if err != nil {
+		if !errors.Is(err, responsestore.ErrNotFound) {
+			log.Warnf("responses store: %v", err)
+		}
+		return rawJSON, state
+	}
+	input, err := json.Marshal(append(history, state.input...))
+	if err != nil {
+		return rawJSON, state
+	}
+	updated, err := sjson.SetRawBytes(rawJSON, "input", input)
+	if err != nil {
+		return rawJSON, state
+	}
+	if updated, err = sjson.DeleteBytes(updated, "previous_response_id"); err != nil {
+		return rawJSON, state
+	}
+	state.expanded = true
+	c.Set(handlers.PreviousResponseIDKey, state.previous)
+	return updated, state
+}
+
+// finishBody echoes the expanded previous_response_id on a non-streaming response and saves it.
+func (s *storedResponse) finishBody(body []byte) []byte {
+	if s == nil {
+		return body
+	}
+	if s.expanded && gjson.GetBytes(body, "id").Exists() {
+		if updated, err := sjson.SetBytes(body, "previous_response_id", s.previous); err == nil {
+			body = updated
+		}
+	}
+	s.persist(body)
+	return body
+}
+
+// observeChunk echoes the expanded previous_response_id on the response objects carried by a
+// stream chunk and saves the response once response.completed arrives.
+func (s *storedResponse) observeChunk(chunk []byte) []byte {
+	if s == nil || (!s.expanded && !s.save) || !bytes.Contains(chunk, []byte(`"response"`)) {
+		return chunk
+	}
+	lines := bytes.Split(chunk, []byte("\n"))
+	for i, line := range lines {
+		if !bytes.HasPrefix(line, []byte("data:")) {
+			continue
+		}
+		payload := bytes.TrimSpace(line[len("data:"):])
+		if !gjson.GetBytes(payload, "response.id").Exists() {
+			continue
+		}
+		if s.expanded {
+			if updated, err := sjson.SetBytes(payload, "response.previous_response_id", s.previous); err == nil {
+				payload = updated
+				lines[i] = append([]byte("data: "), payload...)
+			}
+		}
+		if gjson.GetBytes(payload, "type").String() == "response.completed" {
+			s.persist([]byte(gjson.GetBytes(payload, "response").Raw))
+		}
+	}
+	return bytes.Join(lines, []byte("\n"))
+}
+
+func (s *storedResponse) persist(response []byte) {
+	if !s.save || s.saved {
+		return
+	}
+	id := gjson.GetBytes(response, "id").String()
+	if id == "" {
+		return
+	}
+	s.saved = true
+	rec := &responsestore.Record{
+		ID:                 id,
+		APIKey:             s.apiKey,
+		PreviousResponseID: s.previous,
+		Input:              s.input,
+		Response:           append(json.RawMessage(nil), response...),
+	}
+	if err := s.store.Put(rec); err != nil {
+		log.Warnf("%v", err)
+	}
+}
+
+// GetResponse handles GET /v1/responses/:id.
+func (h *OpenAIResponsesAPIHandler) GetResponse(c *gin.Context) {
+	rec, ok := lookupStoredResponse(c)
+	if !ok {
+		return
+	}
+	c.Data(http.StatusOK, "application/json", rec.Response)
+}
+
+// DeleteResponse handles DELETE /v1/responses/:id.
+func (h *OpenAIResponsesAPIHandler) DeleteResponse(c *gin.Context) {
+	id := c.Param("id")
+	store := responsestore.Active()
+	if store == nil {
+		writeResponseNotFound(c, id)
+		return
+	}
+	if err := store.Delete(id, c.GetString("apiKey")); err != nil {
+		writeStoredResponseError(c, id, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": id, "object": "response", "deleted": true})
+}
+
+// ListInputItems handles GET /v1/responses/:id/input_items.
+func (h *OpenAIResponsesAPIHandler) ListInputItems(c *gin.Context) {
+	rec, ok := lookupStoredResponse(c)
+	if !ok {
+		return
+	}
+	items, err := responsestore.Active().InputHistory(rec.ID, rec.APIKey)
+	if err != nil {
+		writeStoredResponseError(c, rec.ID, err)
+		return
+	}
+	data := make([]json.RawMessage, 0, len(items))
+	for i, item := range items {
+		if !gjson.GetBytes(item, "id").Exists() {
+			if updated, errSet := sjson.SetBytes(item, "id", inputItemID(rec.ID, i)); errSet == nil {
+				item = updated
+			}
+		}
+		data = append(data, item)
+	}
+	if c.DefaultQuery("order", "desc") != "asc" {
+		for i, j := 0, len(data)-1; i < j; i, j = i+1, j-1 {
+			data[i], data[j] = data[j], data[i]
+		}
+	}
+	if after := c.Query("after"); after != "" {
+		for i, item := range data {
+			if gjson.GetBytes(item, "id").String() == after {
+				data = data[i+1:]
+				break
+			}
+		}
+	}
+	limit, errLimit := strconv.Atoi(c.Query("limit"))
+	if errLimit != nil || limit <= 0 {
+		limit = defaultInputItemsLimit
+	}
+	if limit > maxInputItemsLimit {
+		limit = maxInputItemsLimit
+	}
+	hasMore := len(data) > limit
+	if hasMore {
+		data = data[:limit]
+	}
+	out := gin.H{"object": "list", "data": data, "has_more": hasMore, "first_id": nil, "last_id": nil}
+	if len(data) > 0 {
+		out["first_id"] = gjson.GetBytes(data[0], "id").String()
+		out["last_id"] = gjson.GetBytes(data[len(data)-1], "id").String()
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+func lookupStoredResponse(c *gin.Context) (*responsestore.Record, bool) {
+	id := c.Param("id")
+	store := responsestore.Active()
+	if store == nil {
+		writeResponseNotFound(c, id)
+		return nil, false
+	}
+	rec, err := store.Get(id, c.GetString("apiKey"))
+	if err != nil {
+		writeStoredResponseError(c, id, err)
+		return nil, false
+	}
+	return rec, true
+}
+
+func writeStoredResponseError(c *gin.Context, id string, err error) {
+	if errors.Is(err, responsestore.ErrNotFound) {
+		writeResponseNotFound(c, id)
+		return
+	}
+	log.Errorf("%v", err)
+	c.JSON(http.StatusInternalServerError, handlers.ErrorResponse{
+		Error: handlers.ErrorDetail{
+			Message: "Failed to read stored response",
+			Type:    "server_error",
+		},
+	})
+}
+
+func writeResponseNotFound(c *gin.Context, id string) {
+	c.JSON(http.StatusNotFound, handlers.ErrorResponse{
+		Error: handlers.ErrorDetail{
+			Message: fmt.Sprintf("Response with id '%s' not found.", id),
+			Type:    "invalid_request_error",
+		},
+	})
+}
+
+// inputItemID names an input item that was sent without an id, stable across listings.
+func inputItemID(responseID string, index int) string {
+	sum := sha256.Sum256([]byte(responseID + "\x00" + strconv.Itoa(index)))
+	return "msg_" + hex.EncodeToString(sum[:12])
+}
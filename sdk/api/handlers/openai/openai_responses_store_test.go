@@ -0,0 +1,158 @@
+package openai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/responsestore"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+type statelessResponsesExecutor struct {
+	payloads [][]byte
+}
+
+func (e *statelessResponsesExecutor) Identifier() string { return "stateless-provider" }
+
+func (e *statelessResponsesExecutor) Execute(_ context.Context, _ *coreauth.Auth, req coreexecutor.Request, _ coreexecutor.Options) (coreexecutor.Response, error) {
+	e.payloads = append(e.payloads, req.Payload)
+	n := len(e.payloads)
+	body := fmt.Sprintf(`{"id":"resp_%d","object":"response","status":"completed","output":[{"id":"msg_%d","type":"message","role":"assistant","content":[{"type":"output_text","text":"answer %d"}]}]}`, n, n, n)
+	return coreexecutor.Response{Payload: []byte(body)}, nil
+}
+
+func (e *statelessResponsesExecutor) ExecuteStream(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (e *statelessResponsesExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (e *statelessResponsesExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, errors.New("not implemented")
+}
+
+func (e *statelessResponsesExecutor) HttpRequest(context.Context, *coreauth.Auth, *http.Request) (*http.Response, error) {
+	return nil, errors.New("not implemented")
+}
+
+func TestOpenAIResponsesStore_ExpandsPreviousResponseID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	if err := responsestore.Configure(config.ResponsesStoreConfig{Enabled: true}, filepath.Join(t.TempDir(), "responses.db")); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	t.Cleanup(func() { _ = responsestore.Configure(config.ResponsesStoreConfig{}, "") })
+
+	executor := &statelessResponsesExecutor{}
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+	auth := &coreauth.Auth{ID: "stateless-auth", Provider: executor.Identifier(), Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("Register auth: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{{ID: "stateless-model"}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(auth.ID) })
+
+	h := NewOpenAIResponsesAPIHandler(handlers.NewBaseAPIHandlers(&sdkconfig.SDKConfig{}, manager))
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("apiKey", c.GetHeader("X-Test-Key")) })
+	router.POST("/v1/responses", h.Responses)
+	router.GET("/v1/responses/:id", h.GetResponse)
+	router.GET("/v1/responses/:id/input_items", h.ListInputItems)
+	router.DELETE("/v1/responses/:id", h.DeleteResponse)
+	serve := func(method, path, body, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Test-Key", key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := serve(http.MethodPost, "/v1/responses", `{"model":"stateless-model","input":"first question"}`, "key-1"); w.Code != http.StatusOK {
+		t.Fatalf("first turn: status %d: %s", w.Code, w.Body.String())
+	}
+	w := serve(http.MethodPost, "/v1/responses", `{"model":"stateless-model","previous_response_id":"resp_1","input":"second question"}`, "key-1")
+	if w.Code != http.StatusOK {
+		t.Fatalf("second turn: status %d: %s", w.Code, w.Body.String())
+	}
+	if got := gjson.GetBytes(w.Body.Bytes(), "previous_response_id").String(); got != "resp_1" {
+		t.Fatalf("previous_response_id = %q, want resp_1", got)
+	}
+
+	sent := gjson.ParseBytes(executor.payloads[1])
+	if sent.Get("previous_response_id").Exists() {
+		t.Fatalf("expanded request must not carry previous_response_id: %s", sent.Raw)
+	}
+	input := sent.Get("input").Array()
+	if len(input) != 3 || input[0].Get("content.0.text").String() != "first question" ||
+		input[1].Get("content.0.text").String() != "answer 1" || input[2].Get("content.0.text").String() != "second question" {
+		t.Fatalf("unexpected expanded input %s", sent.Get("input").Raw)
+	}
+
+	if w = serve(http.MethodGet, "/v1/responses/resp_2", "", "key-2"); w.Code != http.StatusNotFound {
+		t.Fatalf("expected another key not to see the response, got %d", w.Code)
+	}
+	if w = serve(http.MethodGet, "/v1/responses/resp_2", "", "key-1"); gjson.GetBytes(w.Body.Bytes(), "output.0.content.0.text").String() != "answer 2" {
+		t.Fatalf("unexpected stored response %s", w.Body.String())
+	}
+	w = serve(http.MethodGet, "/v1/responses/resp_2/input_items?order=asc&limit=2", "", "key-1")
+	items := gjson.ParseBytes(w.Body.Bytes())
+	if items.Get("object").String() != "list" || len(items.Get("data").Array()) != 2 || !items.Get("has_more").Bool() ||
+		items.Get("data.0.content.0.text").String() != "first question" || items.Get("first_id").String() == "" {
+		t.Fatalf("unexpected input items %s", w.Body.String())
+	}
+
+	if w = serve(http.MethodDelete, "/v1/responses/resp_2", "", "key-1"); !gjson.GetBytes(w.Body.Bytes(), "deleted").Bool() {
+		t.Fatalf("unexpected delete response %d: %s", w.Code, w.Body.String())
+	}
+	if w = serve(http.MethodGet, "/v1/responses/resp_2", "", "key-1"); w.Code != http.StatusNotFound {
+		t.Fatalf("expected deleted response to be gone, got %d", w.Code)
+	}
+
+	if w = serve(http.MethodPost, "/v1/responses", `{"model":"stateless-model","input":"private","store":false}`, "key-1"); w.Code != http.StatusOK {
+		t.Fatalf("unstored turn: status %d: %s", w.Code, w.Body.String())
+	}
+	if w = serve(http.MethodGet, "/v1/responses/resp_3", "", "key-1"); w.Code != http.StatusNotFound {
+		t.Fatalf("expected store:false response not to be stored, got %d", w.Code)
+	}
+}
+
+func TestStoredResponse_ObserveChunkSavesCompletedStream(t *testing.T) {
+	store, err := responsestore.Open(filepath.Join(t.TempDir(), "responses.db"), 0)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer func() { _ = store.Close() }()
+
+	state := &storedResponse{store: store, previous: "resp_0", expanded: true, save: true}
+	created := state.observeChunk([]byte("event: response.created\ndata: {\"type\":\"response.created\",\"response\":{\"id\":\"resp_9\",\"status\":\"in_progress\"}}"))
+	if !strings.Contains(string(created), `"previous_response_id":"resp_0"`) || !strings.HasPrefix(string(created), "event: response.created\n") {
+		t.Fatalf("unexpected created event %q", created)
+	}
+	if _, err = store.Get("resp_9", ""); !errors.Is(err, responsestore.ErrNotFound) {
+		t.Fatalf("response must not be stored before it completes, got %v", err)
+	}
+	state.observeChunk([]byte("event: response.completed\ndata: {\"type\":\"response.completed\",\"response\":{\"id\":\"resp_9\",\"status\":\"completed\",\"output\":[]}}"))
+	rec, err := store.Get("resp_9", "")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if rec.PreviousResponseID != "resp_0" || gjson.GetBytes(rec.Response, "previous_response_id").String() != "resp_0" {
+		t.Fatalf("unexpected stored record %+v", rec)
+	}
+}
@@ -0,0 +1,377 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/structuredoutput"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	responsesconverter "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/openai/openai/responses"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	structuredModeEmulate = "emulate"
+	structuredModeNative  = "native"
+
+	// structuredStrategyTool passes the schema as the parameters of a forced function call.
+	structuredStrategyTool = "tool"
+
+	// defaultStructuredAttempts bounds the requests made until the answer matches the schema.
+	defaultStructuredAttempts = 3
+
+	// defaultStructuredToolName names the forced function when the schema has no usable name.
+	defaultStructuredToolName = "structured_output"
+)
+
+// nonStructuredOutputProviders drop or ignore response_format on the way upstream.
+var nonStructuredOutputProviders = map[string]struct{}{
+	"aistudio":       {},
+	"antigravity":    {},
+	"claude":         {},
+	"gemini":         {},
+	"gemini-cli":     {},
+	"github-copilot": {},
+	"iflow":          {},
+	"kiro":           {},
+	"qwen":           {},
+	"vertex":         {},
+}
+
+var invalidToolNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// structuredFormat is the structured output requested through response_format.
+type structuredFormat struct {
+	name string
+	// schema is the JSON Schema of a json_schema format, or nil for json_object.
+	schema []byte
+}
+
+// requestedStructuredFormat reads response_format from a chat completions request. It returns
+// nil when the request does not ask for JSON output.
+func requestedStructuredFormat(chatJSON []byte) *structuredFormat {
+	format := gjson.GetBytes(chatJSON, "response_format")
+	switch format.Get("type").String() {
+	case "json_schema":
+		f := &structuredFormat{name: format.Get("json_schema.name").String()}
+		if schema := format.Get("json_schema.schema"); schema.IsObject() {
+			f.schema = []byte(schema.Raw)
+		}
+		return f
+	case "json_object":
+		return &structuredFormat{}
+	}
+	return nil
+}
+
+// responsesResponseFormat converts text.format of a Responses request into a chat completions
+// response_format. It returns an empty string when the request does not ask for JSON output.
+func responsesResponseFormat(responsesJSON []byte) string {
+	format := gjson.GetBytes(responsesJSON, "text.format")
+	switch format.Get("type").String() {
+	case "json_schema":
+		out := `{"type":"json_schema","json_schema":{"name":""}}`
+		out, _ = sjson.Set(out, "json_schema.name", format.Get("name").String())
+		if schema := format.Get("schema"); schema.Exists() {
+			out, _ = sjson.SetRaw(out, "json_schema.schema", schema.Raw)
+		}
+		if strict := format.Get("strict"); strict.Exists() {
+			out, _ = sjson.Set(out, "json_schema.strict", strict.Bool())
+		}
+		return out
+	case "json_object":
+		return `{"type":"json_object"}`
+	}
+	return ""
+}
+
+// shouldEmulateStructuredOutputs reports whether structured outputs for modelName are emulated
+// by the proxy instead of being forwarded to the provider.
+func shouldEmulateStructuredOutputs(h *handlers.BaseAPIHandler, modelName string) bool {
+	mode := ""
+	if h.Cfg != nil {
+		mode = strings.ToLower(strings.TrimSpace(h.Cfg.StructuredOutputs.Mode))
+	}
+	switch mode {
+	case structuredModeEmulate:
+		return true
+	case structuredModeNative:
+		return false
+	}
+	// Auto: emulate as soon as one provider serving the model would ignore the format, unless
+	// the model declares response_format support.
+	baseModel := thinking.ParseSuffix(modelName).ModelName
+	for _, provider := range util.GetProviderName(baseModel) {
+		if info := registry.LookupModelInfo(baseModel, provider); info != nil && slices.Contains(info.SupportedParameters, "response_format") {
+			continue
+		}
+		if _, ok := nonStructuredOutputProviders[strings.ToLower(provider)]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// emulateStructuredOutput runs a chat completions request asking for structured output against
+// a model that does not enforce response_format. The format is passed as instructions or as a
+// forced function call, the answer is validated, and the conversation is continued with the
+// violations until the answer conforms or the attempts are used up. It returns a non-streaming
+// chat completion whose message content is the validated JSON.
+func emulateStructuredOutput(ctx context.Context, h *handlers.BaseAPIHandler, chatJSON []byte, format *structuredFormat, alt string) ([]byte, *interfaces.ErrorMessage) {
+	modelName := gjson.GetBytes(chatJSON, "model").String()
+	attempts := defaultStructuredAttempts
+	useTool := false
+	if h.Cfg != nil {
+		if h.Cfg.StructuredOutputs.MaxAttempts > 0 {
+			attempts = h.Cfg.StructuredOutputs.MaxAttempts
+		}
+		useTool = strings.EqualFold(strings.TrimSpace(h.Cfg.StructuredOutputs.Strategy), structuredStrategyTool) &&
+			format.schema != nil && !gjson.GetBytes(chatJSON, "tools").Exists()
+	}
+
+	request := chatJSON
+	for _, field := range []string{"response_format", "stream", "stream_options"} {
+		request, _ = sjson.DeleteBytes(request, field)
+	}
+	if useTool {
+		toolName := structuredToolName(format.name)
+		tools := []byte(`[{"type":"function","function":{"name":"","description":"Return the final answer as structured data.","parameters":{}}}]`)
+		tools, _ = sjson.SetBytes(tools, "0.function.name", toolName)
+		tools, _ = sjson.SetRawBytes(tools, "0.function.parameters", format.schema)
+		request, _ = sjson.SetRawBytes(request, "tools", tools)
+		request, _ = sjson.SetRawBytes(request, "tool_choice", []byte(`{"type":"function","function":{"name":"`+toolName+`"}}`))
+	} else {
+		system, _ := sjson.Set(`{"role":"system","content":""}`, "content", structuredoutput.Instructions(format.name, format.schema))
+		messages := []string{system}
+		for _, message := range gjson.GetBytes(request, "messages").Array() {
+			messages = append(messages, message.Raw)
+		}
+		request, _ = sjson.SetRawBytes(request, "messages", []byte("["+strings.Join(messages, ",")+"]"))
+	}
+
+	var usage chatUsage
+	var violations []string
+	for attempt := 1; attempt <= attempts; attempt++ {
+		resp, errMsg := h.ExecuteWithAuthManager(ctx, OpenAI, modelName, request, alt)
+		if errMsg != nil {
+			return nil, errMsg
+		}
+		usage.add(resp)
+
+		message := gjson.GetBytes(resp, "choices.0.message")
+		answer := message.Get("content").String()
+		if useTool {
+			answer = message.Get("tool_calls.0.function.arguments").String()
+		} else if len(message.Get("tool_calls").Array()) > 0 {
+			// The model called one of the client's tools; the structured answer follows in a later turn.
+			return usage.apply(resp), nil
+		}
+
+		document, ok := structuredoutput.Extract(answer)
+		switch {
+		case !ok:
+			violations = []string{"the answer is not valid JSON"}
+		case format.schema == nil:
+			violations = nil
+			if !gjson.Parse(document).IsObject() {
+				violations = []string{"the answer must be a JSON object"}
+			}
+		default:
+			violations = structuredoutput.Validate(format.schema, []byte(document))
+		}
+		if len(violations) == 0 {
+			return usage.apply(structuredCompletion(resp, document)), nil
+		}
+		log.Debugf("structured outputs: attempt %d of %d for %s does not match the schema: %s", attempt, attempts, modelName, strings.Join(violations, "; "))
+
+		feedback := "Your previous answer is invalid:\n- " + strings.Join(violations, "\n- ") + "\nAnswer again with only the corrected JSON."
+		assistant, _ := sjson.Set(`{"role":"assistant","content":""}`, "content", answer)
+		user, _ := sjson.Set(`{"role":"user","content":""}`, "content", feedback)
+		request, _ = sjson.SetRawBytes(request, "messages.-1", []byte(assistant))
+		request, _ = sjson.SetRawBytes(request, "messages.-1", []byte(user))
+	}
+	return nil, &interfaces.ErrorMessage{
+		StatusCode: http.StatusBadGateway,
+		Error:      fmt.Errorf("structured output from %s does not match the requested format after %d attempts: %s", modelName, attempts, strings.Join(violations, "; ")),
+	}
+}
+
+// structuredToolName derives a valid function name from the schema name.
+func structuredToolName(name string) string {
+	name = invalidToolNameChars.ReplaceAllString(name, "_")
+	if name == "" || name == "_" {
+		return defaultStructuredToolName
+	}
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+// structuredCompletion rewrites a chat completion so its message carries document as content,
+// replacing the forced function call when one was used.
+func structuredCompletion(resp []byte, document string) []byte {
+	out, _ := sjson.SetBytes(resp, "choices.0.message.content", document)
+	out, _ = sjson.DeleteBytes(out, "choices.0.message.tool_calls")
+	out, _ = sjson.SetBytes(out, "choices.0.finish_reason", "stop")
+	return out
+}
+
+// chatUsage sums the token usage of the attempts made for one emulated request.
+type chatUsage struct {
+	prompt, completion, total int64
+	seen                      bool
+}
+
+func (u *chatUsage) add(resp []byte) {
+	usage := gjson.GetBytes(resp, "usage")
+	if !usage.IsObject() {
+		return
+	}
+	u.seen = true
+	u.prompt += usage.Get("prompt_tokens").Int()
+	u.completion += usage.Get("completion_tokens").Int()
+	u.total += usage.Get("total_tokens").Int()
+}
+
+// apply writes the summed usage into the final chat completion.
+func (u *chatUsage) apply(resp []byte) []byte {
+	if !u.seen {
+		return resp
+	}
+	resp, _ = sjson.SetBytes(resp, "usage.prompt_tokens", u.prompt)
+	resp, _ = sjson.SetBytes(resp, "usage.completion_tokens", u.completion)
+	resp, _ = sjson.SetBytes(resp, "usage.total_tokens", u.total)
+	return resp
+}
+
+// chatCompletionChunks renders a non-streaming chat completion as stream chunks: one carrying
+// the whole message and one carrying the finish reason and, when requested, the usage.
+func chatCompletionChunks(resp []byte, includeUsage bool) [][]byte {
+	root := gjson.ParseBytes(resp)
+	base := []byte(`{"id":"","object":"chat.completion.chunk","created":0,"model":"","choices":[{"index":0,"delta":{},"finish_reason":null}]}`)
+	base, _ = sjson.SetBytes(base, "id", root.Get("id").String())
+	base, _ = sjson.SetBytes(base, "created", root.Get("created").Int())
+	base, _ = sjson.SetBytes(base, "model", root.Get("model").String())
+
+	message := root.Get("choices.0.message")
+	content, _ := sjson.SetBytes(base, "choices.0.delta.role", "assistant")
+	if text := message.Get("content"); text.Exists() && text.Type != gjson.Null {
+		content, _ = sjson.SetBytes(content, "choices.0.delta.content", text.String())
+	}
+	for i, call := range message.Get("tool_calls").Array() {
+		delta, _ := sjson.Set(call.Raw, "index", i)
+		content, _ = sjson.SetRawBytes(content, "choices.0.delta.tool_calls.-1", []byte(delta))
+	}
+
+	finishReason := root.Get("choices.0.finish_reason").String()
+	if finishReason == "" {
+		finishReason = "stop"
+	}
+	finish, _ := sjson.SetBytes(base, "choices.0.finish_reason", finishReason)
+	if usage := root.Get("usage"); includeUsage && usage.IsObject() {
+		finish, _ = sjson.SetRawBytes(finish, "usage", []byte(usage.Raw))
+	}
+	return [][]byte{content, finish}
+}
+
+func setStructuredSSEHeaders(c *gin.Context) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("Access-Control-Allow-Origin", "*")
+}
+
+// handleStructuredOutput answers a chat completions request whose structured output is
+// emulated. Streaming clients receive the validated answer as a short stream.
+func (h *OpenAIAPIHandler) handleStructuredOutput(c *gin.Context, rawJSON []byte, format *structuredFormat, stream bool) {
+	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+	stopKeepAlive := func() {}
+	if !stream {
+		c.Header("Content-Type", "application/json")
+		stopKeepAlive = h.StartNonStreamingKeepAlive(c, cliCtx)
+	}
+	resp, errMsg := emulateStructuredOutput(cliCtx, h.BaseAPIHandler, rawJSON, format, h.GetAlt(c))
+	stopKeepAlive()
+	if errMsg != nil {
+		h.WriteErrorResponse(c, errMsg)
+		cliCancel(errMsg.Error)
+		return
+	}
+	if !stream {
+		_, _ = c.Writer.Write(resp)
+		cliCancel()
+		return
+	}
+
+	setStructuredSSEHeaders(c)
+	for _, chunk := range chatCompletionChunks(resp, gjson.GetBytes(rawJSON, "stream_options.include_usage").Bool()) {
+		_, _ = fmt.Fprintf(c.Writer, "data: %s\n\n", string(chunk))
+	}
+	_, _ = fmt.Fprintf(c.Writer, "data: [DONE]\n\n")
+	if flusher, ok := c.Writer.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	cliCancel()
+}
+
+// handleStructuredOutput answers a Responses request whose structured output is emulated. The
+// request is run as chat completions and the validated answer is converted back, as a response
+// object or as a stream of response events.
+func (h *OpenAIResponsesAPIHandler) handleStructuredOutput(c *gin.Context, rawJSON []byte, responseFormat string, stream bool, stored *storedResponse) {
+	modelName := gjson.GetBytes(rawJSON, "model").String()
+	chatJSON := responsesconverter.ConvertOpenAIResponsesRequestToOpenAIChatCompletions(modelName, rawJSON, false)
+	chatJSON, _ = sjson.SetRawBytes(chatJSON, "response_format", []byte(responseFormat))
+
+	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+	stopKeepAlive := func() {}
+	if !stream {
+		c.Header("Content-Type", "application/json")
+		stopKeepAlive = h.StartNonStreamingKeepAlive(c, cliCtx)
+	}
+	resp, errMsg := emulateStructuredOutput(cliCtx, h.BaseAPIHandler, chatJSON, requestedStructuredFormat(chatJSON), "")
+	stopKeepAlive()
+	if errMsg != nil {
+		h.WriteErrorResponse(c, errMsg)
+		cliCancel(errMsg.Error)
+		return
+	}
+
+	var param any
+	if !stream {
+		converted := responsesconverter.ConvertOpenAIChatCompletionsResponseToOpenAIResponsesNonStream(cliCtx, modelName, rawJSON, rawJSON, resp, &param)
+		if converted == "" {
+			h.WriteErrorResponse(c, &interfaces.ErrorMessage{
+				StatusCode: http.StatusInternalServerError,
+				Error:      fmt.Errorf("failed to convert chat completion response to responses format"),
+			})
+			cliCancel(fmt.Errorf("response conversion failed"))
+			return
+		}
+		_, _ = c.Writer.Write(stored.finishBody([]byte(converted)))
+		cliCancel()
+		return
+	}
+
+	setStructuredSSEHeaders(c)
+	for _, chunk := range chatCompletionChunks(resp, true) {
+		writeChatAsResponsesChunk(c, cliCtx, modelName, rawJSON, chunk, &param, stored)
+	}
+	_, _ = c.Writer.Write([]byte("\n"))
+	if flusher, ok := c.Writer.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	cliCancel()
+}
@@ -0,0 +1,139 @@
+package openai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// scriptedChatExecutor answers chat completions with the next scripted message content.
+type scriptedChatExecutor struct {
+	answers  []string
+	payloads [][]byte
+}
+
+func (e *scriptedChatExecutor) Identifier() string { return "scripted-provider" }
+
+func (e *scriptedChatExecutor) Execute(_ context.Context, _ *coreauth.Auth, req coreexecutor.Request, _ coreexecutor.Options) (coreexecutor.Response, error) {
+	e.payloads = append(e.payloads, req.Payload)
+	answer := e.answers[min(len(e.payloads), len(e.answers))-1]
+	body := fmt.Sprintf(`{"id":"chatcmpl-%d","object":"chat.completion","created":1,"model":"scripted-model","choices":[{"index":0,"message":{"role":"assistant","content":""},"finish_reason":"stop"}],"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15}}`, len(e.payloads))
+	body, _ = sjson.Set(body, "choices.0.message.content", answer)
+	return coreexecutor.Response{Payload: []byte(body)}, nil
+}
+
+func (e *scriptedChatExecutor) ExecuteStream(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (e *scriptedChatExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (e *scriptedChatExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, errors.New("not implemented")
+}
+
+func (e *scriptedChatExecutor) HttpRequest(context.Context, *coreauth.Auth, *http.Request) (*http.Response, error) {
+	return nil, errors.New("not implemented")
+}
+
+func newStructuredOutputsRouter(t *testing.T, executor *scriptedChatExecutor) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+	auth := &coreauth.Auth{ID: "scripted-auth", Provider: executor.Identifier(), Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("Register auth: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{{ID: "scripted-model"}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(auth.ID) })
+
+	base := handlers.NewBaseAPIHandlers(&sdkconfig.SDKConfig{StructuredOutputs: sdkconfig.StructuredOutputsConfig{Mode: "emulate"}}, manager)
+	router := gin.New()
+	router.POST("/v1/chat/completions", NewOpenAIAPIHandler(base).ChatCompletions)
+	router.POST("/v1/responses", NewOpenAIResponsesAPIHandler(base).Responses)
+	return router
+}
+
+const personSchema = `{"type":"object","properties":{"name":{"type":"string"},"age":{"type":"integer"}},"required":["name","age"],"additionalProperties":false}`
+
+func TestChatCompletionsStructuredOutputRetriesUntilValid(t *testing.T) {
+	executor := &scriptedChatExecutor{answers: []string{"```json\n{\"name\":\"Ada\"}\n```", `Here you go: {"name":"Ada","age":36}`}}
+	router := newStructuredOutputsRouter(t, executor)
+
+	body := `{"model":"scripted-model","messages":[{"role":"user","content":"Who wrote the first program?"}],
+		"response_format":{"type":"json_schema","json_schema":{"name":"person","strict":true,"schema":` + personSchema + `}}}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+
+	resp := gjson.ParseBytes(w.Body.Bytes())
+	if content := resp.Get("choices.0.message.content").String(); content != `{"name":"Ada","age":36}` {
+		t.Fatalf("content = %q, want the extracted JSON", content)
+	}
+	if total := resp.Get("usage.total_tokens").Int(); total != 30 {
+		t.Fatalf("usage.total_tokens = %d, want the sum of both attempts", total)
+	}
+	if len(executor.payloads) != 2 {
+		t.Fatalf("expected one retry, got %d requests", len(executor.payloads))
+	}
+	first := gjson.ParseBytes(executor.payloads[0])
+	if first.Get("response_format").Exists() || first.Get("messages.0.role").String() != "system" ||
+		!strings.Contains(first.Get("messages.0.content").String(), `"required":["name","age"]`) {
+		t.Fatalf("first request must carry the schema as instructions: %s", first.Raw)
+	}
+	retry := gjson.ParseBytes(executor.payloads[1]).Get("messages").Array()
+	if feedback := retry[len(retry)-1].Get("content").String(); !strings.Contains(feedback, `missing required property "age"`) {
+		t.Fatalf("retry must explain the violation, got %q", feedback)
+	}
+}
+
+func TestChatCompletionsStructuredOutputGivesUp(t *testing.T) {
+	executor := &scriptedChatExecutor{answers: []string{"I'd rather not."}}
+	router := newStructuredOutputsRouter(t, executor)
+
+	body := `{"model":"scripted-model","messages":[{"role":"user","content":"hi"}],"response_format":{"type":"json_object"}}`
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)))
+	if w.Code != http.StatusBadGateway || len(executor.payloads) != defaultStructuredAttempts {
+		t.Fatalf("expected 502 after %d attempts, got %d after %d: %s", defaultStructuredAttempts, w.Code, len(executor.payloads), w.Body.String())
+	}
+}
+
+func TestResponsesStructuredOutputStream(t *testing.T) {
+	executor := &scriptedChatExecutor{answers: []string{`{"name":"Grace","age":85}`}}
+	router := newStructuredOutputsRouter(t, executor)
+
+	body := `{"model":"scripted-model","stream":true,"input":"Who found the first bug?",
+		"text":{"format":{"type":"json_schema","name":"person","strict":true,"schema":` + personSchema + `}}}`
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/responses", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+	out := w.Body.String()
+	if !strings.Contains(out, "event: response.completed") || !strings.Contains(out, `"text":"{\"name\":\"Grace\",\"age\":85}"`) {
+		t.Fatalf("expected a completed response stream carrying the JSON, got:\n%s", out)
+	}
+	if sent := gjson.ParseBytes(executor.payloads[0]); sent.Get("stream").Bool() || sent.Get("response_format").Exists() {
+		t.Fatalf("emulated request must be non-streaming without response_format: %s", sent.Raw)
+	}
+}
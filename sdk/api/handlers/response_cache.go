@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cache"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/guardrails"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tracing"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// CacheStatusHeader reports whether a response was served from the response cache ("hit")
+// or stored into it after an upstream call ("miss").
+const CacheStatusHeader = "X-CPA-Cache"
+
+// CacheUsageSource marks usage records produced by response cache hits.
+const CacheUsageSource = "cache"
+
+type responseCacheBypassKey struct{}
+
+// WithoutResponseCache marks ctx so the response cache is neither consulted nor filled.
+// Handlers use it for requests that are expected to produce a different result each time.
+func WithoutResponseCache(ctx context.Context) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, responseCacheBypassKey{}, true)
+}
+
+// responseCacheLookup resolves the response cache key for a request and returns the cached
+// entry when one is available. A nil cache or empty key means the request is not cached.
+// Clients can send "Cache-Control: no-cache" to skip the lookup (the fresh response is
+// still stored) or "Cache-Control: no-store" to bypass the cache entirely.
+func responseCacheLookup(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string, stream bool) (*cache.ResponseCache, string, *cache.ResponseEntry) {
+	rc := cache.ActiveResponseCache()
+	if rc == nil || ctx == nil {
+		return nil, "", nil
+	}
+	if bypass, _ := ctx.Value(responseCacheBypassKey{}).(bool); bypass {
+		return nil, "", nil
+	}
+	directives := ""
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil && ginCtx.Request != nil {
+		directives = strings.ToLower(ginCtx.GetHeader("Cache-Control"))
+	}
+	if strings.Contains(directives, "no-store") {
+		return nil, "", nil
+	}
+	key, ok := rc.Key(cache.ResponseKey{
+		Format:  handlerType,
+		Model:   modelName,
+		APIKey:  clientAPIKey(ctx),
+		Alt:     alt,
+		Stream:  stream,
+		Payload: rawJSON,
+	})
+	if !ok {
+		return nil, "", nil
+	}
+	if strings.Contains(directives, "no-cache") {
+		return rc, key, nil
+	}
+	entry, _ := rc.Get(key)
+	return rc, key, entry
+}
+
+// serveCacheHit records a cache hit on the response headers, the span and the usage statistics.
+// Hits are published as zero-token records so request counts stay accurate without cost.
+func serveCacheHit(ctx context.Context, span trace.Span, modelName string, providers []string, entry *cache.ResponseEntry) {
+	span.SetAttributes(tracing.AttrCache.String("hit"))
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		ginCtx.Header(CacheStatusHeader, "hit")
+		if entry.Model != "" {
+			ginCtx.Header(ServedModelHeader, entry.Model)
+		}
+	}
+	record := usage.Record{
+		Model:       modelName,
+		APIKey:      clientAPIKey(ctx),
+		Source:      CacheUsageSource,
+		RequestedAt: time.Now(),
+	}
+	if len(providers) > 0 {
+		record.Provider = providers[0]
+	}
+	usage.PublishRecord(ctx, record)
+}
+
+// clientAPIKey returns the authenticated client key stored on the gin context.
+func clientAPIKey(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		if v, exists := ginCtx.Get("apiKey"); exists {
+			if key, okKey := v.(string); okKey {
+				return key
+			}
+		}
+	}
+	return ""
+}
+
+// markCacheMiss flags a cacheable response that was fetched from upstream.
+func markCacheMiss(ctx context.Context, span trace.Span) {
+	span.SetAttributes(tracing.AttrCache.String("miss"))
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		ginCtx.Header(CacheStatusHeader, "miss")
+	}
+}
+
+// replayCachedStream emits the cached chunks through channels shaped like a live stream,
+// so handlers frame them as SSE in the caller's source format exactly as before.
+// Response guardrails are applied again so policy changes also cover cached entries.
+func replayCachedStream(ctx context.Context, span trace.Span, entry *cache.ResponseEntry, handlerType string, guard *guardrails.StreamScanner) (<-chan []byte, <-chan *interfaces.ErrorMessage) {
+	dataChan := make(chan []byte)
+	errChan := make(chan *interfaces.ErrorMessage, 1)
+	go func() {
+		defer span.End()
+		defer close(dataChan)
+		defer close(errChan)
+		for _, chunk := range entry.Chunks {
+			payload, violation := guard.Chunk(cloneBytes(chunk))
+			if violation != nil {
+				errChan <- guardrailViolation(handlerType, clientAPIKey(ctx), violation)
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case dataChan <- payload:
+			}
+		}
+	}()
+	return dataChan, errChan
+}
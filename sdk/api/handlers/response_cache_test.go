@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cache"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+type countingStreamExecutor struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (e *countingStreamExecutor) Identifier() string { return "codex" }
+
+func (e *countingStreamExecutor) Execute(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	e.mu.Lock()
+	e.calls++
+	e.mu.Unlock()
+	return coreexecutor.Response{Payload: []byte(`{"id":"resp"}`)}, nil
+}
+
+func (e *countingStreamExecutor) ExecuteStream(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	e.mu.Lock()
+	e.calls++
+	e.mu.Unlock()
+	ch := make(chan coreexecutor.StreamChunk, 2)
+	ch <- coreexecutor.StreamChunk{Payload: []byte("data: {\"delta\":\"a\"}\n\n")}
+	ch <- coreexecutor.StreamChunk{Payload: []byte("data: [DONE]\n\n")}
+	close(ch)
+	return ch, nil
+}
+
+func (e *countingStreamExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (e *countingStreamExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, nil
+}
+
+func (e *countingStreamExecutor) HttpRequest(context.Context, *coreauth.Auth, *http.Request) (*http.Response, error) {
+	return nil, nil
+}
+
+func (e *countingStreamExecutor) Calls() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.calls
+}
+
+func newResponseCacheTestHandler(t *testing.T) (*BaseAPIHandler, *countingStreamExecutor) {
+	t.Helper()
+	if err := cache.ConfigureResponseCache(sdkconfig.ResponseCacheConfig{Enabled: true}, ""); err != nil {
+		t.Fatalf("ConfigureResponseCache: %v", err)
+	}
+	t.Cleanup(func() { _ = cache.ConfigureResponseCache(sdkconfig.ResponseCacheConfig{}, "") })
+
+	executor := &countingStreamExecutor{}
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+	auth := &coreauth.Auth{ID: "cache-auth", Provider: "codex", Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("manager.Register: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{{ID: "cache-model"}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(auth.ID) })
+	return NewBaseAPIHandlers(&sdkconfig.SDKConfig{}, manager), executor
+}
+
+func collectStream(dataChan <-chan []byte, errChan <-chan *interfaces.ErrorMessage) (string, *interfaces.ErrorMessage) {
+	var got []byte
+	for chunk := range dataChan {
+		got = append(got, chunk...)
+	}
+	var errMsg *interfaces.ErrorMessage
+	for msg := range errChan {
+		if msg != nil {
+			errMsg = msg
+		}
+	}
+	return string(got), errMsg
+}
+
+func TestExecuteStreamWithAuthManager_ReplaysCachedStream(t *testing.T) {
+	handler, executor := newResponseCacheTestHandler(t)
+
+	first, errFirst := collectStream(handler.ExecuteStreamWithAuthManager(context.Background(), "openai", "cache-model", []byte(`{"model":"cache-model","stream":true,"temperature":0,"messages":[]}`), ""))
+	second, errSecond := collectStream(handler.ExecuteStreamWithAuthManager(context.Background(), "openai", "cache-model", []byte(`{"messages":[],"model":"cache-model","stream":true,"temperature":0}`), ""))
+	if errFirst != nil || errSecond != nil {
+		t.Fatalf("unexpected errors: %+v / %+v", errFirst, errSecond)
+	}
+	if first != second {
+		t.Fatalf("replayed stream = %q, want %q", second, first)
+	}
+	if executor.Calls() != 1 {
+		t.Fatalf("upstream calls = %d, want 1", executor.Calls())
+	}
+}
+
+func TestExecuteWithAuthManager_SkipsCacheForSampledRequests(t *testing.T) {
+	handler, executor := newResponseCacheTestHandler(t)
+
+	for i := 0; i < 2; i++ {
+		if _, errMsg := handler.ExecuteWithAuthManager(context.Background(), "openai", "cache-model", []byte(`{"model":"cache-model","temperature":0.7}`), ""); errMsg != nil {
+			t.Fatalf("unexpected error: %+v", errMsg)
+		}
+	}
+	if executor.Calls() != 2 {
+		t.Fatalf("upstream calls = %d, want 2", executor.Calls())
+	}
+}
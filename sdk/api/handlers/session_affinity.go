@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/tidwall/gjson"
+)
+
+// SessionIDHeader lets clients name the conversation a request belongs to for sticky routing.
+const SessionIDHeader = "X-Session-Id"
+
+// PreviousResponseIDKey is the gin context key holding the previous_response_id of a Responses
+// request whose history was expanded locally and removed from the payload.
+const PreviousResponseIDKey = "previousResponseID"
+
+const (
+	// responseSessionTTL bounds how long a Responses API id keeps resolving to its conversation.
+	responseSessionTTL = 6 * time.Hour
+	// maxResponseSessions bounds the response id table.
+	maxResponseSessions = 50_000
+)
+
+// systemFields are the top-level request fields holding the system prompt in each client format.
+var systemFields = []string{"system", "instructions", "systemInstruction", "system_instruction"}
+
+// turnFields are the top-level request fields holding the conversation turns in each client format.
+var turnFields = []string{"messages", "contents", "input"}
+
+// withSessionKey records the conversation key of the request in meta so the auth manager can
+// keep the conversation on one credential.
+func withSessionKey(ctx context.Context, meta map[string]any, rawJSON []byte) string {
+	key := sessionKey(ctx, rawJSON)
+	if key != "" {
+		meta[coreexecutor.SessionKeyMetadataKey] = key
+	}
+	return key
+}
+
+// sessionKey identifies the conversation of a request. In order of preference it uses the
+// X-Session-Id header, Claude's metadata.user_id (which carries the Claude Code session), the
+// conversation of the Responses API previous_response_id, and finally a hash of the system
+// prompt and the turns up to the first user message, which stay the same for every turn of a
+// conversation. Keys are scoped to the client API key.
+func sessionKey(ctx context.Context, rawJSON []byte) string {
+	id := ""
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil && ginCtx.Request != nil {
+		if header := strings.TrimSpace(ginCtx.GetHeader(SessionIDHeader)); header != "" {
+			id = "header:" + header
+		}
+	}
+	if id == "" {
+		if userID := strings.TrimSpace(gjson.GetBytes(rawJSON, "metadata.user_id").String()); userID != "" {
+			id = "user:" + userID
+		}
+	}
+	if id == "" {
+		previous := strings.TrimSpace(gjson.GetBytes(rawJSON, "previous_response_id").String())
+		if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil && previous == "" {
+			previous = ginCtx.GetString(PreviousResponseIDKey)
+		}
+		if previous != "" {
+			if key, ok := responseSessions.lookup(previous); ok {
+				return key
+			}
+			id = "response:" + previous
+		}
+	}
+	if id == "" {
+		id = leadingMessages(rawJSON)
+		if id == "" {
+			return ""
+		}
+		id = "prefix:" + id
+	}
+	sum := sha256.Sum256([]byte(clientAPIKey(ctx) + "\x00" + id))
+	return hex.EncodeToString(sum[:16])
+}
+
+// leadingMessages returns the system prompt and the turns up to and including the first user
+// turn, or "" when the request carries no conversation.
+func leadingMessages(rawJSON []byte) string {
+	if !gjson.ValidBytes(rawJSON) {
+		return ""
+	}
+	root := gjson.ParseBytes(rawJSON)
+	var buf bytes.Buffer
+	for _, field := range systemFields {
+		if value := root.Get(field); value.Exists() {
+			buf.WriteString(value.Raw)
+		}
+	}
+	for _, field := range turnFields {
+		turns := root.Get(field)
+		if !turns.IsArray() {
+			continue
+		}
+		for _, turn := range turns.Array() {
+			buf.WriteString(turn.Raw)
+			if role := turn.Get("role").String(); role == "" || role == "user" {
+				break
+			}
+		}
+		break
+	}
+	return buf.String()
+}
+
+// rememberResponseSession maps Responses API ids found in a response body or stream chunk to
+// the conversation key, so a follow-up request naming them as previous_response_id stays on
+// the same credential.
+func rememberResponseSession(handlerType, key string, payload []byte) {
+	if key == "" || handlerType != constant.OpenaiResponse || len(payload) == 0 {
+		return
+	}
+	if gjson.ValidBytes(payload) {
+		responseSessions.remember(gjson.GetBytes(payload, "id").String(), key)
+		responseSessions.remember(gjson.GetBytes(payload, "response.id").String(), key)
+		return
+	}
+	for _, event := range parseSSEEvents(payload) {
+		responseSessions.remember(gjson.GetBytes(event.data, "response.id").String(), key)
+	}
+}
+
+type responseSession struct {
+	id      string
+	key     string
+	expires time.Time
+}
+
+// responseSessionTable maps Responses API ids to conversation keys. Entries are kept in least
+// recently remembered order, which is also expiry order, so eviction only looks at the back.
+type responseSessionTable struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   list.List
+}
+
+var responseSessions = &responseSessionTable{}
+
+func (t *responseSessionTable) remember(responseID, key string) {
+	if responseID == "" {
+		return
+	}
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.entries == nil {
+		t.entries = make(map[string]*list.Element)
+	}
+	if elem, exists := t.entries[responseID]; exists {
+		entry := elem.Value.(*responseSession)
+		entry.key, entry.expires = key, now.Add(responseSessionTTL)
+		t.order.MoveToFront(elem)
+		return
+	}
+	for elem := t.order.Back(); elem != nil; elem = t.order.Back() {
+		if now.Before(elem.Value.(*responseSession).expires) && len(t.entries) < maxResponseSessions {
+			break
+		}
+		t.removeLocked(elem)
+	}
+	t.entries[responseID] = t.order.PushFront(&responseSession{id: responseID, key: key, expires: now.Add(responseSessionTTL)})
+}
+
+func (t *responseSessionTable) lookup(responseID string) (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	elem, ok := t.entries[responseID]
+	if !ok {
+		return "", false
+	}
+	entry := elem.Value.(*responseSession)
+	if !time.Now().Before(entry.expires) {
+		t.removeLocked(elem)
+		return "", false
+	}
+	return entry.key, true
+}
+
+func (t *responseSessionTable) removeLocked(elem *list.Element) {
+	t.order.Remove(elem)
+	delete(t.entries, elem.Value.(*responseSession).id)
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+)
+
+func TestSessionKey_StableAcrossTurns(t *testing.T) {
+	ctx := context.Background()
+	turn1 := []byte(`{"model":"gpt-5","messages":[{"role":"system","content":"be brief"},{"role":"user","content":"hi"}]}`)
+	turn2 := []byte(`{"model":"gpt-5","messages":[{"role":"system","content":"be brief"},{"role":"user","content":"hi"},{"role":"assistant","content":"hello"},{"role":"user","content":"more"}]}`)
+	other := []byte(`{"model":"gpt-5","messages":[{"role":"system","content":"be brief"},{"role":"user","content":"bye"}]}`)
+	if key := sessionKey(ctx, turn1); key == "" || key != sessionKey(ctx, turn2) {
+		t.Fatalf("turns of one conversation must share a key: %q vs %q", key, sessionKey(ctx, turn2))
+	}
+	if sessionKey(ctx, turn1) == sessionKey(ctx, other) {
+		t.Fatal("different conversations must not share a key")
+	}
+
+	claude1 := []byte(`{"metadata":{"user_id":"user_abc_account__session_1"},"messages":[{"role":"user","content":"a"}]}`)
+	claude2 := []byte(`{"metadata":{"user_id":"user_abc_account__session_1"},"messages":[{"role":"user","content":"b"}]}`)
+	if sessionKey(ctx, claude1) != sessionKey(ctx, claude2) {
+		t.Fatal("requests with the same metadata.user_id must share a key")
+	}
+
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	c.Request.Header.Set(SessionIDHeader, "explicit")
+	c.Set("apiKey", "client-a")
+	withHeader := context.WithValue(ctx, "gin", c)
+	if sessionKey(withHeader, turn1) != sessionKey(withHeader, other) {
+		t.Fatal("X-Session-Id must take precedence over the message prefix")
+	}
+	if sessionKey(withHeader, turn1) == sessionKey(ctx, turn1) {
+		t.Fatal("keys must be scoped to the client API key")
+	}
+}
+
+func TestSessionKey_FollowsPreviousResponseID(t *testing.T) {
+	ctx := context.Background()
+	first := []byte(`{"model":"gpt-5","input":[{"role":"user","content":"start"}]}`)
+	key := sessionKey(ctx, first)
+	rememberResponseSession(constant.OpenaiResponse, key, []byte("event: response.created\ndata: {\"type\":\"response.created\",\"response\":{\"id\":\"resp_1\"}}\n\n"))
+
+	second := []byte(`{"model":"gpt-5","previous_response_id":"resp_1","input":[{"role":"user","content":"next"}]}`)
+	if got := sessionKey(ctx, second); got != key {
+		t.Fatalf("follow-up key = %q, want the conversation key %q", got, key)
+	}
+	rememberResponseSession(constant.OpenaiResponse, key, []byte(`{"id":"resp_2","object":"response"}`))
+	third := []byte(`{"model":"gpt-5","previous_response_id":"resp_2","input":[{"role":"user","content":"again"}]}`)
+	if got := sessionKey(ctx, third); got != key {
+		t.Fatalf("second follow-up key = %q, want %q", got, key)
+	}
+}
+
+func TestResponseSessionTable_EvictsLeastRecentlyRemembered(t *testing.T) {
+	table := &responseSessionTable{}
+	for i := 0; i < maxResponseSessions; i++ {
+		table.remember(fmt.Sprintf("resp_%d", i), "key")
+	}
+	table.remember("resp_0", "refreshed")
+	table.remember("resp_new", "new")
+
+	if _, ok := table.lookup("resp_1"); ok {
+		t.Fatal("expected the least recently remembered id to be evicted")
+	}
+	if key, ok := table.lookup("resp_0"); !ok || key != "refreshed" {
+		t.Fatalf("resp_0 = %q, %v; want refreshed id to survive", key, ok)
+	}
+	if key, ok := table.lookup("resp_new"); !ok || key != "new" {
+		t.Fatalf("resp_new = %q, %v", key, ok)
+	}
+	if len(table.entries) != maxResponseSessions || table.order.Len() != maxResponseSessions {
+		t.Fatalf("table size = %d/%d, want %d", len(table.entries), table.order.Len(), maxResponseSessions)
+	}
+}
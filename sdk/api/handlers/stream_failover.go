@@ -0,0 +1,386 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const defaultStreamingFailoverRetries = 0
+
+// StreamingFailoverRetries returns how many times a stream that fails after bytes were sent may be
+// continued on another credential.
+func StreamingFailoverRetries(cfg *config.SDKConfig) int {
+	retries := defaultStreamingFailoverRetries
+	if cfg != nil {
+		retries = cfg.Streaming.FailoverRetries
+	}
+	if retries < 0 {
+		retries = 0
+	}
+	return retries
+}
+
+// prefillProviders lists the providers whose upstream continues a trailing assistant message
+// instead of starting the answer over. Other providers would repeat the text the client already
+// received, so streams are only continued on these.
+var prefillProviders = map[string]struct{}{
+	"claude": {},
+}
+
+// claudePrefillProviders lists providers that resume prefills only when they serve a Claude model.
+// Antigravity forwards Claude models to Anthropic, which continues the trailing assistant turn;
+// its Gemini models start the answer over.
+var claudePrefillProviders = map[string]struct{}{
+	"antigravity": {},
+}
+
+// prefillCapableProviders returns the providers a failed stream of model may be continued on.
+func prefillCapableProviders(providers []string, model string) []string {
+	claudeModel := strings.HasPrefix(strings.ToLower(thinking.ParseSuffix(model).ModelName), "claude")
+	var out []string
+	for _, provider := range providers {
+		key := strings.ToLower(strings.TrimSpace(provider))
+		if _, ok := prefillProviders[key]; ok {
+			out = append(out, provider)
+			continue
+		}
+		if _, ok := claudePrefillProviders[key]; ok && claudeModel {
+			out = append(out, provider)
+		}
+	}
+	return out
+}
+
+// streamTranscript records the assistant text already forwarded to the client for one stream, so a
+// stream that dies mid-way can be re-issued with that text as a prefill and the continuation spliced
+// into the same client stream. Only plain text answers are resumable; once a tool call or a second
+// choice has been forwarded the stream cannot be continued faithfully. OpenAI Responses streams are
+// not supported.
+type streamTranscript struct {
+	handlerType string
+	text        strings.Builder
+	resumable   bool
+	finished    bool
+	continuing  bool
+	// trimLeading is set while the prefill dropped trailing whitespace the client already
+	// received, so leading whitespace of the continuation must not be forwarded again.
+	trimLeading bool
+
+	// openaiID is the chat.completion.chunk id the client has seen.
+	openaiID string
+
+	// claudeNextIndex is the next free content block index on the client stream.
+	claudeNextIndex int
+	// claudeOpenText is the index of the text block still open on the client stream, or -1.
+	claudeOpenText int
+	// claudeResume is the open text block the continuation's first text block merges into, or -1.
+	claudeResume int
+	// claudeIndexes maps continuation block indexes to client block indexes.
+	claudeIndexes map[int]int
+}
+
+// newStreamTranscript returns a transcript for the client format, or nil when mid-stream failover
+// is not supported for it.
+func newStreamTranscript(handlerType string) *streamTranscript {
+	switch handlerType {
+	case constant.OpenAI, constant.Claude, constant.Gemini:
+		return &streamTranscript{handlerType: handlerType, resumable: true, claudeOpenText: -1, claudeResume: -1}
+	default:
+		return nil
+	}
+}
+
+// observe records a chunk as forwarded to the client.
+func (t *streamTranscript) observe(chunk []byte) {
+	if t == nil || !t.resumable {
+		return
+	}
+	switch t.handlerType {
+	case constant.OpenAI:
+		t.observeOpenAI(chunk)
+	case constant.Claude:
+		for _, event := range parseSSEEvents(chunk) {
+			t.observeClaude(event)
+		}
+	case constant.Gemini:
+		t.observeGemini(chunk)
+	}
+}
+
+func (t *streamTranscript) observeOpenAI(chunk []byte) {
+	if !gjson.ValidBytes(chunk) {
+		return
+	}
+	root := gjson.ParseBytes(chunk)
+	if t.openaiID == "" {
+		t.openaiID = root.Get("id").String()
+	}
+	for _, choice := range root.Get("choices").Array() {
+		delta := choice.Get("delta")
+		if choice.Get("index").Int() != 0 || delta.Get("tool_calls").Exists() || delta.Get("function_call").Exists() {
+			t.resumable = false
+			return
+		}
+		t.text.WriteString(delta.Get("content").String())
+		if choice.Get("finish_reason").String() != "" {
+			t.finished = true
+		}
+	}
+}
+
+func (t *streamTranscript) observeClaude(event sseEvent) {
+	data := gjson.ParseBytes(event.data)
+	index := int(data.Get("index").Int())
+	switch data.Get("type").String() {
+	case "content_block_start":
+		if index+1 > t.claudeNextIndex {
+			t.claudeNextIndex = index + 1
+		}
+		switch data.Get("content_block.type").String() {
+		case "text":
+			t.claudeOpenText = index
+		case "thinking", "redacted_thinking":
+		default:
+			t.resumable = false
+		}
+	case "content_block_delta":
+		switch data.Get("delta.type").String() {
+		case "text_delta":
+			t.text.WriteString(data.Get("delta.text").String())
+		case "thinking_delta", "signature_delta":
+		default:
+			t.resumable = false
+		}
+	case "content_block_stop":
+		if index == t.claudeOpenText {
+			t.claudeOpenText = -1
+		}
+	case "message_delta":
+		if data.Get("delta.stop_reason").String() != "" {
+			t.finished = true
+		}
+	case "message_stop":
+		t.finished = true
+	}
+}
+
+func (t *streamTranscript) observeGemini(chunk []byte) {
+	if !gjson.ValidBytes(chunk) {
+		return
+	}
+	for _, candidate := range gjson.GetBytes(chunk, "candidates").Array() {
+		if candidate.Get("index").Int() != 0 {
+			t.resumable = false
+			return
+		}
+		for _, part := range candidate.Get("content.parts").Array() {
+			if part.Get("functionCall").Exists() {
+				t.resumable = false
+				return
+			}
+			if part.Get("thought").Bool() {
+				continue
+			}
+			t.text.WriteString(part.Get("text").String())
+		}
+		if candidate.Get("finishReason").String() != "" {
+			t.finished = true
+		}
+	}
+}
+
+// continuationRequest rewrites the client request so the upstream continues the forwarded text.
+func (t *streamTranscript) continuationRequest(rawJSON []byte) ([]byte, bool) {
+	if t == nil || !t.resumable || t.finished || !gjson.ValidBytes(rawJSON) {
+		return nil, false
+	}
+	// Prefills may not end with whitespace and cannot be combined with extended thinking.
+	text := strings.TrimRightFunc(t.text.String(), unicode.IsSpace)
+	t.trimLeading = len(text) < t.text.Len()
+	var (
+		path    string
+		message any
+	)
+	switch t.handlerType {
+	case constant.OpenAI:
+		rawJSON, _ = sjson.DeleteBytes(rawJSON, "reasoning_effort")
+		path = "messages.-1"
+		message = map[string]any{"role": "assistant", "content": text}
+	case constant.Claude:
+		rawJSON, _ = sjson.DeleteBytes(rawJSON, "thinking")
+		path = "messages.-1"
+		message = map[string]any{"role": "assistant", "content": []map[string]string{{"type": "text", "text": text}}}
+	case constant.Gemini:
+		rawJSON, _ = sjson.DeleteBytes(rawJSON, "generationConfig.thinkingConfig")
+		path = "contents.-1"
+		message = map[string]any{"role": "model", "parts": []map[string]string{{"text": text}}}
+	default:
+		return nil, false
+	}
+	if text == "" {
+		return rawJSON, true
+	}
+	encoded, err := json.Marshal(message)
+	if err != nil {
+		return nil, false
+	}
+	out, err := sjson.SetRawBytes(rawJSON, path, encoded)
+	if err != nil {
+		return nil, false
+	}
+	return out, true
+}
+
+// beginContinuation switches the transcript to splicing chunks of a continuation stream.
+func (t *streamTranscript) beginContinuation() {
+	if t == nil {
+		return
+	}
+	t.continuing = true
+	t.claudeResume = t.claudeOpenText
+	t.claudeIndexes = make(map[int]int)
+}
+
+// splice rewrites a continuation chunk so it reads as part of the original client stream. It returns
+// nil when nothing of the chunk should be forwarded.
+func (t *streamTranscript) splice(chunk []byte) []byte {
+	if t == nil || !t.continuing {
+		return chunk
+	}
+	switch t.handlerType {
+	case constant.OpenAI:
+		if !gjson.ValidBytes(chunk) {
+			return chunk
+		}
+		if t.openaiID != "" {
+			chunk, _ = sjson.SetBytes(chunk, "id", t.openaiID)
+		}
+		chunk, _ = sjson.DeleteBytes(chunk, "choices.0.delta.role")
+		if content := gjson.GetBytes(chunk, "choices.0.delta.content"); content.Exists() {
+			chunk, _ = sjson.SetBytes(chunk, "choices.0.delta.content", t.trimResumed(content.String()))
+		}
+		return chunk
+	case constant.Claude:
+		return t.spliceClaude(chunk)
+	case constant.Gemini:
+		if !gjson.ValidBytes(chunk) {
+			return chunk
+		}
+		for i, part := range gjson.GetBytes(chunk, "candidates.0.content.parts").Array() {
+			if text := part.Get("text"); text.Exists() && !part.Get("thought").Bool() {
+				chunk, _ = sjson.SetBytes(chunk, fmt.Sprintf("candidates.0.content.parts.%d.text", i), t.trimResumed(text.String()))
+			}
+		}
+		return chunk
+	default:
+		return chunk
+	}
+}
+
+// trimResumed drops the leading whitespace of continuation text while the client already holds
+// the whitespace the prefill had to leave out.
+func (t *streamTranscript) trimResumed(text string) string {
+	if !t.trimLeading {
+		return text
+	}
+	text = strings.TrimLeftFunc(text, unicode.IsSpace)
+	if text != "" {
+		t.trimLeading = false
+	}
+	return text
+}
+
+func (t *streamTranscript) spliceClaude(chunk []byte) []byte {
+	var out bytes.Buffer
+	for _, event := range parseSSEEvents(chunk) {
+		data := event.data
+		root := gjson.ParseBytes(data)
+		index := int(root.Get("index").Int())
+		switch root.Get("type").String() {
+		case "message_start", "ping":
+			continue
+		case "content_block_start":
+			if root.Get("content_block.type").String() == "text" && t.claudeResume >= 0 {
+				// The first text block continues the block left open by the failed stream.
+				t.claudeIndexes[index] = t.claudeResume
+				t.claudeResume = -1
+				continue
+			}
+			t.claudeIndexes[index] = t.claudeNextIndex
+			t.claudeNextIndex++
+			data, _ = sjson.SetBytes(data, "index", t.claudeIndexes[index])
+		case "content_block_delta", "content_block_stop":
+			if mapped, ok := t.claudeIndexes[index]; ok {
+				data, _ = sjson.SetBytes(data, "index", mapped)
+			}
+			if root.Get("delta.type").String() == "text_delta" {
+				text := t.trimResumed(root.Get("delta.text").String())
+				if text == "" {
+					continue
+				}
+				data, _ = sjson.SetBytes(data, "delta.text", text)
+			}
+		}
+		if event.name != "" {
+			out.WriteString("event: ")
+			out.WriteString(event.name)
+			out.WriteString("\n")
+		}
+		out.WriteString("data: ")
+		out.Write(data)
+		out.WriteString("\n\n")
+	}
+	if out.Len() == 0 {
+		return nil
+	}
+	return out.Bytes()
+}
+
+// withExcludedAuths returns a copy of meta asking the auth manager to avoid the given auths.
+func withExcludedAuths(meta map[string]any, authIDs []string) map[string]any {
+	out := make(map[string]any, len(meta)+1)
+	for key, value := range meta {
+		out[key] = value
+	}
+	if len(authIDs) > 0 {
+		out[coreexecutor.ExcludedAuthsMetadataKey] = append([]string(nil), authIDs...)
+	}
+	return out
+}
+
+type sseEvent struct {
+	name string
+	data []byte
+}
+
+// parseSSEEvents splits a chunk of server-sent events into its events with JSON data.
+func parseSSEEvents(chunk []byte) []sseEvent {
+	var events []sseEvent
+	var current sseEvent
+	for _, line := range bytes.Split(chunk, []byte("\n")) {
+		line = bytes.TrimRight(line, "\r")
+		switch {
+		case bytes.HasPrefix(line, []byte("event:")):
+			current.name = strings.TrimSpace(string(line[len("event:"):]))
+		case bytes.HasPrefix(line, []byte("data:")):
+			current.data = bytes.TrimSpace(line[len("data:"):])
+		case len(line) == 0 && current.data != nil:
+			events = append(events, current)
+			current = sseEvent{}
+		}
+	}
+	if current.data != nil {
+		events = append(events, current)
+	}
+	return events
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+type dropMidStreamExecutor struct {
+	provider string
+	mu       sync.Mutex
+	auths    []string
+	payloads []string
+}
+
+func (e *dropMidStreamExecutor) Identifier() string { return e.provider }
+
+func (e *dropMidStreamExecutor) Execute(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, &coreauth.Error{Code: "not_implemented", Message: "Execute not implemented"}
+}
+
+func (e *dropMidStreamExecutor) ExecuteStream(_ context.Context, auth *coreauth.Auth, req coreexecutor.Request, _ coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	e.mu.Lock()
+	e.auths = append(e.auths, auth.ID)
+	e.payloads = append(e.payloads, string(req.Payload))
+	call := len(e.auths)
+	e.mu.Unlock()
+
+	ch := make(chan coreexecutor.StreamChunk, 3)
+	if call == 1 {
+		ch <- coreexecutor.StreamChunk{Payload: []byte(`{"id":"chatcmpl-1","choices":[{"index":0,"delta":{"role":"assistant","content":"Hello "}}]}`)}
+		ch <- coreexecutor.StreamChunk{Err: &coreauth.Error{Code: "upstream_closed", Message: "connection reset", HTTPStatus: http.StatusBadGateway}}
+	} else {
+		ch <- coreexecutor.StreamChunk{Payload: []byte(`{"id":"chatcmpl-2","choices":[{"index":0,"delta":{"role":"assistant","content":" world"}}]}`)}
+		ch <- coreexecutor.StreamChunk{Payload: []byte(`{"id":"chatcmpl-2","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`)}
+	}
+	close(ch)
+	return ch, nil
+}
+
+func (e *dropMidStreamExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (e *dropMidStreamExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, &coreauth.Error{Code: "not_implemented", Message: "CountTokens not implemented"}
+}
+
+func (e *dropMidStreamExecutor) HttpRequest(context.Context, *coreauth.Auth, *http.Request) (*http.Response, error) {
+	return nil, &coreauth.Error{Code: "not_implemented", Message: "HttpRequest not implemented", HTTPStatus: http.StatusNotImplemented}
+}
+
+func newFailoverHandler(t *testing.T, provider string) (*BaseAPIHandler, *dropMidStreamExecutor) {
+	t.Helper()
+	executor := &dropMidStreamExecutor{provider: provider}
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+	for _, id := range []string{provider + "-failover-a", provider + "-failover-b"} {
+		if _, err := manager.Register(context.Background(), &coreauth.Auth{ID: id, Provider: provider, Status: coreauth.StatusActive}); err != nil {
+			t.Fatalf("manager.Register(%s): %v", id, err)
+		}
+		registry.GetGlobalRegistry().RegisterClient(id, provider, []*registry.ModelInfo{{ID: provider + "-failover-model"}})
+		authID := id
+		t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(authID) })
+	}
+	handler := NewBaseAPIHandlers(&sdkconfig.SDKConfig{
+		Streaming: sdkconfig.StreamingConfig{FailoverRetries: 1},
+	}, manager)
+	return handler, executor
+}
+
+func TestExecuteStreamWithAuthManager_FailsOverMidStream(t *testing.T) {
+	handler, executor := newFailoverHandler(t, "claude")
+	raw := []byte(`{"model":"claude-failover-model","stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+	dataChan, errChan := handler.ExecuteStreamWithAuthManager(context.Background(), "openai", "claude-failover-model", raw, "")
+
+	var content strings.Builder
+	for chunk := range dataChan {
+		if id := gjson.GetBytes(chunk, "id").String(); id != "chatcmpl-1" {
+			t.Fatalf("expected spliced chunks to keep the original id, got %q", id)
+		}
+		content.WriteString(gjson.GetBytes(chunk, "choices.0.delta.content").String())
+	}
+	for msg := range errChan {
+		if msg != nil {
+			t.Fatalf("unexpected stream error: %v", msg.Error)
+		}
+	}
+
+	if content.String() != "Hello world" {
+		t.Fatalf("expected spliced content without doubled whitespace, got %q", content.String())
+	}
+	if len(executor.auths) != 2 || executor.auths[0] == executor.auths[1] {
+		t.Fatalf("expected the continuation on another credential, got %v", executor.auths)
+	}
+	prefill := gjson.Get(executor.payloads[1], "messages.1")
+	if prefill.Get("role").String() != "assistant" || prefill.Get("content").String() != "Hello" {
+		t.Fatalf("expected assistant prefill in continuation, got %s", executor.payloads[1])
+	}
+}
+
+func TestExecuteStreamWithAuthManager_NoFailoverWithoutPrefillSupport(t *testing.T) {
+	handler, executor := newFailoverHandler(t, "codex")
+	raw := []byte(`{"model":"codex-failover-model","stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+	dataChan, errChan := handler.ExecuteStreamWithAuthManager(context.Background(), "openai", "codex-failover-model", raw, "")
+
+	var content strings.Builder
+	for chunk := range dataChan {
+		content.WriteString(gjson.GetBytes(chunk, "choices.0.delta.content").String())
+	}
+	var streamErr error
+	for msg := range errChan {
+		if msg != nil {
+			streamErr = msg.Error
+		}
+	}
+	if streamErr == nil || content.String() != "Hello " || len(executor.auths) != 1 {
+		t.Fatalf("expected the stream to fail without a restart, got %q (err %v, %d calls)", content.String(), streamErr, len(executor.auths))
+	}
+}
+
+func TestStreamTranscript_SplicesClaudeContinuation(t *testing.T) {
+	transcript := newStreamTranscript("claude")
+	transcript.observe([]byte("event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\"}}\n\n" +
+		"event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n\n"))
+	transcript.observe([]byte("event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"func main() \"}}\n\n"))
+
+	continuation, ok := transcript.continuationRequest([]byte(`{"model":"claude","thinking":{"type":"enabled"},"messages":[{"role":"user","content":"write go"}]}`))
+	if !ok {
+		t.Fatal("expected the stream to be resumable")
+	}
+	if gjson.GetBytes(continuation, "thinking").Exists() || gjson.GetBytes(continuation, "messages.1.content.0.text").String() != "func main()" {
+		t.Fatalf("unexpected continuation request %s", continuation)
+	}
+
+	transcript.beginContinuation()
+	out := transcript.splice([]byte("event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_2\"}}\n\n" +
+		"event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n\n"))
+	if out != nil {
+		t.Fatalf("expected continuation preamble to be dropped, got %q", out)
+	}
+	out = transcript.splice([]byte("event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"{}\"}}\n\n" +
+		"event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":1,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n\n"))
+	events := parseSSEEvents(out)
+	if len(events) != 2 || gjson.GetBytes(events[0].data, "index").Int() != 0 || events[0].name != "content_block_delta" {
+		t.Fatalf("unexpected spliced events %q", out)
+	}
+	transcript.observe(out)
+	if gjson.GetBytes(events[1].data, "index").Int() != 1 {
+		t.Fatalf("expected later blocks to get fresh indexes, got %q", out)
+	}
+
+	transcript.observe([]byte("event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":2,\"content_block\":{\"type\":\"tool_use\",\"id\":\"t\",\"name\":\"run\"}}\n\n"))
+	if _, ok = transcript.continuationRequest([]byte(`{"messages":[]}`)); ok {
+		t.Fatal("expected a stream with tool calls not to be resumable")
+	}
+}
+
+func TestPrefillCapableProviders_AntigravityOnlyForClaudeModels(t *testing.T) {
+	providers := []string{"claude", "antigravity", "gemini-cli"}
+	if got := strings.Join(prefillCapableProviders(providers, "claude-sonnet-4-5(8192)"), ","); got != "claude,antigravity" {
+		t.Fatalf("claude model providers = %s, want claude,antigravity", got)
+	}
+	if got := strings.Join(prefillCapableProviders(providers, "gemini-2.5-pro"), ","); got != "claude" {
+		t.Fatalf("gemini model providers = %s, want claude", got)
+	}
+}
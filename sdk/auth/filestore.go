@@ -12,6 +12,8 @@ import (
 	"sync"
 	"time"
 
+	baseauth "github.com/router-for-me/CLIProxyAPI/v6/internal/auth"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authcrypt"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 )
 
@@ -64,7 +66,7 @@ func (s *FileTokenStore) Save(ctx context.Context, auth *cliproxyauth.Auth) (str
 
 	switch {
 	case auth.Storage != nil:
-		if err = auth.Storage.SaveTokenToFile(path); err != nil {
+		if err = baseauth.SealTokenStorage(auth.Storage, path); err != nil {
 			return "", err
 		}
 	case auth.Metadata != nil:
@@ -73,10 +75,14 @@ func (s *FileTokenStore) Save(ctx context.Context, auth *cliproxyauth.Auth) (str
 		if errMarshal != nil {
 			return "", fmt.Errorf("auth filestore: marshal metadata failed: %w", errMarshal)
 		}
-		if existing, errRead := os.ReadFile(path); errRead == nil {
-			if jsonEqual(existing, raw) {
-				return path, nil
-			}
+		existing, errRead := os.ReadFile(path)
+		if errRead == nil && authcrypt.RecordEqual(existing, raw) {
+			return path, nil
+		}
+		if raw, err = authcrypt.Encrypt(raw); err != nil {
+			return "", fmt.Errorf("auth filestore: encrypt metadata failed: %w", err)
+		}
+		if errRead == nil {
 			file, errOpen := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0o600)
 			if errOpen != nil {
 				return "", fmt.Errorf("auth filestore: open existing failed: %w", errOpen)
@@ -156,9 +162,57 @@ func (s *FileTokenStore) Delete(ctx context.Context, id string) error {
 	if err = os.Remove(path); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("auth filestore: delete failed: %w", err)
 	}
+	return s.DeleteRuntimeState(ctx, id)
+}
+
+// ListRuntimeStates reads the runtime state side records kept under the runtime state directory.
+func (s *FileTokenStore) ListRuntimeStates(context.Context) ([]*cliproxyauth.RuntimeState, error) {
+	dir := s.runtimeStateDir()
+	if dir == "" {
+		return nil, fmt.Errorf("auth filestore: directory not configured")
+	}
+	return cliproxyauth.ReadRuntimeStateDir(dir)
+}
+
+// SaveRuntimeState writes the runtime state side record of an auth.
+func (s *FileTokenStore) SaveRuntimeState(_ context.Context, state *cliproxyauth.RuntimeState) error {
+	if state == nil || state.ID == "" {
+		return fmt.Errorf("auth filestore: runtime state id is empty")
+	}
+	dir := s.runtimeStateDir()
+	if dir == "" {
+		return fmt.Errorf("auth filestore: directory not configured")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := cliproxyauth.WriteRuntimeStateFile(dir, state)
+	return err
+}
+
+// DeleteRuntimeState removes the runtime state side record of an auth.
+func (s *FileTokenStore) DeleteRuntimeState(_ context.Context, id string) error {
+	dir := s.runtimeStateDir()
+	if dir == "" || strings.TrimSpace(id) == "" {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.Remove(filepath.Join(dir, cliproxyauth.RuntimeStateKey(id))); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("auth filestore: delete runtime state failed: %w", err)
+	}
 	return nil
 }
 
+// runtimeStateDir returns the directory holding runtime state side records. The files use a
+// ".state" suffix so auth listing and the file watcher ignore them.
+func (s *FileTokenStore) runtimeStateDir() string {
+	dir := s.baseDirSnapshot()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, ".runtime-state")
+}
+
 func (s *FileTokenStore) resolveDeletePath(id string) (string, error) {
 	if strings.ContainsRune(id, os.PathSeparator) || filepath.IsAbs(id) {
 		return id, nil
@@ -171,7 +225,7 @@ func (s *FileTokenStore) resolveDeletePath(id string) (string, error) {
 }
 
 func (s *FileTokenStore) readAuthFile(path, baseDir string) (*cliproxyauth.Auth, error) {
-	data, err := os.ReadFile(path)
+	data, err := authcrypt.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("read file: %w", err)
 	}
@@ -200,7 +254,11 @@ func (s *FileTokenStore) readAuthFile(path, baseDir string) (*cliproxyauth.Auth,
 				fetchedProjectID, errFetch := FetchAntigravityProjectID(context.Background(), accessToken, http.DefaultClient)
 				if errFetch == nil && strings.TrimSpace(fetchedProjectID) != "" {
 					metadata["project_id"] = strings.TrimSpace(fetchedProjectID)
-					if raw, errMarshal := json.Marshal(metadata); errMarshal == nil {
+					raw, errMarshal := json.Marshal(metadata)
+					if errMarshal == nil {
+						raw, errMarshal = authcrypt.Encrypt(raw)
+					}
+					if errMarshal == nil {
 						if file, errOpen := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0o600); errOpen == nil {
 							_, _ = file.Write(raw)
 							_ = file.Close()
@@ -312,66 +370,3 @@ func (s *FileTokenStore) baseDirSnapshot() string {
 	defer s.dirLock.RUnlock()
 	return s.baseDir
 }
-
-// jsonEqual compares two JSON blobs by parsing them into Go objects and deep comparing.
-func jsonEqual(a, b []byte) bool {
-	var objA any
-	var objB any
-	if err := json.Unmarshal(a, &objA); err != nil {
-		return false
-	}
-	if err := json.Unmarshal(b, &objB); err != nil {
-		return false
-	}
-	return deepEqualJSON(objA, objB)
-}
-
-func deepEqualJSON(a, b any) bool {
-	switch valA := a.(type) {
-	case map[string]any:
-		valB, ok := b.(map[string]any)
-		if !ok || len(valA) != len(valB) {
-			return false
-		}
-		for key, subA := range valA {
-			subB, ok1 := valB[key]
-			if !ok1 || !deepEqualJSON(subA, subB) {
-				return false
-			}
-		}
-		return true
-	case []any:
-		sliceB, ok := b.([]any)
-		if !ok || len(valA) != len(sliceB) {
-			return false
-		}
-		for i := range valA {
-			if !deepEqualJSON(valA[i], sliceB[i]) {
-				return false
-			}
-		}
-		return true
-	case float64:
-		valB, ok := b.(float64)
-		if !ok {
-			return false
-		}
-		return valA == valB
-	case string:
-		valB, ok := b.(string)
-		if !ok {
-			return false
-		}
-		return valA == valB
-	case bool:
-		valB, ok := b.(bool)
-		if !ok {
-			return false
-		}
-		return valA == valB
-	case nil:
-		return b == nil
-	default:
-		return false
-	}
-}
@@ -0,0 +1,237 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+// clusterStandIn emulates the shared database of a cluster: refresh leases, the stored
+// credentials and runtime states, and change notifications to the other replicas.
+type clusterStandIn struct {
+	mu       sync.Mutex
+	leases   map[string]bool
+	tokens   map[string]any
+	states   map[string]*RuntimeState
+	replicas []*clusterReplicaStore
+}
+
+type clusterReplicaStore struct {
+	cluster *clusterStandIn
+	mu      sync.Mutex
+	handler func(*RuntimeState)
+}
+
+func newClusterStandIn() *clusterStandIn {
+	return &clusterStandIn{
+		leases: make(map[string]bool),
+		tokens: make(map[string]any),
+		states: make(map[string]*RuntimeState),
+	}
+}
+
+func (c *clusterStandIn) replica() *clusterReplicaStore {
+	r := &clusterReplicaStore{cluster: c}
+	c.mu.Lock()
+	c.replicas = append(c.replicas, r)
+	c.mu.Unlock()
+	return r
+}
+
+func (c *clusterStandIn) broadcast(origin *clusterReplicaStore, state *RuntimeState) {
+	c.mu.Lock()
+	replicas := append([]*clusterReplicaStore(nil), c.replicas...)
+	c.mu.Unlock()
+	for _, r := range replicas {
+		if r == origin {
+			continue
+		}
+		r.mu.Lock()
+		handler := r.handler
+		r.mu.Unlock()
+		if handler != nil {
+			handler(state)
+		}
+	}
+}
+
+func (r *clusterReplicaStore) List(context.Context) ([]*Auth, error) { return nil, nil }
+
+func (r *clusterReplicaStore) Save(_ context.Context, auth *Auth) (string, error) {
+	r.cluster.mu.Lock()
+	r.cluster.tokens[auth.ID] = auth.Metadata["refresh_token"]
+	r.cluster.mu.Unlock()
+	return "", nil
+}
+
+func (r *clusterReplicaStore) Delete(context.Context, string) error { return nil }
+
+func (r *clusterReplicaStore) ListRuntimeStates(context.Context) ([]*RuntimeState, error) {
+	return nil, nil
+}
+
+func (r *clusterReplicaStore) SaveRuntimeState(_ context.Context, state *RuntimeState) error {
+	r.cluster.mu.Lock()
+	r.cluster.states[state.ID] = state
+	r.cluster.mu.Unlock()
+	r.cluster.broadcast(r, state)
+	return nil
+}
+
+func (r *clusterReplicaStore) DeleteRuntimeState(_ context.Context, id string) error {
+	r.cluster.mu.Lock()
+	delete(r.cluster.states, id)
+	r.cluster.mu.Unlock()
+	r.cluster.broadcast(r, &RuntimeState{ID: id})
+	return nil
+}
+
+func (r *clusterReplicaStore) SubscribeRuntimeState(fn func(*RuntimeState)) {
+	r.mu.Lock()
+	r.handler = fn
+	r.mu.Unlock()
+}
+
+func (r *clusterReplicaStore) AcquireRefresh(_ context.Context, auth *Auth) (func(), bool, error) {
+	r.cluster.mu.Lock()
+	defer r.cluster.mu.Unlock()
+	if r.cluster.leases[auth.ID] {
+		return nil, false, nil
+	}
+	if r.cluster.tokens[auth.ID] != auth.Metadata["refresh_token"] {
+		// Another replica refreshed the credential already.
+		return nil, false, nil
+	}
+	r.cluster.leases[auth.ID] = true
+	return func() {
+		r.cluster.mu.Lock()
+		delete(r.cluster.leases, auth.ID)
+		r.cluster.mu.Unlock()
+	}, true, nil
+}
+
+type clusterRefreshExecutor struct {
+	refreshes atomic.Int32
+}
+
+func (e *clusterRefreshExecutor) Identifier() string { return "claude" }
+
+func (e *clusterRefreshExecutor) Execute(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{}, nil
+}
+
+func (e *clusterRefreshExecutor) ExecuteStream(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	return nil, nil
+}
+
+func (e *clusterRefreshExecutor) Refresh(_ context.Context, auth *Auth) (*Auth, error) {
+	e.refreshes.Add(1)
+	time.Sleep(20 * time.Millisecond)
+	auth.Metadata["refresh_token"] = "rt-2"
+	return auth, nil
+}
+
+func (e *clusterRefreshExecutor) CountTokens(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{}, nil
+}
+
+func (e *clusterRefreshExecutor) HttpRequest(context.Context, *Auth, *http.Request) (*http.Response, error) {
+	return nil, nil
+}
+
+func newClusterReplicas(t *testing.T, cluster *clusterStandIn, exec ProviderExecutor, n int) []*Manager {
+	t.Helper()
+	managers := make([]*Manager, 0, n)
+	for i := 0; i < n; i++ {
+		mgr := NewManager(cluster.replica(), nil, nil)
+		mgr.RegisterExecutor(exec)
+		auth := &Auth{
+			ID:       "claude-user.json",
+			Provider: "claude",
+			Metadata: map[string]any{"type": "claude", "refresh_token": "rt-1"},
+		}
+		if _, err := mgr.Register(context.Background(), auth); err != nil {
+			t.Fatalf("Register returned error: %v", err)
+		}
+		managers = append(managers, mgr)
+	}
+	return managers
+}
+
+func TestManager_ClusterRefreshRunsOnce(t *testing.T) {
+	cluster := newClusterStandIn()
+	exec := &clusterRefreshExecutor{}
+	replicas := newClusterReplicas(t, cluster, exec, 3)
+
+	var wg sync.WaitGroup
+	for _, mgr := range replicas {
+		wg.Add(1)
+		go func(mgr *Manager) {
+			defer wg.Done()
+			mgr.refreshAuth(context.Background(), "claude-user.json")
+		}(mgr)
+	}
+	wg.Wait()
+	if got := exec.refreshes.Load(); got != 1 {
+		t.Fatalf("expected a single refresh across replicas, got %d", got)
+	}
+
+	// Replicas that still hold the old refresh token must not refresh with it.
+	stale := 0
+	for _, mgr := range replicas {
+		if auth, _ := mgr.GetByID("claude-user.json"); auth.Metadata["refresh_token"] != "rt-1" {
+			continue
+		}
+		stale++
+		mgr.refreshAuth(context.Background(), "claude-user.json")
+	}
+	if stale != 2 {
+		t.Fatalf("expected two replicas with the old refresh token, got %d", stale)
+	}
+	if got := exec.refreshes.Load(); got != 1 {
+		t.Fatalf("expected stale replicas to skip refresh, got %d refreshes", got)
+	}
+}
+
+func TestManager_ClusterSharesCooldowns(t *testing.T) {
+	cluster := newClusterStandIn()
+	replicas := newClusterReplicas(t, cluster, &clusterRefreshExecutor{}, 3)
+	ctx := context.Background()
+	retryAfter := time.Hour
+
+	replicas[0].MarkResult(ctx, Result{
+		AuthID:     "claude-user.json",
+		Provider:   "claude",
+		Model:      "claude-sonnet-4",
+		RetryAfter: &retryAfter,
+		Error:      &Error{HTTPStatus: 429, Message: "rate limited"},
+	})
+	waitForModelCooldown(t, replicas[1], "claude-user.json", "claude-sonnet-4", true)
+	waitForModelCooldown(t, replicas[2], "claude-user.json", "claude-sonnet-4", true)
+
+	replicas[0].MarkResult(ctx, Result{AuthID: "claude-user.json", Provider: "claude", Model: "claude-sonnet-4", Success: true})
+	waitForModelCooldown(t, replicas[1], "claude-user.json", "claude-sonnet-4", false)
+	waitForModelCooldown(t, replicas[2], "claude-user.json", "claude-sonnet-4", false)
+}
+
+func waitForModelCooldown(t *testing.T, mgr *Manager, authID, model string, cooling bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		auth, ok := mgr.GetByID(authID)
+		state := (*ModelState)(nil)
+		if ok {
+			state = auth.ModelStates[model]
+		}
+		if (state != nil && state.Unavailable) == cooling {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("model %s of %s cooling=%v not observed", model, authID, cooling)
+}
@@ -17,11 +17,14 @@ import (
 	"github.com/google/uuid"
 	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/metrics"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tracing"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // ProviderExecutor defines the contract required by Manager to execute provider calls.
@@ -144,6 +147,27 @@ type Manager struct {
 
 	// Auto refresh state
 	refreshCancel context.CancelFunc
+
+	// restoredStates holds runtime states read on Load that wait for their auth to be registered.
+	restoredStates map[string]*RuntimeState
+	// runtimeStateMu guards the runtime state fields below. runtimeStateSaved tracks the last
+	// written fingerprint per auth ID so unchanged states are not written again,
+	// runtimeStateDirty holds the auth IDs waiting to be saved and runtimeStateWriting reports
+	// whether the writer goroutine is running.
+	runtimeStateMu      sync.Mutex
+	runtimeStateSaved   map[string]string
+	runtimeStateDirty   map[string]struct{}
+	runtimeStateWriting bool
+
+	// probeCancel stops the background prober; probeResults keeps the last probe per auth ID
+	// and probeRound the last round started with StartProbeAll.
+	probeCancel  context.CancelFunc
+	probeMu      sync.Mutex
+	probeResults map[string][]ProbeResult
+	probeRound   ProbeRound
+
+	// affinity pins conversations to the credential that served them.
+	affinity sessionAffinity
 }
 
 // NewManager constructs a manager with optional custom selector and hook.
@@ -165,6 +189,7 @@ func NewManager(store Store, selector Selector, hook Hook) *Manager {
 	// atomic.Value requires non-nil initial value.
 	manager.runtimeConfig.Store(&internalconfig.Config{})
 	manager.apiKeyModelAlias.Store(apiKeyModelAliasTable(nil))
+	manager.subscribeRuntimeState(store)
 	return manager
 }
 
@@ -183,8 +208,9 @@ func (m *Manager) SetSelector(selector Selector) {
 // SetStore swaps the underlying persistence store.
 func (m *Manager) SetStore(store Store) {
 	m.mu.Lock()
-	defer m.mu.Unlock()
 	m.store = store
+	m.mu.Unlock()
+	m.subscribeRuntimeState(store)
 }
 
 // SetRoundTripperProvider register a provider that returns a per-auth RoundTripper.
@@ -415,6 +441,7 @@ func (m *Manager) Register(ctx context.Context, auth *Auth) (*Auth, error) {
 	}
 	auth.EnsureIndex()
 	m.mu.Lock()
+	m.restoreRuntimeStateLocked(auth)
 	m.auths[auth.ID] = auth.Clone()
 	m.mu.Unlock()
 	m.rebuildAPIKeyModelAliasFromRuntimeConfig()
@@ -434,6 +461,7 @@ func (m *Manager) Update(ctx context.Context, auth *Auth) (*Auth, error) {
 		auth.indexAssigned = existing.indexAssigned
 	}
 	auth.EnsureIndex()
+	m.restoreRuntimeStateLocked(auth)
 	m.auths[auth.ID] = auth.Clone()
 	m.mu.Unlock()
 	m.rebuildAPIKeyModelAliasFromRuntimeConfig()
@@ -461,6 +489,7 @@ func (m *Manager) Load(ctx context.Context) error {
 		auth.EnsureIndex()
 		m.auths[auth.ID] = auth.Clone()
 	}
+	m.loadRuntimeStatesLocked(ctx)
 	cfg, _ := m.runtimeConfig.Load().(*internalconfig.Config)
 	if cfg == nil {
 		cfg = &internalconfig.Config{}
@@ -471,7 +500,21 @@ func (m *Manager) Load(ctx context.Context) error {
 
 // Execute performs a non-streaming execution using the configured selector and executor.
 // It supports multiple providers for the same model and round-robins the starting provider per model.
+// When the requested model fails, configured model fallbacks are tried in order.
 func (m *Manager) Execute(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	var resp cliproxyexecutor.Response
+	errExec := m.executeWithFallbacks(providers, req, opts, func(p []string, r cliproxyexecutor.Request, o cliproxyexecutor.Options) error {
+		var err error
+		resp, err = m.executeWithRetry(ctx, p, r, o)
+		return err
+	})
+	if errExec != nil {
+		return cliproxyexecutor.Response{}, errExec
+	}
+	return resp, nil
+}
+
+func (m *Manager) executeWithRetry(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
 	normalized := m.normalizeProviders(providers)
 	if len(normalized) == 0 {
 		return cliproxyexecutor.Response{}, &Error{Code: "provider_not_found", Message: "no provider supplied"}
@@ -481,7 +524,7 @@ func (m *Manager) Execute(ctx context.Context, providers []string, req cliproxye
 
 	var lastErr error
 	for attempt := 0; ; attempt++ {
-		resp, errExec := m.executeMixedOnce(ctx, normalized, req, opts)
+		resp, errExec := m.executeHedged(ctx, normalized, req, opts, m.executeMixedOnce)
 		if errExec == nil {
 			return resp, nil
 		}
@@ -502,7 +545,21 @@ func (m *Manager) Execute(ctx context.Context, providers []string, req cliproxye
 
 // ExecuteCount performs a non-streaming execution using the configured selector and executor.
 // It supports multiple providers for the same model and round-robins the starting provider per model.
+// When the requested model fails, configured model fallbacks are tried in order.
 func (m *Manager) ExecuteCount(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	var resp cliproxyexecutor.Response
+	errExec := m.executeWithFallbacks(providers, req, opts, func(p []string, r cliproxyexecutor.Request, o cliproxyexecutor.Options) error {
+		var err error
+		resp, err = m.executeCountWithRetry(ctx, p, r, o)
+		return err
+	})
+	if errExec != nil {
+		return cliproxyexecutor.Response{}, errExec
+	}
+	return resp, nil
+}
+
+func (m *Manager) executeCountWithRetry(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
 	normalized := m.normalizeProviders(providers)
 	if len(normalized) == 0 {
 		return cliproxyexecutor.Response{}, &Error{Code: "provider_not_found", Message: "no provider supplied"}
@@ -512,7 +569,7 @@ func (m *Manager) ExecuteCount(ctx context.Context, providers []string, req clip
 
 	var lastErr error
 	for attempt := 0; ; attempt++ {
-		resp, errExec := m.executeCountMixedOnce(ctx, normalized, req, opts)
+		resp, errExec := m.executeHedged(ctx, normalized, req, opts, m.executeCountMixedOnce)
 		if errExec == nil {
 			return resp, nil
 		}
@@ -533,7 +590,21 @@ func (m *Manager) ExecuteCount(ctx context.Context, providers []string, req clip
 
 // ExecuteStream performs a streaming execution using the configured selector and executor.
 // It supports multiple providers for the same model and round-robins the starting provider per model.
+// When the requested model fails before streaming starts, configured model fallbacks are tried in order.
 func (m *Manager) ExecuteStream(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	var chunks <-chan cliproxyexecutor.StreamChunk
+	errStream := m.executeWithFallbacks(providers, req, opts, func(p []string, r cliproxyexecutor.Request, o cliproxyexecutor.Options) error {
+		var err error
+		chunks, err = m.executeStreamWithRetry(ctx, p, r, o)
+		return err
+	})
+	if errStream != nil {
+		return nil, errStream
+	}
+	return chunks, nil
+}
+
+func (m *Manager) executeStreamWithRetry(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
 	normalized := m.normalizeProviders(providers)
 	if len(normalized) == 0 {
 		return nil, &Error{Code: "provider_not_found", Message: "no provider supplied"}
@@ -569,8 +640,10 @@ func (m *Manager) executeMixedOnce(ctx context.Context, providers []string, req
 	routeModel := req.Model
 	opts = ensureRequestedModelMetadata(opts, routeModel)
 	tried := make(map[string]struct{})
+	hedge := hedgeGroupFromContext(ctx)
 	var lastErr error
 	for {
+		hedge.exclude(tried)
 		auth, executor, provider, errPick := m.pickNextMixed(ctx, providers, routeModel, opts, tried)
 		if errPick != nil {
 			if lastErr != nil {
@@ -581,18 +654,26 @@ func (m *Manager) executeMixedOnce(ctx context.Context, providers []string, req
 
 		entry := logEntryWithRequestID(ctx)
 		debugLogAuthSelection(entry, auth, provider, req.Model)
+		cliproxyexecutor.ObserveSelection(ctx, auth.ID, provider)
 
 		tried[auth.ID] = struct{}{}
-		execCtx := ctx
+		hedge.claim(auth.ID)
+		release := m.acquireLoad(auth.ID)
+		execCtx, attemptSpan := startAttemptSpan(ctx, auth, provider, routeModel, len(tried))
 		if rt := m.roundTripperFor(auth); rt != nil {
 			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
 			execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
 		}
+		execCtx = m.withUpstreamQuotaReporter(execCtx, auth.ID)
 		execReq := req
 		execReq.Model = rewriteModelForAuth(routeModel, auth)
 		execReq.Model = m.applyOAuthModelAlias(auth, execReq.Model)
 		execReq.Model = m.applyAPIKeyModelAlias(auth, execReq.Model)
+		started := time.Now()
 		resp, errExec := executor.Execute(execCtx, auth, execReq, opts)
+		release()
+		endAttemptSpan(attemptSpan, errExec)
+		metrics.ObserveDuration(provider, routeModel, metrics.KindExecute, time.Since(started))
 		result := Result{AuthID: auth.ID, Provider: provider, Model: routeModel, Success: errExec == nil}
 		if errExec != nil {
 			if errCtx := execCtx.Err(); errCtx != nil {
@@ -622,8 +703,10 @@ func (m *Manager) executeCountMixedOnce(ctx context.Context, providers []string,
 	routeModel := req.Model
 	opts = ensureRequestedModelMetadata(opts, routeModel)
 	tried := make(map[string]struct{})
+	hedge := hedgeGroupFromContext(ctx)
 	var lastErr error
 	for {
+		hedge.exclude(tried)
 		auth, executor, provider, errPick := m.pickNextMixed(ctx, providers, routeModel, opts, tried)
 		if errPick != nil {
 			if lastErr != nil {
@@ -634,18 +717,26 @@ func (m *Manager) executeCountMixedOnce(ctx context.Context, providers []string,
 
 		entry := logEntryWithRequestID(ctx)
 		debugLogAuthSelection(entry, auth, provider, req.Model)
+		cliproxyexecutor.ObserveSelection(ctx, auth.ID, provider)
 
 		tried[auth.ID] = struct{}{}
-		execCtx := ctx
+		hedge.claim(auth.ID)
+		release := m.acquireLoad(auth.ID)
+		execCtx, attemptSpan := startAttemptSpan(ctx, auth, provider, routeModel, len(tried))
 		if rt := m.roundTripperFor(auth); rt != nil {
 			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
 			execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
 		}
+		execCtx = m.withUpstreamQuotaReporter(execCtx, auth.ID)
 		execReq := req
 		execReq.Model = rewriteModelForAuth(routeModel, auth)
 		execReq.Model = m.applyOAuthModelAlias(auth, execReq.Model)
 		execReq.Model = m.applyAPIKeyModelAlias(auth, execReq.Model)
+		started := time.Now()
 		resp, errExec := executor.CountTokens(execCtx, auth, execReq, opts)
+		release()
+		endAttemptSpan(attemptSpan, errExec)
+		metrics.ObserveDuration(provider, routeModel, metrics.KindCount, time.Since(started))
 		result := Result{AuthID: auth.ID, Provider: provider, Model: routeModel, Success: errExec == nil}
 		if errExec != nil {
 			if errCtx := execCtx.Err(); errCtx != nil {
@@ -674,7 +765,11 @@ func (m *Manager) executeStreamMixedOnce(ctx context.Context, providers []string
 	}
 	routeModel := req.Model
 	opts = ensureRequestedModelMetadata(opts, routeModel)
-	tried := make(map[string]struct{})
+	excluded := excludedAuthsFromMetadata(opts.Metadata)
+	tried := make(map[string]struct{}, len(excluded))
+	for _, id := range excluded {
+		tried[id] = struct{}{}
+	}
 	var lastErr error
 	for {
 		auth, executor, provider, errPick := m.pickNextMixed(ctx, providers, routeModel, opts, tried)
@@ -682,24 +777,37 @@ func (m *Manager) executeStreamMixedOnce(ctx context.Context, providers []string
 			if lastErr != nil {
 				return nil, lastErr
 			}
+			if len(excluded) > 0 {
+				// Nothing but the excluded auths can serve the model; fall back to them.
+				excluded = nil
+				clear(tried)
+				continue
+			}
 			return nil, errPick
 		}
 
 		entry := logEntryWithRequestID(ctx)
 		debugLogAuthSelection(entry, auth, provider, req.Model)
+		cliproxyexecutor.ObserveSelection(ctx, auth.ID, provider)
 
 		tried[auth.ID] = struct{}{}
-		execCtx := ctx
+		release := m.acquireLoad(auth.ID)
+		execCtx, attemptSpan := startAttemptSpan(ctx, auth, provider, routeModel, len(tried))
 		if rt := m.roundTripperFor(auth); rt != nil {
 			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
 			execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
 		}
+		execCtx = m.withUpstreamQuotaReporter(execCtx, auth.ID)
 		execReq := req
 		execReq.Model = rewriteModelForAuth(routeModel, auth)
 		execReq.Model = m.applyOAuthModelAlias(auth, execReq.Model)
 		execReq.Model = m.applyAPIKeyModelAlias(auth, execReq.Model)
+		started := time.Now()
 		chunks, errStream := executor.ExecuteStream(execCtx, auth, execReq, opts)
 		if errStream != nil {
+			release()
+			endAttemptSpan(attemptSpan, errStream)
+			metrics.ObserveDuration(provider, routeModel, metrics.KindStream, time.Since(started))
 			if errCtx := execCtx.Err(); errCtx != nil {
 				return nil, errCtx
 			}
@@ -717,11 +825,20 @@ func (m *Manager) executeStreamMixedOnce(ctx context.Context, providers []string
 		out := make(chan cliproxyexecutor.StreamChunk)
 		go func(streamCtx context.Context, streamAuth *Auth, streamProvider string, streamChunks <-chan cliproxyexecutor.StreamChunk) {
 			defer close(out)
+			defer release()
+			var streamErr error
+			defer func() { endAttemptSpan(attemptSpan, streamErr) }()
 			var failed bool
 			forward := true
+			firstChunk := true
 			for chunk := range streamChunks {
+				if firstChunk {
+					firstChunk = false
+					metrics.ObserveTimeToFirstByte(streamProvider, routeModel, time.Since(started))
+				}
 				if chunk.Err != nil && !failed {
 					failed = true
+					streamErr = chunk.Err
 					rerr := &Error{Message: chunk.Err.Error()}
 					var se cliproxyexecutor.StatusError
 					if errors.As(chunk.Err, &se) && se != nil {
@@ -745,11 +862,31 @@ func (m *Manager) executeStreamMixedOnce(ctx context.Context, providers []string
 			if !failed {
 				m.MarkResult(streamCtx, Result{AuthID: streamAuth.ID, Provider: streamProvider, Model: routeModel, Success: true})
 			}
+			metrics.ObserveDuration(streamProvider, routeModel, metrics.KindStream, time.Since(started))
 		}(execCtx, auth.Clone(), provider, chunks)
 		return out, nil
 	}
 }
 
+// startAttemptSpan opens the span covering one upstream attempt with the selected auth.
+func startAttemptSpan(ctx context.Context, auth *Auth, provider, model string, attempt int) (context.Context, trace.Span) {
+	return tracing.Start(ctx, "cliproxy.attempt",
+		tracing.AttrProvider.String(provider),
+		tracing.AttrModel.String(model),
+		tracing.AttrAuthIndex.String(auth.Index),
+		tracing.AttrAttempt.Int(attempt),
+	)
+}
+
+// endAttemptSpan records the attempt outcome, including the upstream status code when known.
+func endAttemptSpan(span trace.Span, err error) {
+	var se cliproxyexecutor.StatusError
+	if errors.As(err, &se) && se != nil {
+		tracing.SetStatusCode(span, se.StatusCode())
+	}
+	tracing.End(span, err)
+}
+
 func ensureRequestedModelMetadata(opts cliproxyexecutor.Options, requestedModel string) cliproxyexecutor.Options {
 	requestedModel = strings.TrimSpace(requestedModel)
 	if requestedModel == "" {
@@ -771,6 +908,22 @@ func ensureRequestedModelMetadata(opts cliproxyexecutor.Options, requestedModel
 	return opts
 }
 
+// excludedAuthsFromMetadata returns the auth IDs listed under cliproxyexecutor.ExcludedAuthsMetadataKey.
+func excludedAuthsFromMetadata(meta map[string]any) []string {
+	if len(meta) == 0 {
+		return nil
+	}
+	switch ids := meta[cliproxyexecutor.ExcludedAuthsMetadataKey].(type) {
+	case []string:
+		return ids
+	case string:
+		if ids != "" {
+			return []string{ids}
+		}
+	}
+	return nil
+}
+
 func hasRequestedModelMetadata(meta map[string]any) bool {
 	if len(meta) == 0 {
 		return false
@@ -1144,6 +1297,8 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 	setModelQuota := false
 
 	m.mu.Lock()
+	// A fresh result supersedes any runtime state still waiting to be restored.
+	delete(m.restoredStates, result.AuthID)
 	if auth, ok := m.auths[result.AuthID]; ok && auth != nil {
 		now := time.Now()
 
@@ -1237,6 +1392,7 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 		_ = m.persist(ctx, auth)
 	}
 	m.mu.Unlock()
+	m.persistRuntimeState(ctx, result.AuthID)
 
 	if clearModelQuota && result.Model != "" {
 		registry.GetGlobalRegistry().ClearModelQuotaExceeded(result.AuthID, result.Model)
@@ -1250,9 +1406,33 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 		registry.GetGlobalRegistry().SuspendClientModel(result.AuthID, result.Model, suspendReason)
 	}
 
+	metrics.ObserveResult(result.Provider, result.Model, result.Success, statusCodeFromResult(result.Error))
+	if tracker, ok := m.loadAwareSelector(); ok {
+		tracker.Observe(result)
+	}
+
 	m.hook.OnResult(ctx, result)
 }
 
+func (m *Manager) loadAwareSelector() (LoadAwareSelector, bool) {
+	m.mu.RLock()
+	selector := m.selector
+	m.mu.RUnlock()
+	tracker, ok := selector.(LoadAwareSelector)
+	return tracker, ok && tracker != nil
+}
+
+// acquireLoad reports an execution start to load-aware selectors and returns the matching release func.
+func (m *Manager) acquireLoad(authID string) func() {
+	tracker, ok := m.loadAwareSelector()
+	if !ok {
+		return func() {}
+	}
+	tracker.Acquire(authID)
+	var once sync.Once
+	return func() { once.Do(func() { tracker.Release(authID) }) }
+}
+
 func ensureModelState(auth *Auth, model string) *ModelState {
 	if auth == nil || model == "" {
 		return nil
@@ -1561,7 +1741,7 @@ func (m *Manager) pickNext(ctx context.Context, provider, model string, opts cli
 		m.mu.RUnlock()
 		return nil, nil, &Error{Code: "auth_not_found", Message: "no auth available"}
 	}
-	selected, errPick := m.selector.Pick(ctx, provider, model, opts, candidates)
+	selected, errPick := m.pickWithAffinity(ctx, provider, model, opts, candidates)
 	if errPick != nil {
 		m.mu.RUnlock()
 		return nil, nil, errPick
@@ -1583,7 +1763,14 @@ func (m *Manager) pickNext(ctx context.Context, provider, model string, opts cli
 	return authCopy, executor, nil
 }
 
-func (m *Manager) pickNextMixed(ctx context.Context, providers []string, model string, opts cliproxyexecutor.Options, tried map[string]struct{}) (*Auth, ProviderExecutor, string, error) {
+func (m *Manager) pickNextMixed(ctx context.Context, providers []string, model string, opts cliproxyexecutor.Options, tried map[string]struct{}) (picked *Auth, _ ProviderExecutor, _ string, errPicked error) {
+	ctx, span := tracing.Start(ctx, "cliproxy.auth.select", tracing.AttrModel.String(model))
+	defer func() {
+		if picked != nil {
+			span.SetAttributes(tracing.AttrAuthIndex.String(picked.Index), tracing.AttrProvider.String(picked.Provider))
+		}
+		tracing.End(span, errPicked)
+	}()
 	providerSet := make(map[string]struct{}, len(providers))
 	for _, provider := range providers {
 		p := strings.TrimSpace(strings.ToLower(provider))
@@ -1629,11 +1816,12 @@ func (m *Manager) pickNextMixed(ctx context.Context, providers []string, model s
 		}
 		candidates = append(candidates, candidate)
 	}
+	span.SetAttributes(tracing.AttrCandidates.Int(len(candidates)))
 	if len(candidates) == 0 {
 		m.mu.RUnlock()
 		return nil, nil, "", &Error{Code: "auth_not_found", Message: "no auth available"}
 	}
-	selected, errPick := m.selector.Pick(ctx, "mixed", model, opts, candidates)
+	selected, errPick := m.pickWithAffinity(ctx, "mixed", model, opts, candidates)
 	if errPick != nil {
 		m.mu.RUnlock()
 		return nil, nil, "", errPick
@@ -1986,6 +2174,18 @@ func (m *Manager) refreshAuth(ctx context.Context, id string) {
 	if auth == nil || exec == nil {
 		return
 	}
+	if coordinator, ok := m.refreshCoordinator(); ok {
+		release, acquired, errLease := coordinator.AcquireRefresh(ctx, auth.Clone())
+		if errLease != nil {
+			log.Warnf("refresh lease for %s, %s failed: %v", auth.Provider, auth.ID, errLease)
+			return
+		}
+		if !acquired {
+			log.Debugf("refresh of %s, %s handled by another instance", auth.Provider, auth.ID)
+			return
+		}
+		defer release()
+	}
 	cloned := auth.Clone()
 	updated, err := exec.Refresh(ctx, cloned)
 	if err != nil && errors.Is(err, context.Canceled) {
@@ -2021,6 +2221,15 @@ func (m *Manager) refreshAuth(ctx context.Context, id string) {
 	_, _ = m.Update(ctx, updated)
 }
 
+// refreshCoordinator returns the store when it coordinates refreshes between processes.
+func (m *Manager) refreshCoordinator() (RefreshCoordinator, bool) {
+	m.mu.RLock()
+	store := m.store
+	m.mu.RUnlock()
+	coordinator, ok := store.(RefreshCoordinator)
+	return coordinator, ok && coordinator != nil
+}
+
 func (m *Manager) executorFor(provider string) ProviderExecutor {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -2038,7 +2247,11 @@ func (m *Manager) roundTripperFor(auth *Auth) http.RoundTripper {
 	if p == nil || auth == nil {
 		return nil
 	}
-	return p.RoundTripperFor(auth)
+	rt := p.RoundTripperFor(auth)
+	if rt == nil {
+		return nil
+	}
+	return tracing.Transport(rt)
 }
 
 // RoundTripperProvider defines a minimal provider of per-auth HTTP transports.
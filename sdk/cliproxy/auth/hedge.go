@@ -0,0 +1,196 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/metrics"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+// defaultHedgeDelay is how long the first attempt runs before a hedge is issued.
+const defaultHedgeDelay = 2 * time.Second
+
+type hedgeGroupKey struct{}
+
+// hedgeGroup tracks the credentials claimed by the attempts of one hedged request so the
+// first attempt and the hedge never run on the same credential.
+type hedgeGroup struct {
+	mu      sync.Mutex
+	claimed map[string]struct{}
+}
+
+func hedgeGroupFromContext(ctx context.Context) *hedgeGroup {
+	if ctx == nil {
+		return nil
+	}
+	group, _ := ctx.Value(hedgeGroupKey{}).(*hedgeGroup)
+	return group
+}
+
+// claim records that an attempt of the group picked the auth.
+func (g *hedgeGroup) claim(authID string) {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	g.claimed[authID] = struct{}{}
+	g.mu.Unlock()
+}
+
+// exclude adds the auths claimed by other attempts of the group to tried.
+func (g *hedgeGroup) exclude(tried map[string]struct{}) {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	for id := range g.claimed {
+		tried[id] = struct{}{}
+	}
+	g.mu.Unlock()
+}
+
+type executeOnceFunc func(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error)
+
+// hedgeDelay returns how long to wait before hedging a request for model, or zero when the
+// request must not be hedged. Only models listed in the config are hedged, so long
+// generations never double their upstream spend by accident.
+func (m *Manager) hedgeDelay(model string) time.Duration {
+	cfg, _ := m.runtimeConfig.Load().(*internalconfig.Config)
+	if cfg == nil || !cfg.Hedging.Enabled {
+		return 0
+	}
+	matched := false
+	for _, pattern := range cfg.Hedging.Models {
+		if matchHedgeModel(strings.TrimSpace(pattern), model) {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return 0
+	}
+	if cfg.Hedging.DelayMs > 0 {
+		return time.Duration(cfg.Hedging.DelayMs) * time.Millisecond
+	}
+	return defaultHedgeDelay
+}
+
+// executeHedged runs once and, if it has not finished after the hedge delay, runs it a second
+// time on a different credential. The first success is returned and the other attempt is
+// cancelled with ErrHedgeLost; cancelled attempts are not recorded against their credential.
+func (m *Manager) executeHedged(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options, once executeOnceFunc) (cliproxyexecutor.Response, error) {
+	delay := m.hedgeDelay(req.Model)
+	if delay <= 0 {
+		return once(ctx, providers, req, opts)
+	}
+
+	type outcome struct {
+		resp  cliproxyexecutor.Response
+		err   error
+		hedge bool
+		meta  map[string]any
+	}
+	results := make(chan outcome, 2)
+	groupCtx := context.WithValue(ctx, hedgeGroupKey{}, &hedgeGroup{claimed: make(map[string]struct{})})
+	// Each attempt works on its own copy of the metadata: the caller writes into opts.Metadata
+	// once the winner returns, while the losing attempt may still be reading it.
+	run := func(runCtx context.Context, hedge bool) {
+		attemptOpts := opts
+		attemptOpts.Metadata = cloneHedgeMetadata(opts.Metadata)
+		resp, err := once(runCtx, providers, req, attemptOpts)
+		results <- outcome{resp: resp, err: err, hedge: hedge, meta: attemptOpts.Metadata}
+	}
+
+	primaryCtx, cancelPrimary := context.WithCancelCause(groupCtx)
+	defer cancelPrimary(cliproxyexecutor.ErrHedgeLost)
+	go run(primaryCtx, false)
+
+	hedgeCtx, cancelHedge := context.WithCancelCause(cliproxyexecutor.WithHedge(groupCtx))
+	defer cancelHedge(cliproxyexecutor.ErrHedgeLost)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	pending, hedged := 1, false
+	var primaryErr, hedgeErr error
+	for {
+		select {
+		case <-timer.C:
+			pending++
+			hedged = true
+			go run(hedgeCtx, true)
+		case out := <-results:
+			pending--
+			if out.err == nil {
+				if hedged {
+					winner := "primary"
+					if out.hedge {
+						winner = "hedge"
+					}
+					metrics.ObserveHedge(req.Model, winner)
+					logEntryWithRequestID(ctx).Debugf("hedged request for model %s won by %s attempt", req.Model, winner)
+				}
+				if served, ok := out.meta[cliproxyexecutor.ServedModelMetadataKey].(string); ok {
+					recordServedModel(opts.Metadata, served)
+				}
+				return out.resp, nil
+			}
+			if out.hedge {
+				hedgeErr = out.err
+			} else {
+				primaryErr = out.err
+			}
+			if pending > 0 {
+				continue
+			}
+			if hedged {
+				metrics.ObserveHedge(req.Model, "none")
+			}
+			if primaryErr != nil {
+				return cliproxyexecutor.Response{}, primaryErr
+			}
+			return cliproxyexecutor.Response{}, hedgeErr
+		case <-ctx.Done():
+			return cliproxyexecutor.Response{}, ctx.Err()
+		}
+	}
+}
+
+// cloneHedgeMetadata returns a shallow copy of meta for one hedge attempt.
+func cloneHedgeMetadata(meta map[string]any) map[string]any {
+	if meta == nil {
+		return nil
+	}
+	out := make(map[string]any, len(meta))
+	for k, v := range meta {
+		out[k] = v
+	}
+	return out
+}
+
+// matchHedgeModel performs case-insensitive wildcard matching where '*' matches any substring.
+func matchHedgeModel(pattern, model string) bool {
+	pattern, model = strings.ToLower(pattern), strings.ToLower(model)
+	if pattern == "" {
+		return false
+	}
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == model
+	}
+	if !strings.HasPrefix(model, parts[0]) {
+		return false
+	}
+	model = model[len(parts[0]):]
+	for _, segment := range parts[1 : len(parts)-1] {
+		idx := strings.Index(model, segment)
+		if idx < 0 {
+			return false
+		}
+		model = model[idx+len(segment):]
+	}
+	return strings.HasSuffix(model, parts[len(parts)-1])
+}
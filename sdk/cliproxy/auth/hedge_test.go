@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+type hedgeTestExecutor struct {
+	mu     sync.Mutex
+	hedged map[string]bool
+	causes map[string]error
+}
+
+func (e *hedgeTestExecutor) Identifier() string { return "claude" }
+
+func (e *hedgeTestExecutor) Execute(ctx context.Context, auth *Auth, _ cliproxyexecutor.Request, _ cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	e.mu.Lock()
+	e.hedged[auth.ID] = cliproxyexecutor.IsHedge(ctx)
+	e.mu.Unlock()
+	if auth.ID == "hedge-a-slow" {
+		<-ctx.Done()
+		e.mu.Lock()
+		e.causes[auth.ID] = context.Cause(ctx)
+		e.mu.Unlock()
+		return cliproxyexecutor.Response{}, tracingTestStatusError{code: http.StatusGatewayTimeout}
+	}
+	return cliproxyexecutor.Response{Payload: []byte(auth.ID)}, nil
+}
+
+func (e *hedgeTestExecutor) ExecuteStream(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	return nil, tracingTestStatusError{code: http.StatusNotImplemented}
+}
+
+func (e *hedgeTestExecutor) Refresh(_ context.Context, auth *Auth) (*Auth, error) { return auth, nil }
+
+func (e *hedgeTestExecutor) CountTokens(ctx context.Context, auth *Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return e.Execute(ctx, auth, req, opts)
+}
+
+func (e *hedgeTestExecutor) HttpRequest(context.Context, *Auth, *http.Request) (*http.Response, error) {
+	return nil, nil
+}
+
+func TestManagerExecute_HedgesSlowAttempt(t *testing.T) {
+	exec := &hedgeTestExecutor{hedged: map[string]bool{}, causes: map[string]error{}}
+	m := NewManager(nil, &FillFirstSelector{}, nil)
+	m.RegisterExecutor(exec)
+	m.SetConfig(&internalconfig.Config{Hedging: internalconfig.HedgingConfig{
+		Enabled: true,
+		DelayMs: 20,
+		Models:  []string{"claude-*-haiku"},
+	}})
+	reg := registry.GetGlobalRegistry()
+	for _, id := range []string{"hedge-a-slow", "hedge-b-fast"} {
+		if _, err := m.Register(context.Background(), &Auth{ID: id, Provider: "claude"}); err != nil {
+			t.Fatalf("register %s: %v", id, err)
+		}
+		reg.RegisterClient(id, "claude", []*registry.ModelInfo{{ID: "claude-3-5-haiku"}})
+		authID := id
+		t.Cleanup(func() { reg.UnregisterClient(authID) })
+	}
+
+	req := cliproxyexecutor.Request{Model: "claude-3-5-haiku"}
+	resp, err := m.ExecuteCount(context.Background(), []string{"claude"}, req, cliproxyexecutor.Options{})
+	if err != nil {
+		t.Fatalf("ExecuteCount() error = %v", err)
+	}
+	if string(resp.Payload) != "hedge-b-fast" {
+		t.Fatalf("payload = %q, want the hedge response", resp.Payload)
+	}
+
+	exec.mu.Lock()
+	hedged := exec.hedged
+	exec.mu.Unlock()
+	if hedged["hedge-a-slow"] || !hedged["hedge-b-fast"] {
+		t.Fatalf("unexpected hedge flags %v", hedged)
+	}
+
+	// The loser is cancelled asynchronously; its result must not count against the auth.
+	var cause error
+	for i := 0; i < 100 && cause == nil; i++ {
+		time.Sleep(10 * time.Millisecond)
+		exec.mu.Lock()
+		cause = exec.causes["hedge-a-slow"]
+		exec.mu.Unlock()
+	}
+	if !errors.Is(cause, cliproxyexecutor.ErrHedgeLost) {
+		t.Fatalf("loser cancel cause = %v, want ErrHedgeLost", cause)
+	}
+	time.Sleep(20 * time.Millisecond)
+	slow, _ := m.GetByID("hedge-a-slow")
+	if slow.LastError != nil || len(slow.ModelStates) != 0 {
+		t.Fatalf("cancelled attempt was recorded against its auth: %+v", slow)
+	}
+}
+
+// metadataReadingExecutor keeps reading the request metadata on the slow credential until it
+// is cancelled, like selection and payload helpers do while an attempt is in flight.
+type metadataReadingExecutor struct {
+	hedgeTestExecutor
+	done chan struct{}
+}
+
+func (e *metadataReadingExecutor) Execute(ctx context.Context, auth *Auth, _ cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	if auth.ID != "hedge-meta-a-slow" {
+		return cliproxyexecutor.Response{Payload: []byte(auth.ID)}, nil
+	}
+	defer close(e.done)
+	ticker := time.NewTicker(time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return cliproxyexecutor.Response{}, context.Cause(ctx)
+		case <-ticker.C:
+			for range opts.Metadata {
+			}
+		}
+	}
+}
+
+func TestManagerExecute_HedgeAttemptsDoNotShareMetadata(t *testing.T) {
+	exec := &metadataReadingExecutor{done: make(chan struct{})}
+	m := NewManager(nil, &FillFirstSelector{}, nil)
+	m.RegisterExecutor(exec)
+	m.SetConfig(&internalconfig.Config{Hedging: internalconfig.HedgingConfig{
+		Enabled: true,
+		DelayMs: 5,
+		Models:  []string{"claude-3-5-haiku"},
+	}})
+	reg := registry.GetGlobalRegistry()
+	for _, id := range []string{"hedge-meta-a-slow", "hedge-meta-b-fast"} {
+		if _, err := m.Register(context.Background(), &Auth{ID: id, Provider: "claude"}); err != nil {
+			t.Fatalf("register %s: %v", id, err)
+		}
+		reg.RegisterClient(id, "claude", []*registry.ModelInfo{{ID: "claude-3-5-haiku"}})
+		authID := id
+		t.Cleanup(func() { reg.UnregisterClient(authID) })
+	}
+
+	meta := map[string]any{cliproxyexecutor.RequestedModelMetadataKey: "claude-3-5-haiku"}
+	resp, err := m.Execute(context.Background(), []string{"claude"}, cliproxyexecutor.Request{Model: "claude-3-5-haiku"}, cliproxyexecutor.Options{Metadata: meta})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if string(resp.Payload) != "hedge-meta-b-fast" {
+		t.Fatalf("payload = %q, want the hedge response", resp.Payload)
+	}
+	if served := meta[cliproxyexecutor.ServedModelMetadataKey]; served != "claude-3-5-haiku" {
+		t.Fatalf("served model = %v, want it copied back from the winner", served)
+	}
+	select {
+	case <-exec.done:
+	case <-time.After(time.Second):
+		t.Fatal("losing attempt was not cancelled")
+	}
+}
+
+func TestHedgeDelay_RequiresListedModel(t *testing.T) {
+	m := NewManager(nil, nil, nil)
+	m.SetConfig(&internalconfig.Config{Hedging: internalconfig.HedgingConfig{Enabled: true}})
+	if delay := m.hedgeDelay("claude-opus-4"); delay != 0 {
+		t.Fatalf("hedgeDelay() = %v with no models listed, want hedging off", delay)
+	}
+	m.SetConfig(&internalconfig.Config{Hedging: internalconfig.HedgingConfig{Enabled: true, Models: []string{"*-haiku"}}})
+	if delay := m.hedgeDelay("claude-opus-4"); delay != 0 {
+		t.Fatalf("hedgeDelay() = %v for an unlisted model, want 0", delay)
+	}
+	if delay := m.hedgeDelay("claude-3-5-haiku"); delay != defaultHedgeDelay {
+		t.Fatalf("hedgeDelay() = %v for a listed model, want %v", delay, defaultHedgeDelay)
+	}
+}
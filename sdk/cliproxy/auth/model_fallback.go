@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// modelFallbacksFor returns the configured fallback chain for the requested model.
+// A key matching the full requested model wins over one matching the model without its
+// thinking suffix. Keys are lower-cased at config load, so the lookup ignores case; when a
+// fallback has no suffix of its own, the suffix of the requested model is carried over.
+func (m *Manager) modelFallbacksFor(model string) []string {
+	cfg, _ := m.runtimeConfig.Load().(*internalconfig.Config)
+	if cfg == nil || len(cfg.ModelFallbacks) == 0 {
+		return nil
+	}
+	parsed := thinking.ParseSuffix(strings.TrimSpace(model))
+	base := strings.TrimSpace(parsed.ModelName)
+	if base == "" {
+		return nil
+	}
+	var chain []string
+	for _, key := range []string{model, base} {
+		if entries, ok := cfg.ModelFallbacks[strings.ToLower(strings.TrimSpace(key))]; ok {
+			chain = entries
+			break
+		}
+	}
+	out := make([]string, 0, len(chain))
+	seen := map[string]struct{}{strings.ToLower(base): {}}
+	for _, entry := range chain {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key := strings.ToLower(thinking.ParseSuffix(entry).ModelName)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		if parsed.HasSuffix && !thinking.ParseSuffix(entry).HasSuffix {
+			entry = entry + "(" + parsed.RawSuffix + ")"
+		}
+		out = append(out, entry)
+	}
+	return out
+}
+
+// executeWithFallbacks runs exec for the requested model and then, while the latest
+// failure is fallback-eligible, for each configured fallback model in order.
+// Executors translate from opts.SourceFormat themselves, so a fallback served by a
+// provider with a different request format is re-translated automatically.
+// The model that finally served the request is recorded in opts.Metadata under
+// cliproxyexecutor.ServedModelMetadataKey. When every model fails, the error of the
+// requested model is returned.
+func (m *Manager) executeWithFallbacks(providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options, exec func([]string, cliproxyexecutor.Request, cliproxyexecutor.Options) error) error {
+	errPrimary := exec(providers, req, opts)
+	if errPrimary == nil {
+		recordServedModel(opts.Metadata, req.Model)
+		return nil
+	}
+	errLast := errPrimary
+	for _, fallback := range m.modelFallbacksFor(req.Model) {
+		if !isFallbackEligible(errLast) {
+			break
+		}
+		fallbackProviders := m.normalizeProviders(util.GetProviderName(thinking.ParseSuffix(fallback).ModelName))
+		if len(fallbackProviders) == 0 {
+			log.Debugf("model fallback: skipping %s, no provider serves it", fallback)
+			continue
+		}
+		log.Debugf("model fallback: %s failed (%v), trying %s", req.Model, errLast, fallback)
+		fallbackReq, fallbackOpts := fallbackRequest(req, opts, fallback)
+		errLast = exec(fallbackProviders, fallbackReq, fallbackOpts)
+		if errLast == nil {
+			recordServedModel(opts.Metadata, fallback)
+			return nil
+		}
+	}
+	return errPrimary
+}
+
+// fallbackRequest rewrites the model of req and opts for a fallback attempt.
+func fallbackRequest(req cliproxyexecutor.Request, opts cliproxyexecutor.Options, model string) (cliproxyexecutor.Request, cliproxyexecutor.Options) {
+	req.Model = model
+	if len(req.Payload) > 0 && gjson.GetBytes(req.Payload, "model").Exists() {
+		if updated, errSet := sjson.SetBytes(req.Payload, "model", model); errSet == nil {
+			req.Payload = updated
+		}
+	}
+	meta := make(map[string]any, len(opts.Metadata)+1)
+	for k, v := range opts.Metadata {
+		meta[k] = v
+	}
+	meta[cliproxyexecutor.RequestedModelMetadataKey] = model
+	opts.Metadata = meta
+	return req, opts
+}
+
+func recordServedModel(meta map[string]any, model string) {
+	if meta != nil {
+		meta[cliproxyexecutor.ServedModelMetadataKey] = model
+	}
+}
+
+// isFallbackEligible reports whether err suggests another model could succeed:
+// no usable credential, credential-level rejections, rate limits and upstream failures.
+func isFallbackEligible(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var authErr *Error
+	if errors.As(err, &authErr) && authErr != nil && authErr.HTTPStatus == 0 {
+		return authErr.Code != "provider_not_found"
+	}
+	status := 0
+	var se cliproxyexecutor.StatusError
+	if errors.As(err, &se) && se != nil {
+		status = se.StatusCode()
+	}
+	switch status {
+	case 0, http.StatusUnauthorized, http.StatusPaymentRequired, http.StatusForbidden,
+		http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return true
+	default:
+		return status >= http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/tidwall/gjson"
+)
+
+type fallbackTestExecutor struct {
+	provider string
+	status   int
+	payloads []string
+}
+
+func (e *fallbackTestExecutor) Identifier() string { return e.provider }
+
+func (e *fallbackTestExecutor) Execute(_ context.Context, _ *Auth, req cliproxyexecutor.Request, _ cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	e.payloads = append(e.payloads, gjson.GetBytes(req.Payload, "model").String())
+	if e.status > 0 {
+		return cliproxyexecutor.Response{}, tracingTestStatusError{code: e.status}
+	}
+	return cliproxyexecutor.Response{Payload: []byte(e.provider)}, nil
+}
+
+func (e *fallbackTestExecutor) ExecuteStream(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	return nil, tracingTestStatusError{code: http.StatusNotImplemented}
+}
+
+func (e *fallbackTestExecutor) Refresh(_ context.Context, auth *Auth) (*Auth, error) {
+	return auth, nil
+}
+
+func (e *fallbackTestExecutor) CountTokens(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{}, nil
+}
+
+func (e *fallbackTestExecutor) HttpRequest(context.Context, *Auth, *http.Request) (*http.Response, error) {
+	return nil, nil
+}
+
+func newFallbackTestManager(t *testing.T, primaryStatus int) (*Manager, *fallbackTestExecutor, *fallbackTestExecutor) {
+	t.Helper()
+	m := NewManager(nil, nil, nil)
+	m.SetConfig(&internalconfig.Config{ModelFallbacks: map[string][]string{
+		"primary-model": {"missing-model", "backup-model"},
+	}})
+	primary := &fallbackTestExecutor{provider: "claude", status: primaryStatus}
+	backup := &fallbackTestExecutor{provider: "gemini"}
+	m.RegisterExecutor(primary)
+	m.RegisterExecutor(backup)
+
+	reg := registry.GetGlobalRegistry()
+	for _, entry := range []struct{ id, provider, model string }{
+		{"fallback-primary", "claude", "primary-model"},
+		{"fallback-backup", "gemini", "backup-model"},
+	} {
+		if _, err := m.Register(context.Background(), &Auth{ID: entry.id, Provider: entry.provider}); err != nil {
+			t.Fatalf("register %s: %v", entry.id, err)
+		}
+		reg.RegisterClient(entry.id, entry.provider, []*registry.ModelInfo{{ID: entry.model}})
+		id := entry.id
+		t.Cleanup(func() { reg.UnregisterClient(id) })
+	}
+	return m, primary, backup
+}
+
+func TestManagerExecute_WalksModelFallbacks(t *testing.T) {
+	m, primary, backup := newFallbackTestManager(t, http.StatusServiceUnavailable)
+
+	meta := map[string]any{}
+	req := cliproxyexecutor.Request{Model: "primary-model", Payload: []byte(`{"model":"primary-model"}`)}
+	resp, err := m.Execute(context.Background(), []string{"claude"}, req, cliproxyexecutor.Options{Metadata: meta})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if string(resp.Payload) != "gemini" {
+		t.Fatalf("payload = %q, want response from fallback provider", resp.Payload)
+	}
+	if got := meta[cliproxyexecutor.ServedModelMetadataKey]; got != "backup-model" {
+		t.Fatalf("served model = %v, want backup-model", got)
+	}
+	if len(primary.payloads) != 1 || len(backup.payloads) != 1 || backup.payloads[0] != "backup-model" {
+		t.Fatalf("payload models primary=%v backup=%v", primary.payloads, backup.payloads)
+	}
+}
+
+func TestManagerExecute_SkipsFallbacksForClientErrors(t *testing.T) {
+	m, _, backup := newFallbackTestManager(t, http.StatusBadRequest)
+
+	req := cliproxyexecutor.Request{Model: "primary-model"}
+	if _, err := m.Execute(context.Background(), []string{"claude"}, req, cliproxyexecutor.Options{}); err == nil {
+		t.Fatal("Execute() error = nil, want upstream 400")
+	}
+	if len(backup.payloads) != 0 {
+		t.Fatalf("fallback executed %d times, want 0", len(backup.payloads))
+	}
+}
+
+func TestModelFallbacksFor_PrefersExactModelKey(t *testing.T) {
+	cfg := &internalconfig.Config{ModelFallbacks: map[string][]string{
+		"Base-Model":       {"upper-fallback"},
+		"base-model":       {"base-fallback"},
+		"base-model(high)": {"high-fallback"},
+	}}
+	cfg.SanitizeModelFallbacks()
+	m := NewManager(nil, nil, nil)
+	m.SetConfig(cfg)
+
+	for model, want := range map[string]string{
+		"base-model(high)": "high-fallback(high)",
+		"BASE-MODEL(low)":  "upper-fallback(low)",
+		"base-model":       "upper-fallback",
+	} {
+		if got := m.modelFallbacksFor(model); len(got) != 1 || got[0] != want {
+			t.Errorf("modelFallbacksFor(%q) = %v, want [%s]", model, got, want)
+		}
+	}
+}
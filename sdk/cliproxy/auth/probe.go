@@ -0,0 +1,437 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultProbeInterval = 30 * time.Minute
+	defaultProbeTimeout  = 30 * time.Second
+	// probeConcurrency bounds the number of probe requests in flight during a probe round.
+	probeConcurrency = 4
+)
+
+// probeSkipModelMarkers excludes registered models that cannot answer a chat completion.
+var probeSkipModelMarkers = []string{"embed", "image", "imagen", "veo", "tts", "audio"}
+
+// ProbeResult is the outcome of a synthetic probe request sent with one credential.
+type ProbeResult struct {
+	// AuthID identifies the probed credential.
+	AuthID string `json:"auth_id"`
+	// Provider is the provider of the credential.
+	Provider string `json:"provider"`
+	// Model is the model the probe was sent to.
+	Model string `json:"model,omitempty"`
+	// Success reports whether the upstream accepted the request.
+	Success bool `json:"success"`
+	// StatusCode is the upstream HTTP status, when one was received.
+	StatusCode int `json:"status_code,omitempty"`
+	// LatencyMs is the round trip time of the probe in milliseconds.
+	LatencyMs int64 `json:"latency_ms"`
+	// Error describes why the probe failed.
+	Error string `json:"error,omitempty"`
+	// Quota holds the rate limit and quota headers returned by the upstream.
+	Quota map[string]string `json:"quota,omitempty"`
+	// Disabled reports whether the credential was disabled because of this probe.
+	Disabled bool `json:"disabled,omitempty"`
+	// CheckedAt is when the probe finished.
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// ProbeRound describes a probe of every enabled credential started with StartProbeAll.
+type ProbeRound struct {
+	// Running reports whether the round is still in progress.
+	Running bool `json:"running"`
+	// StartedAt is when the round started.
+	StartedAt time.Time `json:"started_at"`
+	// FinishedAt is when the round finished; zero while it is running.
+	FinishedAt time.Time `json:"finished_at"`
+	// Results holds the probe results once the round finished.
+	Results []ProbeResult `json:"results,omitempty"`
+}
+
+// ProbeResults returns the results of the last probe of the auth identified by id.
+func (m *Manager) ProbeResults(id string) []ProbeResult {
+	m.probeMu.Lock()
+	defer m.probeMu.Unlock()
+	results := m.probeResults[id]
+	if len(results) == 0 {
+		return nil
+	}
+	out := make([]ProbeResult, len(results))
+	copy(out, results)
+	return out
+}
+
+// ProbeAuth sends a minimal chat request through the executor of the auth identified by id, once
+// per model. Without models the probe model configured for the provider, or else the first chat
+// model registered for the auth, is used. Results are recorded like regular request results, so
+// failing credentials are cooled down, and are kept for ProbeResults.
+func (m *Manager) ProbeAuth(ctx context.Context, id string, models ...string) ([]ProbeResult, error) {
+	auth, ok := m.GetByID(id)
+	if !ok || auth == nil {
+		return nil, &Error{Code: "auth_not_found", Message: fmt.Sprintf("auth %s not found", id), HTTPStatus: http.StatusNotFound}
+	}
+	if auth.Disabled {
+		return nil, &Error{Code: "auth_disabled", Message: fmt.Sprintf("auth %s is disabled", id), HTTPStatus: http.StatusConflict}
+	}
+	if len(models) == 0 {
+		if model := m.probeModel(auth); model != "" {
+			models = []string{model}
+		}
+	}
+	if len(models) == 0 {
+		return nil, &Error{Code: "model_not_found", Message: fmt.Sprintf("no probe model available for auth %s", id), HTTPStatus: http.StatusBadRequest}
+	}
+	results := make([]ProbeResult, 0, len(models))
+	for _, model := range models {
+		results = append(results, m.probeOnce(ctx, auth, model))
+	}
+	m.probeMu.Lock()
+	if m.probeResults == nil {
+		m.probeResults = make(map[string][]ProbeResult)
+	}
+	m.probeResults[id] = results
+	m.probeMu.Unlock()
+	return results, nil
+}
+
+// ProbeAll probes every enabled auth and returns the results.
+func (m *Manager) ProbeAll(ctx context.Context) []ProbeResult {
+	auths := m.snapshotAuths()
+	sort.Slice(auths, func(i, j int) bool { return auths[i].ID < auths[j].ID })
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results []ProbeResult
+	)
+	sem := make(chan struct{}, probeConcurrency)
+	for _, auth := range auths {
+		if auth == nil || auth.Disabled {
+			continue
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(id string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			probed, err := m.ProbeAuth(ctx, id)
+			if err != nil {
+				log.Debugf("auth probe: skipped %s: %v", id, err)
+				return
+			}
+			mu.Lock()
+			results = append(results, probed...)
+			mu.Unlock()
+		}(auth.ID)
+	}
+	wg.Wait()
+	m.forgetProbeResults()
+	return results
+}
+
+// StartProbeAll runs ProbeAll in the background unless a round started this way is still running.
+// It returns the state of the running round and whether it was started by this call.
+func (m *Manager) StartProbeAll() (ProbeRound, bool) {
+	m.probeMu.Lock()
+	defer m.probeMu.Unlock()
+	if m.probeRound.Running {
+		return m.probeRound, false
+	}
+	m.probeRound = ProbeRound{Running: true, StartedAt: time.Now()}
+	go func() {
+		results := m.ProbeAll(context.Background())
+		m.probeMu.Lock()
+		m.probeRound = ProbeRound{StartedAt: m.probeRound.StartedAt, FinishedAt: time.Now(), Results: results}
+		m.probeMu.Unlock()
+	}()
+	return m.probeRound, true
+}
+
+// ProbeRoundStatus returns the state of the last round started with StartProbeAll.
+func (m *Manager) ProbeRoundStatus() ProbeRound {
+	m.probeMu.Lock()
+	defer m.probeMu.Unlock()
+	round := m.probeRound
+	round.Results = append([]ProbeResult(nil), round.Results...)
+	return round
+}
+
+// StartProber runs ProbeAll in the background while auth-probe is enabled in the config.
+// The config is re-read on every tick, so enabling or retuning the prober takes effect on reload.
+func (m *Manager) StartProber(parent context.Context) {
+	m.StopProber()
+	ctx, cancel := context.WithCancel(parent)
+	m.mu.Lock()
+	m.probeCancel = cancel
+	m.mu.Unlock()
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		var last time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			cfg := m.probeConfig()
+			if !cfg.Enabled || time.Since(last) < probeInterval(cfg) {
+				continue
+			}
+			last = time.Now()
+			results := m.ProbeAll(ctx)
+			failed := 0
+			for _, r := range results {
+				if !r.Success {
+					failed++
+				}
+			}
+			log.Infof("auth probe: %d probes, %d failed", len(results), failed)
+		}
+	}()
+}
+
+// StopProber cancels the background prober, if running.
+func (m *Manager) StopProber() {
+	m.mu.Lock()
+	cancel := m.probeCancel
+	m.probeCancel = nil
+	m.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+func (m *Manager) probeConfig() internalconfig.AuthProbeConfig {
+	cfg, _ := m.runtimeConfig.Load().(*internalconfig.Config)
+	if cfg == nil {
+		return internalconfig.AuthProbeConfig{}
+	}
+	return cfg.AuthProbe
+}
+
+func probeInterval(cfg internalconfig.AuthProbeConfig) time.Duration {
+	if cfg.IntervalSeconds > 0 {
+		return time.Duration(cfg.IntervalSeconds) * time.Second
+	}
+	return defaultProbeInterval
+}
+
+// probeModel picks the model the auth is probed with.
+func (m *Manager) probeModel(auth *Auth) string {
+	provider := strings.ToLower(strings.TrimSpace(auth.Provider))
+	for key, model := range m.probeConfig().Models {
+		if strings.EqualFold(strings.TrimSpace(key), provider) && strings.TrimSpace(model) != "" {
+			return strings.TrimSpace(model)
+		}
+	}
+	infos := registry.GetGlobalRegistry().GetModelsForClient(auth.ID)
+	ids := make([]string, 0, len(infos))
+	for _, info := range infos {
+		if info == nil || info.ID == "" {
+			continue
+		}
+		lower := strings.ToLower(info.ID)
+		skip := false
+		for _, marker := range probeSkipModelMarkers {
+			if strings.Contains(lower, marker) {
+				skip = true
+				break
+			}
+		}
+		if !skip {
+			ids = append(ids, info.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return ""
+	}
+	sort.Strings(ids)
+	return ids[0]
+}
+
+// probeOnce sends one probe request and records its outcome.
+func (m *Manager) probeOnce(ctx context.Context, auth *Auth, model string) ProbeResult {
+	provider := strings.ToLower(strings.TrimSpace(auth.Provider))
+	result := ProbeResult{AuthID: auth.ID, Provider: provider, Model: model}
+	executor := m.executorFor(provider)
+	if executor == nil {
+		result.Error = fmt.Sprintf("no executor registered for provider %s", provider)
+		result.CheckedAt = time.Now()
+		return result
+	}
+
+	timeout := defaultProbeTimeout
+	if seconds := m.probeConfig().TimeoutSeconds; seconds > 0 {
+		timeout = time.Duration(seconds) * time.Second
+	}
+	execCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if rt := m.roundTripperFor(auth); rt != nil {
+		execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
+		execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
+	}
+	execCtx = m.withUpstreamQuotaReporter(execCtx, auth.ID)
+	var (
+		observedMu      sync.Mutex
+		observedStatus  int
+		observedHeaders http.Header
+	)
+	execCtx = cliproxyexecutor.WithResponseObserver(execCtx, func(status int, headers http.Header) {
+		observedMu.Lock()
+		observedStatus, observedHeaders = status, headers.Clone()
+		observedMu.Unlock()
+	})
+
+	payload := probePayload(auth, model)
+	execModel := rewriteModelForAuth(model, auth)
+	execModel = m.applyOAuthModelAlias(auth, execModel)
+	execModel = m.applyAPIKeyModelAlias(auth, execModel)
+	req := cliproxyexecutor.Request{Model: execModel, Payload: payload}
+	opts := cliproxyexecutor.Options{
+		OriginalRequest: payload,
+		SourceFormat:    sdktranslator.FromString("openai"),
+		Metadata:        map[string]any{cliproxyexecutor.RequestedModelMetadataKey: model},
+	}
+
+	started := time.Now()
+	_, errExec := executor.Execute(execCtx, auth, req, opts)
+	result.LatencyMs = time.Since(started).Milliseconds()
+	result.CheckedAt = time.Now()
+
+	observedMu.Lock()
+	result.StatusCode = observedStatus
+	result.Quota = quotaHeaders(observedHeaders)
+	observedMu.Unlock()
+
+	outcome := Result{AuthID: auth.ID, Provider: provider, Model: model, Success: errExec == nil}
+	if errExec != nil {
+		if ctx.Err() != nil {
+			// The caller gave up; do not blame the credential.
+			result.Error = ctx.Err().Error()
+			return result
+		}
+		result.Error = errExec.Error()
+		outcome.Error = &Error{Message: errExec.Error()}
+		var se cliproxyexecutor.StatusError
+		if errors.As(errExec, &se) && se != nil {
+			result.StatusCode = se.StatusCode()
+		}
+		outcome.Error.HTTPStatus = result.StatusCode
+		outcome.RetryAfter = retryAfterFromError(errExec)
+	} else {
+		result.Success = true
+		if result.StatusCode == 0 {
+			result.StatusCode = http.StatusOK
+		}
+	}
+	m.MarkResult(ctx, outcome)
+
+	if !result.Success && result.StatusCode == http.StatusUnauthorized && m.probeConfig().DisableOnUnauthorized {
+		result.Disabled = m.disableAfterProbe(ctx, auth.ID, result.Error)
+	}
+	return result
+}
+
+// probePayload builds the minimal OpenAI chat request sent as a probe. OpenAI-compatible
+// upstreams receive the request as is, and OpenAI reasoning models reject max_tokens, so
+// they are limited with max_completion_tokens instead.
+func probePayload(auth *Auth, model string) []byte {
+	limitField := "max_tokens"
+	if isOpenAICompatAuth(auth) {
+		limitField = "max_completion_tokens"
+	}
+	payload, _ := json.Marshal(map[string]any{
+		"model":    model,
+		"messages": []map[string]string{{"role": "user", "content": "ping"}},
+		limitField: 1,
+		"stream":   false,
+	})
+	return payload
+}
+
+// isOpenAICompatAuth reports whether auth belongs to an openai-compatibility provider.
+func isOpenAICompatAuth(auth *Auth) bool {
+	if auth == nil {
+		return false
+	}
+	if auth.Attributes != nil && strings.TrimSpace(auth.Attributes["compat_name"]) != "" {
+		return true
+	}
+	return strings.EqualFold(strings.TrimSpace(auth.Provider), "openai-compatibility")
+}
+
+// disableAfterProbe disables the auth identified by id after its probe was rejected as unauthorized.
+func (m *Manager) disableAfterProbe(ctx context.Context, id, reason string) bool {
+	current, ok := m.GetByID(id)
+	if !ok || current == nil || current.Disabled {
+		return false
+	}
+	current.Disabled = true
+	current.Status = StatusDisabled
+	current.StatusMessage = "disabled by health probe: " + reason
+	current.UpdatedAt = time.Now()
+	if _, err := m.Update(ctx, current); err != nil {
+		log.Warnf("auth probe: failed to disable %s: %v", id, err)
+		return false
+	}
+	log.Warnf("auth probe: disabled %s after unauthorized probe", id)
+	return true
+}
+
+// forgetProbeResults drops results of auths that are no longer registered.
+func (m *Manager) forgetProbeResults() {
+	m.mu.RLock()
+	known := make(map[string]struct{}, len(m.auths))
+	for id := range m.auths {
+		known[id] = struct{}{}
+	}
+	m.mu.RUnlock()
+	m.probeMu.Lock()
+	defer m.probeMu.Unlock()
+	for id := range m.probeResults {
+		if _, ok := known[id]; !ok {
+			delete(m.probeResults, id)
+		}
+	}
+}
+
+// quotaHeaders keeps the rate limit and quota related response headers.
+func quotaHeaders(headers http.Header) map[string]string {
+	if len(headers) == 0 {
+		return nil
+	}
+	out := make(map[string]string)
+	for name, values := range headers {
+		lower := strings.ToLower(name)
+		if len(values) == 0 {
+			continue
+		}
+		if strings.Contains(lower, "ratelimit") || strings.Contains(lower, "rate-limit") || strings.Contains(lower, "quota") || lower == "retry-after" {
+			out[lower] = strings.Join(values, ", ")
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
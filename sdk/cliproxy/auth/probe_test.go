@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/tidwall/gjson"
+)
+
+type probeTestExecutor struct {
+	failures map[string]int
+	mu       sync.Mutex
+	models   []string
+}
+
+func (e *probeTestExecutor) Identifier() string { return "codex" }
+
+func (e *probeTestExecutor) Execute(ctx context.Context, auth *Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	e.mu.Lock()
+	e.models = append(e.models, req.Model)
+	e.mu.Unlock()
+	if gjson.GetBytes(opts.OriginalRequest, "max_tokens").Int() != 1 {
+		return cliproxyexecutor.Response{}, tracingTestStatusError{code: http.StatusBadRequest}
+	}
+	if code := e.failures[auth.ID]; code > 0 {
+		cliproxyexecutor.ObserveResponse(ctx, code, http.Header{})
+		return cliproxyexecutor.Response{}, tracingTestStatusError{code: code}
+	}
+	cliproxyexecutor.ObserveResponse(ctx, http.StatusOK, http.Header{
+		"X-Ratelimit-Remaining-Requests": []string{"42"},
+		"Content-Type":                   []string{"application/json"},
+	})
+	return cliproxyexecutor.Response{Payload: []byte(`{}`)}, nil
+}
+
+func (e *probeTestExecutor) ExecuteStream(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	return nil, tracingTestStatusError{code: http.StatusNotImplemented}
+}
+
+func (e *probeTestExecutor) Refresh(_ context.Context, auth *Auth) (*Auth, error) { return auth, nil }
+
+func (e *probeTestExecutor) CountTokens(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{}, nil
+}
+
+func (e *probeTestExecutor) HttpRequest(context.Context, *Auth, *http.Request) (*http.Response, error) {
+	return nil, nil
+}
+
+func TestManager_ProbeRecordsHealth(t *testing.T) {
+	exec := &probeTestExecutor{failures: map[string]int{"revoked": http.StatusUnauthorized}}
+	mgr := NewManager(nil, nil, nil)
+	mgr.RegisterExecutor(exec)
+	mgr.SetConfig(&internalconfig.Config{AuthProbe: internalconfig.AuthProbeConfig{
+		Models:                map[string]string{"codex": "gpt-5-codex-mini"},
+		DisableOnUnauthorized: true,
+	}})
+	ctx := context.Background()
+	for _, id := range []string{"healthy", "revoked"} {
+		if _, err := mgr.Register(ctx, &Auth{ID: id, Provider: "codex"}); err != nil {
+			t.Fatalf("Register %s: %v", id, err)
+		}
+	}
+
+	results := mgr.ProbeAll(ctx)
+	if len(results) != 2 {
+		t.Fatalf("expected two probe results, got %d", len(results))
+	}
+	if exec.models[0] != "gpt-5-codex-mini" {
+		t.Fatalf("expected configured probe model, got %q", exec.models[0])
+	}
+
+	healthy := mgr.ProbeResults("healthy")
+	if len(healthy) != 1 || !healthy[0].Success || healthy[0].StatusCode != http.StatusOK {
+		t.Fatalf("unexpected healthy probe %+v", healthy)
+	}
+	if healthy[0].Quota["x-ratelimit-remaining-requests"] != "42" || healthy[0].Quota["content-type"] != "" {
+		t.Fatalf("expected only quota headers, got %v", healthy[0].Quota)
+	}
+
+	revoked := mgr.ProbeResults("revoked")
+	if len(revoked) != 1 || revoked[0].Success || revoked[0].StatusCode != http.StatusUnauthorized || !revoked[0].Disabled {
+		t.Fatalf("unexpected revoked probe %+v", revoked)
+	}
+	auth, _ := mgr.GetByID("revoked")
+	if !auth.Disabled || auth.Status != StatusDisabled {
+		t.Fatalf("expected revoked auth to be disabled, got status %s", auth.Status)
+	}
+	if state := auth.ModelStates["gpt-5-codex-mini"]; state == nil || !state.Unavailable {
+		t.Fatalf("expected probed model to cool down, got %+v", state)
+	}
+
+	if _, err := mgr.ProbeAuth(ctx, "revoked"); err == nil {
+		t.Fatal("expected probing a disabled auth to fail")
+	}
+}
+
+func TestProbePayload_OpenAICompatUsesMaxCompletionTokens(t *testing.T) {
+	compat := &Auth{ID: "compat", Provider: "openrouter", Attributes: map[string]string{"compat_name": "openrouter"}}
+	payload := probePayload(compat, "o3-mini")
+	if gjson.GetBytes(payload, "max_tokens").Exists() || gjson.GetBytes(payload, "max_completion_tokens").Int() != 1 {
+		t.Fatalf("openai-compat probe must use max_completion_tokens, got %s", payload)
+	}
+	if payload = probePayload(&Auth{ID: "claude", Provider: "claude"}, "claude-3-5-haiku"); gjson.GetBytes(payload, "max_tokens").Int() != 1 {
+		t.Fatalf("translated probes must use max_tokens, got %s", payload)
+	}
+}
+
+func TestManager_StartProbeAllRunsInBackground(t *testing.T) {
+	exec := &probeTestExecutor{}
+	mgr := NewManager(nil, nil, nil)
+	mgr.RegisterExecutor(exec)
+	mgr.SetConfig(&internalconfig.Config{AuthProbe: internalconfig.AuthProbeConfig{Models: map[string]string{"codex": "gpt-5-codex-mini"}}})
+	if _, err := mgr.Register(context.Background(), &Auth{ID: "healthy", Provider: "codex"}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	round, started := mgr.StartProbeAll()
+	if !started || !round.Running || round.StartedAt.IsZero() {
+		t.Fatalf("expected a running round, got %+v (started %v)", round, started)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for round.Running && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+		round = mgr.ProbeRoundStatus()
+	}
+	if round.Running || round.FinishedAt.IsZero() || len(round.Results) != 1 || !round.Results[0].Success {
+		t.Fatalf("unexpected finished round %+v", round)
+	}
+}
@@ -0,0 +1,415 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// RuntimeState is the persisted runtime availability state of an auth.
+type RuntimeState struct {
+	// ID identifies the auth the state belongs to.
+	ID string `json:"id"`
+	// Status is the lifecycle status of the auth.
+	Status Status `json:"status"`
+	// StatusMessage holds a short description for the current status.
+	StatusMessage string `json:"status_message,omitempty"`
+	// Unavailable flags transient provider unavailability.
+	Unavailable bool `json:"unavailable"`
+	// Quota captures quota information of the auth.
+	Quota QuotaState `json:"quota"`
+	// LastError stores the last failure encountered by the auth.
+	LastError *Error `json:"last_error,omitempty"`
+	// NextRetryAfter is the earliest time the auth should be retried.
+	NextRetryAfter time.Time `json:"next_retry_after"`
+	// ModelStates tracks per-model availability.
+	ModelStates map[string]*ModelState `json:"model_states,omitempty"`
+	// UpdatedAt is when the state was captured.
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// RuntimeStateKey returns a file and object name for the side record of the auth identified by id.
+// Auth IDs may contain path separators or characters that are invalid in file names, so the
+// name is derived from a hash; the ID itself is stored in the record.
+func RuntimeStateKey(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:16]) + ".state"
+}
+
+// ReadRuntimeStateDir decodes every runtime state side record found in dir.
+func ReadRuntimeStateDir(dir string) ([]*RuntimeState, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("runtime state: read directory: %w", err)
+	}
+	states := make([]*RuntimeState, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".state") {
+			continue
+		}
+		data, errRead := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if errRead != nil {
+			log.WithError(errRead).Warnf("runtime state: skip %s", entry.Name())
+			continue
+		}
+		state, errDecode := DecodeRuntimeState(data)
+		if errDecode != nil {
+			log.WithError(errDecode).Warnf("runtime state: skip %s", entry.Name())
+			continue
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+// WriteRuntimeStateFile atomically writes the side record of state into dir and returns its path.
+func WriteRuntimeStateFile(dir string, state *RuntimeState) (string, error) {
+	raw, err := json.Marshal(state)
+	if err != nil {
+		return "", fmt.Errorf("runtime state: marshal: %w", err)
+	}
+	if err = os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("runtime state: create directory: %w", err)
+	}
+	path := filepath.Join(dir, RuntimeStateKey(state.ID))
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, raw, 0o600); err != nil {
+		return "", fmt.Errorf("runtime state: write temp file: %w", err)
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		return "", fmt.Errorf("runtime state: rename file: %w", err)
+	}
+	return path, nil
+}
+
+// DecodeRuntimeState decodes a runtime state side record.
+func DecodeRuntimeState(data []byte) (*RuntimeState, error) {
+	var state RuntimeState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("runtime state: decode: %w", err)
+	}
+	if strings.TrimSpace(state.ID) == "" {
+		return nil, fmt.Errorf("runtime state: record without id")
+	}
+	return &state, nil
+}
+
+// snapshotRuntimeState captures the runtime state of auth.
+func snapshotRuntimeState(auth *Auth) *RuntimeState {
+	if auth == nil {
+		return nil
+	}
+	state := &RuntimeState{
+		ID:             auth.ID,
+		Status:         auth.Status,
+		StatusMessage:  auth.StatusMessage,
+		Unavailable:    auth.Unavailable,
+		Quota:          auth.Quota,
+		LastError:      cloneError(auth.LastError),
+		NextRetryAfter: auth.NextRetryAfter,
+		UpdatedAt:      time.Now(),
+	}
+	if len(auth.ModelStates) > 0 {
+		state.ModelStates = make(map[string]*ModelState, len(auth.ModelStates))
+		for model, ms := range auth.ModelStates {
+			if ms != nil {
+				state.ModelStates[model] = ms.Clone()
+			}
+		}
+	}
+	return state
+}
+
+// isClean reports whether the state carries nothing worth restoring.
+func (s *RuntimeState) isClean() bool {
+	if s.Unavailable || s.Status == StatusError || s.LastError != nil || !s.NextRetryAfter.IsZero() || s.Quota != (QuotaState{}) {
+		return false
+	}
+	for _, ms := range s.ModelStates {
+		if ms == nil {
+			continue
+		}
+		if ms.Unavailable || ms.Status == StatusError || ms.LastError != nil || !ms.NextRetryAfter.IsZero() || ms.Quota != (QuotaState{}) {
+			return false
+		}
+	}
+	return true
+}
+
+// fingerprint identifies the content of the state, ignoring update timestamps.
+func (s *RuntimeState) fingerprint() string {
+	clone := *s
+	clone.UpdatedAt = time.Time{}
+	if len(s.ModelStates) > 0 {
+		clone.ModelStates = make(map[string]*ModelState, len(s.ModelStates))
+		for model, ms := range s.ModelStates {
+			if ms == nil {
+				continue
+			}
+			msClone := ms.Clone()
+			msClone.UpdatedAt = time.Time{}
+			clone.ModelStates[model] = msClone
+		}
+	}
+	raw, err := json.Marshal(&clone)
+	if err != nil {
+		return ""
+	}
+	return string(raw)
+}
+
+// applyRuntimeState restores state onto auth. Cooldowns that elapsed while the process was
+// down are cleared; the quota backoff level is kept so repeated rate limits keep escalating.
+func applyRuntimeState(auth *Auth, state *RuntimeState, now time.Time) {
+	if auth == nil || state == nil || auth.Disabled {
+		return
+	}
+	if len(state.ModelStates) > 0 {
+		auth.ModelStates = make(map[string]*ModelState, len(state.ModelStates))
+		for model, ms := range state.ModelStates {
+			if ms == nil {
+				continue
+			}
+			restored := ms.Clone()
+			if !restored.NextRetryAfter.IsZero() && !restored.NextRetryAfter.After(now) {
+				backoffLevel := restored.Quota.BackoffLevel
+				resetModelState(restored, now)
+				restored.Quota.BackoffLevel = backoffLevel
+			}
+			auth.ModelStates[model] = restored
+		}
+	}
+	auth.Status = state.Status
+	auth.StatusMessage = state.StatusMessage
+	auth.Unavailable = state.Unavailable
+	auth.Quota = state.Quota
+	auth.LastError = cloneError(state.LastError)
+	auth.NextRetryAfter = state.NextRetryAfter
+	if !auth.NextRetryAfter.IsZero() && !auth.NextRetryAfter.After(now) {
+		auth.Unavailable = false
+		auth.NextRetryAfter = time.Time{}
+	}
+	if auth.Quota.Exceeded && !auth.Quota.NextRecoverAt.IsZero() && !auth.Quota.NextRecoverAt.After(now) {
+		auth.Quota = QuotaState{BackoffLevel: auth.Quota.BackoffLevel}
+	}
+	if len(auth.ModelStates) > 0 {
+		updateAggregatedAvailability(auth, now)
+		if !hasModelError(auth, now) && !auth.Unavailable {
+			auth.Status = StatusActive
+			auth.StatusMessage = ""
+			auth.LastError = nil
+		}
+	} else if !auth.Unavailable && !auth.Quota.Exceeded {
+		auth.Status = StatusActive
+		auth.StatusMessage = ""
+		auth.LastError = nil
+	}
+}
+
+// runtimeStateStore returns the store when it persists runtime state.
+func (m *Manager) runtimeStateStore() (RuntimeStateStore, bool) {
+	m.mu.RLock()
+	store := m.store
+	m.mu.RUnlock()
+	rs, ok := store.(RuntimeStateStore)
+	return rs, ok && rs != nil
+}
+
+// loadRuntimeStatesLocked reads persisted runtime states and applies them to the loaded auths.
+// States are also kept until the matching auth is registered or updated, because auths
+// synthesized from config and auth files re-registered by the watcher arrive after Load.
+func (m *Manager) loadRuntimeStatesLocked(ctx context.Context) {
+	rs, ok := m.store.(RuntimeStateStore)
+	if !ok || rs == nil {
+		return
+	}
+	states, err := rs.ListRuntimeStates(ctx)
+	if err != nil {
+		log.Warnf("auth manager: failed to load runtime state: %v", err)
+		return
+	}
+	now := time.Now()
+	m.restoredStates = make(map[string]*RuntimeState, len(states))
+	for _, state := range states {
+		if state == nil || strings.TrimSpace(state.ID) == "" {
+			continue
+		}
+		m.restoredStates[state.ID] = state
+		if auth, exists := m.auths[state.ID]; exists && auth != nil {
+			applyRuntimeState(auth, state, now)
+		}
+	}
+}
+
+// restoreRuntimeStateLocked applies the runtime state restored on Load to auth once, unless
+// auth already carries runtime state of its own.
+func (m *Manager) restoreRuntimeStateLocked(auth *Auth) {
+	state, ok := m.restoredStates[auth.ID]
+	if !ok {
+		return
+	}
+	delete(m.restoredStates, auth.ID)
+	if len(auth.ModelStates) > 0 || auth.LastError != nil || auth.Unavailable {
+		return
+	}
+	applyRuntimeState(auth, state, time.Now())
+}
+
+// runtimeStateFlushDelay is how long runtime state changes are collected before they are
+// written, so bursts of results for the same auth produce a single write.
+const runtimeStateFlushDelay = 250 * time.Millisecond
+
+// persistRuntimeState marks the runtime state of the auth identified by id for saving. A single
+// writer goroutine, started on demand, writes the latest state of each marked auth after
+// runtimeStateFlushDelay; unchanged states are not written again.
+func (m *Manager) persistRuntimeState(ctx context.Context, id string) {
+	if shouldSkipPersist(ctx) {
+		return
+	}
+	if _, ok := m.runtimeStateStore(); !ok {
+		return
+	}
+	m.runtimeStateMu.Lock()
+	defer m.runtimeStateMu.Unlock()
+	if m.runtimeStateDirty == nil {
+		m.runtimeStateDirty = make(map[string]struct{})
+	}
+	m.runtimeStateDirty[id] = struct{}{}
+	if !m.runtimeStateWriting {
+		m.runtimeStateWriting = true
+		go m.runtimeStateWriter()
+	}
+}
+
+// runtimeStateWriter writes the marked runtime states in batches and exits once no auth is
+// left to save.
+func (m *Manager) runtimeStateWriter() {
+	for {
+		time.Sleep(runtimeStateFlushDelay)
+		m.runtimeStateMu.Lock()
+		dirty := m.runtimeStateDirty
+		m.runtimeStateDirty = nil
+		if len(dirty) == 0 {
+			m.runtimeStateWriting = false
+			m.runtimeStateMu.Unlock()
+			return
+		}
+		m.runtimeStateMu.Unlock()
+
+		rs, ok := m.runtimeStateStore()
+		if !ok {
+			continue
+		}
+		for id := range dirty {
+			m.writeRuntimeState(rs, id)
+		}
+	}
+}
+
+// writeRuntimeState saves the current runtime state of the auth identified by id, or deletes
+// the saved state once it carries nothing worth restoring.
+func (m *Manager) writeRuntimeState(rs RuntimeStateStore, id string) {
+	m.mu.RLock()
+	auth := m.auths[id]
+	var state *RuntimeState
+	if auth != nil && !strings.EqualFold(strings.TrimSpace(auth.Attributes["runtime_only"]), "true") {
+		state = snapshotRuntimeState(auth)
+	}
+	m.mu.RUnlock()
+	if state == nil {
+		return
+	}
+
+	fingerprint := ""
+	if !state.isClean() {
+		fingerprint = state.fingerprint()
+	}
+	m.runtimeStateMu.Lock()
+	last, saved := m.runtimeStateSaved[id]
+	m.runtimeStateMu.Unlock()
+	if saved && last == fingerprint {
+		return
+	}
+
+	ctx := context.Background()
+	var err error
+	if fingerprint == "" {
+		err = rs.DeleteRuntimeState(ctx, id)
+	} else {
+		err = rs.SaveRuntimeState(ctx, state)
+	}
+	if err != nil {
+		log.Warnf("auth manager: failed to persist runtime state for %s: %v", id, err)
+		return
+	}
+	m.runtimeStateMu.Lock()
+	if m.runtimeStateSaved == nil {
+		m.runtimeStateSaved = make(map[string]string)
+	}
+	m.runtimeStateSaved[id] = fingerprint
+	m.runtimeStateMu.Unlock()
+}
+
+// subscribeRuntimeState receives runtime states written by other processes when store is shared.
+func (m *Manager) subscribeRuntimeState(store Store) {
+	if subscriber, ok := store.(RuntimeStateSubscriber); ok && subscriber != nil {
+		subscriber.SubscribeRuntimeState(m.applyRemoteRuntimeState)
+	}
+}
+
+// applyRemoteRuntimeState applies a runtime state written by another process. The state is
+// recorded as saved so it is not written back to the store.
+func (m *Manager) applyRemoteRuntimeState(state *RuntimeState) {
+	if state == nil || strings.TrimSpace(state.ID) == "" {
+		return
+	}
+	fingerprint := ""
+	if !state.isClean() {
+		fingerprint = state.fingerprint()
+	}
+
+	m.runtimeStateMu.Lock()
+	defer m.runtimeStateMu.Unlock()
+	if m.runtimeStateSaved == nil {
+		m.runtimeStateSaved = make(map[string]string)
+	}
+	m.runtimeStateSaved[state.ID] = fingerprint
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	auth := m.auths[state.ID]
+	if auth == nil {
+		if fingerprint == "" {
+			delete(m.restoredStates, state.ID)
+		} else {
+			if m.restoredStates == nil {
+				m.restoredStates = make(map[string]*RuntimeState)
+			}
+			m.restoredStates[state.ID] = state
+		}
+		return
+	}
+	now := time.Now()
+	if fingerprint != "" {
+		applyRuntimeState(auth, state, now)
+		return
+	}
+	if auth.Disabled {
+		return
+	}
+	for _, ms := range auth.ModelStates {
+		resetModelState(ms, now)
+	}
+	clearAuthStateOnSuccess(auth, now)
+}
@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+type memoryRuntimeStateStore struct {
+	countingStore
+	mu     sync.Mutex
+	states map[string]*RuntimeState
+	saves  int
+}
+
+func (s *memoryRuntimeStateStore) ListRuntimeStates(context.Context) ([]*RuntimeState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]*RuntimeState, 0, len(s.states))
+	for _, state := range s.states {
+		out = append(out, state)
+	}
+	return out, nil
+}
+
+func (s *memoryRuntimeStateStore) SaveRuntimeState(_ context.Context, state *RuntimeState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.states == nil {
+		s.states = make(map[string]*RuntimeState)
+	}
+	s.states[state.ID] = state
+	s.saves++
+	return nil
+}
+
+func (s *memoryRuntimeStateStore) DeleteRuntimeState(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.states, id)
+	return nil
+}
+
+func (s *memoryRuntimeStateStore) get(id string) *RuntimeState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.states[id]
+}
+
+func waitForRuntimeState(t *testing.T, store *memoryRuntimeStateStore, id string, present bool) *RuntimeState {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if state := store.get(id); (state != nil) == present {
+			return state
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("runtime state for %s present=%v not observed", id, !present)
+	return nil
+}
+
+func TestManager_RuntimeStateSurvivesRestart(t *testing.T) {
+	store := &memoryRuntimeStateStore{}
+	ctx := context.Background()
+	retryAfter := time.Hour
+
+	mgr := NewManager(store, nil, nil)
+	if _, err := mgr.Register(ctx, &Auth{ID: "gemini:apikey:1", Provider: "gemini"}); err != nil {
+		t.Fatalf("Register returned error: %v", err)
+	}
+	mgr.MarkResult(ctx, Result{
+		AuthID:     "gemini:apikey:1",
+		Provider:   "gemini",
+		Model:      "gemini-2.5-pro",
+		Success:    false,
+		RetryAfter: &retryAfter,
+		Error:      &Error{HTTPStatus: 429, Message: "quota exhausted"},
+	})
+	saved := waitForRuntimeState(t, store, "gemini:apikey:1", true)
+	if saved.ModelStates["gemini-2.5-pro"] == nil || !saved.ModelStates["gemini-2.5-pro"].Quota.Exceeded {
+		t.Fatalf("expected quota state to be persisted, got %+v", saved.ModelStates)
+	}
+
+	// An elapsed cooldown of another auth must not be restored.
+	_ = store.SaveRuntimeState(ctx, &RuntimeState{
+		ID:     "claude:apikey:2",
+		Status: StatusError,
+		ModelStates: map[string]*ModelState{
+			"claude-sonnet-4": {
+				Status:         StatusError,
+				Unavailable:    true,
+				NextRetryAfter: time.Now().Add(-time.Minute),
+				LastError:      &Error{HTTPStatus: 503},
+			},
+		},
+	})
+
+	restarted := NewManager(store, nil, nil)
+	if err := restarted.Load(ctx); err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	restored, _ := restarted.Register(ctx, &Auth{ID: "gemini:apikey:1", Provider: "gemini"})
+	state := restored.ModelStates["gemini-2.5-pro"]
+	if state == nil || !state.Unavailable || !state.NextRetryAfter.After(time.Now()) {
+		t.Fatalf("expected model cooldown to be restored, got %+v", state)
+	}
+	if !restored.Quota.Exceeded || restored.Status != StatusError {
+		t.Fatalf("expected auth quota state to be restored, got status=%s quota=%+v", restored.Status, restored.Quota)
+	}
+
+	expired, _ := restarted.Register(ctx, &Auth{ID: "claude:apikey:2", Provider: "claude"})
+	if state := expired.ModelStates["claude-sonnet-4"]; state == nil || state.Unavailable || state.LastError != nil {
+		t.Fatalf("expected elapsed cooldown to be cleared, got %+v", state)
+	}
+	if expired.Status != StatusActive {
+		t.Fatalf("expected auth with elapsed cooldown to be active, got %s", expired.Status)
+	}
+
+	restarted.MarkResult(ctx, Result{AuthID: "gemini:apikey:1", Provider: "gemini", Model: "gemini-2.5-pro", Success: true})
+	waitForRuntimeState(t, store, "gemini:apikey:1", false)
+}
+
+func TestManager_RuntimeStateWritesAreCoalesced(t *testing.T) {
+	store := &memoryRuntimeStateStore{}
+	ctx := context.Background()
+	mgr := NewManager(store, nil, nil)
+	if _, err := mgr.Register(ctx, &Auth{ID: "codex:1", Provider: "codex"}); err != nil {
+		t.Fatalf("Register returned error: %v", err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			mgr.MarkResult(ctx, Result{
+				AuthID:   "codex:1",
+				Provider: "codex",
+				Model:    "gpt-5",
+				Success:  false,
+				Error:    &Error{HTTPStatus: 500 + i%3, Message: "upstream failed"},
+			})
+		}(i)
+	}
+	wg.Wait()
+	waitForRuntimeState(t, store, "codex:1", true)
+	time.Sleep(2 * runtimeStateFlushDelay)
+
+	store.mu.Lock()
+	saves := store.saves
+	store.mu.Unlock()
+	if saves > 2 {
+		t.Fatalf("50 results produced %d runtime state writes, want them coalesced", saves)
+	}
+	mgr.runtimeStateMu.Lock()
+	writing := mgr.runtimeStateWriting
+	mgr.runtimeStateMu.Unlock()
+	if writing {
+		t.Fatal("writer goroutine should exit once nothing is left to save")
+	}
+}
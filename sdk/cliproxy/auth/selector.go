@@ -26,8 +26,43 @@ type RoundRobinSelector struct {
 // rolling-window subscription caps (e.g. chat message limits).
 type FillFirstSelector struct{}
 
+// LeastLoadSelector picks the healthiest available credential instead of rotating blindly.
+// Candidates are scored by recent 429 history, quota backoff level, per-model cooldowns on
+// the same credential, and the number of requests currently in flight; the lowest score
+// wins and ties rotate in a round-robin manner.
+type LeastLoadSelector struct {
+	mu       sync.Mutex
+	cursors  map[string]int
+	inflight map[string]int
+	limited  map[string][]time.Time
+}
+
+// LoadAwareSelector is implemented by selectors that track per-auth execution load.
+// The manager reports when an execution starts and finishes and forwards every recorded
+// result so the selector can maintain recent failure history.
+type LoadAwareSelector interface {
+	Selector
+	// Acquire marks the start of an execution against the auth.
+	Acquire(authID string)
+	// Release marks the end of an execution started with Acquire.
+	Release(authID string)
+	// Observe records an execution result.
+	Observe(result Result)
+}
+
 type blockReason int
 
+const (
+	// leastLoadWindow bounds how long a 429 response keeps influencing selection.
+	leastLoadWindow = 10 * time.Minute
+
+	leastLoadInflightWeight      = 10
+	leastLoadRateLimitWeight     = 25
+	leastLoadBackoffWeight       = 15
+	leastLoadModelErrorWeight    = 5
+	leastLoadModelCooldownWeight = 3
+)
+
 const (
 	blockReasonNone blockReason = iota
 	blockReasonCooldown
@@ -169,7 +204,7 @@ func getAvailableAuths(auths []*Auth, provider, model string, now time.Time) ([]
 		}
 	}
 
-	available := availableByPriority[bestPriority]
+	available := preferQuotaHeadroom(availableByPriority[bestPriority], model, now)
 	if len(available) > 1 {
 		sort.Slice(available, func(i, j int) bool { return available[i].ID < available[j].ID })
 	}
@@ -214,6 +249,129 @@ func (s *FillFirstSelector) Pick(ctx context.Context, provider, model string, op
 	return available[0], nil
 }
 
+// Pick selects the available auth with the lowest load score for the requested model.
+func (s *LeastLoadSelector) Pick(ctx context.Context, provider, model string, opts cliproxyexecutor.Options, auths []*Auth) (*Auth, error) {
+	_ = ctx
+	_ = opts
+	now := time.Now()
+	available, err := getAvailableAuths(auths, provider, model, now)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	best := make([]*Auth, 0, len(available))
+	bestScore := 0
+	for _, candidate := range available {
+		score := s.scoreLocked(candidate, model, now)
+		switch {
+		case len(best) == 0 || score < bestScore:
+			best = append(best[:0], candidate)
+			bestScore = score
+		case score == bestScore:
+			best = append(best, candidate)
+		}
+	}
+	if len(best) == 1 {
+		return best[0], nil
+	}
+	key := provider + ":" + model
+	if s.cursors == nil {
+		s.cursors = make(map[string]int)
+	}
+	index := s.cursors[key]
+	if index >= 2_147_483_640 {
+		index = 0
+	}
+	s.cursors[key] = index + 1
+	return best[index%len(best)], nil
+}
+
+// Acquire implements LoadAwareSelector.
+func (s *LeastLoadSelector) Acquire(authID string) {
+	if authID == "" {
+		return
+	}
+	s.mu.Lock()
+	if s.inflight == nil {
+		s.inflight = make(map[string]int)
+	}
+	s.inflight[authID]++
+	s.mu.Unlock()
+}
+
+// Release implements LoadAwareSelector.
+func (s *LeastLoadSelector) Release(authID string) {
+	if authID == "" {
+		return
+	}
+	s.mu.Lock()
+	if s.inflight[authID] <= 1 {
+		delete(s.inflight, authID)
+	} else {
+		s.inflight[authID]--
+	}
+	s.mu.Unlock()
+}
+
+// Observe implements LoadAwareSelector by remembering recent rate-limit responses.
+func (s *LeastLoadSelector) Observe(result Result) {
+	if result.AuthID == "" || result.Success || statusCodeFromResult(result.Error) != http.StatusTooManyRequests {
+		return
+	}
+	now := time.Now()
+	s.mu.Lock()
+	if s.limited == nil {
+		s.limited = make(map[string][]time.Time)
+	}
+	s.limited[result.AuthID] = append(pruneRateLimitHistory(s.limited[result.AuthID], now), now)
+	s.mu.Unlock()
+}
+
+func (s *LeastLoadSelector) scoreLocked(auth *Auth, model string, now time.Time) int {
+	score := s.inflight[auth.ID] * leastLoadInflightWeight
+
+	history := pruneRateLimitHistory(s.limited[auth.ID], now)
+	if len(history) == 0 {
+		delete(s.limited, auth.ID)
+	} else {
+		s.limited[auth.ID] = history
+	}
+	score += len(history) * leastLoadRateLimitWeight
+
+	backoff := auth.Quota.BackoffLevel
+	for name, state := range auth.ModelStates {
+		if state == nil {
+			continue
+		}
+		if name == model {
+			if state.Quota.BackoffLevel > backoff {
+				backoff = state.Quota.BackoffLevel
+			}
+			if state.LastError != nil {
+				score += leastLoadModelErrorWeight
+			}
+			continue
+		}
+		if state.Unavailable && state.NextRetryAfter.After(now) {
+			score += leastLoadModelCooldownWeight
+		}
+	}
+	score += backoff * leastLoadBackoffWeight
+	return score
+}
+
+func pruneRateLimitHistory(history []time.Time, now time.Time) []time.Time {
+	cutoff := now.Add(-leastLoadWindow)
+	kept := history[:0]
+	for _, ts := range history {
+		if ts.After(cutoff) {
+			kept = append(kept, ts)
+		}
+	}
+	return kept
+}
+
 func isAuthBlockedForModel(auth *Auth, model string, now time.Time) (bool, blockReason, time.Time) {
 	if auth == nil {
 		return true, blockReasonOther, time.Time{}
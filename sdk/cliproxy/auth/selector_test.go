@@ -175,3 +175,143 @@ func TestRoundRobinSelectorPick_Concurrent(t *testing.T) {
 	default:
 	}
 }
+
+func TestLeastLoadSelectorPick_PrefersFewerInflight(t *testing.T) {
+	t.Parallel()
+
+	selector := &LeastLoadSelector{}
+	auths := []*Auth{{ID: "a"}, {ID: "b"}}
+
+	selector.Acquire("a")
+	for i := 0; i < 3; i++ {
+		got, err := selector.Pick(context.Background(), "gemini", "", cliproxyexecutor.Options{}, auths)
+		if err != nil {
+			t.Fatalf("Pick() #%d error = %v", i, err)
+		}
+		if got.ID != "b" {
+			t.Fatalf("Pick() #%d auth.ID = %q, want %q", i, got.ID, "b")
+		}
+	}
+
+	selector.Release("a")
+	seen := make(map[string]bool)
+	for i := 0; i < 2; i++ {
+		got, err := selector.Pick(context.Background(), "gemini", "", cliproxyexecutor.Options{}, auths)
+		if err != nil {
+			t.Fatalf("Pick() after release #%d error = %v", i, err)
+		}
+		seen[got.ID] = true
+	}
+	if !seen["a"] || !seen["b"] {
+		t.Fatalf("Pick() after release should rotate across tied auths, got %v", seen)
+	}
+}
+
+func TestLeastLoadSelectorPick_AvoidsRecentRateLimits(t *testing.T) {
+	t.Parallel()
+
+	selector := &LeastLoadSelector{}
+	auths := []*Auth{{ID: "a"}, {ID: "b"}}
+
+	selector.Observe(Result{AuthID: "a", Model: "m", Error: &Error{HTTPStatus: 429}})
+	selector.Observe(Result{AuthID: "b", Model: "m", Error: &Error{HTTPStatus: 500}})
+
+	got, err := selector.Pick(context.Background(), "mixed", "m", cliproxyexecutor.Options{}, auths)
+	if err != nil {
+		t.Fatalf("Pick() error = %v", err)
+	}
+	if got.ID != "b" {
+		t.Fatalf("Pick() auth.ID = %q, want %q", got.ID, "b")
+	}
+}
+
+func TestLeastLoadSelectorPick_ScoresBackoffAndModelCooldowns(t *testing.T) {
+	t.Parallel()
+
+	selector := &LeastLoadSelector{}
+	now := time.Now()
+	model := "test-model"
+
+	backedOff := &Auth{
+		ID: "a",
+		ModelStates: map[string]*ModelState{
+			model: {Status: StatusActive, Quota: QuotaState{BackoffLevel: 3}},
+		},
+	}
+	coolingElsewhere := &Auth{
+		ID: "b",
+		ModelStates: map[string]*ModelState{
+			"other-model": {Unavailable: true, NextRetryAfter: now.Add(time.Minute)},
+		},
+	}
+
+	got, err := selector.Pick(context.Background(), "mixed", model, cliproxyexecutor.Options{}, []*Auth{backedOff, coolingElsewhere})
+	if err != nil {
+		t.Fatalf("Pick() error = %v", err)
+	}
+	if got.ID != "b" {
+		t.Fatalf("Pick() auth.ID = %q, want %q", got.ID, "b")
+	}
+
+	healthy := &Auth{ID: "c"}
+	got, err = selector.Pick(context.Background(), "mixed", model, cliproxyexecutor.Options{}, []*Auth{backedOff, coolingElsewhere, healthy})
+	if err != nil {
+		t.Fatalf("Pick() error = %v", err)
+	}
+	if got.ID != "c" {
+		t.Fatalf("Pick() auth.ID = %q, want %q", got.ID, "c")
+	}
+}
+
+func TestFillFirstSelectorPick_SkipsAuthsNearUpstreamQuota(t *testing.T) {
+	now := time.Now()
+	nearCap := &Auth{ID: "a", UpstreamQuota: &UpstreamQuota{Windows: []QuotaWindow{
+		{Name: "5h", UsedPercent: 97, ResetAt: now.Add(time.Hour)},
+	}}}
+	roomy := &Auth{ID: "b", UpstreamQuota: &UpstreamQuota{Windows: []QuotaWindow{
+		{Name: "5h", UsedPercent: 40, ResetAt: now.Add(time.Hour)},
+	}}}
+	selector := &FillFirstSelector{}
+
+	got, err := selector.Pick(context.Background(), "claude", "claude-sonnet-4-5", cliproxyexecutor.Options{}, []*Auth{nearCap, roomy})
+	if err != nil {
+		t.Fatalf("Pick() error = %v", err)
+	}
+	if got.ID != "b" {
+		t.Fatalf("Pick() auth.ID = %q, want %q", got.ID, "b")
+	}
+
+	got, err = selector.Pick(context.Background(), "claude", "claude-sonnet-4-5", cliproxyexecutor.Options{}, []*Auth{nearCap})
+	if err != nil || got.ID != "a" {
+		t.Fatalf("expected the only candidate to stay selectable, got %v err=%v", got, err)
+	}
+
+	nearCap.UpstreamQuota = &UpstreamQuota{Windows: []QuotaWindow{
+		{Name: "5h", UsedPercent: 97, ResetAt: now.Add(-time.Minute)},
+	}}
+	got, err = selector.Pick(context.Background(), "claude", "claude-sonnet-4-5", cliproxyexecutor.Options{}, []*Auth{nearCap, roomy})
+	if err != nil || got.ID != "a" {
+		t.Fatalf("expected a window past its reset to be ignored, got %v err=%v", got, err)
+	}
+}
+
+func TestManager_ReportUpstreamQuotaMergesWindows(t *testing.T) {
+	mgr := NewManager(nil, nil, nil)
+	if _, err := mgr.Register(context.Background(), &Auth{ID: "claude-1", Provider: "claude"}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	ctx := mgr.withUpstreamQuotaReporter(context.Background(), "claude-1")
+	ReportUpstreamQuota(ctx, &UpstreamQuota{Source: "anthropic", Windows: []QuotaWindow{{Name: "5h", UsedPercent: 10}, {Name: "7d", UsedPercent: 50}}})
+	ReportUpstreamQuota(ctx, &UpstreamQuota{Source: "anthropic", Windows: []QuotaWindow{{Name: "5h", UsedPercent: 30}}})
+
+	auth, _ := mgr.GetByID("claude-1")
+	if auth.UpstreamQuota == nil || len(auth.UpstreamQuota.Windows) != 2 {
+		t.Fatalf("unexpected upstream quota %+v", auth.UpstreamQuota)
+	}
+	if headroom, _ := auth.UpstreamQuota.Headroom("", time.Now()); headroom != 50 {
+		t.Fatalf("expected 50%% headroom, got %v", headroom)
+	}
+	if auth.UpstreamQuota.Windows[0].Name != "5h" || auth.UpstreamQuota.Windows[0].UsedPercent != 30 {
+		t.Fatalf("expected the 5h window to be replaced, got %+v", auth.UpstreamQuota.Windows[0])
+	}
+}
@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/metrics"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+const (
+	// defaultSessionAffinityTTL is how long an idle conversation stays pinned.
+	defaultSessionAffinityTTL = time.Hour
+	// maxSessionPins bounds the pin table; the least recently used pins are dropped beyond it.
+	maxSessionPins = 50_000
+)
+
+type sessionPin struct {
+	key     string
+	authID  string
+	expires time.Time
+}
+
+// sessionAffinity maps conversation keys to the credential serving them. Pins are kept in
+// least recently used order, which is also expiry order while the TTL does not change, so
+// eviction only ever looks at the back of the list.
+type sessionAffinity struct {
+	mu    sync.Mutex
+	pins  map[string]*list.Element
+	order list.List
+}
+
+func (a *sessionAffinity) lookup(key string, now time.Time) (string, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	elem, ok := a.pins[key]
+	if !ok {
+		return "", false
+	}
+	pin := elem.Value.(*sessionPin)
+	if !now.Before(pin.expires) {
+		a.removeLocked(elem)
+		return "", false
+	}
+	return pin.authID, true
+}
+
+func (a *sessionAffinity) pin(key, authID string, expires time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.pins == nil {
+		a.pins = make(map[string]*list.Element)
+	}
+	if elem, exists := a.pins[key]; exists {
+		pin := elem.Value.(*sessionPin)
+		pin.authID, pin.expires = authID, expires
+		a.order.MoveToFront(elem)
+		return
+	}
+	a.evictLocked(time.Now())
+	a.pins[key] = a.order.PushFront(&sessionPin{key: key, authID: authID, expires: expires})
+}
+
+// evictLocked drops expired pins from the back of the list and, if the table is still full,
+// the least recently used pin.
+func (a *sessionAffinity) evictLocked(now time.Time) {
+	for elem := a.order.Back(); elem != nil; elem = a.order.Back() {
+		if now.Before(elem.Value.(*sessionPin).expires) && len(a.pins) < maxSessionPins {
+			return
+		}
+		a.removeLocked(elem)
+	}
+}
+
+func (a *sessionAffinity) removeLocked(elem *list.Element) {
+	a.order.Remove(elem)
+	delete(a.pins, elem.Value.(*sessionPin).key)
+}
+
+// sessionAffinityTTL returns the pin lifetime, or zero when sticky routing is disabled.
+func (m *Manager) sessionAffinityTTL() time.Duration {
+	cfg, _ := m.runtimeConfig.Load().(*internalconfig.Config)
+	if cfg == nil || !cfg.Routing.SessionAffinity.Enabled {
+		return 0
+	}
+	if cfg.Routing.SessionAffinity.TTLSeconds > 0 {
+		return time.Duration(cfg.Routing.SessionAffinity.TTLSeconds) * time.Second
+	}
+	return defaultSessionAffinityTTL
+}
+
+// pickWithAffinity keeps a conversation on the credential that served it while that credential
+// is still available for the model; otherwise it asks the selector and pins the new pick.
+func (m *Manager) pickWithAffinity(ctx context.Context, provider, model string, opts cliproxyexecutor.Options, candidates []*Auth) (*Auth, error) {
+	sessionKey, _ := opts.Metadata[cliproxyexecutor.SessionKeyMetadataKey].(string)
+	ttl := m.sessionAffinityTTL()
+	if sessionKey == "" || ttl <= 0 {
+		return m.selector.Pick(ctx, provider, model, opts, candidates)
+	}
+	pinKey := sessionKey + "|" + strings.ToLower(model)
+	now := time.Now()
+	result := "miss"
+	if authID, ok := m.affinity.lookup(pinKey, now); ok {
+		if pinned := availablePinnedAuth(candidates, authID, provider, model, now); pinned != nil {
+			m.affinity.pin(pinKey, authID, now.Add(ttl))
+			metrics.ObserveSessionAffinity("hit")
+			return pinned, nil
+		}
+		result = "repin"
+	}
+	selected, err := m.selector.Pick(ctx, provider, model, opts, candidates)
+	if err != nil || selected == nil {
+		return selected, err
+	}
+	// A hedge deliberately runs on another credential; it must not move the conversation.
+	if !cliproxyexecutor.IsHedge(ctx) {
+		m.affinity.pin(pinKey, selected.ID, now.Add(ttl))
+		metrics.ObserveSessionAffinity(result)
+		logEntryWithRequestID(ctx).Debugf("session affinity %s: pinned %s to auth %s", result, model, selected.ID)
+	}
+	return selected, nil
+}
+
+// availablePinnedAuth returns the pinned auth when it is among the candidates the selector
+// would choose from, i.e. it is not cooling down, disabled or deprioritised.
+func availablePinnedAuth(candidates []*Auth, authID, provider, model string, now time.Time) *Auth {
+	available, err := getAvailableAuths(candidates, provider, model, now)
+	if err != nil {
+		return nil
+	}
+	for _, candidate := range available {
+		if candidate.ID == authID {
+			return candidate
+		}
+	}
+	return nil
+}
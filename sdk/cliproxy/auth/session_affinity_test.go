@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+func TestManagerPickWithAffinity_PinsSessionWhileHealthy(t *testing.T) {
+	m := NewManager(nil, &RoundRobinSelector{}, nil)
+	m.SetConfig(&internalconfig.Config{Routing: internalconfig.RoutingConfig{
+		SessionAffinity: internalconfig.SessionAffinityConfig{Enabled: true},
+	}})
+	candidates := []*Auth{{ID: "a", Provider: "claude"}, {ID: "b", Provider: "claude"}, {ID: "c", Provider: "claude"}}
+	session := func(key string) cliproxyexecutor.Options {
+		return cliproxyexecutor.Options{Metadata: map[string]any{cliproxyexecutor.SessionKeyMetadataKey: key}}
+	}
+	ctx := context.Background()
+	model := "claude-sonnet-4-5"
+
+	first, err := m.pickWithAffinity(ctx, "claude", model, session("s1"), candidates)
+	if err != nil {
+		t.Fatalf("pickWithAffinity() error = %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		got, _ := m.pickWithAffinity(ctx, "claude", model, session("s1"), candidates)
+		if got.ID != first.ID {
+			t.Fatalf("turn %d went to %s, want pinned %s", i, got.ID, first.ID)
+		}
+	}
+	other, _ := m.pickWithAffinity(ctx, "claude", model, session("s2"), candidates)
+	if other.ID == first.ID {
+		t.Fatalf("a new session should be balanced onto another auth, got %s again", other.ID)
+	}
+
+	// Once the pinned auth cools down the session moves, and stays on its new auth.
+	first.ModelStates = map[string]*ModelState{model: {
+		Unavailable:    true,
+		NextRetryAfter: time.Now().Add(time.Minute),
+		Quota:          QuotaState{Exceeded: true},
+	}}
+	moved, _ := m.pickWithAffinity(ctx, "claude", model, session("s1"), candidates)
+	if moved.ID == first.ID {
+		t.Fatalf("expected the session to leave cooling auth %s", first.ID)
+	}
+	first.ModelStates = nil
+	again, _ := m.pickWithAffinity(ctx, "claude", model, session("s1"), candidates)
+	if again.ID != moved.ID {
+		t.Fatalf("session bounced back to %s, want it to stay on %s", again.ID, moved.ID)
+	}
+
+	m.SetConfig(&internalconfig.Config{})
+	seen := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		got, _ := m.pickWithAffinity(ctx, "claude", model, session("s1"), candidates)
+		seen[got.ID] = true
+	}
+	if len(seen) != 3 {
+		t.Fatalf("with affinity disabled the selector should rotate, got %v", seen)
+	}
+}
+
+func TestSessionAffinity_EvictsLeastRecentlyUsedPins(t *testing.T) {
+	var a sessionAffinity
+	now := time.Now()
+	a.pin("expired", "x", now.Add(-time.Second))
+	for i := 0; i < maxSessionPins-1; i++ {
+		a.pin(fmt.Sprintf("s%d", i), "a", now.Add(time.Hour))
+	}
+	a.pin("s0", "a", now.Add(time.Hour))
+	a.pin("new-1", "b", now.Add(time.Hour))
+	if _, ok := a.pins["expired"]; ok {
+		t.Fatal("expected the expired pin to be evicted first")
+	}
+	a.pin("new-2", "b", now.Add(time.Hour))
+	if len(a.pins) != maxSessionPins || a.order.Len() != maxSessionPins {
+		t.Fatalf("expected the table to stay at %d pins, got %d", maxSessionPins, len(a.pins))
+	}
+	if _, ok := a.lookup("s1", now); ok {
+		t.Fatal("expected the least recently used pin to be evicted")
+	}
+	if authID, ok := a.lookup("s0", now); !ok || authID != "a" {
+		t.Fatal("expected a refreshed pin to survive eviction")
+	}
+}
@@ -11,3 +11,33 @@ type Store interface {
 	// Delete removes the auth record identified by id.
 	Delete(ctx context.Context, id string) error
 }
+
+// RuntimeStateStore is an optional Store extension that keeps the runtime availability state
+// of auths (cooldowns, quota and per-model states) as a side record, so it survives restarts.
+// Side records are keyed by auth ID and also cover auths that have no backing auth file.
+type RuntimeStateStore interface {
+	// ListRuntimeStates returns every persisted runtime state.
+	ListRuntimeStates(ctx context.Context) ([]*RuntimeState, error)
+	// SaveRuntimeState persists state, replacing any existing record for state.ID.
+	SaveRuntimeState(ctx context.Context, state *RuntimeState) error
+	// DeleteRuntimeState removes the runtime state of the auth identified by id.
+	DeleteRuntimeState(ctx context.Context, id string) error
+}
+
+// RefreshCoordinator is an optional Store extension for stores shared by several processes.
+// It elects a single process to refresh a credential, so concurrent refreshes do not
+// invalidate each other's refresh tokens.
+type RefreshCoordinator interface {
+	// AcquireRefresh tries to take the refresh lease of auth. ok is false when another process
+	// holds the lease or has already refreshed the credential. release must be called once the
+	// refreshed auth has been saved when ok is true.
+	AcquireRefresh(ctx context.Context, auth *Auth) (release func(), ok bool, err error)
+}
+
+// RuntimeStateSubscriber is an optional Store extension that delivers runtime states written
+// by other processes sharing the store.
+type RuntimeStateSubscriber interface {
+	// SubscribeRuntimeState registers fn to receive runtime state changes made by other
+	// processes. A state carrying only an ID reports that the record was deleted.
+	SubscribeRuntimeState(fn func(state *RuntimeState))
+}
@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type tracingTestStatusError struct{ code int }
+
+func (e tracingTestStatusError) Error() string   { return http.StatusText(e.code) }
+func (e tracingTestStatusError) StatusCode() int { return e.code }
+
+type tracingTestExecutor struct {
+	failures map[string]int
+}
+
+func (e *tracingTestExecutor) Identifier() string { return "claude" }
+
+func (e *tracingTestExecutor) Execute(_ context.Context, auth *Auth, _ cliproxyexecutor.Request, _ cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	if code := e.failures[auth.ID]; code > 0 {
+		return cliproxyexecutor.Response{}, tracingTestStatusError{code: code}
+	}
+	return cliproxyexecutor.Response{Payload: []byte("ok")}, nil
+}
+
+func (e *tracingTestExecutor) ExecuteStream(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	return nil, tracingTestStatusError{code: http.StatusNotImplemented}
+}
+
+func (e *tracingTestExecutor) Refresh(_ context.Context, auth *Auth) (*Auth, error) { return auth, nil }
+
+func (e *tracingTestExecutor) CountTokens(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{}, nil
+}
+
+func (e *tracingTestExecutor) HttpRequest(context.Context, *Auth, *http.Request) (*http.Response, error) {
+	return nil, nil
+}
+
+func TestManagerExecute_RecordsSelectionAndAttemptSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	m := NewManager(nil, &FillFirstSelector{}, nil)
+	m.RegisterExecutor(&tracingTestExecutor{failures: map[string]int{"a": http.StatusTooManyRequests}})
+	for _, id := range []string{"a", "b"} {
+		if _, err := m.Register(context.Background(), &Auth{ID: id, Provider: "claude"}); err != nil {
+			t.Fatalf("register %s: %v", id, err)
+		}
+	}
+
+	if _, err := m.Execute(context.Background(), []string{"claude"}, cliproxyexecutor.Request{}, cliproxyexecutor.Options{}); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	var selections int
+	var attempts []tracetest.SpanStub
+	for _, span := range exporter.GetSpans() {
+		switch span.Name {
+		case "cliproxy.auth.select":
+			selections++
+		case "cliproxy.attempt":
+			attempts = append(attempts, span)
+		}
+	}
+	if selections != 2 {
+		t.Fatalf("selection spans = %d, want 2", selections)
+	}
+	if len(attempts) != 2 {
+		t.Fatalf("attempt spans = %d, want 2", len(attempts))
+	}
+	var statusCode int64
+	for _, attr := range attempts[0].Attributes {
+		if attr.Key == "http.response.status_code" {
+			statusCode = attr.Value.AsInt64()
+		}
+	}
+	if statusCode != http.StatusTooManyRequests {
+		t.Fatalf("first attempt status code = %d, want %d", statusCode, http.StatusTooManyRequests)
+	}
+	if attempts[0].Status.Code != codes.Error || attempts[1].Status.Code == codes.Error {
+		t.Fatalf("attempt statuses = %v/%v, want Error/Unset", attempts[0].Status.Code, attempts[1].Status.Code)
+	}
+}
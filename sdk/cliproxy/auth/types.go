@@ -44,6 +44,9 @@ type Auth struct {
 	Metadata map[string]any `json:"metadata,omitempty"`
 	// Quota captures recent quota information for load balancers.
 	Quota QuotaState `json:"quota"`
+	// UpstreamQuota holds the latest usage limits reported by the upstream for this credential.
+	// The value is replaced wholesale on every report and never mutated in place.
+	UpstreamQuota *UpstreamQuota `json:"upstream_quota,omitempty"`
 	// LastError stores the last failure encountered while executing or refreshing.
 	LastError *Error `json:"last_error,omitempty"`
 	// CreatedAt is the creation timestamp in UTC.
@@ -0,0 +1,171 @@
+package auth
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// defaultMinQuotaHeadroomPercent is the remaining share of an upstream limit below which a
+// credential is passed over while other candidates still have room.
+const defaultMinQuotaHeadroomPercent = 5
+
+var minQuotaHeadroomPercent atomic.Int64
+
+func init() {
+	minQuotaHeadroomPercent.Store(defaultMinQuotaHeadroomPercent)
+}
+
+// SetMinQuotaHeadroomPercent sets the remaining upstream quota percentage below which selectors
+// avoid a credential. Zero restores the default and a negative value disables the check.
+func SetMinQuotaHeadroomPercent(percent int) {
+	if percent == 0 {
+		percent = defaultMinQuotaHeadroomPercent
+	}
+	minQuotaHeadroomPercent.Store(int64(percent))
+}
+
+// UpstreamQuota is the usage reported by an upstream provider for a credential.
+type UpstreamQuota struct {
+	// Source names the signal the quota was read from (e.g. "anthropic", "codex", "gemini-cli").
+	Source string `json:"source"`
+	// Status carries the provider's own verdict when it reports one (e.g. "allowed_warning").
+	Status string `json:"status,omitempty"`
+	// Windows lists the individual limits reported by the upstream.
+	Windows []QuotaWindow `json:"windows"`
+	// UpdatedAt is when the upstream last reported quota for this credential.
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// QuotaWindow describes a single upstream limit.
+type QuotaWindow struct {
+	// Name identifies the limit, e.g. "5h", "7d", "requests" or "tokens".
+	Name string `json:"name"`
+	// Model scopes the limit to one model; empty applies to every model of the credential.
+	Model string `json:"model,omitempty"`
+	// Limit is the absolute size of the window when the upstream reports one.
+	Limit int64 `json:"limit,omitempty"`
+	// Remaining is the absolute amount left when Limit is reported.
+	Remaining int64 `json:"remaining,omitempty"`
+	// UsedPercent is the consumed share of the window in the 0-100 range.
+	UsedPercent float64 `json:"used_percent"`
+	// ResetAt is when the window resets.
+	ResetAt time.Time `json:"reset_at,omitempty"`
+}
+
+// RemainingPercent returns the share of the window left, in the 0-100 range.
+func (w QuotaWindow) RemainingPercent() float64 {
+	remaining := 100 - w.UsedPercent
+	if w.Limit > 0 {
+		remaining = float64(w.Remaining) * 100 / float64(w.Limit)
+	}
+	return math.Max(0, math.Min(100, remaining))
+}
+
+// expired reports whether the window has already reset.
+func (w QuotaWindow) expired(now time.Time) bool {
+	return !w.ResetAt.IsZero() && !now.Before(w.ResetAt)
+}
+
+// Headroom returns the tightest remaining percentage across the windows that apply to model
+// and have not reset yet. ok is false when no such window is known.
+func (q *UpstreamQuota) Headroom(model string, now time.Time) (percent float64, ok bool) {
+	if q == nil {
+		return 0, false
+	}
+	percent = 100
+	for _, window := range q.Windows {
+		if window.expired(now) || (window.Model != "" && window.Model != model) {
+			continue
+		}
+		percent = math.Min(percent, window.RemainingPercent())
+		ok = true
+	}
+	return percent, ok
+}
+
+// merge folds an update into q, replacing windows with the same name and model and
+// dropping windows that have already reset.
+func (q *UpstreamQuota) merge(update *UpstreamQuota, now time.Time) *UpstreamQuota {
+	merged := &UpstreamQuota{Source: update.Source, Status: update.Status, UpdatedAt: update.UpdatedAt}
+	if merged.UpdatedAt.IsZero() {
+		merged.UpdatedAt = now
+	}
+	type windowKey struct{ name, model string }
+	seen := make(map[windowKey]struct{}, len(update.Windows))
+	for _, window := range update.Windows {
+		seen[windowKey{window.Name, window.Model}] = struct{}{}
+		merged.Windows = append(merged.Windows, window)
+	}
+	if q != nil {
+		for _, window := range q.Windows {
+			if _, ok := seen[windowKey{window.Name, window.Model}]; ok || window.expired(now) {
+				continue
+			}
+			merged.Windows = append(merged.Windows, window)
+		}
+	}
+	sort.SliceStable(merged.Windows, func(i, j int) bool {
+		if merged.Windows[i].Model != merged.Windows[j].Model {
+			return merged.Windows[i].Model < merged.Windows[j].Model
+		}
+		return merged.Windows[i].Name < merged.Windows[j].Name
+	})
+	return merged
+}
+
+type upstreamQuotaReporterKey struct{}
+
+type upstreamQuotaReporter func(quota *UpstreamQuota)
+
+// ReportUpstreamQuota records quota information reported by the upstream for the credential
+// executing under ctx. It is a no-op when ctx was not prepared by the manager.
+func ReportUpstreamQuota(ctx context.Context, quota *UpstreamQuota) {
+	if ctx == nil || quota == nil || len(quota.Windows) == 0 {
+		return
+	}
+	if report, ok := ctx.Value(upstreamQuotaReporterKey{}).(upstreamQuotaReporter); ok && report != nil {
+		report(quota)
+	}
+}
+
+func (m *Manager) withUpstreamQuotaReporter(ctx context.Context, authID string) context.Context {
+	return context.WithValue(ctx, upstreamQuotaReporterKey{}, upstreamQuotaReporter(func(quota *UpstreamQuota) {
+		m.recordUpstreamQuota(authID, quota)
+	}))
+}
+
+func (m *Manager) recordUpstreamQuota(authID string, quota *UpstreamQuota) {
+	if m == nil || authID == "" || quota == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	auth, ok := m.auths[authID]
+	if !ok || auth == nil {
+		return
+	}
+	auth.UpstreamQuota = auth.UpstreamQuota.merge(quota, time.Now())
+}
+
+// preferQuotaHeadroom drops candidates whose upstream quota for model is nearly exhausted,
+// as long as at least one candidate keeps more headroom than the configured minimum.
+func preferQuotaHeadroom(auths []*Auth, model string, now time.Time) []*Auth {
+	threshold := float64(minQuotaHeadroomPercent.Load())
+	if threshold < 0 || len(auths) < 2 {
+		return auths
+	}
+	roomy := make([]*Auth, 0, len(auths))
+	for _, auth := range auths {
+		if headroom, ok := auth.UpstreamQuota.Headroom(model, now); ok && headroom <= threshold {
+			continue
+		}
+		roomy = append(roomy, auth)
+	}
+	if len(roomy) == 0 {
+		return auths
+	}
+	return roomy
+}
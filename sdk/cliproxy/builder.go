@@ -207,6 +207,8 @@ func (b *Builder) Build() (*Service, error) {
 		switch strategy {
 		case "fill-first", "fillfirst", "ff":
 			selector = &coreauth.FillFirstSelector{}
+		case "least-load", "leastload", "least-remaining-load", "ll":
+			selector = &coreauth.LeastLoadSelector{}
 		default:
 			selector = &coreauth.RoundRobinSelector{}
 		}
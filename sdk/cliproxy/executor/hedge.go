@@ -0,0 +1,26 @@
+package executor
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrHedgeLost is the cancellation cause of an attempt that lost the race against its hedge.
+var ErrHedgeLost = errors.New("hedged request answered by another attempt")
+
+type hedgeKey struct{}
+
+// WithHedge marks ctx as belonging to a hedge attempt, i.e. a duplicate of a request that
+// was already sent upstream on another credential.
+func WithHedge(ctx context.Context) context.Context {
+	return context.WithValue(ctx, hedgeKey{}, true)
+}
+
+// IsHedge reports whether ctx belongs to a hedge attempt.
+func IsHedge(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	hedge, _ := ctx.Value(hedgeKey{}).(bool)
+	return hedge
+}
@@ -0,0 +1,53 @@
+package executor
+
+import (
+	"context"
+	"net/http"
+)
+
+type responseObserverKey struct{}
+
+type selectionObserverKey struct{}
+
+// SelectionObserver receives the auth chosen for each execution attempt.
+type SelectionObserver func(authID, provider string)
+
+// WithSelectionObserver returns a context whose executions report selected auths to fn.
+func WithSelectionObserver(ctx context.Context, fn SelectionObserver) context.Context {
+	if fn == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, selectionObserverKey{}, fn)
+}
+
+// ObserveSelection reports the auth picked for an execution attempt to the observer on ctx, if any.
+func ObserveSelection(ctx context.Context, authID, provider string) {
+	if ctx == nil {
+		return
+	}
+	if fn, ok := ctx.Value(selectionObserverKey{}).(SelectionObserver); ok && fn != nil {
+		fn(authID, provider)
+	}
+}
+
+// ResponseObserver receives the status code and headers of upstream responses.
+type ResponseObserver func(status int, headers http.Header)
+
+// WithResponseObserver returns a context whose executions report upstream responses to fn.
+func WithResponseObserver(ctx context.Context, fn ResponseObserver) context.Context {
+	if fn == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, responseObserverKey{}, fn)
+}
+
+// ObserveResponse reports an upstream response to the observer registered on ctx, if any.
+// Executors call it once the upstream status and headers are known.
+func ObserveResponse(ctx context.Context, status int, headers http.Header) {
+	if ctx == nil {
+		return
+	}
+	if fn, ok := ctx.Value(responseObserverKey{}).(ResponseObserver); ok && fn != nil {
+		fn(status, headers)
+	}
+}
@@ -10,6 +10,18 @@ import (
 // RequestedModelMetadataKey stores the client-requested model name in Options.Metadata.
 const RequestedModelMetadataKey = "requested_model"
 
+// ServedModelMetadataKey is set in the caller's Options.Metadata by the auth manager to the
+// model that actually served the request, which differs from the requested model after a fallback.
+const ServedModelMetadataKey = "served_model"
+
+// ExcludedAuthsMetadataKey lists auth IDs ([]string) a streaming request should avoid, e.g. the
+// credential whose stream failed mid-way. Excluded auths are still used when nothing else is available.
+const ExcludedAuthsMetadataKey = "excluded_auths"
+
+// SessionKeyMetadataKey identifies the conversation a request belongs to (string) so the auth
+// manager can keep routing it to the same credential.
+const SessionKeyMetadataKey = "session_key"
+
 // Request encapsulates the translated payload that will be sent to a provider executor.
 type Request struct {
 	// Model is the upstream model identifier after translation.
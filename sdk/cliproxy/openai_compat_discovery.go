@@ -0,0 +1,246 @@
+package cliproxy
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/executor"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// defaultOpenAICompatDiscoverInterval is used when discover-interval-seconds is not set.
+	defaultOpenAICompatDiscoverInterval = time.Hour
+
+	// openAICompatDiscoveryTick is how often the discovery loop checks for due providers.
+	openAICompatDiscoveryTick = 15 * time.Second
+
+	// openAICompatDiscoveryRetry is the first delay before a failed fetch is retried; it doubles
+	// with every further failure up to the discovery interval.
+	openAICompatDiscoveryRetry = 30 * time.Second
+
+	// openAICompatDiscoveryTimeout bounds a single GET /models call.
+	openAICompatDiscoveryTimeout = 15 * time.Second
+)
+
+// openAICompatDiscovery caches the model IDs listed by OpenAI-compatible providers, keyed by auth ID.
+type openAICompatDiscovery struct {
+	mu      sync.Mutex
+	entries map[string]*discoveredModels
+}
+
+type discoveredModels struct {
+	ids       []string
+	fetched   bool
+	fetching  bool
+	failures  int
+	attempted time.Time
+}
+
+// cached returns the cached model IDs for authID. It reports start when the auth has never been
+// queried; the caller then owns the first fetch, so registration never waits on the network.
+func (d *openAICompatDiscovery) cached(authID string) (ids []string, start bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.entries == nil {
+		d.entries = make(map[string]*discoveredModels)
+	}
+	entry := d.entries[authID]
+	if entry == nil {
+		d.entries[authID] = &discoveredModels{fetching: true}
+		return nil, true
+	}
+	return slices.Clone(entry.ids), false
+}
+
+// due reports whether the models of authID should be fetched again. Failed fetches are retried
+// after a backoff that is shorter than interval.
+func (d *openAICompatDiscovery) due(authID string, interval time.Duration, now time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	entry := d.entries[authID]
+	if entry == nil {
+		return true
+	}
+	if entry.fetching {
+		return false
+	}
+	wait := interval
+	if entry.failures > 0 {
+		wait = min(openAICompatDiscoveryRetry<<min(entry.failures-1, 10), interval)
+	}
+	return now.Sub(entry.attempted) >= wait
+}
+
+// refresh fetches the provider model list for auth and reports whether it changed.
+// A failed fetch keeps the previously discovered models so transient errors do not
+// unregister them.
+func (d *openAICompatDiscovery) refresh(ctx context.Context, auth *coreauth.Auth, cfg *config.Config) bool {
+	ctx, cancel := context.WithTimeout(ctx, openAICompatDiscoveryTimeout)
+	defer cancel()
+	ids, err := executor.FetchOpenAICompatModels(ctx, auth, cfg)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.entries == nil {
+		d.entries = make(map[string]*discoveredModels)
+	}
+	entry := d.entries[auth.ID]
+	if entry == nil {
+		entry = &discoveredModels{}
+		d.entries[auth.ID] = entry
+	}
+	entry.attempted = time.Now()
+	entry.fetching = false
+	if err != nil {
+		entry.failures++
+		log.Warnf("openai-compat model discovery failed for %s: %v", auth.Label, err)
+		return false
+	}
+	entry.failures = 0
+	slices.Sort(ids)
+	changed := !entry.fetched || !slices.Equal(entry.ids, ids)
+	entry.ids = ids
+	entry.fetched = true
+	if changed {
+		log.Debugf("openai-compat model discovery for %s returned %d models", auth.Label, len(ids))
+	}
+	return changed
+}
+
+// forget drops cached models for auth IDs that are no longer active.
+func (d *openAICompatDiscovery) forget(keep func(authID string) bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for id := range d.entries {
+		if !keep(id) {
+			delete(d.entries, id)
+		}
+	}
+}
+
+// openAICompatModelInfos builds the registry models of an OpenAI-compatible auth: the configured
+// models (registered under their alias) plus, when discovery is enabled, every model listed by the
+// provider that is not configured explicitly. excluded-models applies to both. The first listing
+// is fetched in the background and the auth is registered again once it arrives.
+func (s *Service) openAICompatModelInfos(a *coreauth.Auth, compat *config.OpenAICompatibility) []*ModelInfo {
+	now := time.Now().Unix()
+	ms := make([]*ModelInfo, 0, len(compat.Models))
+	configured := make(map[string]struct{}, len(compat.Models))
+	for j := range compat.Models {
+		m := compat.Models[j]
+		// Use alias as model ID, fallback to name if alias is empty
+		modelID := m.Alias
+		if modelID == "" {
+			modelID = m.Name
+		}
+		configured[strings.ToLower(strings.TrimSpace(m.Name))] = struct{}{}
+		ms = append(ms, &ModelInfo{
+			ID:          modelID,
+			Object:      "model",
+			Created:     now,
+			OwnedBy:     compat.Name,
+			Type:        "openai-compatibility",
+			DisplayName: modelID,
+			UserDefined: true,
+		})
+	}
+	if compat.DiscoverModels {
+		ids, start := s.compatDiscovery.cached(a.ID)
+		if start {
+			go s.discoverOpenAICompatModels(a.ID)
+		}
+		for _, id := range ids {
+			if _, ok := configured[strings.ToLower(id)]; ok {
+				continue
+			}
+			ms = append(ms, &ModelInfo{
+				ID:          id,
+				Object:      "model",
+				Created:     now,
+				OwnedBy:     compat.Name,
+				Type:        "openai-compatibility",
+				DisplayName: id,
+				UserDefined: true,
+			})
+		}
+	}
+	return applyExcludedModels(ms, compat.ExcludedModels)
+}
+
+// discoverOpenAICompatModels fetches the models of a newly registered auth and registers them
+// when the auth is still active.
+func (s *Service) discoverOpenAICompatModels(authID string) {
+	if s.coreManager == nil {
+		return
+	}
+	a, ok := s.coreManager.GetByID(authID)
+	if !ok {
+		s.compatDiscovery.forget(func(id string) bool { return id != authID })
+		return
+	}
+	s.cfgMu.RLock()
+	cfg := s.cfg
+	s.cfgMu.RUnlock()
+	if !s.compatDiscovery.refresh(context.Background(), a, cfg) {
+		return
+	}
+	if current, ok := s.coreManager.GetByID(authID); ok && !current.Disabled {
+		s.registerModelsForAuth(current)
+	}
+}
+
+// runOpenAICompatDiscovery periodically refreshes discovered models and re-registers auths
+// whose provider model list changed, until ctx is cancelled.
+func (s *Service) runOpenAICompatDiscovery(ctx context.Context) {
+	ticker := time.NewTicker(openAICompatDiscoveryTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.refreshOpenAICompatDiscovery(ctx)
+		}
+	}
+}
+
+func (s *Service) refreshOpenAICompatDiscovery(ctx context.Context) {
+	if s.coreManager == nil {
+		return
+	}
+	s.cfgMu.RLock()
+	cfg := s.cfg
+	s.cfgMu.RUnlock()
+	active := make(map[string]struct{})
+	now := time.Now()
+	for _, a := range s.coreManager.List() {
+		if a == nil || a.Disabled || a.Attributes["discover_models"] != "true" {
+			continue
+		}
+		active[a.ID] = struct{}{}
+		if !s.compatDiscovery.due(a.ID, openAICompatDiscoverInterval(a), now) {
+			continue
+		}
+		if s.compatDiscovery.refresh(ctx, a, cfg) {
+			s.registerModelsForAuth(a)
+		}
+	}
+	s.compatDiscovery.forget(func(authID string) bool {
+		_, ok := active[authID]
+		return ok
+	})
+}
+
+func openAICompatDiscoverInterval(a *coreauth.Auth) time.Duration {
+	if seconds, err := strconv.Atoi(a.Attributes["discover_interval_seconds"]); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return defaultOpenAICompatDiscoverInterval
+}
@@ -0,0 +1,116 @@
+package cliproxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func TestRegisterModelsForAuth_DiscoversOpenAICompatModels(t *testing.T) {
+	var mu sync.Mutex
+	upstream := []string{"acme/large", "acme/small", "acme/preview-1"}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/models" || r.Header.Get("Authorization") != "Bearer sk-test" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		items := make([]string, 0, len(upstream))
+		for _, id := range upstream {
+			items = append(items, fmt.Sprintf(`{"id":%q,"object":"model"}`, id))
+		}
+		_, _ = fmt.Fprintf(w, `{"object":"list","data":[%s]}`, strings.Join(items, ","))
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		OpenAICompatibility: []config.OpenAICompatibility{{
+			Name:           "acme",
+			BaseURL:        server.URL + "/v1",
+			Models:         []config.OpenAICompatibilityModel{{Name: "acme/large", Alias: "large"}},
+			ExcludedModels: []string{"*preview*"},
+			DiscoverModels: true,
+		}},
+	}
+	auth := &coreauth.Auth{
+		ID:       "acme-discovery-auth",
+		Provider: "acme",
+		Label:    "acme",
+		Status:   coreauth.StatusActive,
+		Attributes: map[string]string{
+			"base_url":        server.URL + "/v1",
+			"api_key":         "sk-test",
+			"compat_name":     "acme",
+			"provider_key":    "acme",
+			"discover_models": "true",
+		},
+	}
+	manager := coreauth.NewManager(nil, nil, nil)
+	if _, err := manager.Register(t.Context(), auth); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	s := &Service{cfg: cfg, coreManager: manager}
+	t.Cleanup(func() { GlobalModelRegistry().UnregisterClient(auth.ID) })
+
+	s.registerModelsForAuth(auth)
+	if got, want := registeredModelIDs(auth.ID), "large"; got != want {
+		t.Fatalf("registered models before discovery = %s, want %s", got, want)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for registeredModelIDs(auth.ID) != "acme/small,large" && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got, want := registeredModelIDs(auth.ID), "acme/small,large"; got != want {
+		t.Fatalf("registered models = %s, want %s", got, want)
+	}
+
+	mu.Lock()
+	upstream = []string{"acme/large", "acme/medium"}
+	mu.Unlock()
+	s.compatDiscovery.mu.Lock()
+	s.compatDiscovery.entries[auth.ID].attempted = s.compatDiscovery.entries[auth.ID].attempted.Add(-2 * defaultOpenAICompatDiscoverInterval)
+	s.compatDiscovery.mu.Unlock()
+	s.refreshOpenAICompatDiscovery(t.Context())
+	if got, want := registeredModelIDs(auth.ID), "acme/medium,large"; got != want {
+		t.Fatalf("registered models after refresh = %s, want %s", got, want)
+	}
+}
+
+func registeredModelIDs(clientID string) string {
+	var ids []string
+	for _, model := range registry.GetGlobalRegistry().GetModelsForClient(clientID) {
+		ids = append(ids, model.ID)
+	}
+	sort.Strings(ids)
+	return strings.Join(ids, ",")
+}
+
+func TestOpenAICompatDiscovery_RetriesFailuresAfterBackoff(t *testing.T) {
+	var d openAICompatDiscovery
+	auth := &coreauth.Auth{ID: "acme-unreachable", Label: "acme", Attributes: map[string]string{"base_url": "http://127.0.0.1:1/v1"}}
+	if _, start := d.cached(auth.ID); !start {
+		t.Fatal("expected the first lookup to start a fetch")
+	}
+	if d.due(auth.ID, time.Hour, time.Now()) {
+		t.Fatal("expected no second fetch while the first is running")
+	}
+	d.refresh(t.Context(), auth, &config.Config{})
+
+	now := time.Now()
+	if d.due(auth.ID, time.Hour, now) {
+		t.Fatal("expected a failed fetch not to be retried immediately")
+	}
+	if !d.due(auth.ID, time.Hour, now.Add(openAICompatDiscoveryRetry)) {
+		t.Fatal("expected a failed fetch to be retried after the backoff, not the full interval")
+	}
+}
@@ -14,6 +14,7 @@ import (
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api"
 	kiroauth "github.com/router-for-me/CLIProxyAPI/v6/internal/auth/kiro"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/metrics"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/executor"
 	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
@@ -90,6 +91,9 @@ type Service struct {
 
 	// wsGateway manages websocket Gemini providers.
 	wsGateway *wsrelay.Manager
+
+	// compatDiscovery caches models discovered from OpenAI-compatible providers.
+	compatDiscovery openAICompatDiscovery
 }
 
 // RegisterUsagePlugin registers a usage plugin on the global usage manager.
@@ -228,6 +232,7 @@ func (s *Service) ensureWebsocketGateway() {
 		LogWarnf:       log.Warnf,
 	}
 	s.wsGateway = wsrelay.NewManager(opts)
+	metrics.SetWebsocketSessionSource(s.wsGateway.SessionCount)
 }
 
 func (s *Service) wsOnConnected(channelID string) {
@@ -563,6 +568,8 @@ func (s *Service) Run(ctx context.Context) error {
 			switch strategy {
 			case "fill-first", "fillfirst", "ff":
 				return "fill-first"
+			case "least-load", "leastload", "least-remaining-load", "ll":
+				return "least-load"
 			default:
 				return "round-robin"
 			}
@@ -574,6 +581,8 @@ func (s *Service) Run(ctx context.Context) error {
 			switch nextStrategy {
 			case "fill-first":
 				selector = &coreauth.FillFirstSelector{}
+			case "least-load":
+				selector = &coreauth.LeastLoadSelector{}
 			default:
 				selector = &coreauth.RoundRobinSelector{}
 			}
@@ -624,12 +633,14 @@ func (s *Service) Run(ctx context.Context) error {
 		return fmt.Errorf("cliproxy: failed to start watcher: %w", err)
 	}
 	log.Info("file watcher started for config and auth directory changes")
+	go s.runOpenAICompatDiscovery(watcherCtx)
 
 	// Prefer core auth manager auto refresh if available.
 	if s.coreManager != nil {
 		interval := 15 * time.Minute
 		s.coreManager.StartAutoRefresh(context.Background(), interval)
 		log.Infof("core auth auto-refresh started (interval=%s)", interval)
+		s.coreManager.StartProber(context.Background())
 	}
 
 	select {
@@ -667,6 +678,7 @@ func (s *Service) Shutdown(ctx context.Context) error {
 		}
 		if s.coreManager != nil {
 			s.coreManager.StopAutoRefresh()
+			s.coreManager.StopProber()
 		}
 		if s.watcher != nil {
 			if err := s.watcher.Stop(); err != nil {
@@ -875,25 +887,8 @@ func (s *Service) registerModelsForAuth(a *coreauth.Auth) {
 				compat := &s.cfg.OpenAICompatibility[i]
 				if strings.EqualFold(compat.Name, compatName) {
 					isCompatAuth = true
-					// Convert compatibility models to registry models
-					ms := make([]*ModelInfo, 0, len(compat.Models))
-					for j := range compat.Models {
-						m := compat.Models[j]
-						// Use alias as model ID, fallback to name if alias is empty
-						modelID := m.Alias
-						if modelID == "" {
-							modelID = m.Name
-						}
-						ms = append(ms, &ModelInfo{
-							ID:          modelID,
-							Object:      "model",
-							Created:     time.Now().Unix(),
-							OwnedBy:     compat.Name,
-							Type:        "openai-compatibility",
-							DisplayName: modelID,
-							UserDefined: true,
-						})
-					}
+					// Convert compatibility models (configured and discovered) to registry models
+					ms := s.openAICompatModelInfos(a, compat)
 					// Register and return
 					if len(ms) > 0 {
 						if providerKey == "" {
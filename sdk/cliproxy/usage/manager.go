@@ -18,6 +18,7 @@ type Record struct {
 	Source      string
 	RequestedAt time.Time
 	Failed      bool
+	Hedged      bool
 	Detail      Detail
 }
 
@@ -9,10 +9,16 @@ import internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 type SDKConfig = internalconfig.SDKConfig
 type AccessConfig = internalconfig.AccessConfig
 type AccessProvider = internalconfig.AccessProvider
+type APIKeyLimit = internalconfig.APIKeyLimit
 
 type Config = internalconfig.Config
 
 type StreamingConfig = internalconfig.StreamingConfig
+type ResponseCacheConfig = internalconfig.ResponseCacheConfig
+type ResponsesCompactConfig = internalconfig.ResponsesCompactConfig
+type ContextWindowConfig = internalconfig.ContextWindowConfig
+type StructuredOutputsConfig = internalconfig.StructuredOutputsConfig
+type GuardrailPolicy = internalconfig.GuardrailPolicy
 type TLSConfig = internalconfig.TLSConfig
 type RemoteManagement = internalconfig.RemoteManagement
 type AmpCode = internalconfig.AmpCode